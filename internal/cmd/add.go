@@ -1,11 +1,13 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/database"
 	"github.com/go-shiori/shiori/internal/model"
 	"github.com/spf13/cobra"
 )
@@ -24,6 +26,8 @@ func addCmd() *cobra.Command {
 	cmd.Flags().BoolP("offline", "o", false, "Save bookmark without fetching data from internet")
 	cmd.Flags().BoolP("no-archival", "a", false, "Save bookmark without creating offline archive")
 	cmd.Flags().Bool("log-archival", false, "Log the archival process")
+	cmd.Flags().Bool("render", false, "Render the page with JavaScript in headless browser")
+	cmd.Flags().Bool("suggest-tags", false, "Suggest tags from similar bookmarks and ask which ones to add")
 
 	return cmd
 }
@@ -37,6 +41,8 @@ func addHandler(cmd *cobra.Command, args []string) {
 	offline, _ := cmd.Flags().GetBool("offline")
 	noArchival, _ := cmd.Flags().GetBool("no-archival")
 	logArchival, _ := cmd.Flags().GetBool("log-archival")
+	render, _ := cmd.Flags().GetBool("render")
+	suggestTags, _ := cmd.Flags().GetBool("suggest-tags")
 
 	// Normalize input
 	title = validateTitle(title, "")
@@ -48,6 +54,7 @@ func addHandler(cmd *cobra.Command, args []string) {
 		Title:         title,
 		Excerpt:       excerpt,
 		CreateArchive: !noArchival,
+		RenderJS:      render,
 	}
 
 	// Set bookmark tags
@@ -65,7 +72,7 @@ func addHandler(cmd *cobra.Command, args []string) {
 	}
 
 	// Clean up bookmark URL
-	book.URL, err = core.RemoveUTMParams(book.URL)
+	book.URL, err = canonicalizer.Canonicalize(book.URL)
 	if err != nil {
 		cError.Printf("Failed to clean URL: %v\n", err)
 		os.Exit(1)
@@ -76,20 +83,26 @@ func addHandler(cmd *cobra.Command, args []string) {
 		cInfo.Println("Downloading article...")
 
 		var isFatalErr bool
-		content, contentType, err := core.DownloadBookmark(book.URL)
+		content, contentType, err := fetcher.DownloadBookmark(book.URL)
 		if err != nil {
 			cError.Printf("Failed to download: %v\n", err)
 		}
 
 		if err == nil && content != nil {
 			request := core.ProcessRequest{
-				DataDir:     dataDir,
-				Bookmark:    book,
-				Content:     content,
-				ContentType: contentType,
-				LogArchival: logArchival,
-				KeepTitle:   title != "",
-				KeepExcerpt: excerpt != "",
+				DB:            db,
+				Storage:       fileStorage,
+				Bookmark:      book,
+				Content:       content,
+				ContentType:   contentType,
+				LogArchival:   logArchival,
+				Canonicalizer: canonicalizer,
+				Fetcher:       fetcher,
+				Extractors:    extractors,
+				Browser:       headlessBrowser,
+				Quota:         storageQuota,
+				KeepTitle:     title != "",
+				KeepExcerpt:   excerpt != "",
 			}
 
 			book, isFatalErr, err = core.ProcessBookmark(request)
@@ -110,14 +123,119 @@ func addHandler(cmd *cobra.Command, args []string) {
 		book.Title = book.URL
 	}
 
+	// Apply the tagging rules
+	loadRuleSet().Apply(&book)
+
+	// Let user pick the suggested tags
+	if suggestTags {
+		promptSuggestedTags(&book)
+	}
+
+	// Make sure the (canonical) URL is not saved yet
+	if existing, exist := db.GetBookmark(0, book.URL); exist && existing.ID != book.ID {
+		cError.Printf("URL already saved as bookmark %d\n", existing.ID)
+		os.Exit(1)
+	}
+
 	// Save bookmark to database
-	_, err = db.SaveBookmarks(book)
-	if err != nil {
+	results, err := db.SaveBookmarks(book)
+	if err != nil || len(results) == 0 {
 		cError.Printf("Failed to save bookmark: %v\n", err)
 		os.Exit(1)
 	}
 
+	saveArchiveSizes(book)
+
+	// Index the content for finding related bookmarks
+	if err = core.NewRelatedIndex(db).Index(results[0]); err != nil {
+		cError.Printf("Failed to update related bookmarks index: %v\n", err)
+	}
+
+	// Create embedding for hybrid search
+	if err = core.EmbedBookmarks(db, embedder, results[0]); err != nil {
+		cError.Printf("Failed to create embedding: %v\n", err)
+	}
+
 	// Print added bookmark
 	fmt.Println()
 	printBookmarks(book)
 }
+
+// promptSuggestedTags prints the tags suggested for the bookmark, then adds
+// the ones picked by user.
+func promptSuggestedTags(book *model.Bookmark) {
+	suggestions, err := core.SuggestBookmarkTags(db, *book, core.DefaultTagSuggestions)
+	if err != nil {
+		cError.Printf("Failed to suggest tags: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(suggestions) == 0 {
+		cInfo.Println("No tags to suggest")
+		return
+	}
+
+	fmt.Println()
+	cInfo.Println("Suggested tags:")
+	for i, suggestion := range suggestions {
+		cIndex.Printf("%d. ", i+1)
+		cTag.Print(suggestion.Name)
+		if !suggestion.Exists {
+			cExcerpt.Print(" (new)")
+		}
+		fmt.Println()
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print("Tags to add, e.g. 1,3 or 1-2 (a for all, empty for none): ")
+		answer, _ := reader.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+
+		var picked []int
+		switch answer {
+		case "":
+			return
+		case "a", "all":
+			for i := range suggestions {
+				picked = append(picked, i+1)
+			}
+		default:
+			picked, err = parseStrIndices(strings.FieldsFunc(answer, func(r rune) bool {
+				return r == ',' || r == ' '
+			}))
+		}
+
+		valid := err == nil
+		for _, index := range picked {
+			if index > len(suggestions) {
+				valid = false
+			}
+		}
+
+		if !valid {
+			cError.Println("Invalid answer, use numbers of the suggested tags")
+			continue
+		}
+
+		for _, index := range picked {
+			name := suggestions[index-1].Name
+			if !hasTag(book, name) {
+				book.Tags = append(book.Tags, model.Tag{Name: name})
+			}
+		}
+
+		return
+	}
+}
+
+// hasTag checks whether the bookmark already has the tag.
+func hasTag(book *model.Bookmark, name string) bool {
+	for _, tag := range book.Tags {
+		if database.NormalizeTagName(tag.Name) == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,404 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+	"github.com/go-shiori/shiori/internal/warc"
+	"github.com/spf13/cobra"
+)
+
+func archiveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "archive [indices]",
+		Short: "Create offline archive of the bookmarks",
+		Long: "Create or recreate offline archive of the bookmarks. " +
+			"Accepts space-separated list of indices (e.g. 5 6 23 4 110 45), " +
+			"hyphenated range (e.g. 100-200) or both (e.g. 1-3 7 9). " +
+			"If no arguments, ALL bookmarks will be processed. " +
+			"Bookmarks that were broken in the last link check are skipped, " +
+			"so their existing archive is not replaced by error page.",
+		Run: archiveHandler,
+	}
+
+	cmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompt and archive ALL bookmarks")
+	cmd.Flags().Bool("missing", false, "Only process bookmarks that don't have archive yet")
+	cmd.Flags().Bool("log-archival", false, "Log the archival process")
+	cmd.Flags().Bool("render", false, "Render the pages with JavaScript in headless browser")
+
+	cmd.AddCommand(archiveExportCmd(), archiveImportCmd())
+
+	return cmd
+}
+
+func archiveExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export id",
+		Short: "Export offline archive of a bookmark",
+		Long: "Export offline archive of a bookmark as standard WARC file (ISO 28500), " +
+			"which can be opened by other web archive tools, or as a single self-contained file " +
+			"that can be opened by browser, i.e. HTML with the resources embedded or MHTML.",
+		Args: cobra.ExactArgs(1),
+		Run:  archiveExportHandler,
+	}
+
+	cmd.Flags().StringP("format", "f", "warc.gz", "Format of the exported archive, either warc.gz, warc, singlefile or mhtml")
+	cmd.Flags().StringP("output", "o", "", "Path of the exported file, use - for stdout (default \"shiori-<id>.<extension>\")")
+
+	return cmd
+}
+
+func archiveImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import file",
+		Short: "Import offline archive from WARC file",
+		Long: "Import offline archive from WARC file, which may be compressed with gzip. " +
+			"The captured pages are attached to the bookmarks with the same URL, replacing their archive. " +
+			"If none of them is bookmarked yet, new bookmark is created for the first page.",
+		Args: cobra.ExactArgs(1),
+		Run:  archiveImportHandler,
+	}
+
+	cmd.Flags().Int("id", 0, "Attach the archive to this bookmark instead of matching its URL")
+	cmd.Flags().Bool("log-archival", false, "Log the archival process")
+
+	return cmd
+}
+
+func archiveHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	skipConfirm, _ := cmd.Flags().GetBool("yes")
+	onlyMissing, _ := cmd.Flags().GetBool("missing")
+	logArchival, _ := cmd.Flags().GetBool("log-archival")
+	render, _ := cmd.Flags().GetBool("render")
+
+	// If no arguments (i.e all bookmarks going to be archived), confirm to user
+	if len(args) == 0 && !skipConfirm && !onlyMissing {
+		confirmArchive := ""
+		fmt.Print("Recreate archive of ALL bookmarks? (y/N): ")
+		fmt.Scanln(&confirmArchive)
+
+		if confirmArchive != "y" {
+			fmt.Println("No bookmarks archived")
+			return
+		}
+	}
+
+	// Convert args to ids
+	ids, err := parseStrIndices(args)
+	if err != nil {
+		cError.Printf("Failed to parse args: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Fetch bookmarks from database
+	bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{IDs: ids})
+	if err != nil {
+		cError.Printf("Failed to get bookmarks: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Skip the broken bookmarks, and the archived ones if needed
+	brokenBookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{IDs: ids, Broken: true})
+	if err != nil {
+		cError.Printf("Failed to get broken bookmarks: %v\n", err)
+		os.Exit(1)
+	}
+
+	mapBroken := map[int]struct{}{}
+	for _, book := range brokenBookmarks {
+		mapBroken[book.ID] = struct{}{}
+	}
+
+	var nSkipped int
+	var reachableBookmarks []model.Bookmark
+	for _, book := range bookmarks {
+		if onlyMissing && storage.Exists(fileStorage, core.ArchiveName(book.ID)) {
+			continue
+		}
+
+		if _, broken := mapBroken[book.ID]; broken {
+			nSkipped++
+			continue
+		}
+
+		reachableBookmarks = append(reachableBookmarks, book)
+	}
+	bookmarks = reachableBookmarks
+
+	if nSkipped > 0 {
+		cError.Printf("Skipped %d broken bookmark(s), search them with `is:broken`\n", nSkipped)
+	}
+
+	if len(bookmarks) == 0 {
+		fmt.Println("No bookmarks to archive")
+		return
+	}
+
+	// Create the archives
+	mx := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, 10)
+	logIndex, nFailed := 0, 0
+
+	for _, book := range bookmarks {
+		wg.Add(1)
+
+		go func(book model.Bookmark) {
+			// Make sure to finish the WG
+			defer wg.Done()
+
+			// Register goroutine to semaphore
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+			}()
+
+			book.RenderJS = render
+			err := core.CreateArchive(core.ArchiveRequest{
+				DB:          db,
+				Storage:     fileStorage,
+				Bookmark:    book,
+				LogArchival: logArchival,
+				Fetcher:     fetcher,
+				Browser:     headlessBrowser,
+				Quota:       storageQuota,
+			})
+
+			mx.Lock()
+			defer mx.Unlock()
+
+			logIndex++
+			if err != nil {
+				nFailed++
+				cError.Printf("[%d/%d] Failed to archive %s: %v\n", logIndex, len(bookmarks), book.URL, err)
+				return
+			}
+
+			cInfo.Printf("[%d/%d] Archived %s\n", logIndex, len(bookmarks), book.URL)
+		}(book)
+	}
+
+	wg.Wait()
+	saveArchiveSizes(bookmarks...)
+
+	fmt.Printf("Archive created for %d bookmark(s)\n", len(bookmarks)-nFailed)
+}
+
+func archiveExportHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	formatName, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		cError.Printf("Invalid bookmark ID: %s\n", args[0])
+		os.Exit(1)
+	}
+
+	format, valid := core.GetArchiveFormat(formatName)
+	if !valid {
+		cError.Printf("Unknown archive format: %s\n", formatName)
+		os.Exit(1)
+	}
+
+	book, exist := db.GetBookmark(id, "")
+	if !exist {
+		cError.Printf("Bookmark %d doesn't exist\n", id)
+		os.Exit(1)
+	}
+
+	// Open the destination
+	var dst io.Writer = os.Stdout
+	if output == "" {
+		output = core.ArchiveFilename(book, format)
+	}
+
+	if output != "-" {
+		dstFile, err := os.Create(output)
+		if err != nil {
+			cError.Printf("Failed to create destination file: %v\n", err)
+			os.Exit(1)
+		}
+		defer dstFile.Close()
+		dst = dstFile
+	}
+
+	// Write the archive
+	err = core.ExportArchive(fileStorage, book, format, dst)
+	if err != nil {
+		cError.Printf("Failed to export archive: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output != "-" {
+		fmt.Printf("Archive of bookmark %d exported to %s\n", id, output)
+	}
+}
+
+func archiveImportHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	id, _ := cmd.Flags().GetInt("id")
+	logArchival, _ := cmd.Flags().GetBool("log-archival")
+
+	// Read the captured pages
+	srcFile, err := os.Open(args[0])
+	if err != nil {
+		cError.Printf("Failed to open %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	defer srcFile.Close()
+
+	captures, err := warc.ReadCaptures(srcFile, fetcher.MaxBodySize())
+	if err != nil {
+		cError.Printf("Failed to read %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	var pages []warc.Capture
+	for _, capture := range captures {
+		if strings.Contains(capture.ContentType, "text/html") {
+			pages = append(pages, capture)
+		}
+	}
+
+	if len(pages) == 0 {
+		cError.Println("No HTML page found in WARC file")
+		os.Exit(1)
+	}
+
+	// Find the bookmark for each page
+	type importItem struct {
+		book  model.Bookmark
+		page  warc.Capture
+		isNew bool
+	}
+
+	var items []importItem
+	switch {
+	case id > 0:
+		book, exist := db.GetBookmark(id, "")
+		if !exist {
+			cError.Printf("Bookmark %d doesn't exist\n", id)
+			os.Exit(1)
+		}
+
+		page := pages[0]
+		for _, p := range pages {
+			if strings.TrimRight(p.URL, "/") == strings.TrimRight(book.URL, "/") {
+				page = p
+				break
+			}
+		}
+
+		items = append(items, importItem{book: book, page: page})
+	default:
+		for _, page := range pages {
+			pageURL, err := canonicalizer.Canonicalize(page.URL)
+			if err != nil {
+				continue
+			}
+
+			if book, exist := db.GetBookmark(0, pageURL); exist {
+				items = append(items, importItem{book: book, page: page})
+			}
+		}
+
+		if len(items) == 0 {
+			book, err := newBookmarkFromCapture(pages[0])
+			if err != nil {
+				cError.Printf("Failed to create bookmark: %v\n", err)
+				os.Exit(1)
+			}
+
+			items = append(items, importItem{book: book, page: pages[0], isNew: true})
+		}
+	}
+
+	// Create the archives
+	nFailed := 0
+	for i, item := range items {
+		book := item.book
+		err := core.ImportWARC(core.ArchiveRequest{
+			DB:          db,
+			Storage:     fileStorage,
+			Bookmark:    book,
+			LogArchival: logArchival,
+			Quota:       storageQuota,
+		}, item.page, captures)
+		if err != nil {
+			nFailed++
+			cError.Printf("[%d/%d] Failed to import %s: %v\n", i+1, len(items), item.page.URL, err)
+			continue
+		}
+
+		// If bookmark doesn't have thumbnail, look for it in the archive
+		if !storage.Exists(fileStorage, core.ThumbnailName(book.ID, core.ThumbnailGrid)) {
+			if img, err := core.ThumbnailFromArchive(fileStorage, book.ID); err == nil {
+				core.SaveThumbnails(img, fileStorage, book.ID)
+			}
+		}
+
+		if item.isNew {
+			if _, err := db.SaveBookmarks(book); err != nil {
+				nFailed++
+				cError.Printf("[%d/%d] Failed to save bookmark for %s: %v\n", i+1, len(items), item.page.URL, err)
+				continue
+			}
+		}
+
+		saveArchiveSizes(book)
+		cInfo.Printf("[%d/%d] Imported %s as archive of bookmark %d\n", i+1, len(items), item.page.URL, book.ID)
+	}
+
+	fmt.Printf("Archive imported for %d bookmark(s)\n", len(items)-nFailed)
+	if nFailed > 0 {
+		os.Exit(1)
+	}
+}
+
+// newBookmarkFromCapture prepares new bookmark for the captured page. The bookmark
+// is not saved yet, since its archive might fail to be created.
+func newBookmarkFromCapture(page warc.Capture) (model.Bookmark, error) {
+	pageURL, err := canonicalizer.Canonicalize(page.URL)
+	if err != nil {
+		return model.Bookmark{}, fmt.Errorf("failed to clean URL: %v", err)
+	}
+
+	book := model.Bookmark{URL: pageURL}
+	book.ID, err = db.CreateNewID("bookmark")
+	if err != nil {
+		return model.Bookmark{}, fmt.Errorf("failed to create ID: %v", err)
+	}
+
+	// Like adding bookmark, the page's canonical link is used as URL if no other
+	// bookmark has it yet
+	book, _, err = core.ProcessBookmark(core.ProcessRequest{
+		DB:            db,
+		Storage:       fileStorage,
+		Bookmark:      book,
+		Content:       bytes.NewReader(page.Content),
+		ContentType:   page.ContentType,
+		Canonicalizer: canonicalizer,
+		Fetcher:       fetcher,
+		Extractors:    extractors,
+	})
+	if err != nil {
+		return model.Bookmark{}, err
+	}
+
+	if book.Title == "" {
+		book.Title = book.URL
+	}
+
+	return book, nil
+}
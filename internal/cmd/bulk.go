@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/spf13/cobra"
+)
+
+func bulkCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bulk [indices]",
+		Short: "Apply actions to many bookmarks at once",
+		Long: "Tag, untag, make public or private, refetch or delete many bookmarks at once. " +
+			"Bookmarks are selected by indices, which accepts space-separated list of indices (e.g. 5 6 23 4 110 45), " +
+			"hyphenated range (e.g. 100-200) or both (e.g. 1-3 7 9), or by search filter if no indices given. " +
+			"If there are no indices and filter, ALL bookmarks are selected. " +
+			"Bookmarks are saved in batches, each of them in a single transaction.",
+		Run: bulkHandler,
+	}
+
+	cmd.Flags().StringP("search", "s", "", "Select bookmarks with specified keyword")
+	cmd.Flags().StringSliceP("tags", "t", []string{}, "Select bookmarks with matching tag(s)")
+	cmd.Flags().StringSliceP("exclude-tags", "e", []string{}, "Select bookmarks without these tag(s)")
+	cmd.Flags().BoolP("broken", "b", false, "Select bookmarks that were broken in the last check")
+	cmd.Flags().StringSlice("add-tags", []string{}, "Add these tag(s) to the bookmarks")
+	cmd.Flags().StringSlice("remove-tags", []string{}, "Remove these tag(s) from the bookmarks")
+	cmd.Flags().Bool("public", false, "Make the bookmarks public")
+	cmd.Flags().Bool("private", false, "Make the bookmarks private")
+	cmd.Flags().Bool("refetch", false, "Download the bookmarks again")
+	cmd.Flags().Bool("keep-metadata", false, "Keep existing metadata while refetching")
+	cmd.Flags().BoolP("no-archival", "a", false, "Refetch without updating offline archive")
+	cmd.Flags().Bool("delete", false, "Delete the bookmarks")
+	cmd.Flags().Int("batch-size", core.DefaultBulkBatchSize, "Number of bookmarks saved in a single transaction")
+	cmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+func bulkHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	keyword, _ := cmd.Flags().GetString("search")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	excludedTags, _ := cmd.Flags().GetStringSlice("exclude-tags")
+	onlyBroken, _ := cmd.Flags().GetBool("broken")
+	addTags, _ := cmd.Flags().GetStringSlice("add-tags")
+	removeTags, _ := cmd.Flags().GetStringSlice("remove-tags")
+	makePublic, _ := cmd.Flags().GetBool("public")
+	makePrivate, _ := cmd.Flags().GetBool("private")
+	refetch, _ := cmd.Flags().GetBool("refetch")
+	keepMetadata, _ := cmd.Flags().GetBool("keep-metadata")
+	noArchival, _ := cmd.Flags().GetBool("no-archival")
+	deleteBookmarks, _ := cmd.Flags().GetBool("delete")
+	batchSize, _ := cmd.Flags().GetInt("batch-size")
+	skipConfirm, _ := cmd.Flags().GetBool("yes")
+
+	if makePublic && makePrivate {
+		cError.Println("Bookmarks can't be made public and private at once")
+		os.Exit(1)
+	}
+
+	// Convert args to ids
+	ids, err := parseStrIndices(args)
+	if err != nil {
+		cError.Printf("Failed to parse args: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Prepare actions, refetch goes first so the others are applied to the new content
+	actions := []core.BulkAction{}
+	if refetch {
+		actions = append(actions, core.BulkAction{
+			Type:          core.BulkRefetch,
+			KeepMetadata:  keepMetadata,
+			CreateArchive: !noArchival,
+		})
+	}
+
+	if len(addTags) > 0 {
+		actions = append(actions, core.BulkAction{Type: core.BulkTag, Tags: addTags})
+	}
+
+	if len(removeTags) > 0 {
+		actions = append(actions, core.BulkAction{Type: core.BulkUntag, Tags: removeTags})
+	}
+
+	if makePublic {
+		actions = append(actions, core.BulkAction{Type: core.BulkPublic})
+	}
+
+	if makePrivate {
+		actions = append(actions, core.BulkAction{Type: core.BulkPrivate})
+	}
+
+	if deleteBookmarks {
+		actions = append(actions, core.BulkAction{Type: core.BulkDelete})
+	}
+
+	if err = core.ValidateBulkActions(actions); err != nil {
+		cError.Printf("Invalid actions: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Select the bookmarks
+	filter := database.GetBookmarksOptions{
+		Tags:         tags,
+		ExcludedTags: excludedTags,
+		Keyword:      keyword,
+		Broken:       onlyBroken,
+	}
+	filter.ParseKeywordFilters()
+
+	request := core.BulkRequest{
+		DB:            db,
+		Storage:       fileStorage,
+		IDs:           ids,
+		Filter:        filter,
+		Actions:       actions,
+		BatchSize:     batchSize,
+		Canonicalizer: canonicalizer,
+		Fetcher:       fetcher,
+		Extractors:    extractors,
+		Browser:       headlessBrowser,
+		Quota:         storageQuota,
+	}
+
+	request.IDs, err = core.SelectBulkIDs(request)
+	if err != nil {
+		cError.Printf("Failed to select bookmarks: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(request.IDs) == 0 {
+		cError.Println("No matching bookmarks found")
+		os.Exit(1)
+	}
+
+	// Deleting and changing ALL bookmarks need confirmation
+	noSelection := len(ids) == 0 && keyword == "" && len(tags) == 0 && len(excludedTags) == 0 && !filter.Broken
+	if (deleteBookmarks || noSelection) && !skipConfirm {
+		confirm := ""
+		fmt.Printf("Apply actions to %d bookmark(s)? (y/N): ", len(request.IDs))
+		fmt.Scanln(&confirm)
+
+		if confirm != "y" {
+			fmt.Println("No bookmarks changed")
+			return
+		}
+	}
+
+	// Run the actions, reporting each bookmark once its batch is saved
+	nFailed := 0
+	nResults := 0
+	request.OnResult = func(result core.BulkResult) {
+		nResults++
+		if result.Status == core.BulkFailed {
+			nFailed++
+			cError.Printf("[%d/%d] Bookmark %d failed: %s\n", nResults, len(request.IDs), result.ID, result.Error)
+			return
+		}
+
+		cInfo.Printf("[%d/%d] Bookmark %d %s\n", nResults, len(request.IDs), result.ID, result.Status)
+	}
+
+	if _, err = core.RunBulk(request); err != nil {
+		cError.Printf("Failed to apply actions: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Applied actions to %d bookmark(s), %d failed\n", nResults-nFailed, nFailed)
+	if nFailed > 0 {
+		os.Exit(1)
+	}
+}
@@ -2,28 +2,33 @@ package cmd
 
 import (
 	"fmt"
-	"net/http"
 	"os"
 	"sort"
-	"sync"
 	"time"
 
+	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/database"
 	"github.com/go-shiori/shiori/internal/model"
 	"github.com/spf13/cobra"
 )
 
+// linkCheckHistory is the number of link check results kept for each bookmark.
+const linkCheckHistory = 10
+
 func checkCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "check",
+		Use:   "check [indices]",
 		Short: "Find bookmarked sites that no longer exists on the internet",
 		Long: "Check all bookmarks and find bookmarked sites that no longer exists on the internet. " +
 			"It might take a long time depending on how many bookmarks that you have and want to check. " +
-			"If there are no arguments, it will check ALL of your bookmarks.",
+			"If there are no arguments, it will check ALL of your bookmarks. " +
+			"The result of each check is saved, so broken bookmarks can be searched with `is:broken`.",
 		Run: checkHandler,
 	}
 
 	cmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompt and check ALL bookmarks")
+	cmd.Flags().BoolP("broken", "b", false, "Only check bookmarks that were broken in the last check")
+	cmd.Flags().Duration("stale", 0, "Only check bookmarks that haven't been checked within this duration, e.g. 24h")
 
 	return cmd
 }
@@ -31,9 +36,11 @@ func checkCmd() *cobra.Command {
 func checkHandler(cmd *cobra.Command, args []string) {
 	// Parse flags
 	skipConfirm, _ := cmd.Flags().GetBool("yes")
+	onlyBroken, _ := cmd.Flags().GetBool("broken")
+	staleAge, _ := cmd.Flags().GetDuration("stale")
 
 	// If no arguments (i.e all bookmarks going to be checked), confirm to user
-	if len(args) == 0 && !skipConfirm {
+	if len(args) == 0 && !skipConfirm && !onlyBroken && staleAge == 0 {
 		confirmCheck := ""
 		fmt.Print("Check ALL bookmarks? (y/N): ")
 		fmt.Scanln(&confirmCheck)
@@ -52,93 +59,90 @@ func checkHandler(cmd *cobra.Command, args []string) {
 	}
 
 	// Fetch bookmarks from database
-	filterOptions := database.GetBookmarksOptions{IDs: ids}
+	filterOptions := database.GetBookmarksOptions{IDs: ids, Broken: onlyBroken}
 	bookmarks, err := db.GetBookmarks(filterOptions)
 	if err != nil {
 		cError.Printf("Failed to get bookmarks: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Create HTTP client
-	httpClient := &http.Client{Timeout: time.Minute}
-
-	// Test each bookmark item
-	unreachableIDs := []int{}
-
-	wg := sync.WaitGroup{}
-	chDone := make(chan struct{})
-	chProblem := make(chan int, 10)
-	chMessage := make(chan interface{}, 10)
-	semaphore := make(chan struct{}, 10)
-
-	for i, book := range bookmarks {
-		wg.Add(1)
-
-		go func(i int, book model.Bookmark) {
-			// Make sure to finish the WG
-			defer wg.Done()
-
-			// Register goroutine to semaphore
-			semaphore <- struct{}{}
-			defer func() {
-				<-semaphore
-			}()
-
-			// Ping bookmark's URL
-			_, err := httpClient.Get(book.URL)
-			if err != nil {
-				chProblem <- book.ID
-				chMessage <- fmt.Errorf("Failed to reach %s: %v", book.URL, err)
-				return
-			}
-
-			// Send success message
-			chMessage <- fmt.Sprintf("Reached %s", book.URL)
-		}(i, book)
+	if staleAge > 0 {
+		bookmarks, err = staleLinkBookmarks(bookmarks, staleAge)
+		if err != nil {
+			cError.Printf("Failed to get previous checks: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// Watch messages from channels
-	go func(nBookmark int) {
-		logIndex := 0
-
-		for {
-			select {
-			case <-chDone:
-				cInfo.Println("Check finished")
-				return
-			case id := <-chProblem:
-				unreachableIDs = append(unreachableIDs, id)
-			case msg := <-chMessage:
-				logIndex++
-
-				switch msg.(type) {
-				case error:
-					cError.Printf("[%d/%d] %v\n", logIndex, nBookmark, msg)
-				case string:
-					cInfo.Printf("[%d/%d] %s\n", logIndex, nBookmark, msg)
-				}
-			}
+	// Check each bookmark and save the result
+	logIndex := 0
+	brokenIDs := []int{}
+
+	core.CheckLinks(fetcher, bookmarks, 10, func(book model.Bookmark, result model.LinkCheck) {
+		logIndex++
+
+		if err := db.SaveLinkChecks(result); err != nil {
+			cError.Printf("[%d/%d] Failed to save result for %s: %v\n", logIndex, len(bookmarks), book.URL, err)
 		}
-	}(len(bookmarks))
 
-	// Wait until all download finished
-	wg.Wait()
-	close(chDone)
+		if result.Broken {
+			brokenIDs = append(brokenIDs, book.ID)
+			cError.Printf("[%d/%d] Broken %s: %s\n", logIndex, len(bookmarks), book.URL, result.Message)
+			return
+		}
+
+		msg := fmt.Sprintf("Reached %s (%d)", book.URL, result.StatusCode)
+		if result.FinalURL != "" {
+			msg += " redirected to " + result.FinalURL
+		}
+		cInfo.Printf("[%d/%d] %s\n", logIndex, len(bookmarks), msg)
+	})
+
+	cInfo.Println("Check finished")
+
+	if err := db.PruneLinkChecks(linkCheckHistory); err != nil {
+		cError.Printf("Failed to remove old check results: %v\n", err)
+	}
 
-	// Print the unreachable bookmarks
+	// Print the broken bookmarks
 	fmt.Println()
 
 	var code int
-	if len(unreachableIDs) == 0 {
+	if len(brokenIDs) == 0 {
 		cInfo.Println("All bookmarks are reachable.")
 	} else {
-		sort.Ints(unreachableIDs)
+		sort.Ints(brokenIDs)
 		code = 1
-		cError.Println("Encountered some unreachable bookmarks:")
-		for _, id := range unreachableIDs {
+		cError.Println("Encountered some broken bookmarks:")
+		for _, id := range brokenIDs {
 			cError.Printf("%d ", id)
 		}
 		fmt.Println()
 	}
 	os.Exit(code)
 }
+
+// staleLinkBookmarks returns the bookmarks whose URL is never checked,
+// or not checked within the specified duration.
+func staleLinkBookmarks(bookmarks []model.Bookmark, maxAge time.Duration) ([]model.Bookmark, error) {
+	checks, err := db.GetLinkChecks(database.GetLinkChecksOptions{LatestOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	mapChecked := map[int]time.Time{}
+	for _, check := range checks {
+		if checked, err := parseDBTime(check.Checked); err == nil {
+			mapChecked[check.BookmarkID] = checked
+		}
+	}
+
+	staleBookmarks := []model.Bookmark{}
+	for _, book := range bookmarks {
+		if checked, exist := mapChecked[book.ID]; !exist || time.Since(checked) >= maxAge {
+			staleBookmarks = append(staleBookmarks, book)
+		}
+	}
+
+	return staleBookmarks, nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/spf13/cobra"
+)
+
+func dedupeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Find and merge duplicate bookmarks",
+		Long: "Find bookmarks that point to the same page after their URL canonicalized, " +
+			"e.g. same article saved with http:// and https://, with www. prefix or with tracking parameters. " +
+			"Duplicates are merged into the bookmark that has readable content (or the oldest one), " +
+			"keeping the tags of all bookmarks.",
+		Run: dedupeHandler,
+	}
+
+	cmd.Flags().BoolP("dry-run", "n", false, "Only print the duplicates without merging them")
+	cmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompt and merge ALL duplicates")
+
+	return cmd
+}
+
+func dedupeHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	skipConfirm, _ := cmd.Flags().GetBool("yes")
+
+	// Fetch bookmarks from database
+	bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{WithContent: true})
+	if err != nil {
+		cError.Printf("Failed to get bookmarks: %v\n", err)
+		os.Exit(1)
+	}
+
+	groups := canonicalizer.FindDuplicates(bookmarks)
+	if len(groups) == 0 {
+		cInfo.Println("No duplicate bookmarks found")
+		return
+	}
+
+	// Print the duplicates
+	for _, group := range groups {
+		cTitle.Println(group.Key)
+		for i, book := range group.Bookmarks {
+			symbol := "- "
+			if i == 0 {
+				symbol = "* "
+			}
+
+			cSymbol.Print("  " + symbol)
+			cIndex.Printf("%d. ", book.ID)
+			cURL.Println(book.URL)
+		}
+	}
+
+	fmt.Println()
+	if dryRun {
+		return
+	}
+
+	// Confirm to user
+	if !skipConfirm {
+		confirmMerge := ""
+		fmt.Printf("Merge %d group(s) of duplicate bookmarks? (y/N): ", len(groups))
+		fmt.Scanln(&confirmMerge)
+
+		if confirmMerge != "y" {
+			fmt.Println("No bookmarks merged")
+			return
+		}
+	}
+
+	// Merge each group
+	for _, group := range groups {
+		book, removedIDs := core.MergeDuplicates(group)
+
+		_, err = db.SaveBookmarks(book)
+		if err != nil {
+			cError.Printf("Failed to save bookmark %d: %v\n", book.ID, err)
+			continue
+		}
+
+		err = db.DeleteBookmarks(removedIDs...)
+		if err != nil {
+			cError.Printf("Failed to delete duplicates of %d: %v\n", book.ID, err)
+			continue
+		}
+
+		core.MergeDuplicateFiles(fileStorage, book.ID, removedIDs)
+		cInfo.Printf("Merged %v into %d\n", removedIDs, book.ID)
+	}
+}
@@ -3,10 +3,10 @@ package cmd
 import (
 	"fmt"
 	"os"
-	fp "path/filepath"
-	"strconv"
 	"strings"
 
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -58,20 +58,16 @@ func deleteHandler(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	// Delete thumbnail image and archives from local disk
+	// Delete thumbnail image and archives from storage
 	if len(ids) == 0 {
-		thumbDir := fp.Join(dataDir, "thumb")
-		archiveDir := fp.Join(dataDir, "archive")
-		os.RemoveAll(thumbDir)
-		os.RemoveAll(archiveDir)
+		for _, prefix := range []string{"thumb/", "archive/"} {
+			fileStorage.Walk(prefix, func(info storage.FileInfo) error {
+				return fileStorage.Remove(info.Name)
+			})
+		}
 	} else {
 		for _, id := range ids {
-			strID := strconv.Itoa(id)
-			imgPath := fp.Join(dataDir, "thumb", strID)
-			archivePath := fp.Join(dataDir, "archive", strID)
-
-			os.Remove(imgPath)
-			os.Remove(archivePath)
+			core.RemoveBookmarkFiles(fileStorage, id)
 		}
 	}
 
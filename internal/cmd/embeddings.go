@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/spf13/cobra"
+)
+
+func embeddingsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "embeddings",
+		Short: "Manage the embeddings used for hybrid search",
+	}
+
+	cmd.AddCommand(embeddingsUpdateCmd())
+
+	return cmd
+}
+
+func embeddingsUpdateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Create embeddings for bookmarks that don't have one yet",
+		Long: "Create embeddings for the bookmarks that don't have one from the current " +
+			"provider yet, or that have been modified since. This is done automatically " +
+			"before hybrid search, but it might take a while with slow provider, " +
+			"e.g. after switching to another one.",
+		Args: cobra.NoArgs,
+		Run:  embeddingsUpdateHandler,
+	}
+
+	cmd.Flags().BoolP("all", "a", false, "Create embeddings of all bookmarks again")
+
+	return cmd
+}
+
+func embeddingsUpdateHandler(cmd *cobra.Command, args []string) {
+	all, _ := cmd.Flags().GetBool("all")
+
+	cInfo.Printf("Creating embeddings using %s...\n", embedder.Name())
+
+	nEmbedded, err := core.SyncEmbeddings(db, embedder, all)
+	if err != nil {
+		cError.Printf("Failed to create embeddings: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Embedded %d bookmark(s)\n", nEmbedded)
+}
@@ -7,31 +7,73 @@ import (
 	"strings"
 	"time"
 
+	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/database"
 	"github.com/spf13/cobra"
 )
 
 func exportCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "export target-file",
-		Short: "Export bookmarks into HTML file in Netscape Bookmark format",
-		Args:  cobra.ExactArgs(1),
-		Run:   exportHandler,
+		Use:   "export target-file [indices]",
+		Short: "Export bookmarks into HTML file in Netscape Bookmark format, or into EPUB book",
+		Long: "Export bookmarks into HTML file in Netscape Bookmark format, or into EPUB book for e-readers. " +
+			"The book has one chapter for each bookmark, taken from its readable content along with " +
+			"the images from its offline archive. " +
+			"Accepts space-separated list of indices (e.g. 5 6 23 4 110 45), " +
+			"hyphenated range (e.g. 100-200) or both (e.g. 1-3 7 9) after the target file. " +
+			"If there are no indices and filters, ALL bookmarks will be exported.",
+		Args: cobra.MinimumNArgs(1),
+		Run:  exportHandler,
 	}
 
+	cmd.Flags().StringP("format", "f", "netscape", "Format of the exported file, either netscape or epub")
+	cmd.Flags().StringP("search", "s", "", "Export bookmarks with specified keyword")
+	cmd.Flags().StringSliceP("tags", "t", []string{}, "Export bookmarks with matching tag(s)")
+	cmd.Flags().String("title", "", "Title of the EPUB book")
+
 	return cmd
 }
 
 func exportHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	format, _ := cmd.Flags().GetString("format")
+	keyword, _ := cmd.Flags().GetString("search")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	title, _ := cmd.Flags().GetString("title")
+
+	if format != "netscape" && format != "epub" {
+		cError.Printf("Unknown export format: %s\n", format)
+		os.Exit(1)
+	}
+
+	// Convert args to ids
+	ids, err := parseStrIndices(args[1:])
+	if err != nil {
+		cError.Printf("Failed to parse args: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Fetch bookmarks from database
-	bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{})
+	searchOptions := database.GetBookmarksOptions{
+		IDs:         ids,
+		Tags:        tags,
+		Keyword:     keyword,
+		WithContent: format == "epub",
+	}
+	searchOptions.ParseKeywordFilters()
+
+	bookmarks, err := db.GetBookmarks(searchOptions)
 	if err != nil {
 		cError.Printf("Failed to get bookmarks: %v\n", err)
 		os.Exit(1)
 	}
 
 	if len(bookmarks) == 0 {
-		cError.Println("No saved bookmarks yet")
+		if len(ids) > 0 || keyword != "" || len(tags) > 0 {
+			cError.Println("No matching bookmarks found")
+		} else {
+			cError.Println("No saved bookmarks yet")
+		}
 		return
 	}
 
@@ -49,6 +91,25 @@ func exportHandler(cmd *cobra.Command, args []string) {
 	}
 	defer dstFile.Close()
 
+	if format == "epub" {
+		if title == "" {
+			title = core.EPUBTitle(keyword, tags)
+		}
+
+		err = core.WriteEPUB(dstFile, core.EPUBRequest{
+			Storage:   fileStorage,
+			Title:     title,
+			Bookmarks: bookmarks,
+		})
+		if err != nil {
+			cError.Printf("Failed to export the bookmarks: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Exported %d bookmark(s) to %s\n", len(bookmarks), args[0])
+		return
+	}
+
 	// Write exported bookmark to file
 	fmt.Fprintln(dstFile, ``+
 		`<!DOCTYPE NETSCAPE-Bookmark-file-1>`+
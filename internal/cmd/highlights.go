@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	fp "path/filepath"
+	"strings"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/spf13/cobra"
+)
+
+func highlightsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "highlights",
+		Short: "Manage the highlights and notes of bookmarks",
+	}
+
+	cmd.AddCommand(highlightsExportCmd())
+
+	return cmd
+}
+
+func highlightsExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [indices]",
+		Short: "Export highlights and notes as Markdown",
+		Long: "Export highlights and notes of the bookmarks as Markdown, with a section for each bookmark. " +
+			"Accepts space-separated list of indices (e.g. 5 6 23 4 110 45), " +
+			"hyphenated range (e.g. 100-200) or both (e.g. 1-3 7 9). " +
+			"If there are no indices and tags, highlights of ALL bookmarks will be exported.",
+		Run: highlightsExportHandler,
+	}
+
+	cmd.Flags().StringSliceP("tags", "t", []string{}, "Export highlights of bookmarks with matching tag(s)")
+	cmd.Flags().StringP("output", "o", "", "Write into this file instead of standard output")
+	cmd.Flags().String("title", "", "Title of the document")
+
+	return cmd
+}
+
+func highlightsExportHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	output, _ := cmd.Flags().GetString("output")
+	title, _ := cmd.Flags().GetString("title")
+
+	// Convert args to ids
+	ids, err := parseStrIndices(args)
+	if err != nil {
+		cError.Printf("Failed to parse args: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Fetch bookmarks and their highlights
+	bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{
+		IDs:         ids,
+		Tags:        tags,
+		OrderMethod: database.DefaultOrder,
+	})
+	if err != nil {
+		cError.Printf("Failed to get bookmarks: %v\n", err)
+		os.Exit(1)
+	}
+
+	bookIDs := make([]int, len(bookmarks))
+	for i, book := range bookmarks {
+		bookIDs[i] = book.ID
+	}
+
+	highlights := []model.Highlight{}
+	if len(bookIDs) > 0 {
+		highlights, err = db.GetHighlights(database.GetHighlightsOptions{BookmarkIDs: bookIDs})
+		if err != nil {
+			cError.Printf("Failed to get highlights: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(highlights) == 0 {
+		cError.Println("No highlights found")
+		return
+	}
+
+	if title == "" {
+		title = "Highlights"
+		if len(tags) > 0 {
+			title += ": " + strings.Join(tags, ", ")
+		}
+	}
+
+	// Write into file or standard output
+	var dst io.Writer = os.Stdout
+	if output != "" {
+		if err := os.MkdirAll(fp.Dir(output), os.ModePerm); err != nil {
+			cError.Printf("Failed to create destination directory: %v\n", err)
+			os.Exit(1)
+		}
+
+		dstFile, err := os.Create(output)
+		if err != nil {
+			cError.Printf("Failed to create destination file: %v\n", err)
+			os.Exit(1)
+		}
+		defer dstFile.Close()
+		dst = dstFile
+	}
+
+	if err = core.WriteHighlightsMarkdown(dst, title, bookmarks, highlights); err != nil {
+		cError.Printf("Failed to export highlights: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output != "" {
+		fmt.Printf("Exported %d highlight(s) to %s\n", len(highlights), output)
+	}
+}
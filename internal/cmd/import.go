@@ -6,7 +6,6 @@ import (
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/model"
 	"github.com/spf13/cobra"
 )
@@ -75,7 +74,7 @@ func importHandler(cmd *cobra.Command, args []string) {
 
 		// Clean up URL
 		var err error
-		url, err = core.RemoveUTMParams(url)
+		url, err = canonicalizer.Canonicalize(url)
 		if err != nil {
 			cError.Printf("Skip %s: URL is not valid\n", url)
 			return
@@ -126,6 +125,12 @@ func importHandler(cmd *cobra.Command, args []string) {
 		bookmarks = append(bookmarks, bookmark)
 	})
 
+	// Apply the tagging rules
+	rules := loadRuleSet()
+	for i := range bookmarks {
+		rules.Apply(&bookmarks[i])
+	}
+
 	// Save bookmark to database
 	bookmarks, err = db.SaveBookmarks(bookmarks...)
 	if err != nil {
@@ -6,12 +6,11 @@ import (
 	"net/http"
 	"os"
 	fp "path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/database"
-	"github.com/go-shiori/warc"
 	"github.com/julienschmidt/httprouter"
 	"github.com/spf13/cobra"
 )
@@ -129,10 +128,7 @@ func openHandler(cmd *cobra.Command, args []string) {
 	}
 
 	// Open archive
-	id := strconv.Itoa(bookmarks[0].ID)
-	archivePath := fp.Join(dataDir, "archive", id)
-
-	archive, err := warc.Open(archivePath)
+	archive, err := core.OpenArchive(fileStorage, bookmarks[0].ID)
 	if err != nil {
 		cError.Printf("Failed to open archive: %v\n", err)
 		os.Exit(1)
@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/model"
 	"github.com/spf13/cobra"
 )
@@ -61,7 +60,7 @@ func pocketHandler(cmd *cobra.Command, args []string) {
 
 		// Clean up URL
 		var err error
-		url, err = core.RemoveUTMParams(url)
+		url, err = canonicalizer.Canonicalize(url)
 		if err != nil {
 			cError.Printf("Skip %s: URL is not valid\n", url)
 			return
@@ -105,6 +104,12 @@ func pocketHandler(cmd *cobra.Command, args []string) {
 		bookmarks = append(bookmarks, bookmark)
 	})
 
+	// Apply the tagging rules
+	rules := loadRuleSet()
+	for i := range bookmarks {
+		rules.Apply(&bookmarks[i])
+	}
+
 	// Save bookmark to database
 	bookmarks, err = db.SaveBookmarks(bookmarks...)
 	if err != nil {
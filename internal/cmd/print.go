@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 
+	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/database"
 	"github.com/spf13/cobra"
 )
@@ -27,6 +29,9 @@ func printCmd() *cobra.Command {
 	cmd.Flags().StringP("search", "s", "", "Search bookmark with specified keyword")
 	cmd.Flags().StringSliceP("tags", "t", []string{}, "Print bookmarks with matching tag(s)")
 	cmd.Flags().StringSliceP("exclude-tags", "e", []string{}, "Print bookmarks without these tag(s)")
+	cmd.Flags().BoolP("broken", "b", false, "Only print bookmarks that were broken in the last check")
+	cmd.Flags().Bool("hybrid", false, "Search by both keyword and meaning, most relevant first")
+	cmd.Flags().Int("related", 0, "Print bookmarks most similar to the bookmark with this index, most similar first")
 
 	return cmd
 }
@@ -39,6 +44,9 @@ func printHandler(cmd *cobra.Command, args []string) {
 	indexOnly, _ := cmd.Flags().GetBool("index-only")
 	orderLatest, _ := cmd.Flags().GetBool("latest")
 	excludedTags, _ := cmd.Flags().GetStringSlice("exclude-tags")
+	onlyBroken, _ := cmd.Flags().GetBool("broken")
+	relatedTo, _ := cmd.Flags().GetInt("related")
+	hybridSearch, _ := cmd.Flags().GetBool("hybrid")
 
 	// Convert args to ids
 	ids, err := parseStrIndices(args)
@@ -47,6 +55,32 @@ func printHandler(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// Find the related bookmarks, which replace the indices
+	var related []core.RelatedBookmark
+	if relatedTo > 0 {
+		if _, exist := db.GetBookmark(relatedTo, ""); !exist {
+			cError.Println("No matching index found")
+			return
+		}
+
+		idx := core.NewRelatedIndex(db)
+		if _, err = idx.Sync(); err != nil {
+			cError.Printf("Failed to update related bookmarks index: %v\n", err)
+			return
+		}
+
+		related = idx.Related(relatedTo, core.DefaultRelatedBookmarks)
+		if len(related) == 0 {
+			cError.Println("No related bookmarks found")
+			return
+		}
+
+		ids = make([]int, len(related))
+		for i, item := range related {
+			ids[i] = item.ID
+		}
+	}
+
 	// Read bookmarks from database
 	orderMethod := database.DefaultOrder
 	if orderLatest {
@@ -58,8 +92,22 @@ func printHandler(cmd *cobra.Command, args []string) {
 		Tags:         tags,
 		ExcludedTags: excludedTags,
 		Keyword:      keyword,
+		Broken:       onlyBroken,
 		OrderMethod:  orderMethod,
 	}
+	searchOptions.ParseKeywordFilters()
+
+	if hybridSearch {
+		if _, err = core.SyncEmbeddings(db, embedder, false); err != nil {
+			cError.Printf("Failed to sync embeddings: %v\n", err)
+			return
+		}
+
+		if err = core.PrepareHybridSearch(db, embedder, &searchOptions); err != nil {
+			cError.Printf("Failed to prepare hybrid search: %v\n", err)
+			return
+		}
+	}
 
 	bookmarks, err := db.GetBookmarks(searchOptions)
 	if err != nil {
@@ -71,7 +119,7 @@ func printHandler(cmd *cobra.Command, args []string) {
 		switch {
 		case len(ids) > 0:
 			cError.Println("No matching index found")
-		case keyword != "", len(tags) > 0:
+		case keyword != "", len(tags) > 0, searchOptions.Broken:
 			cError.Println("No matching bookmarks found")
 		default:
 			cError.Println("No bookmarks saved yet")
@@ -79,6 +127,23 @@ func printHandler(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// Keep the related bookmarks in order of their similarity
+	if len(related) > 0 {
+		position := map[int]int{}
+		for i, id := range ids {
+			position[id] = i
+		}
+
+		sort.Slice(bookmarks, func(i, j int) bool {
+			return position[bookmarks[i].ID] < position[bookmarks[j].ID]
+		})
+	}
+
+	// Set favicon URL for each bookmark
+	for i := range bookmarks {
+		bookmarks[i].FaviconURL = core.FaviconURL(fileStorage, "/", bookmarks[i].URL)
+	}
+
 	// Print data
 	if useJSON {
 		bt, err := json.MarshalIndent(&bookmarks, "", "    ")
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/spf13/cobra"
+)
+
+func relatedCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "related",
+		Short: "Manage the index used for finding related bookmarks",
+	}
+
+	cmd.AddCommand(relatedRebuildCmd())
+
+	return cmd
+}
+
+func relatedRebuildCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rebuild",
+		Short: "Rebuild the related bookmarks index from scratch",
+		Long: "Rebuild the related bookmarks index from scratch. The index is updated " +
+			"automatically when bookmarks are saved, so this is only needed when " +
+			"the way bookmarks are indexed has changed.",
+		Args: cobra.NoArgs,
+		Run:  relatedRebuildHandler,
+	}
+}
+
+func relatedRebuildHandler(cmd *cobra.Command, args []string) {
+	cInfo.Println("Indexing bookmarks...")
+
+	nIndexed, err := core.NewRelatedIndex(db).Rebuild()
+	if err != nil {
+		cError.Printf("Failed to rebuild related bookmarks index: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Indexed %d bookmark(s)\n", nIndexed)
+}
@@ -4,8 +4,13 @@ import (
 	"fmt"
 	"os"
 	fp "path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/storage"
 	apppaths "github.com/muesli/go-app-paths"
 	"github.com/spf13/cobra"
 )
@@ -14,6 +19,13 @@ var (
 	db              database.DB
 	dataDir         string
 	developmentMode bool
+	canonicalizer   *core.Canonicalizer
+	fetcher         *core.Fetcher
+	extractors      *core.ExtractorRegistry
+	storageQuota    core.StorageQuota
+	fileStorage     storage.Storage
+	headlessBrowser *core.Browser
+	embedder        core.Embedder
 )
 
 // ShioriCmd returns the root command for shiori
@@ -37,6 +49,16 @@ func ShioriCmd() *cobra.Command {
 		serveCmd(),
 		checkCmd(),
 		migrateCmd(),
+		dedupeCmd(),
+		thumbsCmd(),
+		archiveCmd(),
+		storageCmd(),
+		bulkCmd(),
+		tagsCmd(),
+		rulesCmd(),
+		relatedCmd(),
+		embeddingsCmd(),
+		highlightsCmd(),
 	)
 
 	return rootCmd
@@ -60,12 +82,62 @@ func preRunRootHandler(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	// Open storage for archives, thumbnails and favicons
+	backend, _ := os.LookupEnv("SHIORI_STORAGE")
+	fileStorage, err = openStorage(backend)
+	if err != nil {
+		cError.Printf("Failed to open storage: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Open database
 	db, err = openDatabase()
 	if err != nil {
 		cError.Printf("Failed to open database: %v\n", err)
 		os.Exit(1)
 	}
+
+	// Load URL canonicalization rules
+	canonicalizer, err = core.LoadCanonicalizer(fp.Join(dataDir, "canonical.json"))
+	if err != nil {
+		cError.Printf("Failed to load canonicalization rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Prepare HTTP fetcher
+	fetcher, err = openFetcher()
+	if err != nil {
+		cError.Printf("Failed to prepare fetcher: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Load content extractors
+	extractors, err = core.LoadExtractorRegistry(fp.Join(dataDir, "extractors.json"))
+	if err != nil {
+		cError.Printf("Failed to load extractor rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Find headless browser for rendering pages
+	headlessBrowser, err = loadHeadlessBrowser()
+	if err != nil {
+		cError.Printf("Failed to prepare headless browser: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Load archive storage quota
+	storageQuota, err = loadStorageQuota()
+	if err != nil {
+		cError.Printf("Failed to load storage quota: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Prepare embedding provider for hybrid search
+	embedder, err = loadEmbedder()
+	if err != nil {
+		cError.Printf("Failed to prepare embedding provider: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func getDataDir(portableMode bool) (string, error) {
@@ -112,6 +184,175 @@ func openDatabase() (database.DB, error) {
 	}
 }
 
+func openFetcher() (*core.Fetcher, error) {
+	cfg := core.DefaultFetcherConfig()
+	cfg.ProxyURL, _ = os.LookupEnv("SHIORI_HTTP_PROXY")
+	cfg.HeadersFile = fp.Join(dataDir, "headers.json")
+	cfg.CookiesFile = fp.Join(dataDir, "cookies.txt")
+
+	if userAgent, found := os.LookupEnv("SHIORI_HTTP_USER_AGENT"); found {
+		cfg.UserAgent = userAgent
+	}
+
+	if headersFile, found := os.LookupEnv("SHIORI_HTTP_HEADERS_FILE"); found {
+		cfg.HeadersFile = headersFile
+	}
+
+	if cookiesFile, found := os.LookupEnv("SHIORI_HTTP_COOKIES_FILE"); found {
+		cfg.CookiesFile = cookiesFile
+	}
+
+	if strTimeout, found := os.LookupEnv("SHIORI_HTTP_TIMEOUT"); found {
+		timeout, err := time.ParseDuration(strTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("SHIORI_HTTP_TIMEOUT is not valid: %v", err)
+		}
+		cfg.Timeout = timeout
+	}
+
+	if strRetries, found := os.LookupEnv("SHIORI_HTTP_RETRIES"); found {
+		retries, err := strconv.Atoi(strRetries)
+		if err != nil || retries < 0 {
+			return nil, fmt.Errorf("SHIORI_HTTP_RETRIES is not valid")
+		}
+		cfg.MaxRetries = retries
+	}
+
+	if strBackoff, found := os.LookupEnv("SHIORI_HTTP_RETRY_BACKOFF"); found {
+		backoff, err := time.ParseDuration(strBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("SHIORI_HTTP_RETRY_BACKOFF is not valid: %v", err)
+		}
+		cfg.RetryBackoff = backoff
+	}
+
+	if strMaxSize, found := os.LookupEnv("SHIORI_HTTP_MAX_BODY_SIZE"); found {
+		maxSize, err := parseByteSize(strMaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("SHIORI_HTTP_MAX_BODY_SIZE is not valid: %v", err)
+		}
+		cfg.MaxBodySize = maxSize
+	}
+
+	if strMaxRedirects, found := os.LookupEnv("SHIORI_HTTP_MAX_REDIRECTS"); found {
+		maxRedirects, err := strconv.Atoi(strMaxRedirects)
+		if err != nil || maxRedirects < 0 {
+			return nil, fmt.Errorf("SHIORI_HTTP_MAX_REDIRECTS is not valid")
+		}
+		cfg.MaxRedirects = maxRedirects
+	}
+
+	if strDenyPrivate, found := os.LookupEnv("SHIORI_HTTP_DENY_PRIVATE"); found {
+		denyPrivate, err := strconv.ParseBool(strDenyPrivate)
+		if err != nil {
+			return nil, fmt.Errorf("SHIORI_HTTP_DENY_PRIVATE is not valid: %v", err)
+		}
+		cfg.DenyPrivateIPs = denyPrivate
+	}
+
+	return core.NewFetcher(cfg)
+}
+
+// loadHeadlessBrowser prepares the headless browser for rendering pages with JavaScript.
+// Browser is optional, so if it's not configured and can't be found, nil is returned.
+func loadHeadlessBrowser() (*core.Browser, error) {
+	cfg := core.BrowserConfig{}
+	cfg.ExecPath, _ = os.LookupEnv("SHIORI_BROWSER")
+
+	if strDomains, found := os.LookupEnv("SHIORI_BROWSER_DOMAINS"); found {
+		cfg.Domains = strings.Split(strDomains, ",")
+	}
+
+	if strTimeout, found := os.LookupEnv("SHIORI_BROWSER_TIMEOUT"); found {
+		timeout, err := time.ParseDuration(strTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("SHIORI_BROWSER_TIMEOUT is not valid: %v", err)
+		}
+		cfg.Timeout = timeout
+	}
+
+	b, err := core.NewBrowser(cfg)
+	if err != nil && cfg.ExecPath == "" && len(cfg.Domains) == 0 {
+		return nil, nil
+	}
+
+	return b, err
+}
+
+func loadEmbedder() (core.Embedder, error) {
+	cfg := core.HTTPEmbedderConfig{}
+	cfg.URL, _ = os.LookupEnv("SHIORI_EMBEDDING_URL")
+	cfg.Model, _ = os.LookupEnv("SHIORI_EMBEDDING_MODEL")
+	cfg.APIKey, _ = os.LookupEnv("SHIORI_EMBEDDING_API_KEY")
+
+	// Without server, use the local embedder
+	if cfg.URL == "" {
+		return core.NewHashEmbedder(0), nil
+	}
+
+	if strTimeout, found := os.LookupEnv("SHIORI_EMBEDDING_TIMEOUT"); found {
+		timeout, err := time.ParseDuration(strTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("SHIORI_EMBEDDING_TIMEOUT is not valid: %v", err)
+		}
+		cfg.Timeout = timeout
+	}
+
+	return core.NewHTTPEmbedder(cfg)
+}
+
+func loadStorageQuota() (core.StorageQuota, error) {
+	var quota core.StorageQuota
+
+	if strQuota, found := os.LookupEnv("SHIORI_ARCHIVE_QUOTA"); found {
+		maxTotal, err := parseByteSize(strQuota)
+		if err != nil {
+			return quota, fmt.Errorf("SHIORI_ARCHIVE_QUOTA is not valid: %v", err)
+		}
+		quota.MaxTotal = maxTotal
+	}
+
+	if strMaxSize, found := os.LookupEnv("SHIORI_ARCHIVE_MAX_SIZE"); found {
+		maxArchive, err := parseByteSize(strMaxSize)
+		if err != nil {
+			return quota, fmt.Errorf("SHIORI_ARCHIVE_MAX_SIZE is not valid: %v", err)
+		}
+		quota.MaxArchive = maxArchive
+	}
+
+	return quota, nil
+}
+
+// openStorage opens the storage backend with specified name. If name is empty,
+// the data dir on local disk is used.
+func openStorage(backend string) (storage.Storage, error) {
+	switch backend {
+	case "", "local":
+		return storage.NewLocal(dataDir), nil
+	case "s3":
+		cfg := storage.S3Config{
+			Endpoint:  os.Getenv("SHIORI_S3_ENDPOINT"),
+			Region:    os.Getenv("SHIORI_S3_REGION"),
+			Bucket:    os.Getenv("SHIORI_S3_BUCKET"),
+			Prefix:    os.Getenv("SHIORI_S3_PREFIX"),
+			AccessKey: os.Getenv("SHIORI_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("SHIORI_S3_SECRET_KEY"),
+		}
+
+		if strPathStyle, found := os.LookupEnv("SHIORI_S3_PATH_STYLE"); found {
+			pathStyle, err := strconv.ParseBool(strPathStyle)
+			if err != nil {
+				return nil, fmt.Errorf("SHIORI_S3_PATH_STYLE is not valid: %v", err)
+			}
+			cfg.PathStyle = pathStyle
+		}
+
+		return storage.NewS3(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
 func openSQLiteDatabase() (database.DB, error) {
 	dbPath := fp.Join(dataDir, "shiori.db")
 	return database.OpenSQLiteDatabase(dbPath)
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/spf13/cobra"
+)
+
+func rulesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Manage automatic tagging rules",
+		Long: "Manage automatic tagging rules. New bookmarks that match all conditions " +
+			"of a rule get its tags, and their visibility changed if the rule sets it.",
+	}
+
+	cmd.AddCommand(rulesListCmd(), rulesAddCmd(), rulesDeleteCmd(), rulesApplyCmd())
+
+	return cmd
+}
+
+func rulesListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Print the tagging rules",
+		Args:  cobra.NoArgs,
+		Run:   rulesListHandler,
+	}
+
+	cmd.Flags().BoolP("json", "j", false, "Output data in JSON format")
+
+	return cmd
+}
+
+func rulesAddCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Create new tagging rule",
+		Long: "Create new tagging rule. Bookmark must match all of the specified conditions, " +
+			"while for keywords it's enough to contain any of them.",
+		Args: cobra.NoArgs,
+		Run:  rulesAddHandler,
+	}
+
+	cmd.Flags().StringP("name", "n", "", "Name of the rule, defaults to its tags")
+	cmd.Flags().StringP("domain", "d", "", "Match URL with this domain or its subdomains, e.g. github.com")
+	cmd.Flags().StringP("path", "p", "", "Match URL path with this regular expression, e.g. ^/golang/")
+	cmd.Flags().StringSlice("title", []string{}, "Match title that contains any of these keywords")
+	cmd.Flags().StringSlice("content", []string{}, "Match content that contains any of these keywords")
+	cmd.Flags().StringSliceP("tags", "t", []string{}, "Add these tag(s) to the matching bookmarks")
+	cmd.Flags().Bool("public", false, "Make the matching bookmarks public")
+	cmd.Flags().Bool("private", false, "Make the matching bookmarks private")
+	cmd.Flags().Bool("disabled", false, "Save the rule without enabling it")
+
+	return cmd
+}
+
+func rulesDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete id...",
+		Short: "Delete tagging rules",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   rulesDeleteHandler,
+	}
+}
+
+func rulesApplyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply [indices]",
+		Short: "Apply tagging rules to the saved bookmarks",
+		Long: "Apply the enabled tagging rules to the saved bookmarks. " +
+			"Accepts space-separated list of indices (e.g. 5 6 23 4 110 45), " +
+			"hyphenated range (e.g. 100-200) or both (e.g. 1-3 7 9). " +
+			"If no arguments, ALL bookmarks will be checked.",
+		Run: rulesApplyHandler,
+	}
+
+	cmd.Flags().Bool("dry-run", false, "Only show the bookmarks that would be changed")
+	cmd.Flags().Int("batch-size", core.DefaultBulkBatchSize, "Number of bookmarks saved in a single transaction")
+
+	return cmd
+}
+
+func rulesListHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	useJSON, _ := cmd.Flags().GetBool("json")
+
+	rules, err := db.GetRules()
+	if err != nil {
+		cError.Printf("Failed to get rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	if useJSON {
+		bt, err := json.MarshalIndent(&rules, "", "    ")
+		if err != nil {
+			cError.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(bt))
+		return
+	}
+
+	for _, rule := range rules {
+		cIndex.Printf("%d. ", rule.ID)
+		cTitle.Print(rule.Name)
+		if !rule.Enabled {
+			cError.Print(" (disabled)")
+		}
+		fmt.Println()
+
+		conditions := []string{}
+		if rule.Domain != "" {
+			conditions = append(conditions, "domain "+rule.Domain)
+		}
+		if rule.PathPattern != "" {
+			conditions = append(conditions, "path "+rule.PathPattern)
+		}
+		if len(rule.TitleKeywords) > 0 {
+			conditions = append(conditions, "title "+strings.Join(rule.TitleKeywords, ", "))
+		}
+		if len(rule.ContentKeywords) > 0 {
+			conditions = append(conditions, "content "+strings.Join(rule.ContentKeywords, ", "))
+		}
+
+		actions := []string{}
+		for _, tag := range rule.Tags {
+			actions = append(actions, "#"+tag)
+		}
+		if rule.Public != nil && *rule.Public {
+			actions = append(actions, "public")
+		} else if rule.Public != nil {
+			actions = append(actions, "private")
+		}
+
+		fmt.Print(strings.Repeat(" ", len(strconv.Itoa(rule.ID))+2))
+		cExcerpt.Printf("If %s ", strings.Join(conditions, " and "))
+		cTag.Println("then " + strings.Join(actions, " "))
+	}
+}
+
+func rulesAddHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	name, _ := cmd.Flags().GetString("name")
+	domain, _ := cmd.Flags().GetString("domain")
+	path, _ := cmd.Flags().GetString("path")
+	titleKeywords, _ := cmd.Flags().GetStringSlice("title")
+	contentKeywords, _ := cmd.Flags().GetStringSlice("content")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	makePublic, _ := cmd.Flags().GetBool("public")
+	makePrivate, _ := cmd.Flags().GetBool("private")
+	disabled, _ := cmd.Flags().GetBool("disabled")
+
+	if makePublic && makePrivate {
+		cError.Println("Rule can't make bookmarks public and private at once")
+		os.Exit(1)
+	}
+
+	rule := model.Rule{
+		Name:            name,
+		Domain:          domain,
+		PathPattern:     path,
+		TitleKeywords:   titleKeywords,
+		ContentKeywords: contentKeywords,
+		Tags:            tags,
+		Enabled:         !disabled,
+	}
+
+	if makePublic || makePrivate {
+		rule.Public = &makePublic
+	}
+
+	rule, err := core.CleanRule(rule)
+	if err != nil {
+		cError.Printf("Invalid rule: %v\n", err)
+		os.Exit(1)
+	}
+
+	rule, err = db.SaveRule(rule)
+	if err != nil {
+		cError.Printf("Failed to save rule: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Rule %d created, run `shiori rules apply` to apply it to the saved bookmarks\n", rule.ID)
+}
+
+func rulesDeleteHandler(cmd *cobra.Command, args []string) {
+	ids, err := parseStrIndices(args)
+	if err != nil {
+		cError.Printf("Failed to parse args: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err = db.DeleteRules(ids...); err != nil {
+		cError.Printf("Failed to delete rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Deleted %d rule(s)\n", len(ids))
+}
+
+func rulesApplyHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	batchSize, _ := cmd.Flags().GetInt("batch-size")
+	if batchSize <= 0 {
+		batchSize = core.DefaultBulkBatchSize
+	}
+
+	// Convert args to ids
+	ids, err := parseStrIndices(args)
+	if err != nil {
+		cError.Printf("Failed to parse args: %v\n", err)
+		os.Exit(1)
+	}
+
+	rules := loadRuleSet()
+
+	// Fetch the IDs first, so the content is only loaded one batch at a time
+	if len(ids) == 0 {
+		bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{})
+		if err != nil {
+			cError.Printf("Failed to get bookmarks: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, book := range bookmarks {
+			ids = append(ids, book.ID)
+		}
+	}
+
+	nChanged := 0
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{
+			IDs:         ids[start:end],
+			WithContent: true,
+		})
+		if err != nil {
+			cError.Printf("Failed to get bookmarks: %v\n", err)
+			os.Exit(1)
+		}
+
+		changed := []model.Bookmark{}
+		for _, book := range bookmarks {
+			applied := rules.Apply(&book)
+			if len(applied) == 0 {
+				continue
+			}
+
+			changed = append(changed, book)
+			cIndex.Printf("%d. ", book.ID)
+			fmt.Printf("%s ", book.Title)
+			cTag.Printf("(%s)\n", strings.Join(applied, ", "))
+		}
+
+		if len(changed) > 0 && !dryRun {
+			if _, err = db.SaveBookmarks(changed...); err != nil {
+				cError.Printf("Failed to save bookmarks: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		nChanged += len(changed)
+	}
+
+	if dryRun {
+		fmt.Printf("%d bookmark(s) would be changed\n", nChanged)
+		return
+	}
+
+	fmt.Printf("Changed %d bookmark(s)\n", nChanged)
+}
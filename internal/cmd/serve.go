@@ -2,6 +2,11 @@ package cmd
 
 import (
 	"strings"
+	"time"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
 
 	"github.com/go-shiori/shiori/internal/webserver"
 	"github.com/sirupsen/logrus"
@@ -23,6 +28,8 @@ func serveCmd() *cobra.Command {
 	cmd.Flags().StringP("webroot", "r", "/", "Root path that used by server")
 	cmd.Flags().Bool("log", true, "Print out a non-standard access log")
 	cmd.Flags().Bool("disable-auth", false, "disable user login/out; no auth required")
+	cmd.Flags().Duration("check-interval", 24*time.Hour, "Interval for checking whether bookmarks are still reachable, 0 to disable")
+	cmd.Flags().Duration("sync-interval", 10*time.Minute, "Interval for indexing and embedding bookmarks that changed outside the server, e.g. by import")
 
 	return cmd
 }
@@ -34,6 +41,8 @@ func serveHandler(cmd *cobra.Command, args []string) {
 	rootPath, _ := cmd.Flags().GetString("webroot")
 	log, _ := cmd.Flags().GetBool("log")
 	disableAuth, _ := cmd.Flags().GetBool("disable-auth")
+	checkInterval, _ := cmd.Flags().GetDuration("check-interval")
+	syncInterval, _ := cmd.Flags().GetDuration("sync-interval")
 
 	// Validate root path
 	if rootPath == "" {
@@ -48,15 +57,31 @@ func serveHandler(cmd *cobra.Command, args []string) {
 		rootPath += "/"
 	}
 
+	// Start link checker in background
+	if checkInterval > 0 {
+		go runLinkChecker(checkInterval)
+	}
+
+	// Sync related bookmarks index and embeddings in background, which also loads the index
+	relatedIndex := core.NewRelatedIndex(db)
+	go runIndexSync(relatedIndex, syncInterval)
+
 	// Start server
 	serverConfig := webserver.Config{
 		DB:            db,
-		DataDir:       dataDir,
+		Storage:       fileStorage,
 		ServerAddress: address,
 		ServerPort:    port,
 		RootPath:      rootPath,
 		Log:           log,
 		DisableAuth:   disableAuth,
+		Canonicalizer: canonicalizer,
+		Fetcher:       fetcher,
+		Extractors:    extractors,
+		StorageQuota:  storageQuota,
+		Browser:       headlessBrowser,
+		RelatedIndex:  relatedIndex,
+		Embedder:      embedder,
 	}
 
 	err := webserver.ServeApp(serverConfig)
@@ -64,3 +89,69 @@ func serveHandler(cmd *cobra.Command, args []string) {
 		logrus.Fatalf("Server error: %v\n", err)
 	}
 }
+
+// runIndexSync syncs the related bookmarks index and the embeddings on start, then periodically
+// picks up the bookmarks changed outside the server. Bookmarks saved by the server itself are
+// indexed right away, so this keeps the slow work out of the requests.
+func runIndexSync(idx *core.RelatedIndex, interval time.Duration) {
+	for {
+		if nChanged, err := idx.Sync(); err != nil {
+			logrus.Errorf("Failed to sync related bookmarks index: %v", err)
+		} else if nChanged > 0 {
+			logrus.Infof("Related bookmarks index synced, %d bookmarks changed", nChanged)
+		}
+
+		if embedder != nil {
+			if nEmbedded, err := core.SyncEmbeddings(db, embedder, false); err != nil {
+				logrus.Errorf("Failed to sync embeddings: %v", err)
+			} else if nEmbedded > 0 {
+				logrus.Infof("Embeddings synced, %d bookmarks embedded", nEmbedded)
+			}
+		}
+
+		if interval <= 0 {
+			return
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// runLinkChecker periodically checks bookmarks which haven't been checked within the interval.
+// Since the previous results are kept in database, restarting server doesn't check all bookmarks again.
+func runLinkChecker(interval time.Duration) {
+	tick := time.Hour
+	if interval < tick {
+		tick = interval
+	}
+
+	for {
+		bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{})
+		if err == nil {
+			bookmarks, err = staleLinkBookmarks(bookmarks, interval)
+		}
+
+		if err != nil {
+			logrus.Errorf("Link checker failed to get bookmarks: %v", err)
+		} else if len(bookmarks) > 0 {
+			nBroken := 0
+			core.CheckLinks(fetcher, bookmarks, 5, func(book model.Bookmark, result model.LinkCheck) {
+				if result.Broken {
+					nBroken++
+				}
+
+				if err := db.SaveLinkChecks(result); err != nil {
+					logrus.Errorf("Link checker failed to save result for %s: %v", book.URL, err)
+				}
+			})
+
+			logrus.Infof("Link checker checked %d bookmarks, %d broken", len(bookmarks), nBroken)
+
+			if err := db.PruneLinkChecks(linkCheckHistory); err != nil {
+				logrus.Errorf("Link checker failed to remove old results: %v", err)
+			}
+		}
+
+		time.Sleep(tick)
+	}
+}
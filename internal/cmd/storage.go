@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// storageGCMinAge is the minimum age of unused file before it's removed by garbage collector,
+// so it doesn't remove the archive or shared resource that still being created.
+const storageGCMinAge = time.Hour
+
+func storageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "storage",
+		Short: "Manage disk space used by offline archives",
+		Long: "Manage disk space used by offline archives. Large resources like images, " +
+			"stylesheets and scripts are kept in shared store, so identical resources " +
+			"from different archives are only stored once.",
+	}
+
+	cmd.AddCommand(storageStatsCmd(), storageGCCmd(), storageMigrateCmd())
+
+	return cmd
+}
+
+func storageStatsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show disk space used by offline archives",
+		Long: "Show disk space used by offline archives and the shared resources, " +
+			"along with the largest archives. The sizes recorded in database, which are used to check " +
+			"the quota, are updated as well.",
+		Args: cobra.NoArgs,
+		Run:  storageStatsHandler,
+	}
+
+	cmd.Flags().IntP("top", "n", 10, "Number of largest archives to show")
+
+	return cmd
+}
+
+func storageGCCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove unused archives and shared resources",
+		Long: "Remove archives whose bookmark doesn't exist anymore and shared resources " +
+			"that not used by any archive. Files that modified in the last hour are kept, " +
+			"since they might be still used by running archival process.",
+		Args: cobra.NoArgs,
+		Run:  storageGCHandler,
+	}
+
+	cmd.Flags().Bool("dry-run", false, "Only show what would be removed")
+	cmd.Flags().Bool("dedupe", false, "Move resources of existing archives into the shared store first")
+
+	return cmd
+}
+
+func storageMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy archives, thumbnails and favicons into another storage backend",
+		Long: "Copy archives, thumbnails, favicons and shared resources from the current " +
+			"storage backend (set by SHIORI_STORAGE) into another one. Files that already " +
+			"exist in the target with the same size are skipped, so it's safe to run it again " +
+			"when interrupted. Once finished, set SHIORI_STORAGE to the new backend.",
+		Args: cobra.NoArgs,
+		Run:  storageMigrateHandler,
+	}
+
+	cmd.Flags().String("to", "", "Target storage backend, either local or s3")
+	cmd.Flags().Bool("delete", false, "Remove the files from current storage after they are copied")
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func storageStatsHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	nTop, _ := cmd.Flags().GetInt("top")
+
+	bookmarks, report := analyzeStorage()
+
+	// Save the archive and resource sizes, so the usage is up to date in database
+	saveStorageUsage(bookmarks, report)
+
+	// Print the usage
+	fmt.Printf("Archives         : %d (%s)\n", report.Archives, core.FormatByteSize(report.ArchiveSize))
+	fmt.Printf("Shared resources : %d (%s)\n", report.SharedResources, core.FormatByteSize(report.SharedSize))
+	fmt.Printf("References       : %d (%s saved)\n", report.References, core.FormatByteSize(report.SavedSize))
+	fmt.Printf("Total            : %s\n", core.FormatByteSize(report.Total()))
+
+	if storageQuota.MaxTotal > 0 {
+		fmt.Printf("Quota            : %s (%.1f%% used)\n", core.FormatByteSize(storageQuota.MaxTotal),
+			float64(report.Total())*100/float64(storageQuota.MaxTotal))
+	}
+
+	if storageQuota.MaxArchive > 0 {
+		fmt.Printf("Max archive size : %s\n", core.FormatByteSize(storageQuota.MaxArchive))
+	}
+
+	if len(report.OrphanArchives) > 0 || len(report.UnusedResources) > 0 {
+		fmt.Println()
+		cInfo.Printf("Found %d orphan archive(s) and %d unused resource(s) (%s), run `shiori storage gc` to remove them\n",
+			len(report.OrphanArchives), len(report.UnusedResources),
+			core.FormatByteSize(report.OrphanSize+report.UnusedSize))
+	}
+
+	// Print the largest archives
+	archived := []model.Bookmark{}
+	for _, book := range bookmarks {
+		if size, exist := report.ArchiveSizes[book.ID]; exist {
+			book.ArchiveSize = size
+			archived = append(archived, book)
+		}
+	}
+
+	sort.SliceStable(archived, func(i, j int) bool {
+		return archived[i].ArchiveSize > archived[j].ArchiveSize
+	})
+
+	if nTop > 0 && len(archived) > 0 {
+		if len(archived) > nTop {
+			archived = archived[:nTop]
+		}
+
+		fmt.Println()
+		fmt.Println("Largest archives:")
+		for _, book := range archived {
+			cIndex.Printf("%6d. ", book.ID)
+			fmt.Printf("%10s  ", core.FormatByteSize(book.ArchiveSize))
+			cTitle.Println(book.Title)
+		}
+	}
+}
+
+func storageGCHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	dedupe, _ := cmd.Flags().GetBool("dedupe")
+
+	// Move the resources of old archives into shared store first
+	if dedupe && !dryRun {
+		bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{})
+		if err != nil {
+			cError.Printf("Failed to get bookmarks: %v\n", err)
+			os.Exit(1)
+		}
+
+		nShared := 0
+		for _, book := range bookmarks {
+			if core.ArchiveSize(fileStorage, book.ID) == 0 {
+				continue
+			}
+
+			result, err := core.DedupeArchive(fileStorage, book.ID)
+			if err != nil {
+				cError.Printf("Failed to dedupe archive of bookmark %d: %v\n", book.ID, err)
+				continue
+			}
+			nShared += result.Shared
+		}
+
+		saveArchiveSizes(bookmarks...)
+		fmt.Printf("Moved %d resource(s) into shared store\n", nShared)
+	}
+
+	bookmarks, report := analyzeStorage()
+
+	// Remove the unused files
+	files := append(report.OrphanArchives, report.UnusedResources...)
+	for _, file := range files {
+		if dryRun {
+			fmt.Println(file)
+			continue
+		}
+
+		if err := fileStorage.Remove(file); err != nil {
+			cError.Printf("Failed to remove %s: %v\n", file, err)
+			os.Exit(1)
+		}
+	}
+
+	if !dryRun {
+		for _, file := range report.UnusedResources {
+			delete(report.ResourceSizes, path.Base(file))
+		}
+		saveStorageUsage(bookmarks, report)
+	}
+
+	freed := core.FormatByteSize(report.OrphanSize + report.UnusedSize)
+	if dryRun {
+		fmt.Printf("Would remove %d orphan archive(s) and %d unused resource(s), freeing %s\n",
+			len(report.OrphanArchives), len(report.UnusedResources), freed)
+		return
+	}
+
+	fmt.Printf("Removed %d orphan archive(s) and %d unused resource(s), freeing %s\n",
+		len(report.OrphanArchives), len(report.UnusedResources), freed)
+}
+
+func storageMigrateHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	target, _ := cmd.Flags().GetString("to")
+	deleteSource, _ := cmd.Flags().GetBool("delete")
+
+	currentBackend, _ := os.LookupEnv("SHIORI_STORAGE")
+	if currentBackend == "" {
+		currentBackend = "local"
+	}
+
+	if target == currentBackend {
+		cError.Printf("Storage %s is already used\n", target)
+		os.Exit(1)
+	}
+
+	dst, err := openStorage(target)
+	if err != nil {
+		cError.Printf("Failed to open target storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Copy the files in every prefix
+	nCopied, nSkipped, nFailed := 0, 0, 0
+	for _, prefix := range []string{"archive/", "thumb/", "favicon/", "resources/"} {
+		err = fileStorage.Walk(prefix, func(info storage.FileInfo) error {
+			if dstInfo, err := dst.Stat(info.Name); err == nil && dstInfo.Size == info.Size {
+				nSkipped++
+			} else if err := storage.Copy(dst, info.Name, fileStorage, info.Name); err != nil {
+				cError.Printf("Failed to copy %s: %v\n", info.Name, err)
+				nFailed++
+				return nil
+			} else {
+				nCopied++
+			}
+
+			if deleteSource {
+				if err := fileStorage.Remove(info.Name); err != nil {
+					cError.Printf("Failed to remove %s: %v\n", info.Name, err)
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			cError.Printf("Failed to list files in storage: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Copied %d file(s), skipped %d existing file(s)\n", nCopied, nSkipped)
+	if nFailed > 0 {
+		cError.Printf("Failed to copy %d file(s)\n", nFailed)
+		os.Exit(1)
+	}
+}
+
+// analyzeStorage reports how the storage is used by archives of all bookmarks.
+func analyzeStorage() ([]model.Bookmark, core.StorageReport) {
+	bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{})
+	if err != nil {
+		cError.Printf("Failed to get bookmarks: %v\n", err)
+		os.Exit(1)
+	}
+
+	ids := make([]int, len(bookmarks))
+	for i, book := range bookmarks {
+		ids[i] = book.ID
+	}
+
+	report, err := core.AnalyzeStorage(fileStorage, ids, storageGCMinAge)
+	if err != nil {
+		cError.Printf("Failed to analyze storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	return bookmarks, report
+}
+
+// saveStorageUsage saves the archive size of the bookmarks and the size of shared resources
+// found in the storage, so the usage recorded in database matches the actual one.
+func saveStorageUsage(bookmarks []model.Bookmark, report core.StorageReport) {
+	sizes := make(map[int]int64, len(bookmarks))
+	for _, book := range bookmarks {
+		sizes[book.ID] = report.ArchiveSizes[book.ID]
+	}
+
+	if err := db.UpdateArchiveSizes(sizes); err != nil {
+		cError.Printf("Failed to save archive size: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := db.UpdateResourceSizes(report.ResourceSizes, true); err != nil {
+		cError.Printf("Failed to save resource size: %v\n", err)
+		os.Exit(1)
+	}
+}
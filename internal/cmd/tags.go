@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/spf13/cobra"
+)
+
+func tagsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tags",
+		Short: "Manage the tags of bookmarks",
+		Long: "Manage the tags of bookmarks. Tags can be nested by separating the levels " +
+			"with slash, e.g. dev/go, and searching a tag finds its descendants as well.",
+	}
+
+	cmd.AddCommand(tagsListCmd(), tagsRenameCmd(), tagsMergeCmd(),
+		tagsEditCmd(), tagsDeleteCmd(), tagsCleanCmd())
+
+	return cmd
+}
+
+func tagsListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Print the tags and number of their bookmarks",
+		Args:  cobra.NoArgs,
+		Run:   tagsListHandler,
+	}
+
+	cmd.Flags().BoolP("json", "j", false, "Output data in JSON format")
+
+	return cmd
+}
+
+func tagsRenameCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename tag new-name",
+		Short: "Rename a tag along with its descendants",
+		Long: "Rename a tag along with its descendants, e.g. renaming dev into code " +
+			"turns dev/go into code/go. If the new name is already used, the tags are merged.",
+		Args: cobra.ExactArgs(2),
+		Run:  tagsRenameHandler,
+	}
+}
+
+func tagsMergeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "merge target tag...",
+		Short: "Merge tags into the target tag",
+		Long: "Merge tags into the target tag, which is created if needed. " +
+			"Bookmarks of the merged tags get the target tag, then the merged tags are removed.",
+		Args: cobra.MinimumNArgs(2),
+		Run:  tagsMergeHandler,
+	}
+}
+
+func tagsEditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "edit tag",
+		Short: "Change color and description of a tag",
+		Args:  cobra.ExactArgs(1),
+		Run:   tagsEditHandler,
+	}
+
+	cmd.Flags().StringP("color", "c", "", "Hex color of the tag, e.g. #ff8800, or empty to remove it")
+	cmd.Flags().StringP("description", "d", "", "Description of the tag")
+
+	return cmd
+}
+
+func tagsDeleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete tag...",
+		Short: "Delete tags and detach them from all bookmarks",
+		Long: "Delete tags and detach them from all bookmarks. " +
+			"The bookmarks themselves and the descendants of the tags are kept.",
+		Args: cobra.MinimumNArgs(1),
+		Run:  tagsDeleteHandler,
+	}
+
+	cmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompt and delete the tags")
+
+	return cmd
+}
+
+func tagsCleanCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clean",
+		Short: "Remove tags that not used by any bookmark",
+		Args:  cobra.NoArgs,
+		Run:   tagsCleanHandler,
+	}
+}
+
+func tagsListHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	useJSON, _ := cmd.Flags().GetBool("json")
+
+	tags, err := db.GetTags()
+	if err != nil {
+		cError.Printf("Failed to get tags: %v\n", err)
+		os.Exit(1)
+	}
+
+	if useJSON {
+		bt, err := json.MarshalIndent(&tags, "", "    ")
+		if err != nil {
+			cError.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(bt))
+		return
+	}
+
+	for _, tag := range tags {
+		cTag.Print(tag.Name)
+		fmt.Printf(" (%d)", tag.NBookmarks)
+		if tag.Color != "" {
+			cSymbol.Printf(" %s", tag.Color)
+		}
+		if tag.Description != "" {
+			cExcerpt.Printf(" %s", tag.Description)
+		}
+		fmt.Println()
+	}
+}
+
+func tagsRenameHandler(cmd *cobra.Command, args []string) {
+	tag := getTagByName(args[0])
+
+	result, err := core.RenameTag(db, tag.ID, args[1])
+	if err != nil {
+		cError.Printf("Failed to rename tag: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Tag %s renamed into %s\n", tag.Name, result.Name)
+}
+
+func tagsMergeHandler(cmd *cobra.Command, args []string) {
+	ids := []int{}
+	for _, name := range args[1:] {
+		ids = append(ids, getTagByName(name).ID)
+	}
+
+	result, err := db.MergeTags(args[0], ids...)
+	if err != nil {
+		cError.Printf("Failed to merge tags: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Merged %d tag(s) into %s, which now has %d bookmark(s)\n", len(ids), result.Name, result.NBookmarks)
+}
+
+func tagsEditHandler(cmd *cobra.Command, args []string) {
+	tag := getTagByName(args[0])
+
+	if !cmd.Flags().Changed("color") && !cmd.Flags().Changed("description") {
+		cError.Println("Nothing to change, use --color or --description")
+		os.Exit(1)
+	}
+
+	if cmd.Flags().Changed("color") {
+		color, _ := cmd.Flags().GetString("color")
+		normalized, err := core.NormalizeTagColor(color)
+		if err != nil {
+			cError.Println(err)
+			os.Exit(1)
+		}
+		tag.Color = normalized
+	}
+
+	if cmd.Flags().Changed("description") {
+		description, _ := cmd.Flags().GetString("description")
+		tag.Description = strings.TrimSpace(description)
+	}
+
+	if err := db.UpdateTag(tag); err != nil {
+		cError.Printf("Failed to update tag: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Tag %s updated\n", tag.Name)
+}
+
+func tagsDeleteHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	skipConfirm, _ := cmd.Flags().GetBool("yes")
+
+	tags := []model.Tag{}
+	ids := []int{}
+	nBookmarks := 0
+	for _, name := range args {
+		tag := getTagByName(name)
+		tags = append(tags, tag)
+		ids = append(ids, tag.ID)
+		nBookmarks += tag.NBookmarks
+	}
+
+	if !skipConfirm {
+		confirm := ""
+		fmt.Printf("Remove %d tag(s) from %d bookmark(s)? (y/N): ", len(tags), nBookmarks)
+		fmt.Scanln(&confirm)
+
+		if confirm != "y" {
+			fmt.Println("No tags deleted")
+			return
+		}
+	}
+
+	if err := db.DeleteTags(ids...); err != nil {
+		cError.Printf("Failed to delete tags: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Deleted %d tag(s)\n", len(tags))
+}
+
+func tagsCleanHandler(cmd *cobra.Command, args []string) {
+	nRemoved, err := db.DeleteOrphanTags()
+	if err != nil {
+		cError.Printf("Failed to remove unused tags: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Removed %d unused tag(s)\n", nRemoved)
+}
+
+// getTagByName returns the tag with matching name, or exits if it doesn't exist.
+func getTagByName(name string) model.Tag {
+	tag, exist := db.GetTag(0, name)
+	if !exist {
+		cError.Printf("Tag %q is not found\n", name)
+		os.Exit(1)
+	}
+
+	return tag
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+func thumbsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "thumbs",
+		Short: "Manage thumbnail of the bookmarks",
+	}
+
+	cmd.AddCommand(thumbsRegenerateCmd())
+
+	return cmd
+}
+
+func thumbsRegenerateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "regenerate [indices]",
+		Short: "Regenerate thumbnail of the bookmarks in all sizes",
+		Long: "Regenerate thumbnail of the bookmarks in all sizes. " +
+			"Accepts space-separated list of indices (e.g. 5 6 23 4 110 45), " +
+			"hyphenated range (e.g. 100-200) or both (e.g. 1-3 7 9). " +
+			"If no arguments, ALL bookmarks will be processed. " +
+			"The image is taken from the live page, or from the offline archive if the page is gone.",
+		Run: thumbsRegenerateHandler,
+	}
+
+	cmd.Flags().BoolP("offline", "o", false, "Only use the offline archive and existing thumbnail")
+	cmd.Flags().Bool("missing", false, "Only process bookmarks that don't have thumbnail yet")
+
+	return cmd
+}
+
+func thumbsRegenerateHandler(cmd *cobra.Command, args []string) {
+	// Parse flags
+	offline, _ := cmd.Flags().GetBool("offline")
+	onlyMissing, _ := cmd.Flags().GetBool("missing")
+
+	// Convert args to ids
+	ids, err := parseStrIndices(args)
+	if err != nil {
+		cError.Printf("Failed to parse args: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Fetch bookmarks from database
+	bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{IDs: ids})
+	if err != nil {
+		cError.Printf("Failed to get bookmarks: %v\n", err)
+		os.Exit(1)
+	}
+
+	if onlyMissing {
+		var missing []model.Bookmark
+		for _, book := range bookmarks {
+			if !storage.Exists(fileStorage, core.ThumbnailName(book.ID, core.ThumbnailGrid)) {
+				missing = append(missing, book)
+			}
+		}
+		bookmarks = missing
+	}
+
+	if len(bookmarks) == 0 {
+		cError.Println("No matching index found")
+		os.Exit(1)
+	}
+
+	// Generate the thumbnails
+	mx := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, 10)
+	logIndex, nFailed := 0, 0
+
+	for _, book := range bookmarks {
+		wg.Add(1)
+
+		go func(book model.Bookmark) {
+			// Make sure to finish the WG
+			defer wg.Done()
+
+			// Register goroutine to semaphore
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+			}()
+
+			source, err := core.GenerateThumbnail(core.ThumbnailRequest{
+				Storage:    fileStorage,
+				Bookmark:   book,
+				Fetcher:    fetcher,
+				Extractors: extractors,
+				Offline:    offline,
+			})
+
+			mx.Lock()
+			defer mx.Unlock()
+
+			logIndex++
+			if err != nil {
+				nFailed++
+				cError.Printf("[%d/%d] Failed to generate thumbnail for %d: %v\n", logIndex, len(bookmarks), book.ID, err)
+				return
+			}
+
+			cInfo.Printf("[%d/%d] Generated thumbnail for %d from %s\n", logIndex, len(bookmarks), book.ID, source)
+		}(book)
+	}
+
+	wg.Wait()
+
+	fmt.Printf("Thumbnails regenerated for %d bookmark(s)\n", len(bookmarks)-nFailed)
+}
@@ -36,6 +36,7 @@ func updateCmd() *cobra.Command {
 	cmd.Flags().Bool("keep-metadata", false, "Keep existing metadata. Useful when only want to update bookmark's content")
 	cmd.Flags().BoolP("no-archival", "a", false, "Update bookmark without updating offline archive")
 	cmd.Flags().Bool("log-archival", false, "Log the archival process")
+	cmd.Flags().Bool("render", false, "Render the pages with JavaScript in headless browser")
 
 	return cmd
 }
@@ -50,6 +51,7 @@ func updateHandler(cmd *cobra.Command, args []string) {
 	skipConfirm, _ := cmd.Flags().GetBool("yes")
 	noArchival, _ := cmd.Flags().GetBool("no-archival")
 	logArchival, _ := cmd.Flags().GetBool("log-archival")
+	render, _ := cmd.Flags().GetBool("render")
 	keepMetadata := cmd.Flags().Changed("keep-metadata")
 
 	// If no arguments (i.e all bookmarks going to be updated), confirm to user
@@ -77,7 +79,7 @@ func updateHandler(cmd *cobra.Command, args []string) {
 
 	if cmd.Flags().Changed("url") {
 		// Clean up bookmark URL
-		url, err = core.RemoveUTMParams(url)
+		url, err = canonicalizer.Canonicalize(url)
 		if err != nil {
 			panic(fmt.Errorf("failed to clean URL: %v", err))
 		}
@@ -139,6 +141,7 @@ func updateHandler(cmd *cobra.Command, args []string) {
 
 			// Mark whether book will be archived
 			book.CreateArchive = !noArchival
+			book.RenderJS = render
 
 			// If used, use submitted URL
 			if url != "" {
@@ -156,7 +159,7 @@ func updateHandler(cmd *cobra.Command, args []string) {
 				}()
 
 				// Download data from internet
-				content, contentType, err := core.DownloadBookmark(book.URL)
+				content, contentType, err := fetcher.DownloadBookmark(book.URL)
 				if err != nil {
 					chProblem <- book.ID
 					chMessage <- fmt.Errorf("Failed to download %s: %v", book.URL, err)
@@ -164,13 +167,19 @@ func updateHandler(cmd *cobra.Command, args []string) {
 				}
 
 				request := core.ProcessRequest{
-					DataDir:     dataDir,
-					Bookmark:    book,
-					Content:     content,
-					ContentType: contentType,
-					KeepTitle:   keepMetadata,
-					KeepExcerpt: keepMetadata,
-					LogArchival: logArchival,
+					DB:            db,
+					Storage:       fileStorage,
+					Bookmark:      book,
+					Content:       content,
+					ContentType:   contentType,
+					KeepTitle:     keepMetadata,
+					KeepExcerpt:   keepMetadata,
+					LogArchival:   logArchival,
+					Canonicalizer: canonicalizer,
+					Fetcher:       fetcher,
+					Extractors:    extractors,
+					Browser:       headlessBrowser,
+					Quota:         storageQuota,
 				}
 
 				book, _, err = core.ProcessBookmark(request)
@@ -291,6 +300,8 @@ func updateHandler(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	saveArchiveSizes(bookmarks...)
+
 	// Print updated bookmarks
 	fmt.Println()
 	printBookmarks(bookmarks...)
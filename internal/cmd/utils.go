@@ -9,9 +9,11 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/fatih/color"
+	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/model"
 	"golang.org/x/term"
 )
@@ -54,6 +56,12 @@ func printBookmarks(bookmarks ...model.Bookmark) {
 		cSymbol.Print(strSpace + "> ")
 		cURL.Println(bookmark.URL)
 
+		// Print bookmark favicon
+		if bookmark.FaviconURL != "" {
+			cSymbol.Print(strSpace + "* ")
+			cURL.Println(bookmark.FaviconURL)
+		}
+
 		// Print bookmark excerpt
 		if bookmark.Excerpt != "" {
 			cSymbol.Print(strSpace + "+ ")
@@ -164,3 +172,65 @@ func validateTitle(title, fallback string) string {
 
 	return validUtf
 }
+
+// parseByteSize parses size like "512", "800K", "32M" or "1G" into bytes.
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "B")
+
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1 << 30
+	}
+
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("size %q is not valid", s)
+	}
+
+	return size * multiplier, nil
+}
+
+// parseDBTime parses time that fetched from database. Its format depends on the
+// database engine, but it's always stored in UTC.
+func parseDBTime(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("time %q is not valid", s)
+}
+
+// loadRuleSet fetches the tagging rules from database, or exits if they can't be loaded.
+func loadRuleSet() *core.RuleSet {
+	rules, err := core.LoadRuleSet(db)
+	if err != nil {
+		cError.Printf("Failed to load tagging rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	return rules
+}
+
+// saveArchiveSizes records the current size of offline archive of the bookmarks in database.
+func saveArchiveSizes(bookmarks ...model.Bookmark) {
+	sizes := make(map[int]int64, len(bookmarks))
+	for _, book := range bookmarks {
+		sizes[book.ID] = core.ArchiveSize(fileStorage, book.ID)
+	}
+
+	if err := db.UpdateArchiveSizes(sizes); err != nil {
+		cError.Printf("Failed to save archive size: %v\n", err)
+	}
+}
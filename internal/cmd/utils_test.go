@@ -143,3 +143,50 @@ func Test_parseStrIndices(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseByteSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    int64
+		wantErr bool
+	}{{
+		name: "plain bytes",
+		args: "512",
+		want: 512,
+	}, {
+		name: "kilobytes",
+		args: "800K",
+		want: 800 << 10,
+	}, {
+		name: "megabytes with suffix B",
+		args: "32mb",
+		want: 32 << 20,
+	}, {
+		name: "gigabytes",
+		args: "1G",
+		want: 1 << 30,
+	}, {
+		name:    "invalid size",
+		args:    "big",
+		wantErr: true,
+	}, {
+		name:    "negative size",
+		args:    "-5M",
+		wantErr: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseByteSize(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseByteSize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("parseByteSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	fp "path/filepath"
+	"strconv"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+	"github.com/go-shiori/shiori/internal/warc"
+)
+
+// ArchiveRequest is the request for creating offline archive of a bookmark.
+type ArchiveRequest struct {
+	DB          database.DB
+	Storage     storage.Storage
+	Bookmark    model.Bookmark
+	Content     []byte
+	ContentType string
+	LogArchival bool
+	Fetcher     *Fetcher
+	Quota       StorageQuota
+
+	// Captures are the sub resources captured while the content rendered by browser.
+	Captures []warc.Capture
+
+	// Browser is used to render the page if content is not specified
+	// and the bookmark should be rendered.
+	Browser *Browser
+}
+
+// ArchiveName returns name of the offline archive for the bookmark in storage.
+func ArchiveName(id int) string {
+	return "archive/" + strconv.Itoa(id)
+}
+
+// RemoveBookmarkFiles removes the thumbnails and offline archive of the bookmark.
+func RemoveBookmarkFiles(store storage.Storage, id int) {
+	RemoveThumbnails(store, id)
+	store.Remove(ArchiveName(id))
+}
+
+// CreateArchive creates offline archive of the bookmark, replacing the old one.
+// If content is not specified, the page is downloaded or rendered by browser first.
+func CreateArchive(req ArchiveRequest) error {
+	if err := CheckQuota(req.DB, req.Quota); err != nil {
+		return err
+	}
+
+	fetcher := req.Fetcher
+	if fetcher == nil {
+		var err error
+		if fetcher, err = NewFetcher(DefaultFetcherConfig()); err != nil {
+			return fmt.Errorf("failed to create fetcher: %v", err)
+		}
+	}
+
+	content, contentType, captures := req.Content, req.ContentType, req.Captures
+	if content == nil && ShouldRender(req.Browser, req.Bookmark) {
+		result, err := req.Browser.Render(req.Bookmark.URL, fetcher)
+		if err != nil {
+			return fmt.Errorf("failed to render page: %v", err)
+		}
+
+		content, contentType, captures = result.Content, result.ContentType, result.Resources
+	}
+
+	if content == nil {
+		resp, err := fetcher.Get(req.Bookmark.URL)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		// Don't archive the error page
+		if isBrokenStatus(resp.StatusCode) {
+			return fmt.Errorf("original returned %s", resp.Status)
+		}
+
+		if content, err = readAllLimited(resp.Body, fetcher.MaxBodySize()); err != nil {
+			return err
+		}
+
+		contentType = resp.Header.Get("Content-Type")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "shiori-archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archivePath := fp.Join(tmpDir, "archive")
+	archivalRequest := warc.ArchivalRequest{
+		URL:         req.Bookmark.URL,
+		Reader:      bytes.NewReader(content),
+		ContentType: contentType,
+		UserAgent:   fetcher.UserAgent(),
+		LogEnabled:  req.LogArchival,
+		Client:      fetcher,
+		Captures:    captures,
+	}
+
+	if err := warc.NewArchive(archivalRequest, archivePath); err != nil {
+		return fmt.Errorf("failed to create archive: %v", err)
+	}
+
+	return saveArchive(req, archivePath)
+}
+
+// saveArchive moves the shared resources of newly created archive into the shared store,
+// then saves it as the bookmark's archive if it's still within the quota. If it's not,
+// the bookmark keeps its old archive. The new sizes are recorded in database, so the
+// storage usage stays up to date. Failing to record them only makes the usage outdated
+// until `shiori storage stats` is run, so it's not fatal.
+func saveArchive(req ArchiveRequest, archivePath string) error {
+	// Failed deduplication leaves the archive as it is, so it's not fatal
+	resources := resourceStore{store: req.Storage, sizes: map[string]int64{}}
+	warc.Dedupe(archivePath, resources, sharedResourceMinSize)
+
+	if req.DB != nil && len(resources.sizes) > 0 {
+		req.DB.UpdateResourceSizes(resources.sizes, false)
+	}
+
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	if req.Quota.MaxArchive > 0 && size > req.Quota.MaxArchive {
+		return fmt.Errorf("archive size %s exceeds the limit %s",
+			FormatByteSize(size), FormatByteSize(req.Quota.MaxArchive))
+	}
+
+	if req.Quota.MaxTotal > 0 {
+		usage, err := getStorageUsage(req.DB)
+		if err != nil {
+			return err
+		}
+
+		total := usage.Total() - req.Bookmark.ArchiveSize + size
+		if total > req.Quota.MaxTotal {
+			return fmt.Errorf("archive storage quota exceeded (%s of %s used)",
+				FormatByteSize(total), FormatByteSize(req.Quota.MaxTotal))
+		}
+	}
+
+	if err = uploadArchive(req.Storage, req.Bookmark.ID, archivePath); err != nil {
+		return fmt.Errorf("failed to save archive: %v", err)
+	}
+
+	if req.DB != nil {
+		req.DB.UpdateArchiveSizes(map[int]int64{req.Bookmark.ID: size})
+	}
+
+	return nil
+}
+
+// ArchiveFormat is the format that offline archive can be exported to.
+type ArchiveFormat struct {
+	Name        string
+	Extension   string
+	ContentType string
+}
+
+// ArchiveFormats is the list of supported export formats, the first one is the default.
+var ArchiveFormats = []ArchiveFormat{
+	{Name: "warc.gz", Extension: ".warc.gz", ContentType: "application/gzip"},
+	{Name: "warc", Extension: ".warc", ContentType: "application/warc"},
+	{Name: "singlefile", Extension: ".html", ContentType: "text/html; charset=utf-8"},
+	{Name: "mhtml", Extension: ".mhtml", ContentType: "multipart/related"},
+}
+
+// GetArchiveFormat returns the export format with the specified name.
+// If name is empty, the default format is returned.
+func GetArchiveFormat(name string) (ArchiveFormat, bool) {
+	if name == "" {
+		return ArchiveFormats[0], true
+	}
+
+	for _, format := range ArchiveFormats {
+		if format.Name == name {
+			return format, true
+		}
+	}
+
+	return ArchiveFormat{}, false
+}
+
+// ArchiveFilename returns the file name for the exported archive of the bookmark.
+func ArchiveFilename(book model.Bookmark, format ArchiveFormat) string {
+	return fmt.Sprintf("shiori-%d%s", book.ID, format.Extension)
+}
+
+// ExportArchive writes offline archive of the bookmark in the specified format, i.e. as
+// WARC file, as single HTML file with the resources embedded, or as MHTML file.
+func ExportArchive(store storage.Storage, book model.Bookmark, format ArchiveFormat, w io.Writer) error {
+	info, err := store.Stat(ArchiveName(book.ID))
+	if err != nil {
+		return fmt.Errorf("archive doesn't exist")
+	}
+
+	archive, err := OpenArchive(store, book.ID)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer archive.Close()
+
+	switch format.Name {
+	case "warc", "warc.gz":
+		writer := warc.NewWriter(w, format.Name == "warc.gz")
+		err = writer.WriteInfo(ArchiveFilename(book, format), info.ModTime, map[string]string{
+			"software":   "shiori",
+			"format":     "WARC File Format 1.1",
+			"conformsTo": "http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/",
+			"title":      book.Title,
+		})
+		if err != nil {
+			return err
+		}
+
+		return archive.WriteWARC(writer, book.URL, info.ModTime)
+	case "singlefile":
+		return archive.WriteSingleFile(w)
+	case "mhtml":
+		return archive.WriteMHTML(w, book.URL, book.Title, info.ModTime)
+	default:
+		return fmt.Errorf("unknown archive format: %s", format.Name)
+	}
+}
+
+// ImportWARC creates offline archive of the bookmark from the page captured in WARC file,
+// replacing the old one. The sub resources are taken from the other captures.
+func ImportWARC(req ArchiveRequest, page warc.Capture, captures []warc.Capture) error {
+	if err := CheckQuota(req.DB, req.Quota); err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "shiori-archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archivePath := fp.Join(tmpDir, "archive")
+	if err := warc.NewArchiveFromCaptures(page, captures, archivePath, req.LogArchival); err != nil {
+		return fmt.Errorf("failed to create archive: %v", err)
+	}
+
+	return saveArchive(req, archivePath)
+}
@@ -0,0 +1,659 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	nurl "net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/warc"
+)
+
+const (
+	// browserViewportWidth and browserViewportHeight is the size of browser window.
+	browserViewportWidth  = 1280
+	browserViewportHeight = 800
+
+	// browserMaxScreenshotHeight is the maximum height of page screenshot,
+	// so very long page doesn't take too much memory.
+	browserMaxScreenshotHeight = 8000
+
+	// browserNetworkIdle is how long the network must be idle after page is loaded,
+	// before the page is considered as finished rendering.
+	browserNetworkIdle = 500 * time.Millisecond
+)
+
+// browserNames is the list of executable names looked up in PATH when
+// browser path is not specified.
+var browserNames = []string{
+	"chromium",
+	"chromium-browser",
+	"google-chrome",
+	"google-chrome-stable",
+	"chrome",
+	"headless_shell",
+}
+
+var rxDevToolsURL = regexp.MustCompile(`DevTools listening on (ws://\S+)`)
+
+// snapshotScript serializes the rendered page. Scripts are removed so the page isn't
+// rendered twice when archive is opened, and styles inserted by script are written
+// back into their element.
+const snapshotScript = `(() => {
+	const root = document.documentElement.cloneNode(true);
+	const styles = document.querySelectorAll("style");
+	const clonedStyles = root.querySelectorAll("style");
+	styles.forEach((style, i) => {
+		try {
+			if (style.sheet && clonedStyles[i]) {
+				clonedStyles[i].textContent = Array.from(style.sheet.cssRules).map(r => r.cssText).join("\n");
+			}
+		} catch (e) {}
+	});
+
+	root.querySelectorAll('script:not([type="application/ld+json"]), noscript').forEach(e => e.remove());
+
+	const doctype = document.doctype ? new XMLSerializer().serializeToString(document.doctype) : "";
+	return {url: location.href, contentType: document.contentType, html: doctype + root.outerHTML};
+})()`
+
+// BrowserConfig is the configuration for rendering pages in headless browser.
+type BrowserConfig struct {
+	// ExecPath is path of Chromium or Chrome executable. If empty,
+	// the common browser names are looked up in PATH.
+	ExecPath string
+
+	// Domains is the list of domains whose pages are always rendered, e.g. for
+	// sites that rendered client-side. Their subdomains are matched as well.
+	Domains []string
+
+	// Timeout is the maximum time for rendering a page.
+	Timeout time.Duration
+
+	// MaxConcurrent is the maximum number of pages rendered at the same time.
+	MaxConcurrent int
+}
+
+// Browser renders pages with JavaScript using headless Chromium, which is
+// controlled using Chrome DevTools Protocol. New browser process is started
+// for each page, so nothing is shared between them.
+type Browser struct {
+	execPath  string
+	domains   []string
+	timeout   time.Duration
+	semaphore chan struct{}
+}
+
+// RenderResult is the page rendered by browser.
+type RenderResult struct {
+	// URL is the final URL of the page, after redirects.
+	URL         string
+	ContentType string
+
+	// Content is snapshot of the rendered DOM.
+	Content []byte
+
+	// Resources are sub resources that loaded while rendering the page.
+	Resources []warc.Capture
+
+	// Screenshot is the full-page screenshot, limited to 8000 pixels height.
+	Screenshot image.Image
+}
+
+// NewBrowser creates browser using the specified config.
+func NewBrowser(cfg BrowserConfig) (*Browser, error) {
+	execPath := cfg.ExecPath
+	if execPath == "" {
+		for _, name := range browserNames {
+			if path, err := exec.LookPath(name); err == nil {
+				execPath = path
+				break
+			}
+		}
+
+		if execPath == "" {
+			return nil, fmt.Errorf("chromium is not found")
+		}
+	} else if path, err := exec.LookPath(execPath); err != nil {
+		return nil, fmt.Errorf("browser %q is not found: %v", execPath, err)
+	} else {
+		execPath = path
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 2
+	}
+
+	domains := make([]string, 0, len(cfg.Domains))
+	for _, domain := range cfg.Domains {
+		domain = strings.ToLower(strings.Trim(strings.TrimSpace(domain), "."))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	return &Browser{
+		execPath:  execPath,
+		domains:   domains,
+		timeout:   cfg.Timeout,
+		semaphore: make(chan struct{}, cfg.MaxConcurrent),
+	}, nil
+}
+
+// Match checks if the URL matches the domain rules, so it always rendered.
+func (b *Browser) Match(url string) bool {
+	if b == nil {
+		return false
+	}
+
+	parsedURL, err := nurl.Parse(url)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(parsedURL.Hostname())
+	for _, domain := range b.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ShouldRender checks if the bookmark should be rendered by browser, either because
+// it's requested for the bookmark or its URL matches the domain rules.
+func ShouldRender(b *Browser, book model.Bookmark) bool {
+	return book.RenderJS || b.Match(book.URL)
+}
+
+// Render opens the URL in new browser process, waits until the page finished loading,
+// then takes snapshot of its DOM, the loaded resources and its screenshot. The fetcher
+// is used for its user agent and to check the addresses connected by the page.
+func (b *Browser) Render(url string, fetcher *Fetcher) (RenderResult, error) {
+	if b == nil {
+		return RenderResult{}, fmt.Errorf("headless browser is not available")
+	}
+
+	if err := fetcher.CheckURL(url); err != nil {
+		return RenderResult{}, err
+	}
+
+	b.semaphore <- struct{}{}
+	defer func() { <-b.semaphore }()
+
+	// Start the browser with clean profile
+	profileDir, err := os.MkdirTemp("", "shiori-browser-*")
+	if err != nil {
+		return RenderResult{}, err
+	}
+	defer os.RemoveAll(profileDir)
+
+	args := []string{
+		"--headless",
+		"--disable-gpu",
+		"--disable-extensions",
+		"--disable-sync",
+		"--disable-background-networking",
+		"--no-first-run",
+		"--no-default-browser-check",
+		"--hide-scrollbars",
+		"--mute-audio",
+		"--remote-debugging-port=0",
+		"--user-data-dir=" + profileDir,
+	}
+
+	// If private addresses are denied, the browser connects through proxy that uses fetcher's
+	// dialer, so the address is checked after it's resolved, including for WebSocket. By default
+	// Chromium doesn't use proxy for loopback addresses, so that has to be disabled as well.
+	if fetcher.denyPrivateIPs {
+		proxy, err := startGuardedProxy(fetcher.dialContext)
+		if err != nil {
+			return RenderResult{}, fmt.Errorf("failed to start proxy: %v", err)
+		}
+		defer proxy.Close()
+
+		args = append(args,
+			"--proxy-server="+proxy.URL(),
+			"--proxy-bypass-list=<-loopback>",
+			"--force-webrtc-ip-handling-policy=disable_non_proxied_udp")
+	}
+
+	// Chromium refuses to run as root with sandbox, e.g. in Docker container
+	if os.Geteuid() == 0 {
+		args = append(args, "--no-sandbox")
+	}
+
+	cmd := exec.Command(b.execPath, append(args, "about:blank")...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return RenderResult{}, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return RenderResult{}, fmt.Errorf("failed to start browser: %v", err)
+	}
+
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	wsURL, err := waitDevToolsURL(stderr, b.timeout)
+	if err != nil {
+		return RenderResult{}, err
+	}
+
+	// Connect and render the page
+	page := newRenderedPage(fetcher)
+	conn, err := dialCDP(wsURL, b.timeout, page.handleEvent)
+	if err != nil {
+		return RenderResult{}, fmt.Errorf("failed to connect to browser: %v", err)
+	}
+	defer conn.Close()
+
+	page.conn = conn
+	result, err := page.render(url, b.timeout)
+	conn.Call("", "Browser.close", nil, nil)
+
+	return result, err
+}
+
+// waitDevToolsURL reads the websocket URL printed by browser once it's ready.
+func waitDevToolsURL(stderr io.Reader, timeout time.Duration) (string, error) {
+	chURL := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			if match := rxDevToolsURL.FindStringSubmatch(scanner.Text()); match != nil {
+				chURL <- match[1]
+				break
+			}
+		}
+
+		close(chURL)
+		io.Copy(io.Discard, stderr)
+	}()
+
+	select {
+	case wsURL, ok := <-chURL:
+		if !ok {
+			return "", fmt.Errorf("browser exited before it's ready")
+		}
+		return wsURL, nil
+	case <-time.After(timeout):
+		return "", fmt.Errorf("timeout while waiting browser to start")
+	}
+}
+
+// renderedPage is the page that being rendered. It tracks the network
+// requests to know when the page finished loading.
+type renderedPage struct {
+	conn      *cdpConn
+	fetcher   *Fetcher
+	sessionID string
+
+	mx           sync.Mutex
+	loaded       bool
+	inflight     map[string]struct{}
+	lastActivity time.Time
+	responses    map[string]renderedResponse
+	finished     []string
+}
+
+type renderedResponse struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	MimeType string `json:"mimeType"`
+}
+
+type cdpEvent struct {
+	RequestID string `json:"requestId"`
+	Request   struct {
+		URL string `json:"url"`
+	} `json:"request"`
+	Response renderedResponse `json:"response"`
+}
+
+func newRenderedPage(fetcher *Fetcher) *renderedPage {
+	return &renderedPage{
+		fetcher:      fetcher,
+		inflight:     map[string]struct{}{},
+		responses:    map[string]renderedResponse{},
+		lastActivity: time.Now(),
+	}
+}
+
+func (p *renderedPage) render(url string, timeout time.Duration) (RenderResult, error) {
+	deadline := time.Now().Add(timeout)
+
+	// Open new tab
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	err := p.conn.Call("", "Target.createTarget", map[string]interface{}{"url": "about:blank"}, &target)
+	if err != nil {
+		return RenderResult{}, err
+	}
+
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	err = p.conn.Call("", "Target.attachToTarget", map[string]interface{}{
+		"targetId": target.TargetID,
+		"flatten":  true,
+	}, &session)
+	if err != nil {
+		return RenderResult{}, err
+	}
+
+	p.mx.Lock()
+	p.sessionID = session.SessionID
+	p.mx.Unlock()
+
+	// Prepare the tab
+	var maxBodySize int64 = 32 << 20
+	if p.fetcher.MaxBodySize() > 0 {
+		maxBodySize = p.fetcher.MaxBodySize()
+	}
+
+	commands := []struct {
+		method string
+		params interface{}
+	}{
+		{"Network.enable", map[string]interface{}{
+			"maxTotalBufferSize":    maxBodySize * 4,
+			"maxResourceBufferSize": maxBodySize,
+		}},
+		{"Network.setUserAgentOverride", map[string]interface{}{"userAgent": p.fetcher.UserAgent()}},
+		{"Fetch.enable", map[string]interface{}{}},
+		{"Page.enable", nil},
+		{"Emulation.setDeviceMetricsOverride", map[string]interface{}{
+			"width":             browserViewportWidth,
+			"height":            browserViewportHeight,
+			"deviceScaleFactor": 1,
+			"mobile":            false,
+		}},
+	}
+
+	for _, command := range commands {
+		if err = p.conn.Call(session.SessionID, command.method, command.params, nil); err != nil {
+			return RenderResult{}, err
+		}
+	}
+
+	// Open the page and wait until it finished loading
+	var navigation struct {
+		LoaderID  string `json:"loaderId"`
+		ErrorText string `json:"errorText"`
+	}
+	err = p.conn.Call(session.SessionID, "Page.navigate", map[string]interface{}{"url": url}, &navigation)
+	if err != nil {
+		return RenderResult{}, err
+	}
+
+	if navigation.ErrorText != "" {
+		return RenderResult{}, fmt.Errorf("failed to open page: %s", navigation.ErrorText)
+	}
+
+	for time.Now().Before(deadline) && !p.isIdle() {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Don't render the error page
+	p.mx.Lock()
+	document, hasDocument := p.responses[navigation.LoaderID]
+	p.mx.Unlock()
+
+	if hasDocument && isBrokenStatus(document.Status) {
+		return RenderResult{}, fmt.Errorf("original returned status %d", document.Status)
+	}
+
+	// Take snapshot of the page
+	var evaluation struct {
+		Result struct {
+			Value struct {
+				URL         string `json:"url"`
+				ContentType string `json:"contentType"`
+				HTML        string `json:"html"`
+			} `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	err = p.conn.Call(session.SessionID, "Runtime.evaluate", map[string]interface{}{
+		"expression":    snapshotScript,
+		"returnByValue": true,
+	}, &evaluation)
+	if err != nil {
+		return RenderResult{}, err
+	}
+
+	if evaluation.ExceptionDetails != nil {
+		return RenderResult{}, fmt.Errorf("failed to take snapshot: %s", evaluation.ExceptionDetails.Text)
+	}
+
+	snapshot := evaluation.Result.Value
+	result := RenderResult{
+		URL:         snapshot.URL,
+		ContentType: snapshot.ContentType,
+		Content:     []byte(snapshot.HTML),
+	}
+
+	if result.ContentType == "text/html" {
+		result.ContentType = "text/html; charset=utf-8"
+	}
+
+	// Screenshot is optional, so the error is ignored
+	result.Screenshot, _ = p.screenshot()
+	result.Resources = p.resources(navigation.LoaderID, maxBodySize)
+
+	return result, nil
+}
+
+// handleEvent tracks the network requests of the page. Paused requests are checked
+// in separate goroutine, since command can't be sent while handling event.
+func (p *renderedPage) handleEvent(msg cdpMessage) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if msg.SessionID == "" || msg.SessionID != p.sessionID {
+		return
+	}
+
+	var event cdpEvent
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &event); err != nil {
+			return
+		}
+	}
+
+	p.lastActivity = time.Now()
+
+	switch msg.Method {
+	case "Page.loadEventFired":
+		p.loaded = true
+	case "Network.requestWillBeSent":
+		p.inflight[event.RequestID] = struct{}{}
+	case "Network.responseReceived":
+		p.responses[event.RequestID] = event.Response
+	case "Network.loadingFinished":
+		delete(p.inflight, event.RequestID)
+		p.finished = append(p.finished, event.RequestID)
+	case "Network.loadingFailed":
+		delete(p.inflight, event.RequestID)
+	case "Fetch.requestPaused":
+		go p.continueRequest(msg.SessionID, event.RequestID, event.Request.URL)
+	}
+}
+
+// continueRequest lets the browser send the request, unless the URL is denied by fetcher.
+// It only fails the denied requests early, the connections are checked by the proxy.
+func (p *renderedPage) continueRequest(sessionID, requestID, url string) {
+	if strings.HasPrefix(url, "http:") || strings.HasPrefix(url, "https:") {
+		if err := p.fetcher.CheckURL(url); err != nil {
+			p.conn.Call(sessionID, "Fetch.failRequest", map[string]interface{}{
+				"requestId":   requestID,
+				"errorReason": "AccessDenied",
+			}, nil)
+			return
+		}
+	}
+
+	p.conn.Call(sessionID, "Fetch.continueRequest", map[string]interface{}{"requestId": requestID}, nil)
+}
+
+// isIdle checks if the page is loaded and there are no network activity for a while.
+func (p *renderedPage) isIdle() bool {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	return p.loaded && len(p.inflight) == 0 && time.Since(p.lastActivity) >= browserNetworkIdle
+}
+
+// screenshot captures the whole page, up to the maximum height.
+func (p *renderedPage) screenshot() (image.Image, error) {
+	type size struct {
+		Width  float64 `json:"width"`
+		Height float64 `json:"height"`
+	}
+
+	var metrics struct {
+		ContentSize    *size `json:"contentSize"`
+		CSSContentSize *size `json:"cssContentSize"`
+	}
+	if err := p.conn.Call(p.sessionID, "Page.getLayoutMetrics", nil, &metrics); err != nil {
+		return nil, err
+	}
+
+	contentSize := size{Width: browserViewportWidth, Height: browserViewportHeight}
+	if metrics.CSSContentSize != nil {
+		contentSize = *metrics.CSSContentSize
+	} else if metrics.ContentSize != nil {
+		contentSize = *metrics.ContentSize
+	}
+
+	if contentSize.Width < 1 || contentSize.Height < 1 {
+		contentSize = size{Width: browserViewportWidth, Height: browserViewportHeight}
+	}
+
+	// Limit both sides, since the whole screenshot is decoded in memory
+	if contentSize.Width > browserViewportWidth {
+		contentSize.Width = browserViewportWidth
+	}
+
+	if contentSize.Height > browserMaxScreenshotHeight {
+		contentSize.Height = browserMaxScreenshotHeight
+	}
+
+	var capture struct {
+		Data string `json:"data"`
+	}
+	err := p.conn.Call(p.sessionID, "Page.captureScreenshot", map[string]interface{}{
+		"format":                "png",
+		"captureBeyondViewport": true,
+		"clip": map[string]interface{}{
+			"x":      0,
+			"y":      0,
+			"width":  contentSize.Width,
+			"height": contentSize.Height,
+			"scale":  1,
+		},
+	}, &capture)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(capture.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return png.Decode(bytes.NewReader(data))
+}
+
+// resources returns content of the sub resources that successfully loaded by the page.
+func (p *renderedPage) resources(documentID string, maxSize int64) []warc.Capture {
+	p.mx.Lock()
+	finished := append([]string{}, p.finished...)
+	responses := make(map[string]renderedResponse, len(p.responses))
+	for id, resp := range p.responses {
+		responses[id] = resp
+	}
+	p.mx.Unlock()
+
+	var captures []warc.Capture
+	for _, requestID := range finished {
+		resp, exist := responses[requestID]
+		if !exist || requestID == documentID || resp.Status < 200 || resp.Status >= 300 {
+			continue
+		}
+
+		if !strings.HasPrefix(resp.URL, "http:") && !strings.HasPrefix(resp.URL, "https:") {
+			continue
+		}
+
+		var body struct {
+			Body          string `json:"body"`
+			Base64Encoded bool   `json:"base64Encoded"`
+		}
+		err := p.conn.Call(p.sessionID, "Network.getResponseBody", map[string]interface{}{"requestId": requestID}, &body)
+		if err != nil {
+			continue
+		}
+
+		// Text body is already decoded by browser into UTF-8
+		content := []byte(body.Body)
+		contentType := resp.MimeType
+		if body.Base64Encoded {
+			if content, err = base64.StdEncoding.DecodeString(body.Body); err != nil {
+				continue
+			}
+		} else if contentType != "" {
+			contentType = mime.FormatMediaType(contentType, map[string]string{"charset": "utf-8"})
+		}
+
+		if maxSize > 0 && int64(len(content)) > maxSize {
+			continue
+		}
+
+		captures = append(captures, warc.Capture{
+			URL:         resp.URL,
+			ContentType: contentType,
+			Content:     content,
+		})
+	}
+
+	return captures
+}
+
+// pageTopThumbnail crops the top of full-page screenshot, so it has the same
+// aspect ratio as the default thumbnail.
+func pageTopThumbnail(screenshot image.Image) image.Image {
+	bounds := screenshot.Bounds()
+	height := bounds.Dx() * ThumbnailGrid.Height / ThumbnailGrid.Width
+	if height >= bounds.Dy() {
+		return screenshot
+	}
+
+	return imaging.Crop(screenshot, image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y+height))
+}
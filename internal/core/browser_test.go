@@ -0,0 +1,247 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	fp "path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+	"github.com/go-shiori/shiori/internal/warc"
+	"golang.org/x/net/websocket"
+)
+
+// fakeDevTools emulates the DevTools protocol of browser that renders
+// a page with a single image.
+type fakeDevTools struct {
+	pageURL string
+	image   []byte
+
+	mx        sync.Mutex
+	continued []string
+	clipWidth float64
+}
+
+func (f *fakeDevTools) serve(ws *websocket.Conn) {
+	var sendMx sync.Mutex
+	send := func(msg cdpMessage) {
+		sendMx.Lock()
+		defer sendMx.Unlock()
+		websocket.JSON.Send(ws, msg)
+	}
+
+	event := func(method string, params string) {
+		send(cdpMessage{SessionID: "S", Method: method, Params: json.RawMessage(params)})
+	}
+
+	imageURL := strings.TrimSuffix(f.pageURL, "/app") + "/image.png"
+	for {
+		var msg cdpMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return
+		}
+
+		var result interface{} = map[string]interface{}{}
+		switch msg.Method {
+		case "Target.createTarget":
+			result = map[string]string{"targetId": "T"}
+		case "Target.attachToTarget":
+			result = map[string]string{"sessionId": "S"}
+		case "Fetch.continueRequest":
+			var params struct{ RequestID string }
+			json.Unmarshal(msg.Params, &params)
+			f.mx.Lock()
+			f.continued = append(f.continued, params.RequestID)
+			f.mx.Unlock()
+		case "Page.navigate":
+			send(cdpMessage{ID: msg.ID, Result: json.RawMessage(`{"frameId":"F","loaderId":"L"}`)})
+			event("Fetch.requestPaused", fmt.Sprintf(`{"requestId":"P1","request":{"url":%q}}`, imageURL))
+			event("Network.requestWillBeSent", `{"requestId":"L"}`)
+			event("Network.responseReceived", fmt.Sprintf(`{"requestId":"L","response":{"url":%q,"status":200,"mimeType":"text/html"}}`, f.pageURL))
+			event("Network.loadingFinished", `{"requestId":"L"}`)
+			event("Network.requestWillBeSent", `{"requestId":"R1"}`)
+			event("Network.responseReceived", fmt.Sprintf(`{"requestId":"R1","response":{"url":%q,"status":200,"mimeType":"image/png"}}`, imageURL))
+			event("Network.loadingFinished", `{"requestId":"R1"}`)
+			event("Page.loadEventFired", `{}`)
+			continue
+		case "Runtime.evaluate":
+			result = map[string]interface{}{
+				"result": map[string]interface{}{
+					"value": map[string]string{
+						"url":         f.pageURL,
+						"contentType": "text/html",
+						"html":        `<!DOCTYPE html><html><head><title>App</title></head><body><h1>Rendered by script</h1><img src="image.png"></body></html>`,
+					},
+				},
+			}
+		case "Page.getLayoutMetrics":
+			result = map[string]interface{}{"cssContentSize": map[string]int{"width": 5000, "height": 900}}
+		case "Page.captureScreenshot":
+			var params struct{ Clip struct{ Width float64 } }
+			json.Unmarshal(msg.Params, &params)
+			f.mx.Lock()
+			f.clipWidth = params.Clip.Width
+			f.mx.Unlock()
+			result = map[string]string{"data": base64.StdEncoding.EncodeToString(f.image)}
+		case "Network.getResponseBody":
+			result = map[string]interface{}{"body": base64.StdEncoding.EncodeToString(f.image), "base64Encoded": true}
+		}
+
+		rawResult, _ := json.Marshal(result)
+		send(cdpMessage{ID: msg.ID, Result: rawResult})
+	}
+}
+
+func TestBrowserRender(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake browser is a shell script")
+	}
+
+	var imageData bytes.Buffer
+	png.Encode(&imageData, image.NewRGBA(image.Rect(0, 0, 300, 900)))
+
+	devTools := &fakeDevTools{pageURL: "http://example.com/app", image: imageData.Bytes()}
+	// Like Chromium, connection with Origin header is rejected
+	server := httptest.NewServer(websocket.Server{
+		Handler: devTools.serve,
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			if r.Header.Get("Origin") != "" {
+				return fmt.Errorf("origin %s is not allowed", r.Header.Get("Origin"))
+			}
+			return nil
+		},
+	})
+	defer server.Close()
+
+	// The fake browser only prints the DevTools URL, like Chromium does once it's ready
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/devtools/browser/test"
+	execPath := fp.Join(t.TempDir(), "chromium")
+	script := fmt.Sprintf("#!/bin/sh\necho 'DevTools listening on %s' >&2\nexec sleep 60\n", wsURL)
+	if err := os.WriteFile(execPath, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	browser, err := NewBrowser(BrowserConfig{ExecPath: execPath, Domains: []string{"example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetcher, err := NewFetcher(DefaultFetcherConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := browser.Render(devTools.pageURL, fetcher)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	if !strings.Contains(string(result.Content), "Rendered by script") || result.ContentType != "text/html; charset=utf-8" {
+		t.Errorf("Render() content = %q (%s)", result.Content, result.ContentType)
+	}
+
+	if len(result.Resources) != 1 || result.Resources[0].URL != "http://example.com/image.png" ||
+		!bytes.Equal(result.Resources[0].Content, imageData.Bytes()) {
+		t.Errorf("Render() resources = %v", result.Resources)
+	}
+
+	if result.Screenshot == nil || result.Screenshot.Bounds().Dy() != 900 {
+		t.Errorf("Render() screenshot = %v", result.Screenshot)
+	} else if thumb := pageTopThumbnail(result.Screenshot); thumb.Bounds().Dx() != 300 || thumb.Bounds().Dy() != 200 {
+		t.Errorf("pageTopThumbnail() size = %v", thumb.Bounds())
+	}
+
+	devTools.mx.Lock()
+	if strings.Join(devTools.continued, ",") != "P1" {
+		t.Errorf("continued requests = %v", devTools.continued)
+	}
+	if devTools.clipWidth != browserViewportWidth {
+		t.Errorf("screenshot width = %v, want %v", devTools.clipWidth, browserViewportWidth)
+	}
+	devTools.mx.Unlock()
+
+	// Archive uses the rendered page and its captured image
+	store := storage.NewLocal(t.TempDir())
+	err = CreateArchive(ArchiveRequest{
+		Storage:  store,
+		Bookmark: model.Bookmark{ID: 1, URL: devTools.pageURL},
+		Fetcher:  fetcher,
+		Browser:  browser,
+	})
+	if err != nil {
+		t.Fatalf("CreateArchive() error = %v", err)
+	}
+
+	archive, err := OpenArchive(store, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	root, err := readArchived(archive, "archive-root")
+	if err != nil || !bytes.Contains(root, []byte("Rendered by script")) {
+		t.Errorf("archived page = %q (%v)", root, err)
+	}
+
+	content, err := readArchived(archive, warc.ResourceName("http://example.com/image.png"))
+	if err != nil || !bytes.Equal(content, imageData.Bytes()) {
+		t.Errorf("archived image has %d bytes (%v)", len(content), err)
+	}
+}
+
+// readArchived reads the uncompressed content of archived resource.
+func readArchived(archive *warc.Archive, name string) ([]byte, error) {
+	content, _, err := archive.Read(name)
+	if err != nil {
+		return nil, err
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(gzipReader)
+}
+
+func TestBrowserMatch(t *testing.T) {
+	browser := &Browser{domains: []string{"app.example.com", "spa.io"}}
+
+	tests := map[string]bool{
+		"https://app.example.com/page":   true,
+		"https://APP.example.com/page":   true,
+		"https://www.spa.io/":            true,
+		"https://example.com/":           false,
+		"https://notspa.io/":             false,
+		"https://spa.io.evil.com/":       false,
+		"https://app.example.com:8080/x": true,
+	}
+
+	for url, want := range tests {
+		if got := browser.Match(url); got != want {
+			t.Errorf("Match(%q) = %v, want %v", url, got, want)
+		}
+	}
+
+	var noBrowser *Browser
+	if ShouldRender(noBrowser, model.Bookmark{URL: "https://spa.io/"}) {
+		t.Error("ShouldRender() without browser = true")
+	}
+
+	if !ShouldRender(noBrowser, model.Bookmark{URL: "https://spa.io/", RenderJS: true}) {
+		t.Error("ShouldRender() for requested bookmark = false")
+	}
+}
@@ -0,0 +1,398 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+)
+
+// Types of bulk action.
+const (
+	BulkTag     = "tag"
+	BulkUntag   = "untag"
+	BulkPublic  = "public"
+	BulkPrivate = "private"
+	BulkRefetch = "refetch"
+	BulkDelete  = "delete"
+)
+
+// Status of bookmark after bulk actions.
+const (
+	BulkUpdated = "updated"
+	BulkDeleted = "deleted"
+	BulkFailed  = "failed"
+)
+
+// DefaultBulkBatchSize is the number of bookmarks saved in a single transaction.
+const DefaultBulkBatchSize = 100
+
+// BulkAction is an action applied to many bookmarks at once. Tags is used by
+// tag and untag, while KeepMetadata and CreateArchive are used by refetch.
+type BulkAction struct {
+	Type          string   `json:"type"`
+	Tags          []string `json:"tags,omitempty"`
+	KeepMetadata  bool     `json:"keepMetadata,omitempty"`
+	CreateArchive bool     `json:"createArchive,omitempty"`
+}
+
+// BulkResult is the result of bulk actions for a single bookmark.
+type BulkResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkRequest is the request for applying actions to bookmarks in bulk. The
+// bookmarks are selected by IDs, or by Filter if there are no IDs.
+type BulkRequest struct {
+	DB            database.DB
+	Storage       storage.Storage
+	IDs           []int
+	Filter        database.GetBookmarksOptions
+	Actions       []BulkAction
+	BatchSize     int
+	Concurrency   int
+	Canonicalizer *Canonicalizer
+	Fetcher       *Fetcher
+	Extractors    *ExtractorRegistry
+	Browser       *Browser
+	Quota         StorageQuota
+
+	// OnResult is called for each bookmark once its batch is finished.
+	OnResult func(BulkResult)
+}
+
+// ValidateBulkActions makes sure the actions are known and complete. Delete
+// can't be combined with other actions, since it makes them pointless.
+func ValidateBulkActions(actions []BulkAction) error {
+	if len(actions) == 0 {
+		return fmt.Errorf("no action specified")
+	}
+
+	for _, action := range actions {
+		switch action.Type {
+		case BulkTag, BulkUntag:
+			if len(cleanTagNames(action.Tags)) == 0 {
+				return fmt.Errorf("%s action needs tags", action.Type)
+			}
+		case BulkDelete:
+			if len(actions) > 1 {
+				return fmt.Errorf("delete action can't be combined with other actions")
+			}
+		case BulkPublic, BulkPrivate, BulkRefetch:
+		default:
+			return fmt.Errorf("unknown action %q", action.Type)
+		}
+	}
+
+	return nil
+}
+
+// SelectBulkIDs returns IDs of the bookmarks selected by the request.
+func SelectBulkIDs(req BulkRequest) ([]int, error) {
+	if len(req.IDs) > 0 {
+		ids := []int{}
+		seen := map[int]struct{}{}
+		for _, id := range req.IDs {
+			if _, exist := seen[id]; !exist {
+				seen[id] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	}
+
+	filter := req.Filter
+	filter.WithContent = false
+	filter.Limit, filter.Offset = 0, 0
+	filter.OrderMethod = database.DefaultOrder
+
+	bookmarks, err := req.DB.GetBookmarks(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search bookmarks: %v", err)
+	}
+
+	ids := make([]int, len(bookmarks))
+	for i, book := range bookmarks {
+		ids[i] = book.ID
+	}
+
+	return ids, nil
+}
+
+// RunBulk applies the actions to the selected bookmarks. The bookmarks are
+// processed in batches, each of them saved in a single transaction, so a
+// failed batch doesn't undo the previous ones.
+func RunBulk(req BulkRequest) ([]BulkResult, error) {
+	if err := ValidateBulkActions(req.Actions); err != nil {
+		return nil, err
+	}
+
+	ids, err := SelectBulkIDs(req)
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBulkBatchSize
+	}
+
+	results := []BulkResult{}
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		batchResults := runBulkBatch(req, ids[start:end])
+		for _, result := range batchResults {
+			if req.OnResult != nil {
+				req.OnResult(result)
+			}
+		}
+
+		results = append(results, batchResults...)
+	}
+
+	return results, nil
+}
+
+// runBulkBatch applies the actions to a single batch of bookmarks.
+func runBulkBatch(req BulkRequest, ids []int) []BulkResult {
+	results := make([]BulkResult, len(ids))
+	indexes := map[int]int{}
+	for i, id := range ids {
+		results[i] = BulkResult{ID: id}
+		indexes[id] = i
+	}
+
+	failAll := func(err error) []BulkResult {
+		for i := range results {
+			if results[i].Status == "" {
+				results[i].Status = BulkFailed
+				results[i].Error = err.Error()
+			}
+		}
+		return results
+	}
+
+	// Fetch the bookmarks of this batch
+	bookmarks, err := req.DB.GetBookmarks(database.GetBookmarksOptions{
+		IDs:         ids,
+		WithContent: true,
+	})
+	if err != nil {
+		return failAll(fmt.Errorf("failed to fetch bookmarks: %v", err))
+	}
+
+	found := map[int]struct{}{}
+	for _, book := range bookmarks {
+		found[book.ID] = struct{}{}
+	}
+
+	for i, id := range ids {
+		if _, exist := found[id]; !exist {
+			results[i].Status = BulkFailed
+			results[i].Error = "bookmark is not found"
+		}
+	}
+
+	// Delete is never combined with other actions
+	if req.Actions[0].Type == BulkDelete {
+		foundIDs := make([]int, len(bookmarks))
+		for i, book := range bookmarks {
+			foundIDs[i] = book.ID
+		}
+
+		if err = req.DB.DeleteBookmarks(foundIDs...); err != nil {
+			return failAll(fmt.Errorf("failed to delete bookmarks: %v", err))
+		}
+
+		for _, id := range foundIDs {
+			RemoveBookmarkFiles(req.Storage, id)
+			results[indexes[id]].Status = BulkDeleted
+		}
+
+		return results
+	}
+
+	// Refetch first, so the other actions are applied to the new content
+	for _, action := range req.Actions {
+		if action.Type == BulkRefetch {
+			bookmarks = refetchBulk(req, action, bookmarks, results, indexes)
+		}
+	}
+
+	archived := []model.Bookmark{}
+	for i := range bookmarks {
+		for _, action := range req.Actions {
+			applyBulkAction(&bookmarks[i], action)
+		}
+
+		if bookmarks[i].CreateArchive {
+			archived = append(archived, bookmarks[i])
+		}
+	}
+
+	if len(bookmarks) > 0 {
+		if _, err = req.DB.SaveBookmarks(bookmarks...); err != nil {
+			return failAll(fmt.Errorf("failed to save bookmarks: %v", err))
+		}
+	}
+
+	if len(archived) > 0 {
+		sizes := map[int]int64{}
+		for _, book := range archived {
+			sizes[book.ID] = ArchiveSize(req.Storage, book.ID)
+		}
+		req.DB.UpdateArchiveSizes(sizes)
+	}
+
+	for _, book := range bookmarks {
+		results[indexes[book.ID]].Status = BulkUpdated
+	}
+
+	return results
+}
+
+// refetchBulk downloads and processes the bookmarks again. Bookmarks that failed
+// are marked in results and removed from the returned list, so they're not saved.
+func refetchBulk(req BulkRequest, action BulkAction, bookmarks []model.Bookmark, results []BulkResult, indexes map[int]int) []model.Bookmark {
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+
+	mx := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, concurrency)
+	failed := map[int]struct{}{}
+
+	for i := range bookmarks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			book := bookmarks[i]
+			book.CreateArchive = action.CreateArchive
+			result, isFatalErr, err := refetchBookmark(req, action, book)
+
+			mx.Lock()
+			defer mx.Unlock()
+			if err != nil && isFatalErr {
+				failed[book.ID] = struct{}{}
+				results[indexes[book.ID]].Status = BulkFailed
+				results[indexes[book.ID]].Error = err.Error()
+				return
+			}
+
+			// Non-fatal error, e.g. failed archival, is reported while the bookmark is still updated
+			if err != nil {
+				results[indexes[book.ID]].Error = err.Error()
+			}
+			bookmarks[i] = result
+		}(i)
+	}
+
+	wg.Wait()
+
+	succeeded := []model.Bookmark{}
+	for _, book := range bookmarks {
+		if _, isFailed := failed[book.ID]; !isFailed {
+			succeeded = append(succeeded, book)
+		}
+	}
+
+	return succeeded
+}
+
+// refetchBookmark downloads and processes the bookmark again. Like ProcessBookmark,
+// the error is not fatal if the bookmark is still processed and can be saved.
+func refetchBookmark(req BulkRequest, action BulkAction, book model.Bookmark) (model.Bookmark, bool, error) {
+	fetcher := req.Fetcher
+	if fetcher == nil {
+		var err error
+		fetcher, err = NewFetcher(DefaultFetcherConfig())
+		if err != nil {
+			return book, true, err
+		}
+	}
+
+	content, contentType, err := fetcher.DownloadBookmark(book.URL)
+	if err != nil {
+		return book, true, fmt.Errorf("failed to download: %v", err)
+	}
+	defer content.Close()
+
+	result, isFatalErr, err := ProcessBookmark(ProcessRequest{
+		DB:            req.DB,
+		Storage:       req.Storage,
+		Bookmark:      book,
+		Content:       content,
+		ContentType:   contentType,
+		KeepTitle:     action.KeepMetadata,
+		KeepExcerpt:   action.KeepMetadata,
+		Canonicalizer: req.Canonicalizer,
+		Fetcher:       fetcher,
+		Extractors:    req.Extractors,
+		Browser:       req.Browser,
+		Quota:         req.Quota,
+	})
+	if err != nil && isFatalErr {
+		return book, true, fmt.Errorf("failed to process: %v", err)
+	}
+
+	return result, false, err
+}
+
+// applyBulkAction applies the action that only changes the bookmark's fields.
+func applyBulkAction(book *model.Bookmark, action BulkAction) {
+	switch action.Type {
+	case BulkPublic:
+		book.Public = 1
+	case BulkPrivate:
+		book.Public = 0
+	case BulkTag:
+		for _, name := range cleanTagNames(action.Tags) {
+			exist := false
+			for i, tag := range book.Tags {
+				if tag.Name == name {
+					book.Tags[i].Deleted = false
+					exist = true
+					break
+				}
+			}
+
+			if !exist {
+				book.Tags = append(book.Tags, model.Tag{Name: name})
+			}
+		}
+	case BulkUntag:
+		for _, name := range cleanTagNames(action.Tags) {
+			for i, tag := range book.Tags {
+				if tag.Name == name {
+					book.Tags[i].Deleted = true
+				}
+			}
+		}
+	}
+}
+
+// cleanTagNames normalizes the tag names like database does, and removes the empty ones.
+func cleanTagNames(names []string) []string {
+	cleaned := []string{}
+	for _, name := range names {
+		name = database.NormalizeTagName(name)
+		if name != "" {
+			cleaned = append(cleaned, name)
+		}
+	}
+
+	return cleaned
+}
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	fp "path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+)
+
+func TestRunBulk(t *testing.T) {
+	db, err := database.OpenSQLiteDatabase(fp.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for id := 1; id <= 5; id++ {
+		book := model.Bookmark{
+			ID:    id,
+			URL:   fmt.Sprintf("https://example.com/%d", id),
+			Title: fmt.Sprintf("Example %d", id),
+			Tags:  []model.Tag{{Name: "old"}},
+		}
+		if id%2 == 0 {
+			book.Title = "Even " + book.Title
+		}
+		if _, err = db.SaveBookmarks(book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := storage.NewLocal(t.TempDir())
+	describe := func(id int) string {
+		books, err := db.GetBookmarks(database.GetBookmarksOptions{IDs: []int{id}})
+		if err != nil || len(books) == 0 {
+			return "missing"
+		}
+
+		tags := []string{}
+		for _, tag := range books[0].Tags {
+			tags = append(tags, tag.Name)
+		}
+		return fmt.Sprintf("%d %s", books[0].Public, strings.Join(tags, ","))
+	}
+
+	// Select by filter, with more than one batch
+	results, err := RunBulk(BulkRequest{
+		DB:        db,
+		Storage:   store,
+		Filter:    database.GetBookmarksOptions{Keyword: "even"},
+		BatchSize: 1,
+		Actions: []BulkAction{
+			{Type: BulkTag, Tags: []string{"New", " "}},
+			{Type: BulkUntag, Tags: []string{"OLD"}},
+			{Type: BulkPublic},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(results) != "[{2 updated } {4 updated }]" {
+		t.Errorf("RunBulk() results = %v", results)
+	}
+
+	want := map[int]string{1: "0 old", 2: "1 new", 3: "0 old", 4: "1 new", 5: "0 old"}
+	for id, desc := range want {
+		if got := describe(id); got != desc {
+			t.Errorf("bookmark %d = %q, want %q", id, got, desc)
+		}
+	}
+
+	// Select by IDs, including the missing one
+	results, err = RunBulk(BulkRequest{
+		DB:        db,
+		Storage:   store,
+		IDs:       []int{3, 9, 5, 3},
+		BatchSize: 2,
+		Actions:   []BulkAction{{Type: BulkDelete}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(results) != "[{3 deleted } {9 failed bookmark is not found} {5 deleted }]" {
+		t.Errorf("RunBulk() results = %v", results)
+	}
+
+	if describe(3) != "missing" || describe(5) != "missing" || describe(1) != "0 old" {
+		t.Errorf("bookmarks after delete = %q, %q, %q", describe(1), describe(3), describe(5))
+	}
+}
+
+func TestValidateBulkActions(t *testing.T) {
+	tests := []struct {
+		actions []BulkAction
+		valid   bool
+	}{
+		{[]BulkAction{{Type: BulkTag, Tags: []string{"a"}}, {Type: BulkRefetch}}, true},
+		{[]BulkAction{{Type: BulkDelete}}, true},
+		{[]BulkAction{}, false},
+		{[]BulkAction{{Type: BulkUntag, Tags: []string{" "}}}, false},
+		{[]BulkAction{{Type: BulkDelete}, {Type: BulkPublic}}, false},
+		{[]BulkAction{{Type: "archive"}}, false},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateBulkActions(tt.actions); (err == nil) != tt.valid {
+			t.Errorf("ValidateBulkActions(%v) = %v, want valid %v", tt.actions, err, tt.valid)
+		}
+	}
+}
+
+func TestRunBulkRefetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><head><title>Refetched</title></head><body><article><p>"+
+			strings.Repeat("Refetched content of the page. ", 50)+"</p></article></body></html>")
+	}))
+	defer server.Close()
+
+	db, err := database.OpenSQLiteDatabase(fp.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	book := model.Bookmark{ID: 1, URL: server.URL + "/page", Title: "Old"}
+	if _, err = db.SaveBookmarks(book); err != nil {
+		t.Fatal(err)
+	}
+
+	// The archive exceeds the quota, but the bookmark is still updated
+	results, err := RunBulk(BulkRequest{
+		DB:      db,
+		Storage: storage.NewLocal(t.TempDir()),
+		IDs:     []int{1},
+		Actions: []BulkAction{{Type: BulkRefetch, CreateArchive: true}},
+		Quota:   StorageQuota{MaxArchive: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].Status != BulkUpdated || !strings.Contains(results[0].Error, "exceeds") {
+		t.Errorf("RunBulk() results = %v", results)
+	}
+
+	saved, _ := db.GetBookmark(1, "")
+	if saved.Title != "Refetched" {
+		t.Errorf("bookmark title = %q, want Refetched", saved.Title)
+	}
+}
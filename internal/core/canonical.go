@@ -0,0 +1,350 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	nurl "net/url"
+	"os"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// CanonicalRules is the set of rules used to clean up an URL.
+type CanonicalRules struct {
+	ForceHTTPS         bool     `json:"forceHttps"`
+	StripWWW           bool     `json:"stripWww"`
+	StripTrailingSlash bool     `json:"stripTrailingSlash"`
+	KeepFragment       bool     `json:"keepFragment"`
+	UnwrapAMP          bool     `json:"unwrapAmp"`
+	RemoveParams       []string `json:"removeParams"`
+	KeepParams         []string `json:"keepParams"`
+}
+
+// CanonicalConfig is the content of canonicalization config file.
+type CanonicalConfig struct {
+	RuleSet          string                    `json:"ruleSet"`
+	UseLinkCanonical *bool                     `json:"useLinkCanonical"`
+	Rules            CanonicalRules            `json:"rules"`
+	Domains          map[string]CanonicalRules `json:"domains"`
+}
+
+// Canonicalizer converts URLs into their canonical form, so the same page
+// is not saved several times under different URLs.
+type Canonicalizer struct {
+	Rules            CanonicalRules
+	Domains          map[string]CanonicalRules
+	UseLinkCanonical bool
+}
+
+var trackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "gclsrc", "msclkid", "yclid", "igshid",
+	"mc_cid", "mc_eid", "_hsenc", "_hsmi", "mkt_tok", "ref", "ref_src", "ref_url",
+}
+
+// CanonicalRuleSets is the list of built-in rule sets.
+var CanonicalRuleSets = map[string]CanonicalRules{
+	"minimal": {
+		RemoveParams: []string{"utm_*"},
+	},
+	"standard": {
+		UnwrapAMP:    true,
+		RemoveParams: trackingParams,
+	},
+	"aggressive": {
+		ForceHTTPS:         true,
+		StripWWW:           true,
+		StripTrailingSlash: true,
+		UnwrapAMP:          true,
+		RemoveParams:       trackingParams,
+	},
+}
+
+// NewCanonicalizer creates canonicalizer using the specified rule set.
+func NewCanonicalizer(ruleSet string) (*Canonicalizer, error) {
+	if ruleSet == "" {
+		ruleSet = "standard"
+	}
+
+	rules, ok := CanonicalRuleSets[ruleSet]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule set %q", ruleSet)
+	}
+
+	return &Canonicalizer{
+		Rules:            rules,
+		Domains:          map[string]CanonicalRules{},
+		UseLinkCanonical: true,
+	}, nil
+}
+
+// LoadCanonicalizer creates canonicalizer from the config file in specified path.
+// If the file doesn't exist, the standard rule set is used.
+func LoadCanonicalizer(configPath string) (*Canonicalizer, error) {
+	f, err := os.Open(configPath)
+	if os.IsNotExist(err) {
+		return NewCanonicalizer("")
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cfg CanonicalConfig
+	if err = json.NewDecoder(f).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", configPath, err)
+	}
+
+	c, err := NewCanonicalizer(cfg.RuleSet)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Rules = mergeCanonicalRules(c.Rules, cfg.Rules)
+	if cfg.UseLinkCanonical != nil {
+		c.UseLinkCanonical = *cfg.UseLinkCanonical
+	}
+
+	for domain, rules := range cfg.Domains {
+		c.Domains[normalizeDomain(domain)] = rules
+	}
+
+	return c, nil
+}
+
+// mergeCanonicalRules combines two rules. Flags are enabled if it's enabled
+// in either rules, while params in extra rules are appended to the base.
+func mergeCanonicalRules(base, extra CanonicalRules) CanonicalRules {
+	merged := base
+	merged.ForceHTTPS = base.ForceHTTPS || extra.ForceHTTPS
+	merged.StripWWW = base.StripWWW || extra.StripWWW
+	merged.StripTrailingSlash = base.StripTrailingSlash || extra.StripTrailingSlash
+	merged.KeepFragment = base.KeepFragment || extra.KeepFragment
+	merged.UnwrapAMP = base.UnwrapAMP || extra.UnwrapAMP
+	merged.RemoveParams = append(append([]string{}, base.RemoveParams...), extra.RemoveParams...)
+	if len(extra.KeepParams) > 0 {
+		merged.KeepParams = extra.KeepParams
+	}
+
+	return merged
+}
+
+// rulesFor returns the rules that used for the specified host.
+func (c *Canonicalizer) rulesFor(host string) CanonicalRules {
+	domain := normalizeDomain(host)
+	for {
+		if rules, ok := c.Domains[domain]; ok {
+			return mergeCanonicalRules(c.Rules, rules)
+		}
+
+		idx := strings.Index(domain, ".")
+		if idx < 0 {
+			return c.Rules
+		}
+		domain = domain[idx+1:]
+	}
+}
+
+// Canonicalize cleans up the URL using the configured rules.
+func (c *Canonicalizer) Canonicalize(url string) (string, error) {
+	tmp, err := nurl.Parse(strings.TrimSpace(url))
+	if err != nil || tmp.Scheme == "" || tmp.Hostname() == "" {
+		return url, fmt.Errorf("URL is not valid")
+	}
+
+	rules := c.rulesFor(tmp.Hostname())
+	if rules.UnwrapAMP {
+		if unwrapped, ok := unwrapAMPURL(tmp); ok {
+			tmp = unwrapped
+			rules = c.rulesFor(tmp.Hostname())
+		}
+	}
+
+	// Normalize scheme and host
+	tmp.Scheme = strings.ToLower(tmp.Scheme)
+	tmp.Host = strings.ToLower(tmp.Host)
+	if port := tmp.Port(); (port == "80" && tmp.Scheme == "http") || (port == "443" && tmp.Scheme == "https") {
+		tmp.Host = tmp.Hostname()
+	}
+
+	if rules.ForceHTTPS && tmp.Scheme == "http" {
+		tmp.Scheme = "https"
+	}
+
+	if rules.StripWWW {
+		tmp.Host = strings.TrimPrefix(tmp.Host, "www.")
+	}
+
+	if rules.StripTrailingSlash && tmp.Path != "/" {
+		tmp.Path = strings.TrimSuffix(tmp.Path, "/")
+		tmp.RawPath = strings.TrimSuffix(tmp.RawPath, "/")
+	}
+
+	if !rules.KeepFragment {
+		tmp.Fragment = ""
+		tmp.RawFragment = ""
+	}
+
+	// Remove unwanted queries
+	queries := tmp.Query()
+	for key := range queries {
+		if !queryParamAllowed(key, rules) {
+			queries.Del(key)
+		}
+	}
+
+	tmp.RawQuery = queryEncodeWithoutEmptyValues(queries)
+	return tmp.String(), nil
+}
+
+// DuplicateKey returns the key that used to find duplicate bookmarks.
+// Two URLs that only differ in scheme, "www." prefix or trailing slash
+// will have the same key.
+func (c *Canonicalizer) DuplicateKey(url string) string {
+	canonical, err := c.Canonicalize(url)
+	if err != nil {
+		return url
+	}
+
+	tmp, _ := nurl.Parse(canonical)
+	key := normalizeDomain(tmp.Host) + strings.TrimSuffix(tmp.EscapedPath(), "/")
+	if tmp.RawQuery != "" {
+		key += "?" + tmp.RawQuery
+	}
+
+	if tmp.Fragment != "" {
+		key += "#" + tmp.Fragment
+	}
+
+	return key
+}
+
+// LinkCanonical looks for `<link rel=canonical>` in the HTML document
+// and returns its absolute URL. Returns empty string if it's not found or
+// if it points to different site than the page URL.
+func (c *Canonicalizer) LinkCanonical(pageURL string, html io.Reader) string {
+	if !c.UseLinkCanonical {
+		return ""
+	}
+
+	base, err := nurl.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+
+	doc, err := goquery.NewDocumentFromReader(html)
+	if err != nil {
+		return ""
+	}
+
+	href, _ := doc.Find(`link[rel~="canonical"]`).First().Attr("href")
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+
+	canonical, err := base.Parse(href)
+	if err != nil || (canonical.Scheme != "http" && canonical.Scheme != "https") {
+		return ""
+	}
+
+	if normalizeDomain(canonical.Hostname()) != normalizeDomain(base.Hostname()) {
+		return ""
+	}
+
+	return canonical.String()
+}
+
+func queryParamAllowed(key string, rules CanonicalRules) bool {
+	key = strings.ToLower(key)
+	if len(rules.KeepParams) > 0 {
+		return matchParam(key, rules.KeepParams)
+	}
+
+	return !matchParam(key, rules.RemoveParams)
+}
+
+func matchParam(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unwrapAMPURL returns the original URL of AMP pages.
+func unwrapAMPURL(u *nurl.URL) (*nurl.URL, bool) {
+	host := strings.ToLower(u.Hostname())
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	// Google AMP viewer, e.g. https://www.google.com/amp/s/example.com/article
+	// and AMP cache, e.g. https://example-com.cdn.ampproject.org/c/s/example.com/article
+	changed := false
+	var wrapped []string
+	switch {
+	case strings.HasSuffix(host, ".cdn.ampproject.org"):
+		wrapped = parts
+		if len(wrapped) > 0 && len(wrapped[0]) == 1 {
+			wrapped = wrapped[1:]
+		}
+	case strings.HasPrefix(normalizeDomain(host), "google.") && len(parts) > 1 && parts[0] == "amp":
+		wrapped = parts[1:]
+	}
+
+	if len(wrapped) > 0 {
+		scheme := "http"
+		if wrapped[0] == "s" {
+			scheme = "https"
+			wrapped = wrapped[1:]
+		}
+
+		if len(wrapped) > 0 {
+			tmp, err := nurl.Parse(scheme + "://" + strings.Join(wrapped, "/"))
+			if err == nil && tmp.Hostname() != "" {
+				tmp.RawQuery = u.RawQuery
+				u, changed = tmp, true
+				host = strings.ToLower(u.Hostname())
+				parts = strings.Split(strings.Trim(u.Path, "/"), "/")
+			}
+		}
+	}
+
+	// AMP version hosted by the site itself, e.g. amp.example.com,
+	// example.com/article/amp or example.com/article?amp=1
+	unwrapped := *u
+	if strings.HasPrefix(host, "amp.") {
+		unwrapped.Host = strings.TrimPrefix(unwrapped.Host, "amp.")
+		changed = true
+	}
+
+	if len(parts) > 1 && parts[len(parts)-1] == "amp" {
+		unwrapped.Path = "/" + strings.Join(parts[:len(parts)-1], "/")
+		unwrapped.RawPath = ""
+		changed = true
+	}
+
+	queries := unwrapped.Query()
+	if _, exist := queries["amp"]; exist {
+		queries.Del("amp")
+		unwrapped.RawQuery = queryEncodeWithoutEmptyValues(queries)
+		changed = true
+	}
+
+	return &unwrapped, changed
+}
+
+func normalizeDomain(host string) string {
+	host = strings.ToLower(host)
+	if idx := strings.Index(host, ":"); idx >= 0 {
+		host = host[:idx]
+	}
+
+	return strings.TrimPrefix(host, "www.")
+}
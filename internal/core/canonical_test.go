@@ -0,0 +1,136 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+func TestCanonicalizer_Canonicalize(t *testing.T) {
+	standard, _ := NewCanonicalizer("standard")
+	aggressive, _ := NewCanonicalizer("aggressive")
+
+	youtube, _ := NewCanonicalizer("standard")
+	youtube.Domains["youtube.com"] = CanonicalRules{KeepParams: []string{"v", "t"}}
+
+	tests := []struct {
+		name string
+		c    *Canonicalizer
+		args string
+		want string
+	}{{
+		name: "remove tracking params and fragment",
+		c:    standard,
+		args: "https://example.com/article?utm_source=x&fbclid=abc&id=5#comments",
+		want: "https://example.com/article?id=5",
+	}, {
+		name: "lowercase host and remove default port",
+		c:    standard,
+		args: "HTTP://Example.COM:80/Path",
+		want: "http://example.com/Path",
+	}, {
+		name: "unwrap google AMP viewer",
+		c:    standard,
+		args: "https://www.google.com/amp/s/example.com/article",
+		want: "https://example.com/article",
+	}, {
+		name: "unwrap AMP cache",
+		c:    standard,
+		args: "https://example-com.cdn.ampproject.org/c/s/example.com/article/amp",
+		want: "https://example.com/article",
+	}, {
+		name: "aggressive rules",
+		c:    aggressive,
+		args: "http://www.example.com/article/?ref=hn",
+		want: "https://example.com/article",
+	}, {
+		name: "per-domain keep params",
+		c:    youtube,
+		args: "https://www.youtube.com/watch?v=abc&feature=share&t=10",
+		want: "https://www.youtube.com/watch?t=10&v=abc",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.c.Canonicalize(tt.args)
+			if err != nil {
+				t.Errorf("Canonicalize() error = %v", err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("Canonicalize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizer_DuplicateKey(t *testing.T) {
+	c, _ := NewCanonicalizer("standard")
+	urls := []string{
+		"http://example.com/article",
+		"https://www.example.com/article/",
+		"https://example.com/article?gclid=123#top",
+	}
+
+	want := c.DuplicateKey(urls[0])
+	for _, url := range urls[1:] {
+		if got := c.DuplicateKey(url); got != want {
+			t.Errorf("DuplicateKey(%s) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+func TestCanonicalizer_LinkCanonical(t *testing.T) {
+	c, _ := NewCanonicalizer("standard")
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{{
+		name: "relative canonical",
+		html: `<html><head><link rel="canonical" href="/article"></head></html>`,
+		want: "https://www.example.com/article",
+	}, {
+		name: "canonical to other site",
+		html: `<html><head><link rel="canonical" href="https://other.com/article"></head></html>`,
+		want: "",
+	}, {
+		name: "no canonical",
+		html: `<html><head></head></html>`,
+		want: "",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.LinkCanonical("https://www.example.com/article?amp=1", strings.NewReader(tt.html))
+			if got != tt.want {
+				t.Errorf("LinkCanonical() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	c, _ := NewCanonicalizer("standard")
+	bookmarks := []model.Bookmark{
+		{ID: 1, URL: "http://example.com/a", Title: "http://example.com/a", Tags: []model.Tag{{ID: 1, Name: "go"}}},
+		{ID: 2, URL: "https://example.com/a/", Title: "Article", HasContent: true, Tags: []model.Tag{{ID: 2, Name: "web"}}},
+		{ID: 3, URL: "https://example.com/b", Title: "Other"},
+	}
+
+	groups := c.FindDuplicates(bookmarks)
+	if len(groups) != 1 {
+		t.Fatalf("FindDuplicates() found %d groups, want 1", len(groups))
+	}
+
+	book, removedIDs := MergeDuplicates(groups[0])
+	if book.ID != 2 || len(removedIDs) != 1 || removedIDs[0] != 1 {
+		t.Errorf("MergeDuplicates() kept %d and removed %v, want 2 and [1]", book.ID, removedIDs)
+	}
+
+	if len(book.Tags) != 2 {
+		t.Errorf("MergeDuplicates() has %d tags, want 2", len(book.Tags))
+	}
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// cdpMaxMessageSize is the maximum size of message received from browser.
+// It's quite large since screenshot and response body are sent as base64.
+const cdpMaxMessageSize = 256 << 20
+
+// cdpMessage is the message of Chrome DevTools Protocol. It's either command
+// sent to browser, result of the command, or event emitted by browser.
+type cdpMessage struct {
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *cdpError       `json:"error,omitempty"`
+}
+
+type cdpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *cdpError) Error() string {
+	return fmt.Sprintf("%s (%d)", err.Message, err.Code)
+}
+
+// cdpConn is connection to browser using Chrome DevTools Protocol.
+// Events are passed to onEvent, which is called from the reading goroutine,
+// so it must not send any command.
+type cdpConn struct {
+	ws      *websocket.Conn
+	onEvent func(cdpMessage)
+	timeout time.Duration
+
+	mx      sync.Mutex
+	lastID  int64
+	pending map[int64]chan cdpMessage
+	err     error
+}
+
+// dialCDP connects to the DevTools websocket URL of the browser.
+// Commands that not answered within the timeout are failed.
+func dialCDP(wsURL string, timeout time.Duration, onEvent func(cdpMessage)) (*cdpConn, error) {
+	config, err := websocket.NewConfig(wsURL, "http://127.0.0.1/")
+	if err != nil {
+		return nil, err
+	}
+
+	netConn, err := net.DialTimeout("tcp", config.Location.Host, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := websocket.NewClient(config, &noOriginConn{Conn: netConn})
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	ws.MaxPayloadBytes = cdpMaxMessageSize
+
+	conn := &cdpConn{
+		ws:      ws,
+		onEvent: onEvent,
+		timeout: timeout,
+		pending: map[int64]chan cdpMessage{},
+	}
+
+	go conn.readLoop()
+	return conn, nil
+}
+
+// noOriginConn removes Origin header from the websocket handshake. Chromium rejects
+// connection that has Origin header unless it's allowed by --remote-allow-origins,
+// while x/net/websocket always sends it.
+type noOriginConn struct {
+	net.Conn
+	handshakeSent bool
+}
+
+func (c *noOriginConn) Write(p []byte) (int, error) {
+	if c.handshakeSent {
+		return c.Conn.Write(p)
+	}
+
+	lines := strings.Split(string(p), "\r\n")
+	filtered := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.ToLower(line), "origin:") {
+			filtered = append(filtered, line)
+		}
+	}
+
+	c.handshakeSent = strings.Contains(string(p), "\r\n\r\n")
+	if _, err := c.Conn.Write([]byte(strings.Join(filtered, "\r\n"))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Call sends the command to the target session, then waits for its result.
+// If sessionID is empty, the command is sent to the browser itself.
+func (conn *cdpConn) Call(sessionID, method string, params, result interface{}) error {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	conn.mx.Lock()
+	if conn.err != nil {
+		conn.mx.Unlock()
+		return conn.err
+	}
+
+	conn.lastID++
+	msg := cdpMessage{
+		ID:        conn.lastID,
+		SessionID: sessionID,
+		Method:    method,
+		Params:    rawParams,
+	}
+
+	chResult := make(chan cdpMessage, 1)
+	conn.pending[msg.ID] = chResult
+	conn.mx.Unlock()
+
+	if err = websocket.JSON.Send(conn.ws, msg); err != nil {
+		conn.close(err)
+		return err
+	}
+
+	var reply cdpMessage
+	select {
+	case msg, ok := <-chResult:
+		if !ok {
+			conn.mx.Lock()
+			defer conn.mx.Unlock()
+			return conn.err
+		}
+		reply = msg
+	case <-time.After(conn.timeout):
+		conn.mx.Lock()
+		delete(conn.pending, msg.ID)
+		conn.mx.Unlock()
+		return fmt.Errorf("%s: timeout while waiting browser", method)
+	}
+
+	if reply.Error != nil {
+		return fmt.Errorf("%s: %w", method, reply.Error)
+	}
+
+	if result != nil && len(reply.Result) > 0 {
+		return json.Unmarshal(reply.Result, result)
+	}
+
+	return nil
+}
+
+// Close closes the connection. Commands that still waiting are failed.
+func (conn *cdpConn) Close() error {
+	conn.close(fmt.Errorf("connection to browser is closed"))
+	return nil
+}
+
+func (conn *cdpConn) readLoop() {
+	for {
+		var msg cdpMessage
+		if err := websocket.JSON.Receive(conn.ws, &msg); err != nil {
+			conn.close(fmt.Errorf("connection to browser is lost: %v", err))
+			return
+		}
+
+		if msg.ID == 0 {
+			if conn.onEvent != nil {
+				conn.onEvent(msg)
+			}
+			continue
+		}
+
+		conn.mx.Lock()
+		chResult, exist := conn.pending[msg.ID]
+		delete(conn.pending, msg.ID)
+		conn.mx.Unlock()
+
+		if exist {
+			chResult <- msg
+		}
+	}
+}
+
+func (conn *cdpConn) close(err error) {
+	conn.mx.Lock()
+	defer conn.mx.Unlock()
+
+	if conn.err != nil {
+		return
+	}
+
+	conn.err = err
+	conn.ws.Close()
+	for id, chResult := range conn.pending {
+		close(chResult)
+		delete(conn.pending, id)
+	}
+}
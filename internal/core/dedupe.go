@@ -0,0 +1,120 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+)
+
+// DuplicateGroup is a list of bookmarks that point to the same page.
+// The first bookmark is the one that will be kept after merging.
+type DuplicateGroup struct {
+	Key       string           `json:"key"`
+	Bookmarks []model.Bookmark `json:"bookmarks"`
+}
+
+// FindDuplicates groups the bookmarks which have the same duplicate key.
+// Only groups with more than one bookmark are returned.
+func (c *Canonicalizer) FindDuplicates(bookmarks []model.Bookmark) []DuplicateGroup {
+	keys := []string{}
+	mapGroup := map[string][]model.Bookmark{}
+	for _, book := range bookmarks {
+		key := c.DuplicateKey(book.URL)
+		if _, exist := mapGroup[key]; !exist {
+			keys = append(keys, key)
+		}
+		mapGroup[key] = append(mapGroup[key], book)
+	}
+
+	groups := []DuplicateGroup{}
+	for _, key := range keys {
+		books := mapGroup[key]
+		if len(books) < 2 {
+			continue
+		}
+
+		// Prefer bookmark that has readable content, then the oldest one
+		sort.SliceStable(books, func(i, j int) bool {
+			if books[i].HasContent != books[j].HasContent {
+				return books[i].HasContent
+			}
+			return books[i].ID < books[j].ID
+		})
+
+		groups = append(groups, DuplicateGroup{Key: key, Bookmarks: books})
+	}
+
+	return groups
+}
+
+// MergeDuplicates merges bookmarks in the group into the first one.
+// Returns the merged bookmark and IDs of bookmarks that must be removed.
+func MergeDuplicates(group DuplicateGroup) (model.Bookmark, []int) {
+	book := group.Bookmarks[0]
+
+	mapTags := map[string]struct{}{}
+	for _, tag := range book.Tags {
+		mapTags[tag.Name] = struct{}{}
+	}
+
+	removedIDs := []int{}
+	for _, dup := range group.Bookmarks[1:] {
+		removedIDs = append(removedIDs, dup.ID)
+
+		if book.Title == "" || book.Title == book.URL {
+			book.Title = dup.Title
+		}
+
+		if book.Excerpt == "" {
+			book.Excerpt = dup.Excerpt
+		}
+
+		if book.Author == "" {
+			book.Author = dup.Author
+		}
+
+		if !book.HasContent && dup.HasContent {
+			book.Content = dup.Content
+			book.HTML = dup.HTML
+			book.HasContent = true
+		}
+
+		if dup.Public > book.Public {
+			book.Public = dup.Public
+		}
+
+		for _, tag := range dup.Tags {
+			if _, exist := mapTags[tag.Name]; !exist {
+				mapTags[tag.Name] = struct{}{}
+				book.Tags = append(book.Tags, model.Tag{Name: tag.Name})
+			}
+		}
+	}
+
+	return book, removedIDs
+}
+
+// MergeDuplicateFiles moves the thumbnail and archive of removed bookmarks
+// to the kept bookmark if it doesn't have them yet, then removes the rest.
+func MergeDuplicateFiles(store storage.Storage, keptID int, removedIDs []int) {
+	for _, id := range removedIDs {
+		for _, size := range ThumbnailSizes {
+			moveOrRemoveFile(store, ThumbnailName(id, size), ThumbnailName(keptID, size))
+		}
+
+		moveOrRemoveFile(store, ArchiveName(id), ArchiveName(keptID))
+	}
+}
+
+// moveOrRemoveFile moves the file to destination if it doesn't exist yet.
+// Otherwise, the file is removed.
+func moveOrRemoveFile(store storage.Storage, srcName, dstName string) {
+	if storage.Exists(store, srcName) && !storage.Exists(store, dstName) {
+		if err := storage.Move(store, srcName, dstName); err == nil {
+			return
+		}
+	}
+
+	store.Remove(srcName)
+}
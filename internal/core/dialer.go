@@ -0,0 +1,173 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	nurl "net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrBodyTooLarge is returned when the downloaded content exceeds the size limit.
+	ErrBodyTooLarge = errors.New("content exceeds the maximum size")
+
+	// ErrTooManyRedirects is returned when the request redirected too many times.
+	ErrTooManyRedirects = errors.New("stopped after too many redirects")
+)
+
+// ErrDeniedAddress is returned when fetcher tries to connect to a denied address.
+type ErrDeniedAddress struct {
+	IP net.IP
+}
+
+func (e *ErrDeniedAddress) Error() string {
+	return fmt.Sprintf("connection to private address %s is denied", e.IP)
+}
+
+// deniedNetworks is the list of networks that is not covered by net.IP methods,
+// but still should not be reached from the outside.
+var deniedNetworks = func() []*net.IPNet {
+	cidrs := []string{
+		"0.0.0.0/8",     // "this" network
+		"100.64.0.0/10", // carrier-grade NAT
+		"192.0.0.0/24",  // IETF protocol assignments
+		"198.18.0.0/15", // benchmarking
+		"240.0.0.0/4",   // reserved
+		"64:ff9b::/96",  // NAT64
+	}
+
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, networks[i], _ = net.ParseCIDR(cidr)
+	}
+	return networks
+}()
+
+// isDeniedIP checks if the IP is loopback, private, link-local
+// (including cloud metadata endpoints) or otherwise not public.
+func isDeniedIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+
+	for _, network := range deniedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newDialContext creates dial function that checks the resolved IP address
+// before connecting. Connection to proxy is always allowed.
+func newDialContext(timeout time.Duration, denyPrivate bool, proxyAddr string) func(context.Context, string, string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if !denyPrivate {
+		return dialer.DialContext
+	}
+
+	guardedDialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || isDeniedIP(ip) {
+				return &ErrDeniedAddress{IP: ip}
+			}
+
+			return nil
+		},
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if proxyAddr != "" && addr == proxyAddr {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		return guardedDialer.DialContext(ctx, network, addr)
+	}
+}
+
+// proxyAddress returns the host:port of the proxy URL.
+func proxyAddress(proxyURL *nurl.URL) string {
+	if proxyURL == nil {
+		return ""
+	}
+
+	port := proxyURL.Port()
+	if port == "" {
+		switch proxyURL.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// limitedReadCloser is reader that returns ErrBodyTooLarge
+// when the content is larger than the limit.
+type limitedReadCloser struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (r *limitedReadCloser) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, ErrBodyTooLarge
+	}
+
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n + int(r.remaining), ErrBodyTooLarge
+	}
+
+	return n, err
+}
+
+// readAllLimited reads all content from the reader, and returns
+// ErrBodyTooLarge if its size exceeds the limit.
+func readAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(content)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+
+	return content, nil
+}
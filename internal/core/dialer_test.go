@@ -0,0 +1,47 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func Test_isDeniedIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isDeniedIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("isDeniedIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readAllLimited(t *testing.T) {
+	content, err := readAllLimited(strings.NewReader("hello"), 5)
+	if err != nil || string(content) != "hello" {
+		t.Errorf("readAllLimited() = %q, %v, want hello", content, err)
+	}
+
+	_, err = readAllLimited(strings.NewReader("hello world"), 5)
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("readAllLimited() error = %v, want %v", err, ErrBodyTooLarge)
+	}
+}
@@ -1,25 +1,239 @@
 package core
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
+	nurl "net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var httpClient = &http.Client{Timeout: time.Minute}
+// FetcherConfig is the configuration for downloading web pages.
+type FetcherConfig struct {
+	ProxyURL       string
+	Timeout        time.Duration
+	MaxRetries     int
+	RetryBackoff   time.Duration
+	UserAgent      string
+	HeadersFile    string
+	CookiesFile    string
+	MaxBodySize    int64
+	MaxRedirects   int
+	DenyPrivateIPs bool
+}
 
-// DownloadBookmark downloads bookmarked page from specified URL.
-// Return response body, make sure to close it later.
-func DownloadBookmark(url string) (io.ReadCloser, string, error) {
-	// Prepare download request
+// Fetcher downloads web pages and their resources.
+type Fetcher struct {
+	client         *http.Client
+	userAgent      string
+	maxRetries     int
+	retryBackoff   time.Duration
+	headers        map[string]map[string]string
+	maxBodySize    int64
+	denyPrivateIPs bool
+	useProxy       bool
+	dialContext    func(context.Context, string, string) (net.Conn, error)
+}
+
+// DefaultFetcherConfig returns the default config for fetcher.
+func DefaultFetcherConfig() FetcherConfig {
+	return FetcherConfig{
+		Timeout:      time.Minute,
+		MaxRetries:   2,
+		RetryBackoff: time.Second,
+		UserAgent:    userAgent,
+		MaxBodySize:  32 << 20,
+		MaxRedirects: 10,
+	}
+}
+
+// NewFetcher creates new fetcher using the specified config.
+func NewFetcher(cfg FetcherConfig) (*Fetcher, error) {
+	var proxyURL *nurl.URL
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.ProxyURL != "" {
+		var err error
+		proxyURL, err = nurl.Parse(cfg.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("proxy URL %q is not valid", cfg.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport.DialContext = newDialContext(30*time.Second, cfg.DenyPrivateIPs, proxyAddress(proxyURL))
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CookiesFile != "" {
+		if err = loadCookiesFile(jar, cfg.CookiesFile); err != nil {
+			return nil, fmt.Errorf("failed to load cookies: %v", err)
+		}
+	}
+
+	headers := map[string]map[string]string{}
+	if cfg.HeadersFile != "" {
+		if headers, err = loadHeadersFile(cfg.HeadersFile); err != nil {
+			return nil, fmt.Errorf("failed to load headers: %v", err)
+		}
+	}
+
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = userAgent
+	}
+
+	f := &Fetcher{
+		userAgent:      cfg.UserAgent,
+		maxRetries:     cfg.MaxRetries,
+		retryBackoff:   cfg.RetryBackoff,
+		headers:        headers,
+		maxBodySize:    cfg.MaxBodySize,
+		denyPrivateIPs: cfg.DenyPrivateIPs,
+		useProxy:       proxyURL != nil,
+		dialContext:    transport.DialContext,
+	}
+
+	f.client = &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		Jar:       jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+
+			return f.CheckURL(req.URL.String())
+		},
+	}
+
+	return f, nil
+}
+
+// UserAgent returns the user agent used by this fetcher.
+func (f *Fetcher) UserAgent() string {
+	return f.userAgent
+}
+
+// MaxBodySize returns the maximum size of content that can be downloaded.
+func (f *Fetcher) MaxBodySize() int64 {
+	return f.maxBodySize
+}
+
+// CheckURL makes sure the URL can be fetched, i.e. it uses HTTP(S) and, if private
+// addresses are denied, its host doesn't resolve to any private address.
+func (f *Fetcher) CheckURL(url string) error {
+	parsedURL, err := nurl.Parse(url)
+	if err != nil || parsedURL.Hostname() == "" {
+		return fmt.Errorf("URL %q is not valid", url)
+	}
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return fmt.Errorf("URL scheme %q is not supported", parsedURL.Scheme)
+	}
+
+	if !f.denyPrivateIPs {
+		return nil
+	}
+
+	ips, err := net.LookupIP(parsedURL.Hostname())
+	if err != nil {
+		return err
+	}
+
+	for _, ip := range ips {
+		if isDeniedIP(ip) {
+			return &ErrDeniedAddress{IP: ip}
+		}
+	}
+
+	return nil
+}
+
+// Do sends the HTTP request with the configured user agent, headers and cookies.
+// Requests that failed because of network error or server error are retried.
+func (f *Fetcher) Do(req *http.Request) (*http.Response, error) {
+	// When proxy is used the address can't be checked at dial time,
+	// so check it before sending the request.
+	if f.useProxy {
+		if err := f.CheckURL(req.URL.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	req.Header.Set("User-Agent", f.userAgent)
+	for key, value := range f.headersFor(req.URL.Hostname()) {
+		req.Header.Set(key, value)
+	}
+
+	backoff := f.retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := f.client.Do(req)
+		if err != nil && !isRetryableError(err) {
+			return nil, err
+		}
+
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt >= f.maxRetries || req.Method != "GET" {
+			return f.limitBody(resp, err)
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// limitBody makes sure the response body doesn't exceed the maximum size.
+func (f *Fetcher) limitBody(resp *http.Response, err error) (*http.Response, error) {
+	if err != nil || f.maxBodySize <= 0 {
+		return resp, err
+	}
+
+	if resp.ContentLength > f.maxBodySize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", resp.Request.URL, ErrBodyTooLarge)
+	}
+
+	resp.Body = &limitedReadCloser{ReadCloser: resp.Body, remaining: f.maxBodySize}
+	return resp, nil
+}
+
+// isRetryableError checks if the request error might be temporary.
+func isRetryableError(err error) bool {
+	var deniedErr *ErrDeniedAddress
+	return !errors.As(err, &deniedErr) && !errors.Is(err, ErrTooManyRedirects) && !errors.Is(err, ErrBodyTooLarge)
+}
+
+// Get sends GET request to the specified URL.
+func (f *Fetcher) Get(url string) (*http.Response, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
+	return f.Do(req)
+}
+
+// DownloadBookmark downloads bookmarked page from specified URL.
+// Return response body, make sure to close it later.
+func (f *Fetcher) DownloadBookmark(url string) (io.ReadCloser, string, error) {
 	// Send download request
-	req.Header.Set("User-Agent", userAgent)
-	resp, err := httpClient.Do(req)
+	resp, err := f.Get(url)
 	if err != nil {
 		return nil, "", err
 	}
@@ -29,3 +243,125 @@ func DownloadBookmark(url string) (io.ReadCloser, string, error) {
 
 	return resp.Body, contentType, nil
 }
+
+// headersFor returns the custom headers for specified host,
+// including the headers for its parent domains.
+func (f *Fetcher) headersFor(host string) map[string]string {
+	result := map[string]string{}
+	domain := normalizeDomain(host)
+	for domain != "" {
+		for key, value := range f.headers[domain] {
+			if _, exist := result[key]; !exist {
+				result[key] = value
+			}
+		}
+
+		idx := strings.Index(domain, ".")
+		if idx < 0 {
+			break
+		}
+		domain = domain[idx+1:]
+	}
+
+	for key, value := range f.headers["*"] {
+		if _, exist := result[key]; !exist {
+			result[key] = value
+		}
+	}
+
+	return result
+}
+
+// loadHeadersFile reads JSON file that maps domain into its custom headers, e.g.
+// {"example.com": {"Authorization": "Bearer xxx"}, "*": {"Accept-Language": "en"}}
+func loadHeadersFile(path string) (map[string]map[string]string, error) {
+	headers := map[string]map[string]string{}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return headers, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tmp map[string]map[string]string
+	if err = json.NewDecoder(f).Decode(&tmp); err != nil {
+		return nil, err
+	}
+
+	for domain, domainHeaders := range tmp {
+		if domain != "*" {
+			domain = normalizeDomain(domain)
+		}
+		headers[domain] = domainHeaders
+	}
+
+	return headers, nil
+}
+
+// loadCookiesFile reads cookies in Netscape format (the one used by curl and wget)
+// and put it into the cookie jar.
+func loadCookiesFile(jar http.CookieJar, path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	mapCookies := map[string][]*http.Cookie{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// domain, include subdomains, path, secure, expiry, name, value
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+
+		cookie := &http.Cookie{
+			Name:   fields[5],
+			Value:  fields[6],
+			Path:   fields[2],
+			Secure: strings.EqualFold(fields[3], "TRUE"),
+		}
+
+		if expiry, _ := strconv.ParseInt(fields[4], 10, 64); expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+		}
+
+		domain := strings.TrimPrefix(fields[0], ".")
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+
+		scheme := "http"
+		if cookie.Secure {
+			scheme = "https"
+		}
+
+		cookieURL := scheme + "://" + domain + "/"
+		mapCookies[cookieURL] = append(mapCookies[cookieURL], cookie)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	for strURL, cookies := range mapCookies {
+		cookieURL, err := nurl.Parse(strURL)
+		if err != nil {
+			continue
+		}
+		jar.SetCookies(cookieURL, cookies)
+	}
+
+	return nil
+}
@@ -0,0 +1,345 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+const (
+	// DefaultEmbeddingDimensions is the size of vectors created by HashEmbedder.
+	DefaultEmbeddingDimensions = 512
+
+	// maxEmbeddingText is the maximum number of characters of bookmark's text
+	// that used for its embedding, since most providers limit the input size.
+	maxEmbeddingText = 8000
+
+	// trigramWeight is the weight of character trigrams in hashed vectors
+	// relative to whole words, which lets words with same stem to match.
+	trigramWeight = 0.5
+)
+
+// Embedder creates embeddings, vectors that represent meaning of texts.
+// Texts with similar meaning have vectors with high cosine similarity.
+type Embedder interface {
+	// Name identifies the embedder and its model. Embeddings are only
+	// comparable if they're created by embedder with the same name.
+	Name() string
+
+	// Embed creates embedding for each of the texts.
+	Embed(texts []string) ([][]float32, error)
+}
+
+// HashEmbedder is local embedder that doesn't need any model. Words and their
+// character trigrams are hashed into fixed-size vector, so it's able to match
+// different forms of the same word, but not synonyms.
+type HashEmbedder struct {
+	dimensions int
+}
+
+// NewHashEmbedder creates HashEmbedder with specified vector size.
+// If dimensions is not positive, DefaultEmbeddingDimensions is used.
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	if dimensions <= 0 {
+		dimensions = DefaultEmbeddingDimensions
+	}
+
+	return &HashEmbedder{dimensions: dimensions}
+}
+
+// Name returns the name of embedder, including its vector size.
+func (e *HashEmbedder) Name() string {
+	return fmt.Sprintf("hash-ngram-%d", e.dimensions)
+}
+
+// Embed creates hashed vector for each of the texts.
+func (e *HashEmbedder) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	// Count the features, which are the words and their trigrams
+	features := map[string]float64{}
+	for _, word := range tokenize(text) {
+		features[word]++
+
+		runes := []rune("<" + word + ">")
+		for i := 0; i+3 <= len(runes); i++ {
+			features["#"+string(runes[i:i+3])] += trigramWeight
+		}
+	}
+
+	// Hash each feature into the vector, using sublinear frequency so long
+	// texts are not dominated by their most common words. The sign is hashed
+	// as well, so collisions tend to cancel each other out.
+	values := make([]float64, e.dimensions)
+	for feature, count := range features {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+
+		weight := 1 + math.Log(count)
+		if count < 1 {
+			weight = count
+		}
+
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		values[sum%uint64(e.dimensions)] += weight
+	}
+
+	// Normalize the vector
+	var norm float64
+	for _, value := range values {
+		norm += value * value
+	}
+	norm = math.Sqrt(norm)
+
+	vector := make([]float32, e.dimensions)
+	if norm == 0 {
+		return vector
+	}
+
+	for i, value := range values {
+		vector[i] = float32(value / norm)
+	}
+
+	return vector
+}
+
+// HTTPEmbedderConfig is the configuration for HTTPEmbedder.
+type HTTPEmbedderConfig struct {
+	// URL is the embeddings endpoint, e.g. http://localhost:11434/v1/embeddings.
+	URL string
+
+	// Model is the name of model that sent to the server.
+	Model string
+
+	// APIKey is sent as bearer token, if not empty.
+	APIKey string
+
+	// Timeout is the maximum time for each request.
+	Timeout time.Duration
+}
+
+// HTTPEmbedder creates embeddings using server that implements the OpenAI
+// compatible embeddings API, which is supported by most local model servers.
+type HTTPEmbedder struct {
+	url    string
+	model  string
+	apiKey string
+	client *http.Client
+}
+
+type httpEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type httpEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// NewHTTPEmbedder creates HTTPEmbedder using the specified config.
+func NewHTTPEmbedder(cfg HTTPEmbedderConfig) (*HTTPEmbedder, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("embeddings URL is required")
+	}
+
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("embeddings model is required")
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+
+	return &HTTPEmbedder{
+		url:    cfg.URL,
+		model:  cfg.Model,
+		apiKey: cfg.APIKey,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Name returns the name of embedder, which is the name of its model.
+func (e *HTTPEmbedder) Name() string {
+	return "http:" + e.model
+}
+
+// Embed sends the texts to server, and returns the embeddings in same order.
+func (e *HTTPEmbedder) Embed(texts []string) ([][]float32, error) {
+	body, err := json.Marshal(httpEmbeddingRequest{
+		Model: e.model,
+		Input: texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request embeddings: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to request embeddings: %s", resp.Status)
+	}
+
+	var result httpEmbeddingResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse embeddings: %v", err)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d is out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("embedding for text %d is missing", i)
+		}
+	}
+
+	return vectors, nil
+}
+
+// EmbeddingText returns the text of bookmark that used for its embedding.
+func EmbeddingText(book model.Bookmark) string {
+	text := strings.Join([]string{book.Title, book.Excerpt, book.Content}, "\n")
+	if runes := []rune(text); len(runes) > maxEmbeddingText {
+		text = string(runes[:maxEmbeddingText])
+	}
+
+	return text
+}
+
+// EmbedBookmarks creates and saves embeddings of the bookmarks, which must be
+// loaded with their content.
+func EmbedBookmarks(db database.DB, embedder Embedder, bookmarks ...model.Bookmark) error {
+	if len(bookmarks) == 0 {
+		return nil
+	}
+
+	texts := make([]string, len(bookmarks))
+	for i, book := range bookmarks {
+		texts[i] = EmbeddingText(book)
+	}
+
+	vectors, err := embedder.Embed(texts)
+	if err != nil {
+		return err
+	}
+
+	embeddings := make([]model.Embedding, len(bookmarks))
+	for i, book := range bookmarks {
+		embeddings[i] = model.Embedding{
+			BookmarkID: book.ID,
+			Provider:   embedder.Name(),
+			Modified:   book.Modified,
+			Vector:     vectors[i],
+		}
+	}
+
+	return db.SaveEmbeddings(embeddings...)
+}
+
+// SyncEmbeddings creates embeddings for bookmarks that don't have one from the
+// embedder yet, or that have been modified since. If all is true, embeddings of
+// all bookmarks are created again. Returns the number of embedded bookmarks.
+func SyncEmbeddings(db database.DB, embedder Embedder, all bool) (int, error) {
+	bookmarks, err := db.GetBookmarks(database.GetBookmarksOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	embeddings, err := db.GetEmbeddings(embedder.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	modified := map[int]string{}
+	for _, embedding := range embeddings {
+		modified[embedding.BookmarkID] = embedding.Modified
+	}
+
+	changedIDs := []int{}
+	for _, book := range bookmarks {
+		if lastModified, exist := modified[book.ID]; all || !exist || lastModified != book.Modified {
+			changedIDs = append(changedIDs, book.ID)
+		}
+	}
+
+	// Embed the changed bookmarks, loading their content one batch at a time
+	for start := 0; start < len(changedIDs); start += DefaultBulkBatchSize {
+		end := start + DefaultBulkBatchSize
+		if end > len(changedIDs) {
+			end = len(changedIDs)
+		}
+
+		batch, err := db.GetBookmarks(database.GetBookmarksOptions{
+			IDs:         changedIDs[start:end],
+			WithContent: true,
+		})
+		if err != nil {
+			return start, err
+		}
+
+		if err = EmbedBookmarks(db, embedder, batch...); err != nil {
+			return start, err
+		}
+	}
+
+	return len(changedIDs), nil
+}
+
+// PrepareHybridSearch sets the search vector of opts using embedding of its
+// keyword, so bookmarks are searched by both keyword and meaning. The embeddings
+// of bookmarks are not synced, bookmarks without one only match by keyword.
+// Nothing is changed if there is no keyword.
+func PrepareHybridSearch(db database.DB, embedder Embedder, opts *database.GetBookmarksOptions) error {
+	if opts.Keyword == "" {
+		return nil
+	}
+
+	vectors, err := embedder.Embed([]string{opts.Keyword})
+	if err != nil {
+		return fmt.Errorf("failed to embed keyword: %v", err)
+	}
+
+	opts.SearchVector = vectors[0]
+	opts.SearchProvider = embedder.Name()
+	return nil
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	embedder := NewHashEmbedder(0)
+	if embedder.Name() != "hash-ngram-512" {
+		t.Errorf("Name() = %s, want hash-ngram-512", embedder.Name())
+	}
+
+	vectors, err := embedder.Embed([]string{
+		"Concurrency with goroutines in golang",
+		"Goroutine concurrency patterns",
+		"Baking sourdough bread at home",
+		"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	similar := dotProduct(vectors[0], vectors[1])
+	unrelated := dotProduct(vectors[0], vectors[2])
+	if similar <= unrelated || similar < 0.3 {
+		t.Errorf("similarity = %.3f for similar texts, %.3f for unrelated texts", similar, unrelated)
+	}
+
+	if same := dotProduct(vectors[0], vectors[0]); same < 0.999 {
+		t.Errorf("similarity with itself = %.3f, want 1", same)
+	}
+
+	if len(vectors[3]) != 512 || dotProduct(vectors[3], vectors[3]) != 0 {
+		t.Errorf("embedding of empty text is not zero vector")
+	}
+}
+
+func TestHTTPEmbedder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req httpEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "mini" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		// Return the embeddings in reverse order, which must be sorted by index
+		w.Write([]byte(`{"data": [
+			{"index": 1, "embedding": [0, 1]},
+			{"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	defer srv.Close()
+
+	embedder, err := NewHTTPEmbedder(HTTPEmbedderConfig{URL: srv.URL, Model: "mini"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vectors, err := embedder.Embed([]string{"first", "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := [][]float32{{1, 0}, {0, 1}}; !reflect.DeepEqual(vectors, want) {
+		t.Errorf("Embed() = %v, want %v", vectors, want)
+	}
+
+	if _, err = embedder.Embed([]string{"first", "second", "third"}); err == nil {
+		t.Errorf("Embed() with missing embedding returns no error")
+	}
+
+	embedder, _ = NewHTTPEmbedder(HTTPEmbedderConfig{URL: srv.URL, Model: "other"})
+	if _, err = embedder.Embed([]string{"first"}); err == nil {
+		t.Errorf("Embed() with failed request returns no error")
+	}
+}
+
+// dotProduct is the cosine similarity of normalized vectors.
+func dotProduct(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
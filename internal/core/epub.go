@@ -0,0 +1,558 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image/png"
+	"io"
+	nurl "net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+	"github.com/go-shiori/shiori/internal/warc"
+	"github.com/gofrs/uuid"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// rxXMLName matches attribute name that valid in XHTML.
+var rxXMLName = regexp.MustCompile(`^[a-zA-Z_][-a-zA-Z0-9_.]*$`)
+
+// epubImageTypes is the image types that supported by EPUB readers,
+// mapped to their file extension. The other images are converted to PNG.
+var epubImageTypes = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+}
+
+// epubRemovedTags is the elements that removed from the content,
+// since they are either unsupported or useless in e-reader.
+var epubRemovedTags = map[string]struct{}{
+	"script": {}, "noscript": {}, "style": {}, "link": {}, "meta": {},
+	"iframe": {}, "frame": {}, "object": {}, "embed": {}, "form": {},
+	"input": {}, "button": {}, "select": {}, "textarea": {}, "svg": {}, "math": {},
+	"video": {}, "audio": {}, "source": {}, "picture": {}, "canvas": {},
+}
+
+// EPUBRequest is the request for exporting bookmarks as EPUB.
+// The bookmarks must be fetched with their content.
+type EPUBRequest struct {
+	Storage   storage.Storage
+	Title     string
+	Bookmarks []model.Bookmark
+	Date      time.Time
+}
+
+type epubChapter struct {
+	ID      string
+	Path    string
+	Title   string
+	Author  string
+	URL     string
+	Saved   string
+	Content string
+}
+
+type epubImage struct {
+	ID        string
+	Path      string
+	MediaType string
+	Content   []byte
+}
+
+type epubFile struct {
+	path     string
+	template *template.Template
+	data     interface{}
+}
+
+type epubBook struct {
+	Identifier string
+	Title      string
+	Language   string
+	Authors    []string
+	Source     string
+	Date       string
+	Modified   string
+	Chapters   []epubChapter
+	Images     []epubImage
+}
+
+// WriteEPUB writes the bookmarks as EPUB book, one chapter for each bookmark. Content is
+// taken from the reader view, with its images taken from the offline archive.
+func WriteEPUB(w io.Writer, req EPUBRequest) error {
+	if len(req.Bookmarks) == 0 {
+		return fmt.Errorf("no bookmarks to export")
+	}
+
+	if req.Date.IsZero() {
+		req.Date = time.Now()
+	}
+
+	// Prepare the book metadata
+	urls := make([]string, len(req.Bookmarks))
+	for i, book := range req.Bookmarks {
+		urls[i] = book.URL
+	}
+
+	book := epubBook{
+		Identifier: uuid.NewV5(uuid.NamespaceURL, strings.Join(urls, "\n")).String(),
+		Title:      req.Title,
+		Language:   "en",
+		Date:       req.Date.UTC().Format("2006-01-02"),
+		Modified:   req.Date.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+
+	if len(req.Bookmarks) == 1 {
+		single := req.Bookmarks[0]
+		book.Source = single.URL
+		if book.Title == "" {
+			book.Title = single.Title
+		}
+		if saved, err := time.Parse("2006-01-02 15:04:05", single.Modified); err == nil {
+			book.Date = saved.Format("2006-01-02")
+		}
+	}
+
+	if book.Title == "" {
+		book.Title = "Shiori Bookmarks"
+	}
+
+	mapAuthors := map[string]struct{}{}
+	for _, bookmark := range req.Bookmarks {
+		author := strings.TrimSpace(bookmark.Author)
+		if _, exist := mapAuthors[author]; author != "" && !exist {
+			mapAuthors[author] = struct{}{}
+			book.Authors = append(book.Authors, author)
+		}
+	}
+
+	// Create the chapters
+	for i, bookmark := range req.Bookmarks {
+		chapter, images := createEPUBChapter(req.Storage, bookmark, i+1, len(book.Images))
+		book.Chapters = append(book.Chapters, chapter)
+		book.Images = append(book.Images, images...)
+	}
+
+	// Write the files. Mimetype must be the first one and not compressed.
+	zw := zip.NewWriter(w)
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []epubFile{
+		{"META-INF/container.xml", epubContainerTemplate, nil},
+		{"OEBPS/content.opf", epubPackageTemplate, book},
+		{"OEBPS/nav.xhtml", epubNavTemplate, book},
+		{"OEBPS/toc.ncx", epubNCXTemplate, book},
+		{"OEBPS/style.css", epubStyleTemplate, nil},
+	}
+
+	for _, chapter := range book.Chapters {
+		files = append(files, epubFile{"OEBPS/" + chapter.Path, epubChapterTemplate, chapter})
+	}
+
+	for _, file := range files {
+		fw, err := zw.Create(file.path)
+		if err != nil {
+			return err
+		}
+
+		if err = file.template.Execute(fw, file.data); err != nil {
+			return fmt.Errorf("failed to write %s: %v", file.path, err)
+		}
+	}
+
+	for _, image := range book.Images {
+		fw, err := zw.Create("OEBPS/" + image.Path)
+		if err != nil {
+			return err
+		}
+
+		if _, err = fw.Write(image.Content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// EPUBTitle returns the default title of EPUB book for the search. Empty title is returned
+// if there is no filter, so the book is titled after the bookmark if it's the only one.
+func EPUBTitle(keyword string, tags []string) string {
+	switch {
+	case keyword != "" && len(tags) > 0:
+		return fmt.Sprintf("Shiori: %s in %s", keyword, strings.Join(tags, ", "))
+	case keyword != "":
+		return "Shiori: " + keyword
+	case len(tags) > 0:
+		return "Shiori: " + strings.Join(tags, ", ")
+	default:
+		return ""
+	}
+}
+
+// createEPUBChapter creates chapter for the bookmark, along with the images that used in it.
+func createEPUBChapter(store storage.Storage, book model.Bookmark, number int, nImages int) (epubChapter, []epubImage) {
+	chapter := epubChapter{
+		ID:     fmt.Sprintf("chapter-%d", number),
+		Path:   fmt.Sprintf("chapter-%d.xhtml", number),
+		Title:  validateEPUBText(book.Title),
+		Author: validateEPUBText(book.Author),
+		URL:    book.URL,
+		Saved:  book.Modified,
+	}
+
+	if chapter.Title == "" {
+		chapter.Title = book.URL
+	}
+
+	if saved, err := time.Parse("2006-01-02 15:04:05", book.Modified); err == nil {
+		chapter.Saved = saved.Format("2 January 2006")
+	}
+
+	// Bookmark that doesn't have readable content only has its excerpt
+	if book.HTML == "" {
+		var paragraphs []string
+		for _, text := range strings.Split(book.Content, "\n") {
+			if text = strings.TrimSpace(text); text != "" {
+				paragraphs = append(paragraphs, "<p>"+html.EscapeString(validateEPUBText(text))+"</p>")
+			}
+		}
+
+		if len(paragraphs) == 0 && book.Excerpt != "" {
+			paragraphs = append(paragraphs, "<p>"+html.EscapeString(validateEPUBText(book.Excerpt))+"</p>")
+		}
+
+		chapter.Content = strings.Join(paragraphs, "\n")
+		return chapter, nil
+	}
+
+	// Look for the images in offline archive
+	var images []epubImage
+	var archive *warc.Archive
+	if a, err := OpenArchive(store, book.ID); err == nil {
+		archive = a
+		defer archive.Close()
+	}
+
+	pageURL, err := nurl.Parse(book.URL)
+	if err != nil {
+		pageURL = &nurl.URL{}
+	}
+
+	mapImages := map[string]string{}
+	addImage := func(src string) string {
+		if archive == nil {
+			return ""
+		}
+
+		srcURL, err := pageURL.Parse(src)
+		if err != nil {
+			return ""
+		}
+
+		name := warc.ResourceName(srcURL.String())
+		if path, exist := mapImages[name]; exist {
+			return path
+		}
+
+		image, err := readEPUBImage(archive, name)
+		if err != nil {
+			mapImages[name] = ""
+			return ""
+		}
+
+		image.ID = fmt.Sprintf("image-%d", nImages+len(images)+1)
+		image.Path = "images/" + image.ID + epubImageTypes[image.MediaType]
+		images = append(images, image)
+		mapImages[name] = image.Path
+		return image.Path
+	}
+
+	content, err := convertToXHTML(book.HTML, pageURL, addImage)
+	if err != nil {
+		content = "<p>" + html.EscapeString(validateEPUBText(book.Excerpt)) + "</p>"
+	}
+
+	chapter.Content = content
+	return chapter, images
+}
+
+// readEPUBImage reads the image from archive, converting it to PNG if it's not supported by EPUB.
+func readEPUBImage(archive *warc.Archive, name string) (epubImage, error) {
+	if name == "" || !archive.HasResource(name) {
+		return epubImage{}, fmt.Errorf("image is not archived")
+	}
+
+	content, contentType, err := readArchiveResource(archive, name)
+	if err != nil {
+		return epubImage{}, err
+	}
+
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	if !strings.HasPrefix(mediaType, "image/") {
+		return epubImage{}, fmt.Errorf("%s is not an image", name)
+	}
+
+	if _, supported := epubImageTypes[mediaType]; supported {
+		return epubImage{MediaType: mediaType, Content: content}, nil
+	}
+
+	img, err := DecodeImage(content, contentType)
+	if err != nil {
+		return epubImage{}, err
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	if err = png.Encode(buffer, img); err != nil {
+		return epubImage{}, err
+	}
+
+	return epubImage{MediaType: "image/png", Content: buffer.Bytes()}, nil
+}
+
+// convertToXHTML converts the HTML content into XHTML that can be used in EPUB. Elements
+// that unsupported by e-reader are removed, and source of images are replaced using addImage.
+// Image that can't be embedded, i.e. when addImage returns empty string, is removed.
+func convertToXHTML(content string, pageURL *nurl.URL, addImage func(string) string) (string, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return "", err
+	}
+
+	for _, node := range nodes {
+		body.AppendChild(node)
+	}
+	sanitizeEPUBNode(body, pageURL, addImage)
+
+	buffer := bytes.NewBuffer(nil)
+	for child := body.FirstChild; child != nil; child = child.NextSibling {
+		if err = html.Render(buffer, child); err != nil {
+			return "", err
+		}
+	}
+
+	return buffer.String(), nil
+}
+
+func sanitizeEPUBNode(node *html.Node, pageURL *nurl.URL, addImage func(string) string) {
+	child := node.FirstChild
+	for child != nil {
+		next := child.NextSibling
+
+		switch child.Type {
+		case html.CommentNode, html.DoctypeNode:
+			node.RemoveChild(child)
+		case html.TextNode:
+			child.Data = validateEPUBText(child.Data)
+		case html.ElementNode:
+			if _, removed := epubRemovedTags[child.Data]; removed {
+				node.RemoveChild(child)
+				break
+			}
+
+			// Element with invalid name, e.g. <o:p> from MS Word, is replaced by its children
+			if !rxXMLName.MatchString(child.Data) {
+				if child.FirstChild != nil {
+					next = child.FirstChild
+				}
+
+				for grandChild := child.FirstChild; grandChild != nil; grandChild = child.FirstChild {
+					child.RemoveChild(grandChild)
+					node.InsertBefore(grandChild, child)
+				}
+
+				node.RemoveChild(child)
+				break
+			}
+
+			if child.DataAtom == atom.Img {
+				src := addImage(getAttr(child, "src"))
+				if src == "" {
+					node.RemoveChild(child)
+					break
+				}
+
+				child.Attr = []html.Attribute{{Key: "src", Val: src}, {Key: "alt", Val: getAttr(child, "alt")}}
+				break
+			}
+
+			var attrs []html.Attribute
+			for _, attr := range child.Attr {
+				switch {
+				case attr.Namespace != "", !rxXMLName.MatchString(attr.Key),
+					strings.HasPrefix(attr.Key, "on"), attr.Key == "style", attr.Key == "srcset":
+					continue
+				case attr.Key == "href":
+					// Only keep the absolute link, since the other pages are not in the book
+					hrefURL, err := pageURL.Parse(attr.Val)
+					if err != nil || (hrefURL.Scheme != "http" && hrefURL.Scheme != "https") {
+						continue
+					}
+					attr.Val = hrefURL.String()
+				}
+
+				attr.Val = validateEPUBText(attr.Val)
+				attrs = append(attrs, attr)
+			}
+			child.Attr = attrs
+
+			sanitizeEPUBNode(child, pageURL, addImage)
+		}
+
+		child = next
+	}
+}
+
+func getAttr(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// validateEPUBText removes characters that not allowed in XML.
+func validateEPUBText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || (r >= 0x20 && r != 0xFFFE && r != 0xFFFF) {
+			return r
+		}
+		return -1
+	}, text)
+}
+
+var epubTemplateFuncs = template.FuncMap{
+	"esc": html.EscapeString,
+	"inc": func(i int) int { return i + 1 },
+}
+
+var epubContainerTemplate = template.Must(template.New("container").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`))
+
+var epubPackageTemplate = template.Must(template.New("package").Funcs(epubTemplateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{.Language}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">urn:uuid:{{.Identifier}}</dc:identifier>
+    <dc:title>{{esc .Title}}</dc:title>
+    <dc:language>{{.Language}}</dc:language>
+    {{- range .Authors}}
+    <dc:creator>{{esc .}}</dc:creator>
+    {{- end}}
+    {{- if .Source}}
+    <dc:source>{{esc .Source}}</dc:source>
+    {{- end}}
+    <dc:date>{{.Date}}</dc:date>
+    <dc:publisher>Shiori</dc:publisher>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    {{- range .Chapters}}
+    <item id="{{.ID}}" href="{{.Path}}" media-type="application/xhtml+xml"/>
+    {{- end}}
+    {{- range .Images}}
+    <item id="{{.ID}}" href="{{.Path}}" media-type="{{.MediaType}}"/>
+    {{- end}}
+  </manifest>
+  <spine toc="ncx">
+    {{- range .Chapters}}
+    <itemref idref="{{.ID}}"/>
+    {{- end}}
+  </spine>
+</package>
+`))
+
+var epubNavTemplate = template.Must(template.New("nav").Funcs(epubTemplateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Language}}" lang="{{.Language}}">
+<head>
+  <title>{{esc .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{esc .Title}}</h1>
+    <ol>
+      {{- range .Chapters}}
+      <li><a href="{{.Path}}">{{esc .Title}}</a></li>
+      {{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+`))
+
+var epubNCXTemplate = template.Must(template.New("ncx").Funcs(epubTemplateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="urn:uuid:{{.Identifier}}"/>
+  </head>
+  <docTitle><text>{{esc .Title}}</text></docTitle>
+  <navMap>
+    {{- range $i, $chapter := .Chapters}}
+    <navPoint id="nav-{{$chapter.ID}}" playOrder="{{inc $i}}">
+      <navLabel><text>{{esc $chapter.Title}}</text></navLabel>
+      <content src="{{$chapter.Path}}"/>
+    </navPoint>
+    {{- end}}
+  </navMap>
+</ncx>
+`))
+
+var epubChapterTemplate = template.Must(template.New("chapter").Funcs(epubTemplateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+  <title>{{esc .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <h1>{{esc .Title}}</h1>
+  <div class="metadata">
+    {{- if .Author}}
+    <p>By {{esc .Author}}</p>
+    {{- end}}
+    <p>Source: <a href="{{esc .URL}}">{{esc .URL}}</a></p>
+    {{- if .Saved}}
+    <p>Saved on {{esc .Saved}}</p>
+    {{- end}}
+  </div>
+  <div class="content">
+{{.Content}}
+  </div>
+</body>
+</html>
+`))
+
+var epubStyleTemplate = template.Must(template.New("style").Parse(`body { font-family: serif; line-height: 1.5; }
+h1 { font-size: 1.5em; line-height: 1.3; }
+img { max-width: 100%; height: auto; }
+pre { white-space: pre-wrap; }
+.metadata { color: #666; font-size: 0.85em; margin-bottom: 2em; border-bottom: 1px solid #ccc; }
+.metadata p { margin: 0.2em 0; }
+.metadata a { color: inherit; word-break: break-all; }
+`))
@@ -0,0 +1,45 @@
+package core
+
+import (
+	nurl "net/url"
+	"testing"
+)
+
+func TestConvertToXHTML(t *testing.T) {
+	pageURL, _ := nurl.Parse("https://example.com/posts/article")
+	addImage := func(src string) string {
+		if src == "pic.png" {
+			return "images/image-1.png"
+		}
+		return ""
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"void element", `<p>a<br>b</p>`, `<p>a<br/>b</p>`},
+		{"script and comment", `<p>a<script>x()</script><!-- c --></p>`, `<p>a</p>`},
+		{"archived image", `<img src="pic.png" srcset="pic.png 2x" alt="pic" onclick="x()">`, `<img src="images/image-1.png" alt="pic"/>`},
+		{"missing image", `<p><img src="missing.png">text</p>`, `<p>text</p>`},
+		{"relative link", `<a href="/other" class="x">o</a>`, `<a href="https://example.com/other" class="x">o</a>`},
+		{"script link", `<a href="javascript:x()">js</a>`, `<a>js</a>`},
+		{"invalid element", `<p>a<o:p>b</o:p>c</p>`, `<p>abc</p>`},
+		{"escaped text", `<p>1 &lt; 2 &amp; "q"</p>`, `<p>1 &lt; 2 &amp; &#34;q&#34;</p>`},
+		{"control character", "<p>a\x0bb</p>", `<p>ab</p>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := convertToXHTML(tt.content, pageURL, addImage)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
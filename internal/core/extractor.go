@@ -0,0 +1,462 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	nurl "net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// ErrNotExtracted is returned by extractor when it can't find the content it expects,
+// so the next matching extractor should be used instead.
+var ErrNotExtracted = errors.New("content not found")
+
+// Article is the readable content extracted from a downloaded page.
+type Article struct {
+	Title    string
+	Author   string
+	Excerpt  string
+	Content  string
+	HTML     string
+	Image    string
+	Favicon  string
+	Readable bool
+
+	// Thumbnail is the image rendered by extractor,
+	// used instead of downloading Image.
+	Thumbnail image.Image
+}
+
+// ExtractRequest is the request for extracting article from downloaded content.
+type ExtractRequest struct {
+	URL         *nurl.URL
+	ContentType string
+	Content     []byte
+	Fetcher     *Fetcher
+}
+
+// Extractor extracts article from pages that it matches.
+type Extractor interface {
+	Name() string
+	Match(url *nurl.URL, contentType string) bool
+	Extract(req ExtractRequest) (Article, error)
+}
+
+// ExtractorRegistry keeps the list of extractors, ordered by priority.
+type ExtractorRegistry struct {
+	extractors []Extractor
+}
+
+// ExtractorConfig is the content of extractors config file.
+type ExtractorConfig struct {
+	Rules []SelectorRule `json:"rules"`
+}
+
+// NewExtractorRegistry creates registry with the built-in extractors.
+// Readability is used as the default for any HTML page.
+func NewExtractorRegistry() *ExtractorRegistry {
+	return &ExtractorRegistry{
+		extractors: []Extractor{
+			&PDFExtractor{},
+			&GitHubExtractor{},
+			&YouTubeExtractor{},
+			&OpenGraphExtractor{Domains: defaultOpenGraphDomains},
+			&ReadabilityExtractor{},
+		},
+	}
+}
+
+// LoadExtractorRegistry creates registry with the built-in extractors
+// and the selector rules from config file in specified path.
+// If the file doesn't exist, only the built-in extractors are used.
+func LoadExtractorRegistry(configPath string) (*ExtractorRegistry, error) {
+	r := NewExtractorRegistry()
+
+	f, err := os.Open(configPath)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cfg ExtractorConfig
+	if err = json.NewDecoder(f).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", configPath, err)
+	}
+
+	// User rules take priority over the built-in extractors,
+	// so register them from the last one.
+	for i := len(cfg.Rules) - 1; i >= 0; i-- {
+		e, err := NewSelectorExtractor(cfg.Rules[i])
+		if err != nil {
+			return nil, fmt.Errorf("rule %q is not valid: %v", cfg.Rules[i].Name, err)
+		}
+		r.Register(e)
+	}
+
+	return r, nil
+}
+
+// Register adds extractor to the registry. The latest registered
+// extractor has the highest priority.
+func (r *ExtractorRegistry) Register(e Extractor) {
+	r.extractors = append([]Extractor{e}, r.extractors...)
+}
+
+// Supports checks if there are any extractor for the specified page.
+func (r *ExtractorRegistry) Supports(url *nurl.URL, contentType string) bool {
+	for _, e := range r.extractors {
+		if e.Match(url, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Extract extracts article using the first matching extractor. If that extractor
+// can't find its content, the next matching extractor is used.
+func (r *ExtractorRegistry) Extract(req ExtractRequest) (Article, error) {
+	var lastErr error
+	for _, e := range r.extractors {
+		if !e.Match(req.URL, req.ContentType) {
+			continue
+		}
+
+		article, err := e.Extract(req)
+		if err == nil {
+			article.HTML = sanitizeHTML(article.HTML)
+			return article, nil
+		}
+
+		lastErr = fmt.Errorf("%s: %w", e.Name(), err)
+	}
+
+	if lastErr == nil {
+		lastErr = ErrNotExtracted
+	}
+
+	return Article{}, lastErr
+}
+
+// SelectorRule is user defined extractor that uses CSS selectors to find the content.
+// To use attribute value instead of text, append the attribute name after `@`,
+// e.g. `meta[property="og:image"]@content`.
+type SelectorRule struct {
+	Name         string   `json:"name"`
+	Domains      []string `json:"domains,omitempty"`
+	URLPattern   string   `json:"urlPattern,omitempty"`
+	ContentTypes []string `json:"contentTypes,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	Author       string   `json:"author,omitempty"`
+	Excerpt      string   `json:"excerpt,omitempty"`
+	Image        string   `json:"image,omitempty"`
+	Content      string   `json:"content"`
+	Remove       []string `json:"remove,omitempty"`
+}
+
+// SelectorExtractor is extractor that uses selector rule.
+type SelectorExtractor struct {
+	rule       SelectorRule
+	urlPattern *regexp.Regexp
+}
+
+// NewSelectorExtractor creates extractor from the selector rule.
+func NewSelectorExtractor(rule SelectorRule) (*SelectorExtractor, error) {
+	if rule.Content == "" {
+		return nil, fmt.Errorf("content selector is required")
+	}
+
+	if len(rule.Domains) == 0 && rule.URLPattern == "" {
+		return nil, fmt.Errorf("domains or URL pattern is required")
+	}
+
+	e := &SelectorExtractor{rule: rule}
+	if rule.URLPattern != "" {
+		var err error
+		if e.urlPattern, err = regexp.Compile(rule.URLPattern); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// Name returns name of the rule.
+func (e *SelectorExtractor) Name() string {
+	return e.rule.Name
+}
+
+// Match checks if the rule is used for the page.
+func (e *SelectorExtractor) Match(url *nurl.URL, contentType string) bool {
+	rule := e.rule
+	if len(rule.ContentTypes) == 0 {
+		if !strings.Contains(contentType, "text/html") {
+			return false
+		}
+	} else if !matchContentType(contentType, rule.ContentTypes) {
+		return false
+	}
+
+	if len(rule.Domains) > 0 && !matchDomain(url.Hostname(), rule.Domains) {
+		return false
+	}
+
+	if e.urlPattern != nil && !e.urlPattern.MatchString(url.String()) {
+		return false
+	}
+
+	return true
+}
+
+// Extract extracts article using the selectors in the rule.
+func (e *SelectorExtractor) Extract(req ExtractRequest) (Article, error) {
+	rule := e.rule
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(req.Content))
+	if err != nil {
+		return Article{}, err
+	}
+
+	for _, selector := range rule.Remove {
+		doc.Find(selector).Remove()
+	}
+
+	content := doc.Find(rule.Content)
+	if content.Length() == 0 {
+		return Article{}, ErrNotExtracted
+	}
+
+	resolveRelativeURLs(content, req.URL)
+	meta := openGraphMeta(doc)
+
+	article := Article{
+		Title:    firstNonEmpty(selectValue(doc, rule.Title), meta["og:title"], doc.Find("title").First().Text()),
+		Author:   firstNonEmpty(selectValue(doc, rule.Author), meta["author"]),
+		Excerpt:  firstNonEmpty(selectValue(doc, rule.Excerpt), meta["og:description"], meta["description"]),
+		Image:    absoluteURL(firstNonEmpty(selectValue(doc, rule.Image), meta["og:image"]), req.URL),
+		Favicon:  pageFavicon(doc, req.URL),
+		HTML:     outerHTML(content),
+		Content:  strings.TrimSpace(content.Text()),
+		Readable: true,
+	}
+
+	return article, nil
+}
+
+// matchDomain checks if the host is one of the domains or their subdomain.
+func matchDomain(host string, domains []string) bool {
+	host = normalizeDomain(host)
+	for _, domain := range domains {
+		domain = normalizeDomain(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchContentType checks if the content type contains any of the specified types.
+func matchContentType(contentType string, types []string) bool {
+	for _, t := range types {
+		if strings.Contains(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectValue returns the trimmed text of the first element matched by the selector,
+// or its attribute when the selector is suffixed with `@attribute`.
+func selectValue(doc *goquery.Document, selector string) string {
+	if selector == "" {
+		return ""
+	}
+
+	attr := ""
+	if idx := strings.LastIndex(selector, "@"); idx >= 0 {
+		selector, attr = selector[:idx], selector[idx+1:]
+	}
+
+	sel := doc.Find(selector).First()
+	if attr != "" {
+		value, _ := sel.Attr(attr)
+		return strings.TrimSpace(value)
+	}
+
+	return normalizeText(sel.Text())
+}
+
+// openGraphMeta returns the OpenGraph, Twitter card and common metadata in the page.
+func openGraphMeta(doc *goquery.Document) map[string]string {
+	meta := map[string]string{}
+	doc.Find("meta").Each(func(_ int, s *goquery.Selection) {
+		key := s.AttrOr("property", "")
+		if key == "" {
+			key = s.AttrOr("name", "")
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		value := strings.TrimSpace(s.AttrOr("content", ""))
+		if key == "" || value == "" {
+			return
+		}
+
+		if _, exist := meta[key]; !exist {
+			meta[key] = value
+		}
+	})
+
+	// Use Twitter card as fallback for OpenGraph
+	for _, key := range []string{"title", "description", "image"} {
+		if meta["og:"+key] == "" && meta["twitter:"+key] != "" {
+			meta["og:"+key] = meta["twitter:"+key]
+		}
+	}
+
+	return meta
+}
+
+// pageFavicon returns the absolute URL of the favicon declared in the page.
+func pageFavicon(doc *goquery.Document, base *nurl.URL) string {
+	href := ""
+	doc.Find("link[rel]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		for _, rel := range strings.Fields(strings.ToLower(s.AttrOr("rel", ""))) {
+			if rel == "icon" {
+				href = s.AttrOr("href", "")
+				return false
+			}
+		}
+		return true
+	})
+
+	return absoluteURL(href, base)
+}
+
+// resolveRelativeURLs converts relative link and image URLs into absolute URLs.
+func resolveRelativeURLs(sel *goquery.Selection, base *nurl.URL) {
+	for _, attr := range []string{"href", "src"} {
+		sel.Find("[" + attr + "]").AddSelection(sel.Filter("[" + attr + "]")).Each(func(_ int, s *goquery.Selection) {
+			value := s.AttrOr(attr, "")
+			if value == "" || strings.HasPrefix(value, "#") {
+				return
+			}
+			s.SetAttr(attr, absoluteURL(value, base))
+		})
+	}
+}
+
+// absoluteURL resolves the URL against base URL.
+func absoluteURL(url string, base *nurl.URL) string {
+	if url == "" || base == nil {
+		return url
+	}
+
+	ref, err := nurl.Parse(url)
+	if err != nil {
+		return url
+	}
+
+	return base.ResolveReference(ref).String()
+}
+
+// outerHTML returns the HTML of all elements in selection.
+func outerHTML(sel *goquery.Selection) string {
+	var sb strings.Builder
+	sel.Each(func(_ int, s *goquery.Selection) {
+		html, err := goquery.OuterHtml(s)
+		if err == nil {
+			sb.WriteString(html)
+		}
+	})
+	return sb.String()
+}
+
+// unsafeElements is the elements removed from article's HTML, since they can
+// run scripts or load other pages when the content is shown in reader view.
+const unsafeElements = "script, noscript, style, link, meta, base, iframe, frame, frameset, " +
+	"object, embed, applet, form, input, button, select, textarea, animate, set"
+
+// unsafeURLAttributes is the attributes whose value is URL, which must not
+// use a scheme that runs script.
+var unsafeURLAttributes = map[string]struct{}{
+	"href": {}, "src": {}, "action": {}, "formaction": {}, "poster": {},
+	"data": {}, "background": {}, "cite": {}, "longdesc": {},
+}
+
+// sanitizeHTML removes elements and attributes that can run scripts from the
+// article's HTML, since it's shown as it is in Shiori's own origin.
+func sanitizeHTML(content string) string {
+	if content == "" {
+		return ""
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+
+	body := doc.Find("body")
+	body.Find(unsafeElements).Remove()
+	body.Find("*").Each(func(_ int, s *goquery.Selection) {
+		node := s.Get(0)
+
+		var attrs []html.Attribute
+		for _, attr := range node.Attr {
+			key := strings.ToLower(attr.Key)
+			if strings.HasPrefix(key, "on") || key == "srcdoc" {
+				continue
+			}
+
+			if _, isURL := unsafeURLAttributes[key]; isURL && isScriptURL(attr.Val) {
+				continue
+			}
+
+			attrs = append(attrs, attr)
+		}
+		node.Attr = attrs
+	})
+
+	result, err := body.Html()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(result)
+}
+
+// isScriptURL checks if the URL runs script when it's opened. Browsers ignore
+// the white spaces and control characters in scheme, so they're removed first.
+func isScriptURL(url string) bool {
+	url = strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, url))
+
+	return strings.HasPrefix(url, "javascript:") || strings.HasPrefix(url, "vbscript:") ||
+		strings.HasPrefix(url, "data:text/html")
+}
+
+// normalizeText trims the text and merges its consecutive white spaces.
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// firstNonEmpty returns the first non empty string.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,278 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	nurl "net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-shiori/go-readability"
+)
+
+// defaultOpenGraphDomains is the list of sites which content can't be parsed
+// by readability, so only their OpenGraph metadata are used.
+var defaultOpenGraphDomains = []string{
+	"twitter.com",
+	"x.com",
+	"facebook.com",
+	"instagram.com",
+	"tiktok.com",
+}
+
+// ReadabilityExtractor extracts article from any HTML page using go-readability.
+type ReadabilityExtractor struct{}
+
+// Name returns name of the extractor.
+func (e *ReadabilityExtractor) Name() string {
+	return "readability"
+}
+
+// Match checks if the page is HTML.
+func (e *ReadabilityExtractor) Match(url *nurl.URL, contentType string) bool {
+	return strings.Contains(contentType, "text/html")
+}
+
+// Extract extracts the readable article from the page.
+func (e *ReadabilityExtractor) Extract(req ExtractRequest) (Article, error) {
+	isReadable := readability.Check(bytes.NewReader(req.Content))
+
+	article, err := readability.FromReader(bytes.NewReader(req.Content), req.URL)
+	if err != nil {
+		return Article{}, err
+	}
+
+	return Article{
+		Title:    article.Title,
+		Author:   article.Byline,
+		Excerpt:  article.Excerpt,
+		Content:  article.TextContent,
+		HTML:     article.Content,
+		Image:    article.Image,
+		Favicon:  article.Favicon,
+		Readable: isReadable,
+	}, nil
+}
+
+// OpenGraphExtractor only uses OpenGraph metadata for pages in the specified domains.
+type OpenGraphExtractor struct {
+	Domains []string
+}
+
+// Name returns name of the extractor.
+func (e *OpenGraphExtractor) Name() string {
+	return "opengraph"
+}
+
+// Match checks if the page is HTML in one of the domains.
+func (e *OpenGraphExtractor) Match(url *nurl.URL, contentType string) bool {
+	return strings.Contains(contentType, "text/html") && matchDomain(url.Hostname(), e.Domains)
+}
+
+// Extract creates article from the page's OpenGraph metadata.
+func (e *OpenGraphExtractor) Extract(req ExtractRequest) (Article, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(req.Content))
+	if err != nil {
+		return Article{}, err
+	}
+
+	meta := openGraphMeta(doc)
+	if meta["og:title"] == "" && meta["og:description"] == "" {
+		return Article{}, ErrNotExtracted
+	}
+
+	description := firstNonEmpty(meta["og:description"], meta["description"])
+	return Article{
+		Title:    firstNonEmpty(meta["og:title"], doc.Find("title").First().Text()),
+		Author:   firstNonEmpty(meta["author"], meta["twitter:creator"]),
+		Excerpt:  description,
+		Content:  description,
+		HTML:     textToHTML(description),
+		Image:    absoluteURL(meta["og:image"], req.URL),
+		Favicon:  pageFavicon(doc, req.URL),
+		Readable: description != "",
+	}, nil
+}
+
+// GitHubExtractor extracts README from GitHub repository page.
+type GitHubExtractor struct{}
+
+// Name returns name of the extractor.
+func (e *GitHubExtractor) Name() string {
+	return "github"
+}
+
+// Match checks if the page is the main page of GitHub repository.
+func (e *GitHubExtractor) Match(url *nurl.URL, contentType string) bool {
+	if !strings.Contains(contentType, "text/html") || normalizeDomain(url.Hostname()) != "github.com" {
+		return false
+	}
+
+	segments := strings.Split(strings.Trim(url.Path, "/"), "/")
+	return len(segments) == 2 && segments[0] != "" && segments[1] != ""
+}
+
+// Extract extracts the README of the repository.
+func (e *GitHubExtractor) Extract(req ExtractRequest) (Article, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(req.Content))
+	if err != nil {
+		return Article{}, err
+	}
+
+	readme := doc.Find("#readme article.markdown-body").First()
+	if readme.Length() == 0 {
+		readme = doc.Find("article.markdown-body").First()
+	}
+
+	if readme.Length() == 0 {
+		return Article{}, ErrNotExtracted
+	}
+
+	// Relative links in README point to the files in default branch, so resolve
+	// them the way GitHub does. Images are resolved first into the raw files,
+	// while the other links are resolved into the file pages.
+	repoName := strings.Trim(req.URL.Path, "/")
+	owner := strings.Split(repoName, "/")[0]
+	branch := githubDefaultBranch(doc, repoName)
+	branchURL := func(kind string) *nurl.URL {
+		return &nurl.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: "/" + repoName + "/" + kind + "/" + branch + "/"}
+	}
+
+	resolveRelativeURLs(readme.Find("img, picture source, video, audio"), branchURL("raw"))
+	resolveRelativeURLs(readme, branchURL("blob"))
+	meta := openGraphMeta(doc)
+
+	return Article{
+		Title:    repoName,
+		Author:   owner,
+		Excerpt:  meta["og:description"],
+		Content:  strings.TrimSpace(readme.Text()),
+		HTML:     outerHTML(readme),
+		Image:    absoluteURL(meta["og:image"], req.URL),
+		Favicon:  pageFavicon(doc, req.URL),
+		Readable: true,
+	}, nil
+}
+
+// githubDefaultBranch returns the default branch of the repository, from the
+// commits feed in the page. If it's not found, HEAD is used which GitHub
+// redirects to the default branch.
+func githubDefaultBranch(doc *goquery.Document, repoName string) string {
+	prefix := "/" + repoName + "/commits/"
+	feedURL := doc.Find(`link[type="application/atom+xml"]`).FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return strings.Contains(s.AttrOr("href", ""), prefix)
+	}).First().AttrOr("href", "")
+
+	if idx := strings.Index(feedURL, prefix); idx >= 0 {
+		if branch := strings.TrimSuffix(feedURL[idx+len(prefix):], ".atom"); branch != "" {
+			return branch
+		}
+	}
+
+	return "HEAD"
+}
+
+// YouTubeExtractor extracts video metadata from YouTube, using its oEmbed
+// endpoint and the page's metadata.
+type YouTubeExtractor struct{}
+
+// Name returns name of the extractor.
+func (e *YouTubeExtractor) Name() string {
+	return "youtube"
+}
+
+// Match checks if the page is YouTube video.
+func (e *YouTubeExtractor) Match(url *nurl.URL, contentType string) bool {
+	if !strings.Contains(contentType, "text/html") {
+		return false
+	}
+
+	switch normalizeDomain(url.Hostname()) {
+	case "youtu.be":
+		return strings.Trim(url.Path, "/") != ""
+	case "youtube.com", "m.youtube.com", "music.youtube.com":
+		return (url.Path == "/watch" && url.Query().Get("v") != "") ||
+			strings.HasPrefix(url.Path, "/shorts/")
+	default:
+		return false
+	}
+}
+
+// Extract extracts the video title, channel, thumbnail and description.
+func (e *YouTubeExtractor) Extract(req ExtractRequest) (Article, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(req.Content))
+	if err != nil {
+		return Article{}, err
+	}
+
+	meta := openGraphMeta(doc)
+	article := Article{
+		Title:   meta["og:title"],
+		Excerpt: firstNonEmpty(meta["og:description"], meta["description"]),
+		Image:   meta["og:image"],
+		Favicon: pageFavicon(doc, req.URL),
+	}
+
+	// oEmbed has more reliable metadata, so use it when available
+	if req.Fetcher != nil {
+		oembed, err := fetchYouTubeOEmbed(req.Fetcher, req.URL.String())
+		if err == nil {
+			article.Title = firstNonEmpty(oembed.Title, article.Title)
+			article.Author = oembed.AuthorName
+			article.Image = firstNonEmpty(oembed.ThumbnailURL, article.Image)
+		}
+	}
+
+	if article.Title == "" {
+		return Article{}, ErrNotExtracted
+	}
+
+	article.Content = article.Excerpt
+	article.Readable = article.Content != ""
+	article.HTML = textToHTML(article.Content)
+	if article.Image != "" {
+		article.HTML = fmt.Sprintf(`<figure><img src="%s" alt="%s"></figure>`,
+			html.EscapeString(article.Image), html.EscapeString(article.Title)) + article.HTML
+	}
+
+	return article, nil
+}
+
+// youTubeOEmbed is the response of YouTube oEmbed endpoint.
+type youTubeOEmbed struct {
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+func fetchYouTubeOEmbed(fetcher *Fetcher, videoURL string) (youTubeOEmbed, error) {
+	var result youTubeOEmbed
+
+	oembedURL := "https://www.youtube.com/oembed?format=json&url=" + nurl.QueryEscape(videoURL)
+	resp, err := fetcher.Get(oembedURL)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("oEmbed returned %s", resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result, err
+}
+
+// textToHTML converts plain text into HTML paragraphs.
+func textToHTML(text string) string {
+	var sb strings.Builder
+	for _, paragraph := range strings.Split(text, "\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			sb.WriteString("<p>" + html.EscapeString(paragraph) + "</p>")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	nurl "net/url"
+	"os"
+	fp "path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractorRegistry_Extract(t *testing.T) {
+	githubPage := `<html><head>
+		<meta property="og:description" content="Simple bookmark manager">
+		<meta property="og:image" content="https://opengraph.githubassets.com/go-shiori/shiori">
+		<link rel="alternate" type="application/atom+xml" title="Recent Commits to shiori:master" href="https://github.com/go-shiori/shiori/commits/master.atom">
+		</head><body><div id="readme"><article class="markdown-body">
+		<h1>Shiori</h1><p>See <a href="docs/Usage.md">usage</a>.<img src="docs/screenshots/01-login.png"></p>
+		</article></div></body></html>`
+
+	tweetPage := `<html><head>
+		<meta property="og:title" content="Someone on X">
+		<meta property="og:description" content="Hello world">
+		</head><body><div id="app"></div></body></html>`
+
+	tests := []struct {
+		name        string
+		url         string
+		content     string
+		wantTitle   string
+		wantContent string
+		wantHTML    string
+	}{{
+		name:        "github readme",
+		url:         "https://github.com/go-shiori/shiori",
+		content:     githubPage,
+		wantTitle:   "go-shiori/shiori",
+		wantContent: "Shiori",
+		wantHTML: `<a href="https://github.com/go-shiori/shiori/blob/master/docs/Usage.md">usage</a>.` +
+			`<img src="https://github.com/go-shiori/shiori/raw/master/docs/screenshots/01-login.png"/>`,
+	}, {
+		name:        "opengraph only",
+		url:         "https://x.com/someone/status/1",
+		content:     tweetPage,
+		wantTitle:   "Someone on X",
+		wantContent: "Hello world",
+		wantHTML:    "<p>Hello world</p>",
+	}}
+
+	r := NewExtractorRegistry()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, _ := nurl.Parse(tt.url)
+			article, err := r.Extract(ExtractRequest{
+				URL:         url,
+				ContentType: "text/html; charset=utf-8",
+				Content:     []byte(tt.content),
+			})
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+
+			if article.Title != tt.wantTitle {
+				t.Errorf("Extract() title = %v, want %v", article.Title, tt.wantTitle)
+			}
+
+			if !strings.Contains(article.Content, tt.wantContent) {
+				t.Errorf("Extract() content = %v, want %v", article.Content, tt.wantContent)
+			}
+
+			if !strings.Contains(article.HTML, tt.wantHTML) {
+				t.Errorf("Extract() HTML = %v, want %v", article.HTML, tt.wantHTML)
+			}
+		})
+	}
+}
+
+func Test_sanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{{
+		name:    "safe content",
+		content: `<p>Hello <a href="https://example.com">world</a></p>`,
+		want:    `<p>Hello <a href="https://example.com">world</a></p>`,
+	}, {
+		name:    "script and style",
+		content: `<div><script>alert(1)</script><style>p{}</style><p>Text</p></div>`,
+		want:    `<div><p>Text</p></div>`,
+	}, {
+		name:    "event handlers",
+		content: `<p onclick="alert(1)" class="text"><img src="a.png" ONERROR="alert(1)"></p>`,
+		want:    `<p class="text"><img src="a.png"/></p>`,
+	}, {
+		name:    "script URLs",
+		content: `<a href=" java&#x09;script:alert(1)">a</a><iframe src="https://example.com"></iframe><img src="data:text/html,x">`,
+		want:    `<a>a</a><img/>`,
+	}, {
+		name:    "script in svg",
+		content: `<svg><script>alert(1)</script><a href="javascript:alert(1)"><text>x</text></a></svg>`,
+		want:    `<svg><a><text>x</text></a></svg>`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeHTML(tt.content); got != tt.want {
+				t.Errorf("sanitizeHTML() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadExtractorRegistry(t *testing.T) {
+	configPath := fp.Join(t.TempDir(), "extractors.json")
+	config := `{"rules": [{
+		"name": "hacker-news",
+		"domains": ["news.ycombinator.com"],
+		"title": ".titleline > a",
+		"author": ".fatitem .hnuser",
+		"content": ".fatitem, .comment-tree",
+		"remove": [".reply"]
+	}]}`
+
+	if err := os.WriteFile(configPath, []byte(config), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := LoadExtractorRegistry(configPath)
+	if err != nil {
+		t.Fatalf("LoadExtractorRegistry() error = %v", err)
+	}
+
+	page := `<html><body><table class="fatitem"><tr><td>
+		<span class="titleline"><a href="https://example.com">Show HN: Something</a></span>
+		<a class="hnuser">alice</a></td></tr></table>
+		<table class="comment-tree"><tr><td>Nice work<div class="reply">reply</div></td></tr></table>
+		</body></html>`
+
+	url, _ := nurl.Parse("https://news.ycombinator.com/item?id=1")
+	article, err := r.Extract(ExtractRequest{URL: url, ContentType: "text/html", Content: []byte(page)})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	if article.Title != "Show HN: Something" || article.Author != "alice" {
+		t.Errorf("Extract() = %q by %q, want %q by %q", article.Title, article.Author, "Show HN: Something", "alice")
+	}
+
+	if !strings.Contains(article.Content, "Nice work") || strings.Contains(article.Content, "reply") {
+		t.Errorf("Extract() content = %q", article.Content)
+	}
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	nurl "net/url"
+	"path"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/go-shiori/shiori/internal/storage"
+	"golang.org/x/image/bmp"
+)
+
+const (
+	// faviconSize is the width and height of stored favicon.
+	faviconSize = 64
+
+	// faviconMaxAge is how long the stored favicon is used before downloaded again.
+	faviconMaxAge = 30 * 24 * time.Hour
+)
+
+// FaviconDomain returns the domain used for storing favicon of the URL.
+func FaviconDomain(url string) string {
+	parsedURL, err := nurl.Parse(url)
+	if err != nil {
+		return ""
+	}
+
+	return normalizeDomain(parsedURL.Host)
+}
+
+// FaviconName returns name of the favicon for specified domain in storage.
+func FaviconName(domain string) string {
+	return "favicon/" + domain
+}
+
+// FaviconURL returns the URL for serving favicon of the bookmark's URL,
+// or empty string if the favicon is not saved yet.
+func FaviconURL(store storage.Storage, rootPath, bookmarkURL string) string {
+	domain := FaviconDomain(bookmarkURL)
+	if domain == "" {
+		return ""
+	}
+
+	if !storage.Exists(store, FaviconName(domain)) {
+		return ""
+	}
+
+	return path.Join(rootPath, "favicon", domain)
+}
+
+// SaveFavicon downloads favicon of the page and saves it for the page's domain.
+// Since favicon is shared by all bookmarks in the same domain, it's only
+// downloaded if the domain doesn't have recent favicon yet.
+func SaveFavicon(fetcher *Fetcher, store storage.Storage, pageURL, faviconURL string) error {
+	parsedURL, err := nurl.Parse(pageURL)
+	if err != nil {
+		return err
+	}
+
+	domain := normalizeDomain(parsedURL.Host)
+	if domain == "" {
+		return fmt.Errorf("URL %q doesn't have domain", pageURL)
+	}
+
+	dstName := FaviconName(domain)
+	if info, err := store.Stat(dstName); err == nil && time.Since(info.ModTime) < faviconMaxAge {
+		return nil
+	}
+
+	// Use the favicon declared in page, or the default location
+	defaultURL := parsedURL.Scheme + "://" + parsedURL.Host + "/favicon.ico"
+	candidates := []string{defaultURL}
+	if faviconURL != "" && faviconURL != defaultURL {
+		candidates = []string{faviconURL, defaultURL}
+	}
+
+	var img image.Image
+	for _, candidate := range candidates {
+		if img, err = downloadBookImage(fetcher, candidate); err == nil {
+			break
+		}
+	}
+
+	if img == nil {
+		return err
+	}
+
+	// Save it as PNG to keep its transparency
+	if bounds := img.Bounds(); bounds.Dx() > faviconSize || bounds.Dy() > faviconSize {
+		img = imaging.Fit(img, faviconSize, faviconSize, imaging.Lanczos)
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	if err = png.Encode(buffer, img); err != nil {
+		return err
+	}
+
+	if err = store.Write(dstName, buffer); err != nil {
+		return fmt.Errorf("failed to save favicon file: %v", err)
+	}
+
+	return nil
+}
+
+// isICO checks if the data is in ICO format.
+func isICO(data []byte) bool {
+	return len(data) >= 6 && bytes.Equal(data[:4], []byte{0, 0, 1, 0})
+}
+
+// decodeICO decodes the largest image in ICO file.
+// The image may be stored as PNG, or as BMP without its file header.
+// Like DecodeImage, image with too many pixels is rejected.
+func decodeICO(data []byte) (image.Image, error) {
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+	if count == 0 || len(data) < 6+count*16 {
+		return nil, fmt.Errorf("ico: invalid format")
+	}
+
+	// Find the largest entry
+	var bestOffset, bestSize, bestWidth, bestBPP int
+	for i := 0; i < count; i++ {
+		entry := data[6+i*16 : 6+(i+1)*16]
+		width := int(entry[0])
+		if width == 0 {
+			width = 256
+		}
+
+		bpp := int(binary.LittleEndian.Uint16(entry[6:8]))
+		if width > bestWidth || (width == bestWidth && bpp > bestBPP) {
+			bestWidth, bestBPP = width, bpp
+			bestSize = int(binary.LittleEndian.Uint32(entry[8:12]))
+			bestOffset = int(binary.LittleEndian.Uint32(entry[12:16]))
+		}
+	}
+
+	if bestOffset < 0 || bestSize < 40 || bestOffset+bestSize > len(data) {
+		return nil, fmt.Errorf("ico: invalid format")
+	}
+
+	entryData := data[bestOffset : bestOffset+bestSize]
+	decodeConfig, decode := png.DecodeConfig, png.Decode
+	if !bytes.HasPrefix(entryData, []byte("\x89PNG")) {
+		entryData = icoBMP(entryData)
+		decodeConfig, decode = bmp.DecodeConfig, bmp.Decode
+	}
+
+	// The size in ICO directory is limited to 256, but the embedded image
+	// may have any size, so check it before decoding.
+	imgConfig, err := decodeConfig(bytes.NewReader(entryData))
+	if err != nil {
+		return nil, err
+	}
+
+	if imgConfig.Width*imgConfig.Height > maxImagePixels {
+		return nil, fmt.Errorf("image is too large (%dx%d)", imgConfig.Width, imgConfig.Height)
+	}
+
+	return decode(bytes.NewReader(entryData))
+}
+
+// icoBMP converts the BMP image in ICO file, which is stored without
+// its file header, into BMP file.
+func icoBMP(entryData []byte) []byte {
+	// BMP in ICO has double height, since it's followed by its transparency mask.
+	// Halve it and add the file header, so it can be decoded as BMP.
+	dib := make([]byte, len(entryData))
+	copy(dib, entryData)
+
+	infoLen := binary.LittleEndian.Uint32(dib[0:4])
+	height := int32(binary.LittleEndian.Uint32(dib[8:12]))
+	binary.LittleEndian.PutUint32(dib[8:12], uint32(height/2))
+
+	paletteLen := uint32(0)
+	if bpp := binary.LittleEndian.Uint16(dib[14:16]); bpp <= 8 {
+		nColors := binary.LittleEndian.Uint32(dib[32:36])
+		if nColors == 0 {
+			nColors = 1 << bpp
+		}
+		paletteLen = nColors * 4
+	}
+
+	header := make([]byte, 14)
+	copy(header, "BM")
+	binary.LittleEndian.PutUint32(header[2:6], uint32(14+len(dib)))
+	binary.LittleEndian.PutUint32(header[10:14], 14+infoLen+paletteLen)
+
+	return append(header, dib...)
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/storage"
+)
+
+// buildICO creates ICO file that contains one 32-bit BMP entry.
+func buildICO(width, height int, c color.NRGBA) []byte {
+	dib := make([]byte, 40, 40+width*height*4*2)
+	binary.LittleEndian.PutUint32(dib[0:4], 40)
+	binary.LittleEndian.PutUint32(dib[4:8], uint32(width))
+	binary.LittleEndian.PutUint32(dib[8:12], uint32(height*2))
+	binary.LittleEndian.PutUint16(dib[12:14], 1)
+	binary.LittleEndian.PutUint16(dib[14:16], 32)
+	for i := 0; i < width*height; i++ {
+		dib = append(dib, c.B, c.G, c.R, c.A)
+	}
+	dib = append(dib, make([]byte, width*height/8)...)
+
+	ico := []byte{0, 0, 1, 0, 1, 0}
+	entry := make([]byte, 16)
+	entry[0], entry[1] = byte(width), byte(height)
+	binary.LittleEndian.PutUint16(entry[4:6], 1)
+	binary.LittleEndian.PutUint16(entry[6:8], 32)
+	binary.LittleEndian.PutUint32(entry[8:12], uint32(len(dib)))
+	binary.LittleEndian.PutUint32(entry[12:16], 22)
+
+	return append(append(ico, entry...), dib...)
+}
+
+func TestDecodeImage_ICO(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	img, err := DecodeImage(buildICO(16, 16, red), "image/x-icon")
+	if err != nil {
+		t.Fatalf("DecodeImage() error = %v", err)
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() != 16 || bounds.Dy() != 16 {
+		t.Errorf("DecodeImage() size = %dx%d, want 16x16", bounds.Dx(), bounds.Dy())
+	}
+
+	if got := color.NRGBAModel.Convert(img.At(8, 8)); got != red {
+		t.Errorf("DecodeImage() color = %v, want %v", got, red)
+	}
+}
+
+func TestDecodeImage_LargeICO(t *testing.T) {
+	// Embedded BMP claims to be much larger than the size in ICO directory
+	ico := buildICO(16, 16, color.NRGBA{A: 255})
+	binary.LittleEndian.PutUint32(ico[22+4:22+8], 20000)
+	binary.LittleEndian.PutUint32(ico[22+8:22+12], 40000)
+
+	if _, err := DecodeImage(ico, "image/x-icon"); err == nil {
+		t.Errorf("DecodeImage() of too large ICO should return error")
+	}
+}
+
+func TestSaveFavicon(t *testing.T) {
+	nRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nRequests++
+		if r.URL.Path != "/icon.png" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 128, 128)))
+	}))
+	defer server.Close()
+
+	fetcher, _ := NewFetcher(DefaultFetcherConfig())
+	store := storage.NewLocal(t.TempDir())
+
+	// Favicon is shared by all pages in the same domain
+	for _, page := range []string{"/article-1", "/article-2"} {
+		err := SaveFavicon(fetcher, store, server.URL+page, server.URL+"/icon.png")
+		if err != nil {
+			t.Fatalf("SaveFavicon() error = %v", err)
+		}
+	}
+
+	if nRequests != 1 {
+		t.Errorf("SaveFavicon() sent %d requests, want 1", nRequests)
+	}
+
+	want := "/favicon/127.0.0.1"
+	if got := FaviconURL(store, "/", server.URL+"/article-3"); got != want {
+		t.Errorf("FaviconURL() = %v, want %v", got, want)
+	}
+
+	f, err := store.Open(FaviconName("127.0.0.1"))
+	if err != nil {
+		t.Fatalf("favicon is not saved: %v", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil || img.Bounds().Dx() != faviconSize {
+		t.Errorf("saved favicon is not valid: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+// maxHighlightContext is the maximum number of characters kept before and
+// after the quote, which is enough to tell apart the same quote in one page.
+const maxHighlightContext = 64
+
+// CleanHighlight validates the highlight, and trims its note and the text
+// around the quote. The quote itself is kept as it is, since it must match
+// the bookmark's content exactly.
+func CleanHighlight(highlight model.Highlight) (model.Highlight, error) {
+	if strings.TrimSpace(highlight.Quote) == "" {
+		return highlight, fmt.Errorf("quote can't be empty")
+	}
+
+	if highlight.Start < 0 || highlight.End < highlight.Start {
+		return highlight, fmt.Errorf("start and end are not valid offsets")
+	}
+
+	if prefix := []rune(highlight.Prefix); len(prefix) > maxHighlightContext {
+		highlight.Prefix = string(prefix[len(prefix)-maxHighlightContext:])
+	}
+
+	if suffix := []rune(highlight.Suffix); len(suffix) > maxHighlightContext {
+		highlight.Suffix = string(suffix[:maxHighlightContext])
+	}
+
+	highlight.Note = strings.TrimSpace(highlight.Note)
+	return highlight, nil
+}
+
+// CheckHighlightAnchor makes sure the highlight is in the text of bookmark's readable
+// content, i.e. its end is within the text and its quote occurs there. The offsets are
+// counted in UTF-16 code units, the same way as the text offsets in browser.
+func CheckHighlightAnchor(highlight model.Highlight, book model.Bookmark) error {
+	text := highlightText(book)
+	if highlight.End > len(utf16.Encode([]rune(text))) {
+		return fmt.Errorf("end is beyond the bookmark's content")
+	}
+
+	if !strings.Contains(text, highlight.Quote) {
+		return fmt.Errorf("quote is not found in the bookmark's content")
+	}
+
+	return nil
+}
+
+// highlightText returns the text that highlights are anchored to, which is the text of
+// bookmark's HTML content as shown in reader view, or its plain content if there's no HTML.
+func highlightText(book model.Bookmark) string {
+	if book.HTML == "" {
+		return book.Content
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(book.HTML))
+	if err != nil {
+		return book.Content
+	}
+
+	return doc.Text()
+}
+
+// WriteHighlightsMarkdown writes the highlights of bookmarks as Markdown
+// document, with a section for each bookmark in the same order. Bookmarks
+// without any highlight are skipped.
+func WriteHighlightsMarkdown(w io.Writer, title string, bookmarks []model.Bookmark, highlights []model.Highlight) error {
+	grouped := map[int][]model.Highlight{}
+	for _, highlight := range highlights {
+		grouped[highlight.BookmarkID] = append(grouped[highlight.BookmarkID], highlight)
+	}
+
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "# %s\n", title)
+
+	for _, book := range bookmarks {
+		if len(grouped[book.ID]) == 0 {
+			continue
+		}
+
+		bookTitle := book.Title
+		if bookTitle == "" {
+			bookTitle = book.URL
+		}
+
+		bookTitle = strings.NewReplacer("[", `\[`, "]", `\]`).Replace(bookTitle)
+		fmt.Fprintf(buf, "\n## [%s](%s)\n", bookTitle, book.URL)
+
+		for _, highlight := range grouped[book.ID] {
+			buf.WriteString("\n")
+			for _, line := range strings.Split(strings.TrimSpace(highlight.Quote), "\n") {
+				fmt.Fprintf(buf, "> %s\n", strings.TrimSpace(line))
+			}
+
+			if highlight.Note != "" {
+				fmt.Fprintf(buf, "\n%s\n", highlight.Note)
+			}
+		}
+	}
+
+	return buf.Flush()
+}
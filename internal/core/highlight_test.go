@@ -0,0 +1,111 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+func TestCleanHighlight(t *testing.T) {
+	tests := []struct {
+		name      string
+		highlight model.Highlight
+		wantErr   bool
+	}{
+		{"valid", model.Highlight{Quote: " quote ", Start: 1, End: 8, Note: " note "}, false},
+		{"empty quote", model.Highlight{Quote: "  ", Start: 1, End: 3}, true},
+		{"negative start", model.Highlight{Quote: "quote", Start: -1, End: 4}, true},
+		{"end before start", model.Highlight{Quote: "quote", Start: 5, End: 4}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CleanHighlight(tt.highlight)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CleanHighlight() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && (got.Quote != tt.highlight.Quote || got.Note != "note") {
+				t.Errorf("CleanHighlight() = %+v", got)
+			}
+		})
+	}
+
+	got, _ := CleanHighlight(model.Highlight{
+		Quote:  "quote",
+		Prefix: strings.Repeat("a", 70) + "before",
+		Suffix: "after" + strings.Repeat("z", 70),
+	})
+	if len(got.Prefix) != maxHighlightContext || !strings.HasSuffix(got.Prefix, "before") ||
+		len(got.Suffix) != maxHighlightContext || !strings.HasPrefix(got.Suffix, "after") {
+		t.Errorf("CleanHighlight() context = %q, %q", got.Prefix, got.Suffix)
+	}
+}
+
+func TestWriteHighlightsMarkdown(t *testing.T) {
+	bookmarks := []model.Bookmark{
+		{ID: 2, URL: "https://example.com/go", Title: "Go [tips]"},
+		{ID: 1, URL: "https://example.com/none", Title: "No highlights"},
+		{ID: 3, URL: "https://example.com/untitled"},
+	}
+
+	highlights := []model.Highlight{
+		{BookmarkID: 2, Quote: "first line\n  second line", Note: "worth reading"},
+		{BookmarkID: 2, Quote: "another"},
+		{BookmarkID: 3, Quote: "untitled"},
+	}
+
+	var sb strings.Builder
+	if err := WriteHighlightsMarkdown(&sb, "Highlights", bookmarks, highlights); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# Highlights
+
+## [Go \[tips\]](https://example.com/go)
+
+> first line
+> second line
+
+worth reading
+
+> another
+
+## [https://example.com/untitled](https://example.com/untitled)
+
+> untitled
+`
+	if sb.String() != want {
+		t.Errorf("WriteHighlightsMarkdown() =\n%s\nwant\n%s", sb.String(), want)
+	}
+}
+
+func TestCheckHighlightAnchor(t *testing.T) {
+	book := model.Bookmark{
+		Content: "Plain content",
+		HTML:    "<p>Café \U0001F600 <b>Golang</b> tips</p>",
+	}
+
+	tests := []struct {
+		name      string
+		book      model.Bookmark
+		highlight model.Highlight
+		wantErr   bool
+	}{
+		{"valid", book, model.Highlight{Quote: "Golang tips", Start: 8, End: 19}, false},
+		{"end beyond content", book, model.Highlight{Quote: "Golang tips", Start: 8, End: 20}, true},
+		{"quote not in content", book, model.Highlight{Quote: "Rust tips", Start: 8, End: 17}, true},
+		{"quote across elements", book, model.Highlight{Quote: "\U0001F600 Golang", Start: 5, End: 14}, false},
+		{"plain content", model.Bookmark{Content: "Plain content"}, model.Highlight{Quote: "content", Start: 6, End: 13}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckHighlightAnchor(tt.highlight, tt.book)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckHighlightAnchor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	nurl "net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+// linkCheckBodySize is the maximum size of page that read for detecting soft 404.
+const linkCheckBodySize = 512 << 10
+
+// rxNotFound matches the common title of error page, used for detecting soft 404.
+var rxNotFound = regexp.MustCompile(`(?i)\b(404|page not found|not found|` +
+	`(does not|doesn't|no longer) exists?|no longer available|page (is )?unavailable)\b`)
+
+// CheckLink checks whether the bookmark's URL is still reachable. HEAD request is used
+// first since it's cheap, then GET request if HEAD is not supported or the page is HTML,
+// which content is needed to detect soft 404, i.e. error page returned with status 200.
+func CheckLink(fetcher *Fetcher, book model.Bookmark) model.LinkCheck {
+	result := model.LinkCheck{
+		BookmarkID: book.ID,
+		Checked:    time.Now().UTC().Format("2006-01-02 15:04:05"),
+	}
+
+	resp, err := sendLinkRequest(fetcher, "HEAD", book.URL)
+	needGet := err != nil
+	if err == nil {
+		resp.Body.Close()
+		needGet = !headSupported(resp.StatusCode) ||
+			(resp.StatusCode < 300 && strings.Contains(resp.Header.Get("Content-Type"), "text/html"))
+	}
+
+	var body []byte
+	if needGet {
+		resp, err = sendLinkRequest(fetcher, "GET", book.URL)
+		if err == nil {
+			body, _ = io.ReadAll(io.LimitReader(resp.Body, linkCheckBodySize))
+			resp.Body.Close()
+		}
+	}
+
+	if err != nil {
+		result.Broken = true
+		result.Message = err.Error()
+		return result
+	}
+
+	result.Method = resp.Request.Method
+	result.StatusCode = resp.StatusCode
+	if finalURL := resp.Request.URL.String(); finalURL != book.URL {
+		result.FinalURL = finalURL
+	}
+
+	switch {
+	case isBrokenStatus(resp.StatusCode):
+		result.Broken = true
+		result.Message = resp.Status
+	case resp.StatusCode < 300:
+		if reason := detectSoftNotFound(book, resp.Request.URL, body); reason != "" {
+			result.Broken = true
+			result.SoftNotFound = true
+			result.Message = "soft 404: " + reason
+		}
+	}
+
+	return result
+}
+
+// ApplyLinkStatus sets the link fields of the bookmark from its latest check, which may be nil
+// if it's never checked. When the original is broken, the bookmark links to its archive instead,
+// or marked as lost if it doesn't have any archive.
+func ApplyLinkStatus(book *model.Bookmark, latestCheck *model.LinkCheck, archiveURL string) {
+	book.LinkURL = book.URL
+	book.Broken = latestCheck != nil && latestCheck.Broken
+	book.Lost = false
+
+	if !book.Broken {
+		return
+	}
+
+	if book.HasArchive {
+		book.LinkURL = archiveURL
+	} else {
+		book.Lost = true
+	}
+}
+
+// CheckLinks checks the bookmarks concurrently, using at most the specified number
+// of requests at the same time. Function fn is called for each result, one by one.
+func CheckLinks(fetcher *Fetcher, bookmarks []model.Bookmark, concurrency int, fn func(model.Bookmark, model.LinkCheck)) {
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	semaphore := make(chan struct{}, concurrency)
+
+	for _, book := range bookmarks {
+		wg.Add(1)
+
+		go func(book model.Bookmark) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+			}()
+
+			result := CheckLink(fetcher, book)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			fn(book, result)
+		}(book)
+	}
+
+	wg.Wait()
+}
+
+// sendLinkRequest sends request for checking link. Its body must be closed by caller.
+func sendLinkRequest(fetcher *Fetcher, method, url string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return fetcher.Do(req)
+}
+
+// headSupported checks if the status code of HEAD request can be trusted. Some servers
+// don't implement HEAD, or reject it while GET to the same URL works fine.
+func headSupported(statusCode int) bool {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound,
+		http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return false
+	default:
+		return statusCode < 500
+	}
+}
+
+// isBrokenStatus checks if the status code means the page is gone. Status that means
+// the page exists but restricted, e.g. login required or rate limited, is not broken.
+func isBrokenStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	default:
+		return statusCode >= 400
+	}
+}
+
+// detectSoftNotFound checks if the page is error page although its status is success.
+// Returns the reason if it is, or empty string if it's not.
+func detectSoftNotFound(book model.Bookmark, finalURL *nurl.URL, body []byte) string {
+	// Site that redirects missing page into its home page
+	originalURL, err := nurl.Parse(book.URL)
+	if err == nil && strings.Trim(originalURL.Path, "/") != "" && strings.Trim(finalURL.Path, "/") == "" &&
+		normalizeDomain(originalURL.Host) == normalizeDomain(finalURL.Host) && finalURL.RawQuery == "" {
+		return "redirected to home page"
+	}
+
+	if len(body) == 0 {
+		return ""
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	// Site that shows error message with status 200. Skip it if the bookmark itself
+	// looks like error page, e.g. an article about HTTP status.
+	if rxNotFound.MatchString(book.Title) {
+		return ""
+	}
+
+	for _, selector := range []string{"title", "h1"} {
+		text := normalizeText(doc.Find(selector).First().Text())
+		if match := rxNotFound.FindString(text); match != "" {
+			return fmt.Sprintf("%s says %q", selector, match)
+		}
+	}
+
+	return ""
+}
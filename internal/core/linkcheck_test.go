@@ -0,0 +1,138 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+func TestCheckLink(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<html><head><title>Home</title></head></html>")
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>Some article</title></head><body><h1>Some article</h1></body></html>")
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+	mux.HandleFunc("/soft", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>Oops</title></head><body><h1>Page not found</h1></body></html>")
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := DefaultFetcherConfig()
+	cfg.MaxRetries = 0
+	fetcher, _ := NewFetcher(cfg)
+
+	tests := []struct {
+		name        string
+		path        string
+		title       string
+		wantStatus  int
+		wantMethod  string
+		wantFinal   string
+		wantBroken  bool
+		wantSoft404 bool
+	}{
+		{"reachable", "/article", "Some article", 200, "GET", "", false, false},
+		{"redirected", "/moved", "Some article", 200, "GET", "/article", false, false},
+		{"not found", "/gone", "Gone", 404, "GET", "", true, false},
+		{"server error", "/error", "Error", 500, "GET", "", true, false},
+		{"restricted", "/private", "Private", 403, "GET", "", false, false},
+		{"not html", "/file.pdf", "Document", 200, "HEAD", "", false, false},
+		{"head not allowed", "/no-head", "Document", 200, "GET", "", false, false},
+		{"redirected to home", "/missing", "Missing", 200, "GET", "/", true, true},
+		{"error page", "/soft", "Some article", 200, "GET", "", true, true},
+		{"article about 404", "/soft", "Why page not found happens", 200, "GET", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := model.Bookmark{ID: 1, URL: server.URL + tt.path, Title: tt.title}
+			got := CheckLink(fetcher, book)
+
+			wantFinal := ""
+			if tt.wantFinal != "" {
+				wantFinal = server.URL + tt.wantFinal
+			}
+
+			if got.StatusCode != tt.wantStatus || got.Method != tt.wantMethod || got.FinalURL != wantFinal {
+				t.Errorf("CheckLink() = %d %s %q, want %d %s %q",
+					got.StatusCode, got.Method, got.FinalURL, tt.wantStatus, tt.wantMethod, wantFinal)
+			}
+
+			if got.Broken != tt.wantBroken || got.SoftNotFound != tt.wantSoft404 {
+				t.Errorf("CheckLink() broken = %v, soft404 = %v, want %v, %v (%s)",
+					got.Broken, got.SoftNotFound, tt.wantBroken, tt.wantSoft404, got.Message)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		got := CheckLink(fetcher, model.Bookmark{ID: 1, URL: "http://127.0.0.1:1/"})
+		if !got.Broken || got.Message == "" {
+			t.Errorf("CheckLink() = %+v, want broken with message", got)
+		}
+	})
+}
+
+func TestApplyLinkStatus(t *testing.T) {
+	archiveURL := "/bookmark/1/archive/"
+	tests := []struct {
+		name        string
+		hasArchive  bool
+		check       *model.LinkCheck
+		wantBroken  bool
+		wantLost    bool
+		wantLinkURL string
+	}{
+		{"never checked", false, nil, false, false, "https://example.com"},
+		{"reachable", true, &model.LinkCheck{}, false, false, "https://example.com"},
+		{"broken with archive", true, &model.LinkCheck{Broken: true}, true, false, archiveURL},
+		{"broken without archive", false, &model.LinkCheck{Broken: true}, true, true, "https://example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := model.Bookmark{ID: 1, URL: "https://example.com", HasArchive: tt.hasArchive}
+			ApplyLinkStatus(&book, tt.check, archiveURL)
+
+			if book.Broken != tt.wantBroken || book.Lost != tt.wantLost || book.LinkURL != tt.wantLinkURL {
+				t.Errorf("ApplyLinkStatus() = %v, %v, %q, want %v, %v, %q",
+					book.Broken, book.Lost, book.LinkURL, tt.wantBroken, tt.wantLost, tt.wantLinkURL)
+			}
+		})
+	}
+}
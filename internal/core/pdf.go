@@ -0,0 +1,289 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	nurl "net/url"
+	"path"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// pdfThumbnailWidth is the width of the rendered first page, in pixels.
+const pdfThumbnailWidth = 600
+
+var (
+	pdfFontsOnce        sync.Once
+	pdfRegular, pdfBold *opentype.Font
+)
+
+// PDFExtractor extracts metadata and text from PDF document.
+type PDFExtractor struct{}
+
+// Name returns name of the extractor.
+func (e *PDFExtractor) Name() string {
+	return "pdf"
+}
+
+// Match checks if the content is PDF document.
+func (e *PDFExtractor) Match(url *nurl.URL, contentType string) bool {
+	return IsPDF(url, contentType)
+}
+
+// Extract extracts title, author and full text of the document,
+// and renders its first page as thumbnail.
+func (e *PDFExtractor) Extract(req ExtractRequest) (article Article, err error) {
+	// The PDF parser panics on malformed document, so recover from it
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to parse PDF: %v", r)
+		}
+	}()
+
+	doc, err := pdf.NewReader(bytes.NewReader(req.Content), int64(len(req.Content)))
+	if err != nil {
+		return Article{}, fmt.Errorf("failed to parse PDF: %v", err)
+	}
+
+	// Extract text from each page
+	pages := make([]string, 0, doc.NumPage())
+	for i := 1; i <= doc.NumPage(); i++ {
+		page := doc.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		if text := pdfPageText(page); text != "" {
+			pages = append(pages, text)
+		}
+	}
+
+	info := doc.Trailer().Key("Info")
+	article = Article{
+		Title:    normalizeText(info.Key("Title").Text()),
+		Author:   normalizeText(info.Key("Author").Text()),
+		Excerpt:  normalizeText(info.Key("Subject").Text()),
+		Content:  strings.Join(pages, "\n\n"),
+		Readable: len(pages) > 0,
+	}
+
+	var firstPage pdf.Page
+	if doc.NumPage() > 0 {
+		firstPage = doc.Page(1)
+	}
+
+	// If document doesn't have title, use the largest text in first page,
+	// which usually is the title, or the file name.
+	if article.Title == "" && !firstPage.V.IsNull() {
+		article.Title = largestPDFText(firstPage)
+	}
+
+	if article.Title == "" {
+		article.Title = strings.TrimSuffix(path.Base(req.URL.Path), path.Ext(req.URL.Path))
+	}
+
+	if article.Excerpt == "" && len(pages) > 0 {
+		article.Excerpt = truncateText(normalizeText(pages[0]), 300)
+	}
+
+	for _, page := range pages {
+		article.HTML += `<section class="pdf-page">`
+		for _, paragraph := range strings.Split(page, "\n\n") {
+			article.HTML += textToHTML(strings.ReplaceAll(paragraph, "\n", " "))
+		}
+		article.HTML += `</section>`
+	}
+
+	if !firstPage.V.IsNull() {
+		article.Thumbnail = renderPDFPage(firstPage, pdfThumbnailWidth)
+	}
+
+	return article, nil
+}
+
+// IsPDF checks if the content type or URL is PDF document.
+func IsPDF(url *nurl.URL, contentType string) bool {
+	if strings.Contains(contentType, "application/pdf") {
+		return true
+	}
+
+	isBinary := contentType == "" ||
+		strings.Contains(contentType, "application/octet-stream") ||
+		strings.Contains(contentType, "binary/octet-stream")
+	return isBinary && url != nil && strings.EqualFold(path.Ext(url.Path), ".pdf")
+}
+
+// pdfPageText returns the text in the page. Each line is separated by
+// new line, and paragraphs are separated by empty line.
+func pdfPageText(page pdf.Page) string {
+	var sb strings.Builder
+	var last pdf.Text
+	for i, t := range page.Content().Text {
+		if i > 0 {
+			lineGap := math.Abs(last.Y - t.Y)
+			switch {
+			case lineGap > last.FontSize*1.8:
+				sb.WriteString("\n\n")
+			case lineGap > last.FontSize*0.5:
+				sb.WriteString("\n")
+			case t.X-(last.X+last.W) > last.FontSize*0.2 && !strings.HasSuffix(last.S, " ") && !strings.HasPrefix(t.S, " "):
+				sb.WriteString(" ")
+			}
+		}
+
+		sb.WriteString(t.S)
+		last = t
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+// largestPDFText returns the text with the largest font size in the page.
+func largestPDFText(page pdf.Page) string {
+	var maxSize float64
+	var sb strings.Builder
+	for _, text := range page.Content().Text {
+		switch {
+		case text.FontSize > maxSize+0.5:
+			maxSize = text.FontSize
+			sb.Reset()
+			sb.WriteString(text.S)
+		case math.Abs(text.FontSize-maxSize) <= 0.5:
+			sb.WriteString(text.S)
+		}
+	}
+
+	title := normalizeText(sb.String())
+	if utf8.RuneCountInString(title) > 200 {
+		return ""
+	}
+
+	return title
+}
+
+// renderPDFPage draws the text and rectangles of the page in their position.
+// The embedded fonts are not parsed, so the text is drawn using Go fonts in
+// the same size, while images and vector paths are skipped.
+func renderPDFPage(page pdf.Page, width int) image.Image {
+	// Page size is in points, default to A4
+	x0, y0, x1, y1 := 0.0, 0.0, 595.0, 842.0
+	if box := pdfMediaBox(page); box.Len() == 4 {
+		x0, y0 = box.Index(0).Float64(), box.Index(1).Float64()
+		x1, y1 = box.Index(2).Float64(), box.Index(3).Float64()
+	}
+
+	if x1-x0 <= 0 || y1-y0 <= 0 {
+		x0, y0, x1, y1 = 0, 0, 595, 842
+	}
+
+	scale := float64(width) / (x1 - x0)
+	height := int(math.Round((y1 - y0) * scale))
+	if height > width*4 {
+		height = width * 4
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	content := page.Content()
+	rectColor := image.NewUniform(color.Gray{Y: 0xdd})
+	for _, r := range content.Rect {
+		rect := image.Rect(
+			int(math.Floor((r.Min.X-x0)*scale)),
+			int(math.Floor((y1-r.Max.Y)*scale)),
+			int(math.Ceil((r.Max.X-x0)*scale)),
+			int(math.Ceil((y1-r.Min.Y)*scale)),
+		)
+		draw.Draw(img, rect, rectColor, image.Point{}, draw.Src)
+	}
+
+	faces := map[string]font.Face{}
+	defer func() {
+		for _, face := range faces {
+			face.Close()
+		}
+	}()
+
+	drawer := font.Drawer{Dst: img, Src: image.NewUniform(color.Black)}
+	for _, t := range content.Text {
+		if strings.TrimSpace(t.S) == "" {
+			continue
+		}
+
+		// Limit the size, so a malformed font size can't make huge glyph
+		size := math.Round(t.FontSize * scale)
+		size = math.Max(1, math.Min(size, float64(height)))
+		bold := strings.Contains(strings.ToLower(t.Font), "bold")
+		key := fmt.Sprintf("%v-%v", size, bold)
+		face, exist := faces[key]
+		if !exist {
+			var err error
+			if face, err = pdfFontFace(size, bold); err != nil {
+				continue
+			}
+			faces[key] = face
+		}
+
+		drawer.Face = face
+		drawer.Dot = fixed.P(int(math.Round((t.X-x0)*scale)), int(math.Round((y1-t.Y)*scale)))
+		drawer.DrawString(t.S)
+	}
+
+	return img
+}
+
+// pdfFontFace returns Go font face in the specified size, in pixels.
+func pdfFontFace(size float64, bold bool) (font.Face, error) {
+	pdfFontsOnce.Do(func() {
+		pdfRegular, _ = opentype.Parse(goregular.TTF)
+		pdfBold, _ = opentype.Parse(gobold.TTF)
+	})
+
+	f := pdfRegular
+	if bold {
+		f = pdfBold
+	}
+
+	if f == nil {
+		return nil, fmt.Errorf("failed to parse font")
+	}
+
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72})
+}
+
+// pdfMediaBox returns the page size, which may be inherited from its parents.
+func pdfMediaBox(page pdf.Page) pdf.Value {
+	for v := page.V; !v.IsNull(); v = v.Key("Parent") {
+		if box := v.Key("MediaBox"); !box.IsNull() {
+			return box
+		}
+	}
+	return pdf.Value{}
+}
+
+// truncateText cuts the text to the maximum length, on word boundary if possible.
+func truncateText(text string, maxLength int) string {
+	if utf8.RuneCountInString(text) <= maxLength {
+		return text
+	}
+
+	runes := []rune(text)[:maxLength]
+	truncated := string(runes)
+	if idx := strings.LastIndex(truncated, " "); idx > maxLength/2 {
+		truncated = truncated[:idx]
+	}
+
+	return truncated + "…"
+}
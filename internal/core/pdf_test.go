@@ -0,0 +1,112 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	nurl "net/url"
+	"strings"
+	"testing"
+)
+
+// buildPDF creates simple one page PDF with the specified metadata and text lines.
+func buildPDF(title, author string, lines ...string) []byte {
+	var stream strings.Builder
+	stream.WriteString("BT /F1 24 Tf 72 760 Td (" + lines[0] + ") Tj ET\n")
+	for i, line := range lines[1:] {
+		stream.WriteString(fmt.Sprintf("BT /F1 12 Tf 72 %d Td (%s) Tj ET\n", 720-i*16, line))
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", stream.Len(), stream.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Title (%s) /Author (%s) >>", title, author),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xrefOffset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xrefOffset)
+
+	return buf.Bytes()
+}
+
+func TestPDFExtractor_Extract(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    []byte
+		wantTitle  string
+		wantAuthor string
+	}{{
+		name:       "with metadata",
+		content:    buildPDF("Attention Is All You Need", "Ashish Vaswani", "Attention", "The dominant sequence transduction models for text"),
+		wantTitle:  "Attention Is All You Need",
+		wantAuthor: "Ashish Vaswani",
+	}, {
+		name:      "title from largest text",
+		content:   buildPDF("", "", "Large Title", "Body text of the document"),
+		wantTitle: "Large Title",
+	}}
+
+	url, _ := nurl.Parse("https://example.com/files/paper.pdf")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &PDFExtractor{}
+			if !e.Match(url, "application/octet-stream") {
+				t.Fatalf("Match() = false, want true")
+			}
+
+			article, err := e.Extract(ExtractRequest{URL: url, ContentType: "application/pdf", Content: tt.content})
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+
+			if article.Title != tt.wantTitle || article.Author != tt.wantAuthor {
+				t.Errorf("Extract() = %q by %q, want %q by %q", article.Title, article.Author, tt.wantTitle, tt.wantAuthor)
+			}
+
+			if !article.Readable || !strings.Contains(article.Content, "text") {
+				t.Errorf("Extract() content = %q", article.Content)
+			}
+
+			if article.Thumbnail == nil || article.Thumbnail.Bounds().Dy() != 776 {
+				t.Fatalf("Extract() thumbnail is not rendered")
+			}
+
+			// The title is drawn at 72pt from left and 32pt from top
+			if !hasDarkPixel(article.Thumbnail, image.Rect(70, 20, 200, 45)) {
+				t.Errorf("Extract() thumbnail doesn't contain the title")
+			}
+		})
+	}
+
+	_, err := (&PDFExtractor{}).Extract(ExtractRequest{URL: url, Content: []byte("not a PDF")})
+	if err == nil {
+		t.Errorf("Extract() of invalid PDF should return error")
+	}
+}
+
+// hasDarkPixel checks if the area of image contains any dark pixel.
+func hasDarkPixel(img image.Image, area image.Rectangle) bool {
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if r, g, b, _ := img.At(x, y).RGBA(); r+g+b < 0x8000*3 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -4,36 +4,35 @@ import (
 	"bytes"
 	"fmt"
 	"image"
-	"image/color"
-	"image/draw"
-	"image/jpeg"
 	"io"
-	"math"
 	"net/url"
-	"os"
 	"path"
-	fp "path/filepath"
 	"strconv"
 	"strings"
 
-	"github.com/disintegration/imaging"
-	"github.com/go-shiori/go-readability"
+	"github.com/go-shiori/shiori/internal/database"
 	"github.com/go-shiori/shiori/internal/model"
-	"github.com/go-shiori/warc"
-
-	// Add support for png
-	_ "image/png"
+	"github.com/go-shiori/shiori/internal/storage"
 )
 
+// maxImagePixels is the maximum number of pixels in image that will be processed as thumbnail.
+const maxImagePixels = 50 * 1000 * 1000
+
 // ProcessRequest is the request for processing bookmark.
 type ProcessRequest struct {
-	DataDir     string
-	Bookmark    model.Bookmark
-	Content     io.Reader
-	ContentType string
-	KeepTitle   bool
-	KeepExcerpt bool
-	LogArchival bool
+	DB            database.DB
+	Storage       storage.Storage
+	Bookmark      model.Bookmark
+	Content       io.Reader
+	ContentType   string
+	KeepTitle     bool
+	KeepExcerpt   bool
+	LogArchival   bool
+	Canonicalizer *Canonicalizer
+	Fetcher       *Fetcher
+	Extractors    *ExtractorRegistry
+	Browser       *Browser
+	Quota         StorageQuota
 }
 
 // ProcessBookmark process the bookmark and archive it if needed.
@@ -47,41 +46,80 @@ func ProcessBookmark(req ProcessRequest) (book model.Bookmark, isFatalErr bool,
 		return book, true, fmt.Errorf("bookmark ID is not valid")
 	}
 
-	// Split bookmark content so it can be processed several times
-	archivalInput := bytes.NewBuffer(nil)
-	readabilityInput := bytes.NewBuffer(nil)
-	readabilityCheckInput := bytes.NewBuffer(nil)
-
-	var multiWriter io.Writer
-	if !strings.Contains(contentType, "text/html") {
-		multiWriter = io.MultiWriter(archivalInput)
-	} else {
-		multiWriter = io.MultiWriter(archivalInput, readabilityInput, readabilityCheckInput)
+	// Make sure fetcher is defined
+	fetcher := req.Fetcher
+	if fetcher == nil {
+		fetcher, err = NewFetcher(DefaultFetcherConfig())
+		if err != nil {
+			return book, true, fmt.Errorf("failed to create fetcher: %v", err)
+		}
 	}
 
-	_, err = io.Copy(multiWriter, req.Content)
+	// Read bookmark content once, so it can be processed several times
+	content, err := readAllLimited(req.Content, fetcher.MaxBodySize())
 	if err != nil {
 		return book, false, fmt.Errorf("failed to process article: %v", err)
 	}
 
-	// If this is HTML, parse for readable content
-	var imageURLs []string
-	if strings.Contains(contentType, "text/html") {
-		isReadable := readability.Check(readabilityCheckInput)
-
-		nurl, err := url.Parse(book.URL)
+	// Render the page in browser if needed, so the content that rendered client-side
+	// is processed and archived. If it fails, the downloaded content is used instead.
+	var rendered RenderResult
+	var renderErr error
+	if ShouldRender(req.Browser, book) && strings.Contains(contentType, "text/html") {
+		result, err := req.Browser.Render(book.URL, fetcher)
 		if err != nil {
-			return book, true, fmt.Errorf("Failed to parse url: %v", err)
+			renderErr = fmt.Errorf("failed to render page: %v", err)
+		} else if strings.HasPrefix(result.ContentType, "text/html") {
+			rendered = result
+			content, contentType = result.Content, result.ContentType
 		}
+	}
+
+	// Make sure extractors is defined
+	extractors := req.Extractors
+	if extractors == nil {
+		extractors = NewExtractorRegistry()
+	}
+
+	nurl, err := url.Parse(book.URL)
+	if err != nil {
+		return book, true, fmt.Errorf("Failed to parse url: %v", err)
+	}
+
+	// PDF doesn't have any other copy for offline reading, so always archive it
+	if IsPDF(nurl, contentType) {
+		contentType = "application/pdf"
+		book.CreateArchive = true
+	}
 
-		article, err := readability.FromReader(readabilityInput, nurl)
+	// If there are extractor for this page, parse for readable content
+	var imageURLs []string
+	var faviconURL string
+	var thumbnail image.Image
+	if extractors.Supports(nurl, contentType) {
+		article, err := extractors.Extract(ExtractRequest{
+			URL:         nurl,
+			ContentType: contentType,
+			Content:     content,
+			Fetcher:     fetcher,
+		})
 		if err != nil {
 			return book, false, fmt.Errorf("failed to parse article: %v", err)
 		}
 
-		book.Author = article.Byline
-		book.Content = article.TextContent
-		book.HTML = article.Content
+		// If page specifies its canonical URL, use it
+		if req.Canonicalizer != nil && strings.Contains(contentType, "text/html") {
+			htmlInput := bytes.NewReader(content)
+			if linkCanonical := req.Canonicalizer.LinkCanonical(book.URL, htmlInput); linkCanonical != "" {
+				if canonical, err := req.Canonicalizer.Canonicalize(linkCanonical); err == nil && !isURLTaken(req.DB, book.ID, canonical) {
+					book.URL = canonical
+				}
+			}
+		}
+
+		book.Author = article.Author
+		book.Content = article.Content
+		book.HTML = article.HTML
 
 		// If title and excerpt doesnt have submitted value, use from article
 		if !req.KeepTitle || book.Title == "" {
@@ -102,125 +140,117 @@ func ProcessBookmark(req ProcessRequest) (book model.Bookmark, isFatalErr bool,
 			imageURLs = append(imageURLs, article.Image)
 		}
 
-		if article.Favicon != "" {
-			imageURLs = append(imageURLs, article.Favicon)
-		}
+		faviconURL = article.Favicon
+		thumbnail = article.Thumbnail
 
-		if !isReadable {
+		if !article.Readable {
 			book.Content = ""
 		}
 
 		book.HasContent = book.Content != ""
 	}
 
+	// If the page is rendered, use its screenshot as thumbnail
+	if thumbnail == nil && rendered.Screenshot != nil {
+		thumbnail = pageTopThumbnail(rendered.Screenshot)
+	}
+
+	// If the bookmark itself is an image, use it as thumbnail
+	if strings.HasPrefix(contentType, "image/") {
+		thumbnail, _ = DecodeImage(content, contentType)
+	}
+
 	// Save article image to local disk
 	strID := strconv.Itoa(book.ID)
-	imgPath := fp.Join(req.DataDir, "thumb", strID)
+	imgURL := path.Join("/", "bookmark", strID, "thumb")
+
+	if thumbnail != nil {
+		if err = SaveThumbnails(thumbnail, req.Storage, book.ID); err == nil {
+			book.ImageURL = imgURL
+		}
+	}
 
 	for _, imageURL := range imageURLs {
-		err = downloadBookImage(imageURL, imgPath)
-		if err == nil {
-			book.ImageURL = path.Join("/", "bookmark", strID, "thumb")
+		if book.ImageURL != "" {
 			break
 		}
+
+		img, err := downloadBookImage(fetcher, imageURL)
+		if err == nil && SaveThumbnails(img, req.Storage, book.ID) == nil {
+			book.ImageURL = imgURL
+		}
+	}
+
+	// Save favicon for the bookmark's domain
+	if err := SaveFavicon(fetcher, req.Storage, book.URL, faviconURL); err == nil {
+		book.FaviconURL = FaviconURL(req.Storage, "/", book.URL)
 	}
 
 	// If needed, create offline archive as well
 	if book.CreateArchive {
-		archivePath := fp.Join(req.DataDir, "archive", fmt.Sprintf("%d", book.ID))
-		os.Remove(archivePath)
-
-		archivalRequest := warc.ArchivalRequest{
-			URL:         book.URL,
-			Reader:      archivalInput,
+		err = CreateArchive(ArchiveRequest{
+			DB:          req.DB,
+			Storage:     req.Storage,
+			Bookmark:    book,
+			Content:     content,
 			ContentType: contentType,
-			UserAgent:   userAgent,
-			LogEnabled:  req.LogArchival,
-		}
-
-		err = warc.NewArchive(archivalRequest, archivePath)
+			Captures:    rendered.Resources,
+			LogArchival: req.LogArchival,
+			Fetcher:     fetcher,
+			Quota:       req.Quota,
+		})
 		if err != nil {
-			return book, false, fmt.Errorf("failed to create archive: %v", err)
+			return book, false, err
 		}
 
 		book.HasArchive = true
+
+		// If image can't be downloaded, look for it in the archive
+		if book.ImageURL == "" {
+			img, err := ThumbnailFromArchive(req.Storage, book.ID)
+			if err == nil && SaveThumbnails(img, req.Storage, book.ID) == nil {
+				book.ImageURL = imgURL
+			}
+		}
 	}
 
-	return book, false, nil
+	return book, false, renderErr
 }
 
-func downloadBookImage(url, dstPath string) error {
+func downloadBookImage(fetcher *Fetcher, url string) (image.Image, error) {
 	// Fetch data from URL
-	resp, err := httpClient.Get(url)
+	resp, err := fetcher.Get(url)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// Make sure it's JPG or PNG image
+	// Make sure it's an image
 	cp := resp.Header.Get("Content-Type")
-	if !strings.Contains(cp, "image/jpeg") && !strings.Contains(cp, "image/png") {
-		return fmt.Errorf("%s is not a supported image", url)
+	if !strings.HasPrefix(cp, "image/") {
+		return nil, fmt.Errorf("%s is not a supported image", url)
 	}
 
-	// At this point, the download has finished successfully.
-	// Prepare destination file.
-	err = os.MkdirAll(fp.Dir(dstPath), os.ModePerm)
+	imgData, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to create image dir: %v", err)
+		return nil, fmt.Errorf("failed to download image %s: %v", url, err)
 	}
 
-	dstFile, err := os.Create(dstPath)
+	img, err := DecodeImage(imgData, cp)
 	if err != nil {
-		return fmt.Errorf("failed to create image file: %v", err)
+		return nil, fmt.Errorf("failed to parse image %s: %v", url, err)
 	}
-	defer dstFile.Close()
-
-	// Parse image and process it.
-	// If image is smaller than 600x400 or its ratio is less than 4:3, resize.
-	// Else, save it as it is.
-	img, _, err := image.Decode(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to parse image %s: %v", url, err)
-	}
-
-	imgRect := img.Bounds()
-	imgWidth := imgRect.Dx()
-	imgHeight := imgRect.Dy()
-	imgRatio := float64(imgWidth) / float64(imgHeight)
-
-	if imgWidth >= 600 && imgHeight >= 400 && imgRatio > 1.3 {
-		err = jpeg.Encode(dstFile, img, nil)
-	} else {
-		// Create background
-		bg := image.NewNRGBA(imgRect)
-		draw.Draw(bg, imgRect, image.NewUniform(color.White), image.Point{}, draw.Src)
-		draw.Draw(bg, imgRect, img, image.Point{}, draw.Over)
-
-		bg = imaging.Fill(bg, 600, 400, imaging.Center, imaging.Lanczos)
-		bg = imaging.Blur(bg, 150)
-		bg = imaging.AdjustBrightness(bg, 30)
-
-		// Create foreground
-		fg := imaging.Fit(img, 600, 400, imaging.Lanczos)
-
-		// Merge foreground and background
-		bgRect := bg.Bounds()
-		fgRect := fg.Bounds()
-		fgPosition := image.Point{
-			X: bgRect.Min.X - int(math.Round(float64(bgRect.Dx()-fgRect.Dx())/2)),
-			Y: bgRect.Min.Y - int(math.Round(float64(bgRect.Dy()-fgRect.Dy())/2)),
-		}
-
-		draw.Draw(bg, bgRect, fg, fgPosition, draw.Over)
 
-		// Save to file
-		err = jpeg.Encode(dstFile, bg, nil)
-	}
+	return img, nil
+}
 
-	if err != nil {
-		return fmt.Errorf("failed to save image %s: %v", url, err)
+// isURLTaken checks whether the URL is already used by another bookmark, since
+// bookmark's URL must be unique. If db is not defined, the URL is never taken.
+func isURLTaken(db database.DB, id int, url string) bool {
+	if db == nil {
+		return false
 	}
 
-	return nil
+	existing, exist := db.GetBookmark(0, url)
+	return exist && existing.ID != id
 }
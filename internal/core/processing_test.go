@@ -0,0 +1,79 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	fp "path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+)
+
+func TestProcessBookmark_CanonicalURL(t *testing.T) {
+	db, err := database.OpenSQLiteDatabase(fp.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	canonicalizer, _ := NewCanonicalizer("standard")
+	fetcher, err := NewFetcher(FetcherConfig{MaxBodySize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := `<html><head><title>Article</title><link rel="canonical" href="/article"></head>` +
+		`<body><article><p>` + strings.Repeat("Some text of the article. ", 50) + `</p></article></body></html>`
+
+	process := func(book model.Bookmark) model.Bookmark {
+		result, _, err := ProcessBookmark(ProcessRequest{
+			DB:            db,
+			Storage:       storage.NewLocal(t.TempDir()),
+			Bookmark:      book,
+			Content:       strings.NewReader(html),
+			ContentType:   "text/html; charset=UTF-8",
+			Canonicalizer: canonicalizer,
+			Fetcher:       fetcher,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// Canonical URL is not used yet, so it replaces bookmark's URL
+	canonical := server.URL + "/article"
+	first := process(model.Bookmark{ID: 1, URL: canonical + "?page=1"})
+	if first.URL != canonical {
+		t.Fatalf("URL = %q, want %q", first.URL, canonical)
+	}
+
+	if _, err = db.SaveBookmarks(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// Canonical URL is used by bookmark 1, so bookmark 2 keeps its URL
+	second := process(model.Bookmark{ID: 2, URL: canonical + "?page=2"})
+	if second.URL != canonical+"?page=2" {
+		t.Errorf("URL = %q, want the old URL", second.URL)
+	}
+
+	if _, err = db.SaveBookmarks(second); err != nil {
+		t.Errorf("SaveBookmarks() error = %v", err)
+	}
+
+	// Bookmark that already uses the canonical URL keeps it
+	if again := process(first); again.URL != canonical {
+		t.Errorf("URL = %q, want %q", again.URL, canonical)
+	}
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// hopHeaders are the headers that only meant for a single connection,
+// so they're not forwarded by the proxy.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"TE",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// guardedProxy is HTTP proxy used by headless browser. Every connection is made
+// by the fetcher's dialer, which checks the address after it's resolved, so the
+// browser can't reach the denied addresses, e.g. through DNS rebinding or WebSocket.
+type guardedProxy struct {
+	listener  net.Listener
+	server    *http.Server
+	transport *http.Transport
+	dial      func(context.Context, string, string) (net.Conn, error)
+}
+
+// startGuardedProxy starts the proxy on random loopback port.
+func startGuardedProxy(dial func(context.Context, string, string) (net.Conn, error)) (*guardedProxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &guardedProxy{
+		listener: listener,
+		dial:     dial,
+		transport: &http.Transport{
+			DialContext:           dial,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+			ResponseHeaderTimeout: time.Minute,
+		},
+	}
+
+	p.server = &http.Server{Handler: p}
+	go p.server.Serve(listener)
+
+	return p, nil
+}
+
+// URL returns the address of the proxy.
+func (p *guardedProxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+// Close stops the proxy.
+func (p *guardedProxy) Close() error {
+	p.transport.CloseIdleConnections()
+	return p.server.Close()
+}
+
+func (p *guardedProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+
+	if !r.URL.IsAbs() || (r.URL.Scheme != "http" && r.URL.Scheme != "https") {
+		http.Error(w, "only absolute HTTP URL is allowed", http.StatusBadRequest)
+		return
+	}
+
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	removeHopHeaders(req.Header)
+
+	resp, err := p.transport.RoundTrip(req)
+	if err != nil {
+		proxyError(w, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// tunnel connects to the requested address, then copies the data between it and the client.
+func (p *guardedProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	target, err := p.dial(r.Context(), "tcp", r.Host)
+	if err != nil {
+		proxyError(w, err)
+		return
+	}
+	defer target.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection can't be tunneled", http.StatusInternalServerError)
+		return
+	}
+
+	client, buffer, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	if _, err = io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+
+	// The buffer might already contain data sent by client after the request
+	done := make(chan struct{})
+	go func() {
+		io.Copy(target, buffer)
+		target.Close()
+		close(done)
+	}()
+
+	io.Copy(client, target)
+	client.Close()
+	<-done
+}
+
+// proxyError responds with 403 if the address is denied, or 502 for the other errors.
+func proxyError(w http.ResponseWriter, err error) {
+	var errDenied *ErrDeniedAddress
+	if errors.As(err, &errDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			header.Del(strings.TrimSpace(name))
+		}
+	}
+
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
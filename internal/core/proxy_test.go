@@ -0,0 +1,89 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	nurl "net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGuardedProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "private page")
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		denyPrivate bool
+		wantStatus  int
+	}{
+		{"allowed", false, http.StatusOK},
+		{"denied", true, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy, err := startGuardedProxy(newDialContext(time.Second, tt.denyPrivate, ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer proxy.Close()
+
+			// Plain HTTP request
+			proxyURL, _ := nurl.Parse(proxy.URL())
+			client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("GET status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && string(body) != "private page" {
+				t.Errorf("GET body = %q", body)
+			}
+
+			// Tunnel, like the one used for HTTPS and WebSocket
+			conn, err := net.Dial("tcp", proxyURL.Host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			host := strings.TrimPrefix(server.URL, "http://")
+			fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", host, host)
+			reader := bufio.NewReader(conn)
+			connectResp, err := http.ReadResponse(reader, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if connectResp.StatusCode != tt.wantStatus {
+				t.Fatalf("CONNECT status = %d, want %d", connectResp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", host)
+			tunneledResp, err := http.ReadResponse(reader, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ = io.ReadAll(tunneledResp.Body)
+			if string(body) != "private page" {
+				t.Errorf("tunneled body = %q", body)
+			}
+		})
+	}
+}
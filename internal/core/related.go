@@ -0,0 +1,289 @@
+package core
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+const (
+	// DefaultRelatedBookmarks is the default number of related bookmarks.
+	DefaultRelatedBookmarks = 10
+
+	// nIndexedTerms is the maximum number of terms indexed for each bookmark,
+	// which keeps the index small without changing the similarity much.
+	nIndexedTerms = 200
+)
+
+// RelatedBookmark is a bookmark similar to the queried one. Score is the
+// cosine similarity of both bookmarks, between 0 and 1.
+type RelatedBookmark struct {
+	ID    int     `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// RelatedIndex is TF-IDF index of the bookmarks' text for finding related
+// bookmarks. The terms of each bookmark are saved in database, and loaded into
+// memory when the index is synced for the first time. Bookmarks are indexed when
+// saved, and the changes made elsewhere are picked up by Sync.
+type RelatedIndex struct {
+	db      database.DB
+	mx      sync.Mutex
+	loaded  bool
+	docs    map[int]relatedDoc
+	weights *tfidf
+}
+
+type relatedDoc struct {
+	Modified string
+	Terms    termVector
+}
+
+// NewRelatedIndex creates index whose terms are saved in the database. It's
+// empty until it's synced, but bookmarks can be indexed before that.
+func NewRelatedIndex(db database.DB) *RelatedIndex {
+	return &RelatedIndex{
+		db:      db,
+		docs:    map[int]relatedDoc{},
+		weights: newTFIDF(),
+	}
+}
+
+// Len returns the number of indexed bookmarks.
+func (idx *RelatedIndex) Len() int {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+
+	return len(idx.docs)
+}
+
+// Index adds the bookmarks into index, replacing their old version, and saves
+// their terms. The bookmarks must be loaded with their content.
+func (idx *RelatedIndex) Index(bookmarks ...model.Bookmark) error {
+	terms := make([]model.BookmarkTerms, len(bookmarks))
+	for i, book := range bookmarks {
+		terms[i] = bookmarkTermsOf(book)
+	}
+
+	idx.mx.Lock()
+	if idx.loaded {
+		for _, item := range terms {
+			idx.put(item)
+		}
+	}
+	idx.mx.Unlock()
+
+	return idx.db.SaveBookmarkTerms(terms...)
+}
+
+// Sync updates the index to match the bookmarks in database. New and modified
+// bookmarks are indexed, while the deleted ones are removed. Returns the number
+// of changed bookmarks. The index is only locked while the changes are applied,
+// so it can be used while the bookmarks are being indexed.
+func (idx *RelatedIndex) Sync() (int, error) {
+	return idx.sync(false)
+}
+
+// Rebuild indexes all bookmarks in database again.
+func (idx *RelatedIndex) Rebuild() (int, error) {
+	return idx.sync(true)
+}
+
+func (idx *RelatedIndex) sync(rebuild bool) (int, error) {
+	// Load the saved terms once, so only the changed bookmarks are indexed
+	idx.mx.Lock()
+	loaded := idx.loaded
+	idx.mx.Unlock()
+
+	if !loaded && !rebuild {
+		saved, err := idx.db.GetBookmarkTerms()
+		if err != nil {
+			return 0, err
+		}
+
+		idx.mx.Lock()
+		if !idx.loaded {
+			for _, item := range saved {
+				idx.put(item)
+			}
+			idx.loaded = true
+		}
+		idx.mx.Unlock()
+	}
+
+	bookmarks, err := idx.db.GetBookmarks(database.GetBookmarksOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	// Find the changed bookmarks, remembering their indexed version, so the
+	// ones indexed again in the meantime are not overwritten
+	idx.mx.Lock()
+	saved := map[int]struct{}{}
+	changed := map[int]string{}
+	changedIDs := []int{}
+	for _, book := range bookmarks {
+		saved[book.ID] = struct{}{}
+		if doc, exist := idx.docs[book.ID]; rebuild || !exist || doc.Modified != book.Modified {
+			changed[book.ID] = doc.Modified
+			changedIDs = append(changedIDs, book.ID)
+		}
+	}
+
+	removedIDs := []int{}
+	for id := range idx.docs {
+		if _, exist := saved[id]; !exist {
+			removedIDs = append(removedIDs, id)
+		}
+	}
+	idx.mx.Unlock()
+
+	// Index the changed bookmarks, loading their content one batch at a time. The terms
+	// that already saved for the same version, e.g. by another process, are used as it is.
+	terms := []model.BookmarkTerms{}
+	for start := 0; start < len(changedIDs); start += DefaultBulkBatchSize {
+		end := start + DefaultBulkBatchSize
+		if end > len(changedIDs) {
+			end = len(changedIDs)
+		}
+
+		batch, err := idx.db.GetBookmarks(database.GetBookmarksOptions{
+			IDs:         changedIDs[start:end],
+			WithContent: true,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		current := map[int]model.BookmarkTerms{}
+		if !rebuild {
+			savedTerms, err := idx.db.GetBookmarkTerms(changedIDs[start:end]...)
+			if err != nil {
+				return 0, err
+			}
+
+			for _, item := range savedTerms {
+				current[item.BookmarkID] = item
+			}
+		}
+
+		newTerms := []model.BookmarkTerms{}
+		for _, book := range batch {
+			if item, exist := current[book.ID]; exist && item.Modified == book.Modified {
+				terms = append(terms, item)
+				continue
+			}
+
+			item := bookmarkTermsOf(book)
+			terms = append(terms, item)
+			newTerms = append(newTerms, item)
+		}
+
+		if err = idx.db.SaveBookmarkTerms(newTerms...); err != nil {
+			return 0, err
+		}
+	}
+
+	// Apply the changes
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+
+	for _, item := range terms {
+		if doc := idx.docs[item.BookmarkID]; doc.Modified == changed[item.BookmarkID] {
+			idx.put(item)
+		}
+	}
+
+	for _, id := range removedIDs {
+		idx.remove(id)
+	}
+	idx.loaded = true
+
+	return len(terms) + len(removedIDs), nil
+}
+
+// Related returns up to n bookmarks that most similar to the bookmark with
+// specified ID, most similar first. Bookmarks that don't share any term with
+// it are never returned.
+func (idx *RelatedIndex) Related(id int, n int) []RelatedBookmark {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+
+	if n <= 0 {
+		n = DefaultRelatedBookmarks
+	}
+
+	doc, exist := idx.docs[id]
+	if !exist {
+		return []RelatedBookmark{}
+	}
+
+	vector := idx.weights.vector(doc.Terms)
+	related := []RelatedBookmark{}
+	for otherID, other := range idx.docs {
+		if otherID == id {
+			continue
+		}
+
+		similarity := vector.cosine(idx.weights.vector(other.Terms))
+		if similarity > 0 {
+			related = append(related, RelatedBookmark{
+				ID:    otherID,
+				Score: math.Round(math.Min(similarity, 1)*1000) / 1000,
+			})
+		}
+	}
+
+	sort.Slice(related, func(i, j int) bool {
+		if related[i].Score != related[j].Score {
+			return related[i].Score > related[j].Score
+		}
+		return related[i].ID < related[j].ID
+	})
+
+	if len(related) > n {
+		related = related[:n]
+	}
+
+	return related
+}
+
+// bookmarkTermsOf counts the terms in bookmark's text, keeping only the most frequent ones.
+func bookmarkTermsOf(book model.Bookmark) model.BookmarkTerms {
+	terms := bookmarkTerms(book.Title, book.Excerpt, book.Content)
+	if len(terms) > nIndexedTerms {
+		kept := termVector{}
+		for _, term := range terms.top(nIndexedTerms) {
+			kept[term] = terms[term]
+		}
+		terms = kept
+	}
+
+	return model.BookmarkTerms{
+		BookmarkID: book.ID,
+		Modified:   book.Modified,
+		Terms:      terms,
+	}
+}
+
+// put adds the bookmark's terms into index, replacing its old version.
+func (idx *RelatedIndex) put(item model.BookmarkTerms) {
+	idx.remove(item.BookmarkID)
+
+	terms := termVector(item.Terms)
+	idx.docs[item.BookmarkID] = relatedDoc{
+		Modified: item.Modified,
+		Terms:    terms,
+	}
+	idx.weights.add(terms)
+}
+
+func (idx *RelatedIndex) remove(id int) {
+	if doc, exist := idx.docs[id]; exist {
+		idx.weights.remove(doc.Terms)
+		delete(idx.docs, id)
+	}
+}
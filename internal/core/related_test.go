@@ -0,0 +1,85 @@
+package core
+
+import (
+	fp "path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+func TestRelatedIndex(t *testing.T) {
+	db, err := database.OpenSQLiteDatabase(fp.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.SaveBookmarks(
+		model.Bookmark{ID: 1, URL: "https://example.com/1", Title: "Goroutines and channels", Content: "Concurrency in golang"},
+		model.Bookmark{ID: 2, URL: "https://example.com/2", Title: "Golang channels explained", Content: "Buffered channels"},
+		model.Bookmark{ID: 3, URL: "https://example.com/3", Title: "Sourdough bread", Content: "Flour and water"},
+		model.Bookmark{ID: 4, URL: "https://example.com/4", Title: "Golang generics", Content: "Type parameters"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relatedIDs := func(idx *RelatedIndex, id int) []int {
+		ids := []int{}
+		for _, item := range idx.Related(id, 10) {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+
+	// Sync indexes all bookmarks, and unrelated bookmark is never returned
+	idx := NewRelatedIndex(db)
+	if n, err := idx.Sync(); err != nil || n != 4 {
+		t.Fatalf("Sync() = %d, %v, want 4", n, err)
+	}
+
+	if got := relatedIDs(idx, 1); !reflect.DeepEqual(got, []int{2, 4}) {
+		t.Errorf("Related(1) = %v, want [2 4]", got)
+	}
+
+	// Terms are loaded from database, and only the changed bookmarks are synced
+	idx = NewRelatedIndex(db)
+	if n, err := idx.Sync(); err != nil || n != 0 {
+		t.Fatalf("Sync() after reopen = %d, %v, want 0", n, err)
+	}
+
+	if err = db.DeleteBookmarks(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = idx.Index(model.Bookmark{ID: 3, Title: "Golang bread", Content: "Goroutines"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := relatedIDs(idx, 1); !reflect.DeepEqual(got, []int{2, 3, 4}) {
+		t.Errorf("Related(1) after indexing = %v, want [2 3 4]", got)
+	}
+
+	if n, err := idx.Sync(); err != nil || n != 2 {
+		t.Fatalf("Sync() after changes = %d, %v, want 2", n, err)
+	}
+
+	if got := relatedIDs(idx, 1); !reflect.DeepEqual(got, []int{4}) || idx.Len() != 3 {
+		t.Errorf("Related(1) after sync = %v, want [4]", got)
+	}
+
+	// Rebuild indexes all bookmarks again, without changing the result
+	if n, err := idx.Rebuild(); err != nil || n != 3 {
+		t.Fatalf("Rebuild() = %d, %v, want 3", n, err)
+	}
+
+	if got := relatedIDs(idx, 1); !reflect.DeepEqual(got, []int{4}) {
+		t.Errorf("Related(1) after rebuild = %v, want [4]", got)
+	}
+}
@@ -0,0 +1,199 @@
+package core
+
+import (
+	"fmt"
+	nurl "net/url"
+	"regexp"
+	"strings"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+// RuleSet is the compiled tagging rules, ready to be applied to bookmarks.
+type RuleSet struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	model.Rule
+	rxPath *regexp.Regexp
+}
+
+// CleanRule normalizes the rule's domain, keywords and tags, then makes sure
+// it has at least one condition and one action, and its path pattern is valid.
+func CleanRule(rule model.Rule) (model.Rule, error) {
+	cleanList := func(items []string, normalize func(string) string) []string {
+		cleaned := []string{}
+		seen := map[string]struct{}{}
+		for _, item := range items {
+			item = normalize(item)
+			if _, exist := seen[item]; !exist && item != "" {
+				seen[item] = struct{}{}
+				cleaned = append(cleaned, item)
+			}
+		}
+		return cleaned
+	}
+
+	normalizeKeyword := func(keyword string) string {
+		return strings.Join(strings.Fields(strings.ToLower(keyword)), " ")
+	}
+
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(rule.Domain)), "www.")
+	rule.PathPattern = strings.TrimSpace(rule.PathPattern)
+	rule.TitleKeywords = cleanList(rule.TitleKeywords, normalizeKeyword)
+	rule.ContentKeywords = cleanList(rule.ContentKeywords, normalizeKeyword)
+	rule.Tags = cleanList(rule.Tags, database.NormalizeTagName)
+
+	if rule.Domain == "" && rule.PathPattern == "" && len(rule.TitleKeywords) == 0 && len(rule.ContentKeywords) == 0 {
+		return rule, fmt.Errorf("rule needs at least one condition")
+	}
+
+	if len(rule.Tags) == 0 && rule.Public == nil {
+		return rule, fmt.Errorf("rule needs tags or public flag to set")
+	}
+
+	if strings.ContainsAny(rule.Domain, "/:") {
+		return rule, fmt.Errorf("domain %q should not contain scheme or path", rule.Domain)
+	}
+
+	if _, err := regexp.Compile(rule.PathPattern); err != nil {
+		return rule, fmt.Errorf("invalid path pattern: %v", err)
+	}
+
+	if rule.Name == "" {
+		rule.Name = strings.Join(rule.Tags, ", ")
+	}
+
+	return rule, nil
+}
+
+// NewRuleSet compiles the enabled rules.
+func NewRuleSet(rules []model.Rule) (*RuleSet, error) {
+	rs := &RuleSet{}
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		compiled := compiledRule{Rule: rule}
+		if rule.PathPattern != "" {
+			rxPath, err := regexp.Compile(rule.PathPattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path pattern in rule %d: %v", rule.ID, err)
+			}
+			compiled.rxPath = rxPath
+		}
+
+		rs.rules = append(rs.rules, compiled)
+	}
+
+	return rs, nil
+}
+
+// LoadRuleSet fetches the tagging rules from database and compiles them.
+func LoadRuleSet(db database.DB) (*RuleSet, error) {
+	rules, err := db.GetRules()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRuleSet(rules)
+}
+
+// Apply applies the matching rules to the bookmark, and returns the names of
+// the rules that changed it. Rules only add tags, so applying them twice is safe.
+func (rs *RuleSet) Apply(book *model.Bookmark) []string {
+	if rs == nil || len(rs.rules) == 0 {
+		return nil
+	}
+
+	url, err := nurl.Parse(book.URL)
+	if err != nil {
+		return nil
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(url.Hostname()), "www.")
+	title := strings.ToLower(book.Title)
+	content := strings.ToLower(book.Content)
+
+	applied := []string{}
+	for _, rule := range rs.rules {
+		if !rule.match(host, url.Path, title, content) {
+			continue
+		}
+
+		changed := false
+		for _, name := range rule.Tags {
+			if addBookmarkTag(book, name) {
+				changed = true
+			}
+		}
+
+		if rule.Public != nil {
+			public := 0
+			if *rule.Public {
+				public = 1
+			}
+
+			if book.Public != public {
+				book.Public = public
+				changed = true
+			}
+		}
+
+		if changed {
+			applied = append(applied, rule.Name)
+		}
+	}
+
+	return applied
+}
+
+func (rule compiledRule) match(host, path, title, content string) bool {
+	if rule.Domain != "" && host != rule.Domain && !strings.HasSuffix(host, "."+rule.Domain) {
+		return false
+	}
+
+	if rule.rxPath != nil && !rule.rxPath.MatchString(path) {
+		return false
+	}
+
+	containsAny := func(text string, keywords []string) bool {
+		for _, keyword := range keywords {
+			if strings.Contains(text, keyword) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(rule.TitleKeywords) > 0 && !containsAny(title, rule.TitleKeywords) {
+		return false
+	}
+
+	if len(rule.ContentKeywords) > 0 && !containsAny(content, rule.ContentKeywords) {
+		return false
+	}
+
+	return true
+}
+
+// addBookmarkTag adds the tag into bookmark, unless it already has it.
+// Returns true if the tag is added.
+func addBookmarkTag(book *model.Bookmark, name string) bool {
+	for i, tag := range book.Tags {
+		if database.NormalizeTagName(tag.Name) == name {
+			if tag.Deleted {
+				book.Tags[i].Deleted = false
+				return true
+			}
+			return false
+		}
+	}
+
+	book.Tags = append(book.Tags, model.Tag{Name: name})
+	return true
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+func TestRuleSetApply(t *testing.T) {
+	public := true
+	rules, err := NewRuleSet([]model.Rule{
+		{ID: 1, Name: "code", Domain: "github.com", Tags: []string{"code"}, Enabled: true},
+		{ID: 2, Name: "go", Domain: "github.com", PathPattern: "^/golang/", Tags: []string{"go"}, Enabled: true},
+		{ID: 3, Name: "recipe", TitleKeywords: []string{"recipe", "cooking"}, Public: &public, Enabled: true},
+		{ID: 4, Name: "rust", ContentKeywords: []string{"cargo"}, Tags: []string{"rust"}, Enabled: true},
+		{ID: 5, Name: "disabled", Domain: "github.com", Tags: []string{"never"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		book    model.Bookmark
+		applied string
+		tags    string
+		public  int
+	}{
+		{model.Bookmark{URL: "https://www.github.com/golang/go"}, "code,go", "code,go", 0},
+		{model.Bookmark{URL: "https://gist.github.com/rust", Content: "Run Cargo build"}, "code,rust", "code,rust", 0},
+		{model.Bookmark{URL: "https://notgithub.com/golang/", Tags: []model.Tag{{Name: "Code"}}}, "", "Code", 0},
+		{model.Bookmark{URL: "https://github.com/x", Tags: []model.Tag{{Name: "Code"}}}, "", "Code", 0},
+		{model.Bookmark{URL: "https://example.com", Title: "Best Cooking tips"}, "recipe", "", 1},
+		{model.Bookmark{URL: "https://example.com", Title: "Recipes", Public: 1}, "", "", 1},
+	}
+
+	for _, tt := range tests {
+		book := tt.book
+		applied := rules.Apply(&book)
+
+		tags := []string{}
+		for _, tag := range book.Tags {
+			tags = append(tags, tag.Name)
+		}
+
+		if strings.Join(applied, ",") != tt.applied || strings.Join(tags, ",") != tt.tags || book.Public != tt.public {
+			t.Errorf("Apply(%s) = %v, tags %v, public %d", tt.book.URL, applied, tags, book.Public)
+		}
+	}
+}
+
+func TestCleanRule(t *testing.T) {
+	public := false
+	tests := []struct {
+		rule  model.Rule
+		valid bool
+	}{
+		{model.Rule{Domain: " WWW.GitHub.com ", Tags: []string{"Code", "code"}}, true},
+		{model.Rule{TitleKeywords: []string{"x"}, Public: &public}, true},
+		{model.Rule{Tags: []string{"code"}}, false},
+		{model.Rule{Domain: "github.com"}, false},
+		{model.Rule{Domain: "https://github.com", Tags: []string{"code"}}, false},
+		{model.Rule{PathPattern: "(", Tags: []string{"code"}}, false},
+	}
+
+	for _, tt := range tests {
+		if _, err := CleanRule(tt.rule); (err == nil) != tt.valid {
+			t.Errorf("CleanRule(%+v) = %v, want valid %v", tt.rule, err, tt.valid)
+		}
+	}
+
+	rule, _ := CleanRule(tests[0].rule)
+	if rule.Domain != "github.com" || strings.Join(rule.Tags, ",") != "code" || rule.Name != "code" {
+		t.Errorf("CleanRule() = %+v", rule)
+	}
+}
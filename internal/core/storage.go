@@ -0,0 +1,331 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+	"github.com/go-shiori/shiori/internal/warc"
+)
+
+// sharedResourceMinSize is the minimum compressed size of archived resource to be moved into
+// the shared store. Smaller resources are kept in the archive, since each shared resource
+// is a separate file which uses at least one disk block.
+const sharedResourceMinSize = 4 << 10
+
+// rxResourceHash matches the name of file in shared resource store.
+var rxResourceHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// StorageQuota limits the disk space used by offline archives. Zero means unlimited.
+type StorageQuota struct {
+	// MaxTotal is the maximum size of all archives and the shared resources.
+	MaxTotal int64
+	// MaxArchive is the maximum size of a single archive, not counting its shared resources.
+	MaxArchive int64
+}
+
+// StorageReport is the detailed usage of storage, along with the files that can be removed.
+type StorageReport struct {
+	model.StorageUsage
+
+	// ArchiveSizes is size of the archive file for each bookmark, and ResourceSizes
+	// is size of each shared resource, keyed by its hash.
+	ArchiveSizes  map[int]int64
+	ResourceSizes map[string]int64
+
+	// References is number of references to shared resources from all archives,
+	// and SavedSize is the disk space saved since they are not stored in each archive.
+	References int
+	SavedSize  int64
+
+	// OrphanArchives is archives which bookmark doesn't exist anymore, and UnusedResources
+	// is shared resources which are not referenced by any archive.
+	OrphanArchives  []string
+	OrphanSize      int64
+	UnusedResources []string
+	UnusedSize      int64
+}
+
+// NewResourceStore returns the store for resources that shared between archives.
+func NewResourceStore(store storage.Storage) warc.ResourceStore {
+	return resourceStore{store: store}
+}
+
+// OpenArchive opens offline archive of the bookmark, along with its shared resources.
+// Archive in remote storage is downloaded first, since it can only be opened from local disk.
+func OpenArchive(store storage.Storage, id int) (*warc.Archive, error) {
+	archivePath, isTemp, err := localArchivePath(store, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isTemp {
+		return warc.OpenWithStore(archivePath, NewResourceStore(store))
+	}
+
+	archive, err := warc.OpenTemp(archivePath, NewResourceStore(store))
+	if err != nil {
+		os.Remove(archivePath)
+	}
+
+	return archive, err
+}
+
+// DedupeArchive moves the large resources in archive of the bookmark into the shared store.
+func DedupeArchive(store storage.Storage, id int) (warc.DedupeResult, error) {
+	archivePath, isTemp, err := localArchivePath(store, id)
+	if err != nil {
+		return warc.DedupeResult{}, err
+	}
+
+	if isTemp {
+		defer os.Remove(archivePath)
+	}
+
+	result, err := warc.Dedupe(archivePath, NewResourceStore(store), sharedResourceMinSize)
+	if err != nil || !isTemp || result.Shared == 0 {
+		return result, err
+	}
+
+	return result, uploadArchive(store, id, archivePath)
+}
+
+// ArchiveSize returns size of the archive file of the bookmark, or 0 if it doesn't have one.
+// Shared resources are not counted, since they are not removed along with the archive.
+func ArchiveSize(store storage.Storage, id int) int64 {
+	info, err := store.Stat(ArchiveName(id))
+	if err != nil {
+		return 0
+	}
+	return info.Size
+}
+
+// CheckQuota checks if the storage usage recorded in database is still within the quota.
+func CheckQuota(db database.DB, quota StorageQuota) error {
+	if quota.MaxTotal <= 0 {
+		return nil
+	}
+
+	usage, err := getStorageUsage(db)
+	if err != nil {
+		return err
+	}
+
+	if usage.Total() >= quota.MaxTotal {
+		return fmt.Errorf("archive storage quota exceeded (%s of %s used)",
+			FormatByteSize(usage.Total()), FormatByteSize(quota.MaxTotal))
+	}
+
+	return nil
+}
+
+// getStorageUsage returns the storage usage recorded in database, which is updated as
+// archives are saved, so the storage doesn't have to be listed each time.
+func getStorageUsage(db database.DB) (model.StorageUsage, error) {
+	if db == nil {
+		return model.StorageUsage{}, fmt.Errorf("database is required to check storage quota")
+	}
+
+	usage, err := db.GetStorageUsage()
+	if err != nil {
+		return usage, fmt.Errorf("failed to get storage usage: %v", err)
+	}
+
+	return usage, nil
+}
+
+// AnalyzeStorage reports how the storage is used by the archives of the bookmarks. Files that
+// modified within minAge are never reported as removable, since they might be still in use by
+// archival process that currently running.
+func AnalyzeStorage(store storage.Storage, bookmarkIDs []int, minAge time.Duration) (StorageReport, error) {
+	report := StorageReport{
+		ArchiveSizes:  map[int]int64{},
+		ResourceSizes: map[string]int64{},
+	}
+
+	mapBookmarks := map[int]struct{}{}
+	for _, id := range bookmarkIDs {
+		mapBookmarks[id] = struct{}{}
+	}
+
+	isOld := func(info storage.FileInfo) bool {
+		return time.Since(info.ModTime) >= minAge
+	}
+
+	// Check the archives and what shared resources they use
+	mapReferences := map[string]int{}
+
+	err := store.Walk("archive/", func(info storage.FileInfo) error {
+		report.Archives++
+		report.ArchiveSize += info.Size
+
+		id, err := strconv.Atoi(strings.TrimPrefix(info.Name, "archive/"))
+		if _, exist := mapBookmarks[id]; err != nil || !exist {
+			if isOld(info) {
+				report.OrphanArchives = append(report.OrphanArchives, info.Name)
+				report.OrphanSize += info.Size
+			}
+			return nil
+		}
+
+		report.ArchiveSizes[id] = info.Size
+
+		archive, err := OpenArchive(store, id)
+		if err != nil {
+			return nil
+		}
+		defer archive.Close()
+
+		refs, _ := archive.SharedResources()
+		for _, ref := range refs {
+			mapReferences[ref]++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	// Check the shared resources
+	err = store.Walk("resources/", func(info storage.FileInfo) error {
+		report.SharedResources++
+		report.SharedSize += info.Size
+
+		hash := path.Base(info.Name)
+		report.ResourceSizes[hash] = info.Size
+
+		nReferences := mapReferences[hash]
+		report.References += nReferences
+		if nReferences > 1 {
+			report.SavedSize += int64(nReferences-1) * info.Size
+		}
+
+		if nReferences == 0 && isOld(info) {
+			report.UnusedResources = append(report.UnusedResources, info.Name)
+			report.UnusedSize += info.Size
+		}
+
+		return nil
+	})
+
+	sort.Strings(report.OrphanArchives)
+	sort.Strings(report.UnusedResources)
+	return report, err
+}
+
+// FormatByteSize formats the size in bytes into human readable form, e.g. 1.5 MB.
+func FormatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// localArchivePath returns path of the bookmark's archive on local disk. Archive in remote
+// storage is downloaded into temporary file, which must be removed by the caller.
+func localArchivePath(store storage.Storage, id int) (string, bool, error) {
+	if local, ok := store.(*storage.Local); ok {
+		archivePath := local.Path(ArchiveName(id))
+		if info, err := os.Stat(archivePath); err != nil || info.IsDir() {
+			return "", false, fmt.Errorf("archive doesn't exist")
+		}
+		return archivePath, false, nil
+	}
+
+	src, err := store.Open(ArchiveName(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", false, fmt.Errorf("archive doesn't exist")
+		}
+		return "", false, err
+	}
+	defer src.Close()
+
+	tmpFile, err := os.CreateTemp("", "shiori-archive-*")
+	if err != nil {
+		return "", false, err
+	}
+
+	_, err = io.Copy(tmpFile, src)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return "", false, fmt.Errorf("failed to download archive: %v", err)
+	}
+
+	return tmpFile.Name(), true, nil
+}
+
+// uploadArchive saves the archive file as the bookmark's archive in storage.
+func uploadArchive(store storage.Storage, id int, archivePath string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return store.Write(ArchiveName(id), f)
+}
+
+// resourceStore keeps the shared resources in storage. Each resource is stored as a file
+// named by its hash, grouped into sub directories by the first two characters. If sizes
+// is not nil, the size of each saved resource is recorded in it.
+type resourceStore struct {
+	store storage.Storage
+	sizes map[string]int64
+}
+
+func (s resourceStore) name(hash string) (string, error) {
+	if !rxResourceHash.MatchString(hash) {
+		return "", fmt.Errorf("invalid resource hash %q", hash)
+	}
+	return "resources/" + hash[:2] + "/" + hash, nil
+}
+
+func (s resourceStore) Get(hash string) ([]byte, error) {
+	name, err := s.name(hash)
+	if err != nil {
+		return nil, err
+	}
+	return storage.ReadFile(s.store, name)
+}
+
+// Put saves the resource. Existing resource is written again as well, so it's marked as
+// recently used and not removed by garbage collector that running at the same time.
+func (s resourceStore) Put(hash string, content []byte) error {
+	name, err := s.name(hash)
+	if err != nil {
+		return err
+	}
+	if err = s.store.Write(name, bytes.NewReader(content)); err != nil {
+		return err
+	}
+
+	if s.sizes != nil {
+		s.sizes[hash] = int64(len(content))
+	}
+
+	return nil
+}
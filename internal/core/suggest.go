@@ -0,0 +1,223 @@
+package core
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+// Default limits for suggesting tags.
+const (
+	DefaultTagSuggestions = 5
+	defaultTagNeighbors   = 10
+	nTagKeywords          = 10
+	minNeighborSimilarity = 0.05
+)
+
+// TagSuggestion is a tag suggested for a bookmark. Score is between 0 and 1,
+// and Exists tells whether the tag is already used by other bookmarks.
+type TagSuggestion struct {
+	Name   string  `json:"name"`
+	Score  float64 `json:"score"`
+	Exists bool    `json:"exists"`
+}
+
+// SuggestTagsRequest is the request for suggesting tags of a bookmark, using
+// the bookmarks in corpus as examples. The bookmark itself is skipped if it's
+// in the corpus, as well as the tags it already has. Terms are the saved terms
+// of the bookmarks in corpus, keyed by bookmark ID. Bookmark whose terms are
+// not saved for its current version is tokenized from its content.
+type SuggestTagsRequest struct {
+	Bookmark  model.Bookmark
+	Corpus    []model.Bookmark
+	Terms     map[int]model.BookmarkTerms
+	Limit     int
+	Neighbors int
+}
+
+// SuggestTags suggests tags for the bookmark. Tags of the most similar tagged
+// bookmarks vote for themselves, weighted by their TF-IDF similarity. The
+// bookmark's top keywords strengthen the existing tags with the same name, or
+// are suggested as new tags.
+func SuggestTags(req SuggestTagsRequest) []TagSuggestion {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultTagSuggestions
+	}
+
+	nNeighbors := req.Neighbors
+	if nNeighbors <= 0 {
+		nNeighbors = defaultTagNeighbors
+	}
+
+	// Build the TF-IDF model from the tagged bookmarks
+	book := req.Bookmark
+	bookTerms := bookmarkTerms(book.Title, book.Excerpt, book.Content)
+	weights := newTFIDF(bookTerms)
+
+	corpus := []model.Bookmark{}
+	corpusTerms := []termVector{}
+	existingTags := map[string]struct{}{}
+	for _, other := range req.Corpus {
+		if other.ID == book.ID && book.ID != 0 || len(other.Tags) == 0 {
+			continue
+		}
+
+		saved, exist := req.Terms[other.ID]
+		terms := termVector(saved.Terms)
+		if !exist || saved.Modified != other.Modified {
+			terms = bookmarkTerms(other.Title, other.Excerpt, other.Content)
+		}
+
+		weights.add(terms)
+		corpus = append(corpus, other)
+		corpusTerms = append(corpusTerms, terms)
+
+		for _, tag := range other.Tags {
+			existingTags[database.NormalizeTagName(tag.Name)] = struct{}{}
+		}
+	}
+
+	// Find the nearest neighbors
+	type neighbor struct {
+		book       model.Bookmark
+		similarity float64
+	}
+
+	vector := weights.vector(bookTerms)
+	neighbors := []neighbor{}
+	for i, other := range corpus {
+		similarity := vector.cosine(weights.vector(corpusTerms[i]))
+		if similarity >= minNeighborSimilarity {
+			neighbors = append(neighbors, neighbor{other, similarity})
+		}
+	}
+
+	sort.SliceStable(neighbors, func(i, j int) bool {
+		return neighbors[i].similarity > neighbors[j].similarity
+	})
+
+	if len(neighbors) > nNeighbors {
+		neighbors = neighbors[:nNeighbors]
+	}
+
+	// Let the neighbors vote for their tags
+	scores := map[string]float64{}
+	totalSimilarity := 0.0
+	for _, neighbor := range neighbors {
+		totalSimilarity += neighbor.similarity
+	}
+
+	for _, neighbor := range neighbors {
+		for _, tag := range neighbor.book.Tags {
+			name := database.NormalizeTagName(tag.Name)
+			scores[name] += neighbor.similarity / totalSimilarity
+		}
+	}
+
+	// Strengthen the tags that match the keywords, or suggest the keywords as new tags.
+	// Existing hierarchical tag matches by its last level, e.g. "go" matches "dev/go".
+	keywords := vector.top(nTagKeywords)
+	for _, keyword := range keywords {
+		weight := vector[keyword] / vector[keywords[0]]
+
+		matched := false
+		for name := range existingTags {
+			if name == keyword || strings.HasSuffix(name, database.TagSeparator+keyword) {
+				scores[name] += weight / 2
+				matched = true
+			}
+		}
+
+		if !matched {
+			scores[keyword] += weight / 3
+		}
+	}
+
+	// Skip the tags that bookmark already has
+	for _, tag := range book.Tags {
+		delete(scores, database.NormalizeTagName(tag.Name))
+	}
+
+	suggestions := []TagSuggestion{}
+	for name, score := range scores {
+		_, exists := existingTags[name]
+		suggestions = append(suggestions, TagSuggestion{
+			Name:   name,
+			Score:  math.Round(math.Min(score, 1)*100) / 100,
+			Exists: exists,
+		})
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Name < suggestions[j].Name
+	})
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	return suggestions
+}
+
+// SuggestBookmarkTags suggests tags for the bookmark, using the tagged bookmarks
+// in database as examples. Their terms are taken from the ones saved by related
+// index, so the content is only loaded for bookmarks that haven't been indexed.
+func SuggestBookmarkTags(db database.DB, book model.Bookmark, limit int) ([]TagSuggestion, error) {
+	tagged, err := db.GetBookmarks(database.GetBookmarksOptions{
+		Tags: []string{"*"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	savedTerms, err := db.GetBookmarkTerms()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := map[int]model.BookmarkTerms{}
+	for _, item := range savedTerms {
+		terms[item.BookmarkID] = item
+	}
+
+	corpus := make([]model.Bookmark, 0, len(tagged))
+	unindexedIDs := []int{}
+	for _, other := range tagged {
+		if item, exist := terms[other.ID]; exist && item.Modified == other.Modified {
+			corpus = append(corpus, other)
+		} else {
+			unindexedIDs = append(unindexedIDs, other.ID)
+		}
+	}
+
+	for start := 0; start < len(unindexedIDs); start += DefaultBulkBatchSize {
+		end := start + DefaultBulkBatchSize
+		if end > len(unindexedIDs) {
+			end = len(unindexedIDs)
+		}
+
+		batch, err := db.GetBookmarks(database.GetBookmarksOptions{
+			IDs:         unindexedIDs[start:end],
+			WithContent: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		corpus = append(corpus, batch...)
+	}
+
+	return SuggestTags(SuggestTagsRequest{
+		Bookmark: book,
+		Corpus:   corpus,
+		Terms:    terms,
+		Limit:    limit,
+	}), nil
+}
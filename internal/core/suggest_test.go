@@ -0,0 +1,127 @@
+package core
+
+import (
+	fp "path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("The Go compiler, version 1.17: it's FAST and go-fmt'd. Über 2021")
+	want := []string{"compiler", "version", "fast", "fmt", "über"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestSuggestTags(t *testing.T) {
+	tagged := func(id int, title, content string, tags ...string) model.Bookmark {
+		book := model.Bookmark{ID: id, Title: title, Content: content}
+		for _, tag := range tags {
+			book.Tags = append(book.Tags, model.Tag{Name: tag})
+		}
+		return book
+	}
+
+	corpus := []model.Bookmark{
+		tagged(1, "Goroutines and channels", "Concurrency in golang with goroutines, channels and select", "dev/golang", "concurrency"),
+		tagged(2, "Error handling in golang", "Wrapping errors with fmt.Errorf and errors.Is in golang", "dev/golang"),
+		tagged(3, "Sourdough bread recipe", "Flour, water, salt and a starter make sourdough bread", "cooking"),
+		tagged(4, "Pizza dough", "Knead the dough with flour and water, then bake the pizza", "cooking"),
+		tagged(5, "Untagged article about goroutines", "Goroutines and channels in golang"),
+	}
+
+	names := func(suggestions []TagSuggestion) []string {
+		result := []string{}
+		for _, suggestion := range suggestions {
+			result = append(result, suggestion.Name)
+		}
+		return result
+	}
+
+	// Neighbors vote for their tags, and keyword matches the last level of hierarchical tag
+	suggestions := SuggestTags(SuggestTagsRequest{
+		Bookmark: tagged(0, "Worker pools in golang", "Goroutines share work through channels"),
+		Corpus:   corpus,
+		Limit:    3,
+	})
+
+	if got := names(suggestions); len(got) != 3 || got[0] != "dev/golang" || got[1] != "concurrency" {
+		t.Errorf("SuggestTags() = %v, want dev/golang and concurrency first", got)
+	}
+
+	if !suggestions[0].Exists || suggestions[0].Score <= suggestions[1].Score || suggestions[0].Score > 1 {
+		t.Errorf("SuggestTags() scores = %+v", suggestions)
+	}
+
+	// Bookmark's own tags and the bookmark itself are skipped
+	suggestions = SuggestTags(SuggestTagsRequest{
+		Bookmark: corpus[2],
+		Corpus:   corpus,
+	})
+
+	for _, suggestion := range suggestions {
+		if suggestion.Name == "cooking" {
+			t.Errorf("SuggestTags() suggested existing tag: %v", names(suggestions))
+		}
+	}
+
+	// Without similar bookmarks, only the keywords are suggested as new tags
+	suggestions = SuggestTags(SuggestTagsRequest{
+		Bookmark: tagged(0, "Kubernetes operators", "Kubernetes operators reconcile custom resources"),
+		Corpus:   corpus,
+		Limit:    1,
+	})
+
+	want := []TagSuggestion{{Name: "kubernetes", Score: 0.33, Exists: false}}
+	if !reflect.DeepEqual(suggestions, want) {
+		t.Errorf("SuggestTags() = %+v, want %+v", suggestions, want)
+	}
+}
+
+func TestSuggestBookmarkTags(t *testing.T) {
+	db, err := database.OpenSQLiteDatabase(fp.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.SaveBookmarks(
+		model.Bookmark{ID: 1, URL: "https://example.com/1", Title: "Cluster notes", Content: "Notes", Tags: []model.Tag{{Name: "ops"}}},
+		model.Bookmark{ID: 2, URL: "https://example.com/2", Title: "Sourdough bread", Content: "Flour and water", Tags: []model.Tag{{Name: "cooking"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The saved terms are used instead of the content, as long as they are
+	// saved for the current version of bookmark
+	book, _ := db.GetBookmark(1, "")
+	err = db.SaveBookmarkTerms(model.BookmarkTerms{
+		BookmarkID: 1,
+		Modified:   book.Modified,
+		Terms:      map[string]float64{"kubernetes": 3, "operators": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suggestions, err := SuggestBookmarkTags(db, model.Bookmark{
+		Title:   "Kubernetes operators",
+		Content: "Kubernetes operators reconcile custom resources",
+	}, 1)
+	if err != nil {
+		t.Fatalf("SuggestBookmarkTags() error = %v", err)
+	}
+
+	if len(suggestions) != 1 || suggestions[0].Name != "ops" {
+		t.Errorf("SuggestBookmarkTags() = %+v, want ops", suggestions)
+	}
+}
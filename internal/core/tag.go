@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+var rxTagColor = regexp.MustCompile(`^#([0-9a-f]{3}|[0-9a-f]{6})$`)
+
+// NormalizeTagColor validates the tag color, which is hex color like #f80 or
+// #ff8800, and returns it in the long lowercase form. Empty color is allowed
+// for removing the color.
+func NormalizeTagColor(color string) (string, error) {
+	original := color
+	color = strings.ToLower(strings.TrimSpace(color))
+	if color == "" {
+		return "", nil
+	}
+
+	if !strings.HasPrefix(color, "#") {
+		color = "#" + color
+	}
+
+	if !rxTagColor.MatchString(color) {
+		return "", fmt.Errorf("invalid color %q, use hex color like #ff8800", original)
+	}
+
+	if len(color) == 4 {
+		color = string([]byte{'#', color[1], color[1], color[2], color[2], color[3], color[3]})
+	}
+
+	return color, nil
+}
+
+// RenameTag changes the name of the tag along with its descendants, e.g.
+// renaming "dev" into "code" turns "dev/go" into "code/go". If the new name
+// is already used, the tags are merged.
+func RenameTag(db database.DB, id int, newName string) (model.Tag, error) {
+	tag, exist := db.GetTag(id, "")
+	if !exist {
+		return model.Tag{}, fmt.Errorf("tag %d is not found", id)
+	}
+
+	newName = database.NormalizeTagName(newName)
+	if newName == "" {
+		return model.Tag{}, fmt.Errorf("tag name is empty")
+	}
+
+	if newName == tag.Name {
+		return tag, nil
+	}
+
+	tags, err := db.GetTags()
+	if err != nil {
+		return model.Tag{}, err
+	}
+
+	prefix := tag.Name + database.TagSeparator
+	for _, descendant := range tags {
+		if strings.HasPrefix(descendant.Name, prefix) {
+			childName := newName + database.TagSeparator + strings.TrimPrefix(descendant.Name, prefix)
+			if _, err = db.MergeTags(childName, descendant.ID); err != nil {
+				return model.Tag{}, fmt.Errorf("failed to rename %s: %v", descendant.Name, err)
+			}
+		}
+	}
+
+	return db.MergeTags(newName, id)
+}
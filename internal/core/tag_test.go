@@ -0,0 +1,71 @@
+package core
+
+import (
+	fp "path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+func TestNormalizeTagColor(t *testing.T) {
+	tests := []struct {
+		color string
+		want  string
+		valid bool
+	}{
+		{"", "", true},
+		{"#FF8800", "#ff8800", true},
+		{"f80", "#ff8800", true},
+		{" #abc ", "#aabbcc", true},
+		{"#ff88", "", false},
+		{"orange", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeTagColor(tt.color)
+		if got != tt.want || (err == nil) != tt.valid {
+			t.Errorf("NormalizeTagColor(%q) = %q, %v, want %q", tt.color, got, err, tt.want)
+		}
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	db, err := database.OpenSQLiteDatabase(fp.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	book := model.Bookmark{ID: 1, URL: "https://example.com", Title: "Example",
+		Tags: []model.Tag{{Name: "dev"}, {Name: "dev/go"}, {Name: "dev/go/test"}, {Name: "code/go"}, {Name: "devops"}}}
+	if _, err = db.SaveBookmarks(book); err != nil {
+		t.Fatal(err)
+	}
+
+	dev, _ := db.GetTag(0, "dev")
+	if _, err = RenameTag(db, dev.ID, "Code"); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := db.GetTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	sort.Strings(names)
+
+	if got := strings.Join(names, ","); got != "code,code/go,code/go/test,devops" {
+		t.Errorf("tags after rename = %s", got)
+	}
+}
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minTermLength is the minimum length of a word to be used as term.
+const minTermLength = 3
+
+// stopWords are common English words that don't say anything about the document.
+var stopWords = makeWordSet(`about above after again against all also and any are aren because been before
+	being below between both but can cannot could couldn did didn does doesn doing don down
+	during each few for from further had hadn has hasn have haven having her here hers herself
+	him himself his how however into isn its itself just let like made make many may more most
+	much must mustn myself need new nor not now off once one only other ought our ours
+	ourselves out over own same shan she should shouldn since some still such than that the
+	their theirs them themselves then there these they this those through too under until upon
+	use used using very via was wasn way well were weren what when where which while who whom
+	why will with within without won would wouldn yet you your yours yourself yourselves http
+	https www com`)
+
+func makeWordSet(words string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, word := range strings.Fields(words) {
+		set[word] = struct{}{}
+	}
+
+	return set
+}
+
+// termVector is sparse vector of term weights.
+type termVector map[string]float64
+
+// tokenize splits the text into lowercase words, skipping stop words, numbers
+// and words that too short.
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	terms := []string{}
+	for _, word := range words {
+		if len([]rune(word)) < minTermLength {
+			continue
+		}
+
+		if _, isStopWord := stopWords[word]; isStopWord {
+			continue
+		}
+
+		if strings.IndexFunc(word, unicode.IsLetter) < 0 {
+			continue
+		}
+
+		terms = append(terms, word)
+	}
+
+	return terms
+}
+
+// bookmarkTerms counts the terms in bookmark's title, excerpt and content.
+// Title and excerpt describe the bookmark better, so their terms count more.
+func bookmarkTerms(title, excerpt, content string) termVector {
+	terms := termVector{}
+	for _, term := range tokenize(title) {
+		terms[term] += 3
+	}
+
+	for _, term := range tokenize(excerpt) {
+		terms[term] += 2
+	}
+
+	for _, term := range tokenize(content) {
+		terms[term]++
+	}
+
+	return terms
+}
+
+// tfidf weights terms of documents by how rare they're in the corpus.
+type tfidf struct {
+	nDocs int
+	df    map[string]int
+}
+
+func newTFIDF(docs ...termVector) *tfidf {
+	model := &tfidf{df: map[string]int{}}
+	for _, doc := range docs {
+		model.add(doc)
+	}
+
+	return model
+}
+
+func (m *tfidf) add(doc termVector) {
+	m.nDocs++
+	for term := range doc {
+		m.df[term]++
+	}
+}
+
+func (m *tfidf) remove(doc termVector) {
+	m.nDocs--
+	for term := range doc {
+		if m.df[term]--; m.df[term] <= 0 {
+			delete(m.df, term)
+		}
+	}
+}
+
+// idf returns smoothed inverse document frequency of the term.
+func (m *tfidf) idf(term string) float64 {
+	return math.Log(float64(1+m.nDocs)/float64(1+m.df[term])) + 1
+}
+
+// vector returns the TF-IDF vector of document, normalized into unit length.
+// Term frequency is dampened, so long documents don't dominate.
+func (m *tfidf) vector(doc termVector) termVector {
+	vector := termVector{}
+	for term, count := range doc {
+		vector[term] = (1 + math.Log(count)) * m.idf(term)
+	}
+
+	return vector.normalize()
+}
+
+func (v termVector) normalize() termVector {
+	norm := 0.0
+	for _, weight := range v {
+		norm += weight * weight
+	}
+
+	if norm == 0 {
+		return v
+	}
+
+	norm = math.Sqrt(norm)
+	for term := range v {
+		v[term] /= norm
+	}
+
+	return v
+}
+
+// cosine returns cosine similarity of two normalized vectors.
+func (v termVector) cosine(other termVector) float64 {
+	if len(other) < len(v) {
+		v, other = other, v
+	}
+
+	similarity := 0.0
+	for term, weight := range v {
+		similarity += weight * other[term]
+	}
+
+	return similarity
+}
+
+// top returns n terms with the highest weight.
+func (v termVector) top(n int) []string {
+	terms := make([]string, 0, len(v))
+	for term := range v {
+		terms = append(terms, term)
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if v[terms[i]] != v[terms[j]] {
+			return v[terms[i]] > v[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+
+	return terms
+}
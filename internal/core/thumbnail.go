@@ -0,0 +1,421 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"math"
+	nurl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/disintegration/imaging"
+	"github.com/go-shiori/shiori/internal/model"
+	"github.com/go-shiori/shiori/internal/storage"
+	"github.com/go-shiori/shiori/internal/warc"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+
+	// Add support for more image formats
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+// ThumbnailSize is the size of generated thumbnail.
+type ThumbnailSize struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+var (
+	// ThumbnailGrid is the thumbnail for bookmark card in grid mode.
+	// It's the default size, stored as thumb/<id> for compatibility.
+	ThumbnailGrid = ThumbnailSize{Name: "grid", Width: 600, Height: 400}
+
+	// ThumbnailList is the thumbnail for bookmark card in list mode.
+	ThumbnailList = ThumbnailSize{Name: "list", Width: 240, Height: 160}
+
+	// ThumbnailFavicon is the small square thumbnail.
+	ThumbnailFavicon = ThumbnailSize{Name: "favicon", Width: 64, Height: 64}
+
+	// ThumbnailSizes is the list of all generated thumbnail sizes.
+	ThumbnailSizes = []ThumbnailSize{ThumbnailGrid, ThumbnailList, ThumbnailFavicon}
+)
+
+// minArchiveImageSize is the minimum width and height of archived image
+// to be used as thumbnail, so icons and tracking pixels are skipped.
+const minArchiveImageSize = 100
+
+// GetThumbnailSize returns thumbnail size with the specified name.
+// Empty name returns the default size.
+func GetThumbnailSize(name string) (ThumbnailSize, bool) {
+	if name == "" {
+		return ThumbnailGrid, true
+	}
+
+	for _, size := range ThumbnailSizes {
+		if size.Name == name {
+			return size, true
+		}
+	}
+
+	return ThumbnailSize{}, false
+}
+
+// ThumbnailName returns name of the bookmark's thumbnail in specified size in storage.
+func ThumbnailName(id int, size ThumbnailSize) string {
+	name := strconv.Itoa(id)
+	if size.Name != ThumbnailGrid.Name {
+		name += "-" + size.Name
+	}
+
+	return "thumb/" + name
+}
+
+// RemoveThumbnails removes thumbnails of the bookmark in all sizes.
+func RemoveThumbnails(store storage.Storage, id int) {
+	for _, size := range ThumbnailSizes {
+		store.Remove(ThumbnailName(id, size))
+	}
+}
+
+// SaveThumbnails saves the image as the bookmark's thumbnails in all sizes.
+func SaveThumbnails(img image.Image, store storage.Storage, id int) error {
+	img = flattenImage(img)
+	for _, size := range ThumbnailSizes {
+		if err := saveThumbnail(img, store, ThumbnailName(id, size), size); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResizeThumbnail creates the bookmark's thumbnail in specified size
+// from its default thumbnail, e.g. for thumbnails created by older version.
+func ResizeThumbnail(store storage.Storage, id int, size ThumbnailSize) error {
+	img, err := openStoredImage(store, ThumbnailName(id, ThumbnailGrid))
+	if err != nil {
+		return err
+	}
+
+	return saveThumbnail(img, store, ThumbnailName(id, size), size)
+}
+
+// openStoredImage decodes the image that saved in storage.
+func openStoredImage(store storage.Storage, name string) (image.Image, error) {
+	f, err := store.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return imaging.Decode(f)
+}
+
+// DecodeImage decodes image in JPEG, PNG, GIF, WebP, BMP, ICO or SVG format.
+// To prevent decompression bomb, image with too many pixels is rejected.
+func DecodeImage(data []byte, contentType string) (image.Image, error) {
+	if isSVG(data, contentType) {
+		return decodeSVG(data)
+	}
+
+	if isICO(data) {
+		return decodeICO(data)
+	}
+
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if imgConfig.Width*imgConfig.Height > maxImagePixels {
+		return nil, fmt.Errorf("image is too large (%dx%d)", imgConfig.Width, imgConfig.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// ThumbnailFromArchive finds image in the archive that can be used as thumbnail.
+// It uses the page's OpenGraph image if it's archived, or the first large enough image.
+func ThumbnailFromArchive(store storage.Storage, id int) (image.Image, error) {
+	archive, err := OpenArchive(store, id)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	content, contentType, err := readArchiveResource(archive, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// If the bookmark itself is an image, just use it
+	if strings.HasPrefix(contentType, "image/") {
+		return DecodeImage(content, contentType)
+	}
+
+	if !strings.Contains(contentType, "text/html") {
+		return nil, fmt.Errorf("archive doesn't have any image")
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	meta := openGraphMeta(doc)
+	if meta["og:image"] != "" {
+		candidates = append(candidates, meta["og:image"])
+	}
+
+	doc.Find("img[src]").Each(func(_ int, s *goquery.Selection) {
+		candidates = append(candidates, s.AttrOr("src", ""))
+	})
+
+	for _, name := range candidates {
+		if !archive.HasResource(name) {
+			continue
+		}
+
+		content, contentType, err := readArchiveResource(archive, name)
+		if err != nil {
+			continue
+		}
+
+		img, err := DecodeImage(content, contentType)
+		if err != nil {
+			continue
+		}
+
+		if bounds := img.Bounds(); bounds.Dx() >= minArchiveImageSize && bounds.Dy() >= minArchiveImageSize {
+			return img, nil
+		}
+	}
+
+	return nil, fmt.Errorf("archive doesn't have any image")
+}
+
+// readArchiveResource reads and decompresses the resource from archive.
+func readArchiveResource(archive *warc.Archive, name string) ([]byte, string, error) {
+	content, contentType, err := archive.Read(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", err
+	}
+	defer gzipReader.Close()
+
+	content, err = io.ReadAll(gzipReader)
+	return content, contentType, err
+}
+
+// saveThumbnail saves image as thumbnail in the destination path.
+// If image is smaller than the thumbnail size or its ratio is too different,
+// it's fitted on the blurred copy of itself. Else, it's cropped to the size.
+func saveThumbnail(img image.Image, store storage.Storage, name string, size ThumbnailSize) error {
+	thumb := createThumbnail(img, size)
+
+	buffer := bytes.NewBuffer(nil)
+	if err := jpeg.Encode(buffer, thumb, nil); err != nil {
+		return err
+	}
+
+	if err := store.Write(name, buffer); err != nil {
+		return fmt.Errorf("failed to save image file: %v", err)
+	}
+
+	return nil
+}
+
+// createThumbnail resizes the image to the thumbnail size. If its aspect ratio is too
+// different, it's fitted into the blurred version of itself.
+func createThumbnail(img image.Image, size ThumbnailSize) image.Image {
+	imgRect := img.Bounds()
+	imgWidth := imgRect.Dx()
+	imgHeight := imgRect.Dy()
+	imgRatio := float64(imgWidth) / float64(imgHeight)
+	sizeRatio := float64(size.Width) / float64(size.Height)
+
+	if imgWidth >= size.Width && imgHeight >= size.Height && math.Abs(imgRatio-sizeRatio) <= sizeRatio*0.15 {
+		return imaging.Fill(img, size.Width, size.Height, imaging.Center, imaging.Lanczos)
+	}
+
+	// Create background
+	bg := imaging.Fill(img, size.Width, size.Height, imaging.Center, imaging.Lanczos)
+	bg = imaging.Blur(bg, float64(size.Width)/4)
+	bg = imaging.AdjustBrightness(bg, 30)
+
+	// Create foreground
+	fg := imaging.Fit(img, size.Width, size.Height, imaging.Lanczos)
+
+	// Merge foreground and background
+	bgRect := bg.Bounds()
+	fgRect := fg.Bounds()
+	fgPosition := image.Point{
+		X: bgRect.Min.X - int(math.Round(float64(bgRect.Dx()-fgRect.Dx())/2)),
+		Y: bgRect.Min.Y - int(math.Round(float64(bgRect.Dy()-fgRect.Dy())/2)),
+	}
+
+	draw.Draw(bg, bgRect, fg, fgPosition, draw.Over)
+
+	return bg
+}
+
+// flattenImage draws the image on white background, so its
+// transparent area doesn't become black when saved as JPEG.
+func flattenImage(img image.Image) image.Image {
+	imgRect := img.Bounds()
+	flat := image.NewNRGBA(image.Rect(0, 0, imgRect.Dx(), imgRect.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, imgRect.Min, draw.Over)
+	return flat
+}
+
+// isSVG checks if the image is SVG, from its content type or content.
+func isSVG(data []byte, contentType string) bool {
+	if strings.Contains(contentType, "image/svg") {
+		return true
+	}
+
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+
+	return bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+}
+
+// decodeSVG rasterizes the SVG image, at least as large as the grid thumbnail.
+func decodeSVG(data []byte) (image.Image, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.WarnErrorMode)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := icon.ViewBox.W, icon.ViewBox.H
+	if width <= 0 || height <= 0 {
+		width, height = float64(ThumbnailGrid.Width), float64(ThumbnailGrid.Width)
+	}
+
+	scale := math.Max(float64(ThumbnailGrid.Width)/width, float64(ThumbnailGrid.Height)/height)
+	w, h := int(math.Ceil(width*scale)), int(math.Ceil(height*scale))
+	if w*h > maxImagePixels {
+		return nil, fmt.Errorf("image is too large (%dx%d)", w, h)
+	}
+
+	icon.SetTarget(0, 0, float64(w), float64(h))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	scanner := rasterx.NewScannerGV(w, h, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(w, h, scanner), 1)
+
+	return img, nil
+}
+
+// ThumbnailRequest is the request for generating bookmark's thumbnail.
+type ThumbnailRequest struct {
+	Storage    storage.Storage
+	Bookmark   model.Bookmark
+	Fetcher    *Fetcher
+	Extractors *ExtractorRegistry
+	Offline    bool
+}
+
+// GenerateThumbnail finds image for the bookmark and saves it as thumbnails in all sizes.
+// The image is looked in the live page first, then in its archive, and finally in its
+// existing thumbnail. Return where the image is found.
+func GenerateThumbnail(req ThumbnailRequest) (string, error) {
+	book := req.Bookmark
+
+	if !req.Offline {
+		img, err := thumbnailFromWeb(req)
+		if err == nil {
+			return "web", SaveThumbnails(img, req.Storage, book.ID)
+		}
+	}
+
+	if img, err := ThumbnailFromArchive(req.Storage, book.ID); err == nil {
+		return "archive", SaveThumbnails(img, req.Storage, book.ID)
+	}
+
+	if img, err := openStoredImage(req.Storage, ThumbnailName(book.ID, ThumbnailGrid)); err == nil {
+		return "existing thumbnail", SaveThumbnails(img, req.Storage, book.ID)
+	}
+
+	return "", fmt.Errorf("no image found")
+}
+
+// thumbnailFromWeb downloads the bookmarked page and its image.
+func thumbnailFromWeb(req ThumbnailRequest) (image.Image, error) {
+	fetcher, extractors := req.Fetcher, req.Extractors
+	if fetcher == nil {
+		var err error
+		if fetcher, err = NewFetcher(DefaultFetcherConfig()); err != nil {
+			return nil, err
+		}
+	}
+
+	if extractors == nil {
+		extractors = NewExtractorRegistry()
+	}
+
+	pageURL, err := nurl.Parse(req.Bookmark.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := fetcher.Get(pageURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("page returned %s", resp.Status)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "image/") {
+		return DecodeImage(content, contentType)
+	}
+
+	article, err := extractors.Extract(ExtractRequest{
+		URL:         pageURL,
+		ContentType: contentType,
+		Content:     content,
+		Fetcher:     fetcher,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if article.Thumbnail != nil {
+		return article.Thumbnail, nil
+	}
+
+	if article.Image == "" {
+		return nil, fmt.Errorf("page doesn't have any image")
+	}
+
+	return downloadBookImage(fetcher, article.Image)
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/storage"
+)
+
+func TestDecodeImage(t *testing.T) {
+	var gifData bytes.Buffer
+	gifImage := image.NewPaletted(image.Rect(0, 0, 40, 30), color.Palette{color.White, color.Black})
+	if err := gif.Encode(&gifData, gifImage, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	svgData := []byte(`<?xml version="1.0"?>
+		<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 32 32">
+		<rect width="32" height="32" fill="#f00"/></svg>`)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		wantWidth   int
+		wantHeight  int
+	}{{
+		name:        "gif",
+		data:        gifData.Bytes(),
+		contentType: "image/gif",
+		wantWidth:   40,
+		wantHeight:  30,
+	}, {
+		name:        "svg without content type",
+		data:        svgData,
+		contentType: "",
+		wantWidth:   600,
+		wantHeight:  600,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := DecodeImage(tt.data, tt.contentType)
+			if err != nil {
+				t.Fatalf("DecodeImage() error = %v", err)
+			}
+
+			if bounds := img.Bounds(); bounds.Dx() != tt.wantWidth || bounds.Dy() != tt.wantHeight {
+				t.Errorf("DecodeImage() size = %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestSaveThumbnails(t *testing.T) {
+	store := storage.NewLocal(t.TempDir())
+	img := image.NewRGBA(image.Rect(0, 0, 1200, 300))
+
+	if err := SaveThumbnails(img, store, 7); err != nil {
+		t.Fatalf("SaveThumbnails() error = %v", err)
+	}
+
+	for _, size := range ThumbnailSizes {
+		thumb, err := openStoredImage(store, ThumbnailName(7, size))
+		if err != nil {
+			t.Fatalf("thumbnail %s is not saved: %v", size.Name, err)
+		}
+
+		if bounds := thumb.Bounds(); bounds.Dx() != size.Width || bounds.Dy() != size.Height {
+			t.Errorf("thumbnail %s size = %dx%d, want %dx%d", size.Name, bounds.Dx(), bounds.Dy(), size.Width, size.Height)
+		}
+	}
+}
@@ -3,6 +3,12 @@ package database
 import (
 	"database/sql"
 	"embed"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
 
 	"github.com/go-shiori/shiori/internal/model"
 )
@@ -22,16 +28,201 @@ const (
 	ByLastModified
 )
 
+// ParseOrderMethod returns the order method with matching name, which is
+// "oldest", "added" or "modified".
+func ParseOrderMethod(name string) (OrderMethod, error) {
+	switch strings.ToLower(name) {
+	case "oldest":
+		return DefaultOrder, nil
+	case "added":
+		return ByLastAdded, nil
+	case "modified":
+		return ByLastModified, nil
+	default:
+		return DefaultOrder, fmt.Errorf("unknown order method %q", name)
+	}
+}
+
+// BookmarkCursor is the position of a bookmark in the list. It's used to fetch
+// bookmarks after or before it, so inserting new bookmarks doesn't shift the
+// result like offset does.
+type BookmarkCursor struct {
+	ID       int
+	Modified string
+}
+
 // GetBookmarksOptions is options for fetching bookmarks from database.
+// After and Before are applied in the order of OrderMethod. Tags and
+// ExcludedTags match their descendants as well, e.g. "dev" matches "dev/go".
 type GetBookmarksOptions struct {
 	IDs          []int
 	Tags         []string
 	ExcludedTags []string
 	Keyword      string
+	Broken       bool
 	WithContent  bool
 	OrderMethod  OrderMethod
 	Limit        int
 	Offset       int
+	After        *BookmarkCursor
+	Before       *BookmarkCursor
+
+	// SearchVector is the embedding of Keyword, created by SearchProvider. When
+	// it's set, bookmarks are ranked by both keyword match and similarity of their
+	// embedding instead of OrderMethod, and the cursors are not supported.
+	SearchVector   []float32
+	SearchProvider string
+}
+
+// orderClauses returns the condition for the cursors, using named parameters,
+// and the ORDER BY clause. If Before is set, the order is reversed so the
+// nearest bookmarks are fetched first, which is restored by restoreOrder.
+func (opts GetBookmarksOptions) orderClauses(idColumn, modifiedColumn string) (string, map[string]interface{}, string) {
+	descending := opts.OrderMethod != DefaultOrder
+	byModified := opts.OrderMethod == ByLastModified
+
+	condition := ""
+	arg := map[string]interface{}{}
+	addCursor := func(name string, cursor *BookmarkCursor, forward bool) {
+		op := ">"
+		if descending == forward {
+			op = "<"
+		}
+
+		arg[name+"_id"] = cursor.ID
+		if !byModified {
+			condition += fmt.Sprintf(` AND %s %s :%s_id`, idColumn, op, name)
+			return
+		}
+
+		arg[name+"_modified"] = cursor.Modified
+		condition += fmt.Sprintf(` AND (%[1]s %[3]s :%[4]s_modified OR (%[1]s = :%[4]s_modified AND %[2]s %[3]s :%[4]s_id))`,
+			modifiedColumn, idColumn, op, name)
+	}
+
+	if opts.After != nil {
+		addCursor("after", opts.After, true)
+	}
+
+	if opts.Before != nil {
+		addCursor("before", opts.Before, false)
+	}
+
+	direction := "ASC"
+	if descending != (opts.Before != nil) {
+		direction = "DESC"
+	}
+
+	order := fmt.Sprintf(` ORDER BY %s %s`, idColumn, direction)
+	if byModified {
+		order = fmt.Sprintf(` ORDER BY %s %s, %s %s`, modifiedColumn, direction, idColumn, direction)
+	}
+
+	return condition, arg, order
+}
+
+// restoreOrder reverses the bookmarks that fetched in reversed order because of Before.
+func (opts GetBookmarksOptions) restoreOrder(bookmarks []model.Bookmark) {
+	if opts.Before == nil {
+		return
+	}
+
+	for i, j := 0, len(bookmarks)-1; i < j; i, j = i+1, j-1 {
+		bookmarks[i], bookmarks[j] = bookmarks[j], bookmarks[i]
+	}
+}
+
+// rxKeywordFilter matches the `is:` filter in search keyword, e.g. `is:broken`.
+var rxKeywordFilter = regexp.MustCompile(`(?i)(^|\s)is:(\S+)`)
+
+// TagSeparator separates parent and child in the name of hierarchical tag.
+const TagSeparator = "/"
+
+// NormalizeTagName returns the tag name as it's saved in database, which is
+// lowercased, without repeated spaces and without empty level in hierarchy.
+func NormalizeTagName(name string) string {
+	levels := []string{}
+	for _, level := range strings.Split(strings.ToLower(name), TagSeparator) {
+		level = strings.Join(strings.Fields(level), " ")
+		if level != "" {
+			levels = append(levels, level)
+		}
+	}
+
+	return strings.Join(levels, TagSeparator)
+}
+
+// tagClauses returns the condition for included and excluded tags, using named
+// parameters. Bookmark must have all of the included tags and none of the
+// excluded ones, where a tag also matches its descendants.
+func tagClauses(idColumn string, tags, excludedTags []string) (string, map[string]interface{}) {
+	condition := ""
+	arg := map[string]interface{}{}
+	matchTag := func(name string, tag string) string {
+		tag = NormalizeTagName(tag)
+		arg[name] = tag
+		arg[name+"_prefix"] = escapeLike(tag) + TagSeparator + "%"
+		return fmt.Sprintf(`t.name = :%s OR t.name LIKE :%s_prefix ESCAPE '!'`, name, name)
+	}
+
+	for i, tag := range tags {
+		condition += fmt.Sprintf(` AND %s IN (
+			SELECT bt.bookmark_id
+			FROM bookmark_tag bt
+			LEFT JOIN tag t ON bt.tag_id = t.id
+			WHERE %s)`, idColumn, matchTag(fmt.Sprintf("tag_%d", i), tag))
+	}
+
+	if len(excludedTags) > 0 {
+		matches := make([]string, len(excludedTags))
+		for i, tag := range excludedTags {
+			matches[i] = matchTag(fmt.Sprintf("extag_%d", i), tag)
+		}
+
+		condition += fmt.Sprintf(` AND %s NOT IN (
+			SELECT bt.bookmark_id
+			FROM bookmark_tag bt
+			LEFT JOIN tag t ON bt.tag_id = t.id
+			WHERE %s)`, idColumn, strings.Join(matches, " OR "))
+	}
+
+	return condition, arg
+}
+
+// escapeLike escapes the wildcards of LIKE pattern, using ! as escape character.
+func escapeLike(pattern string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(pattern)
+}
+
+// ParseKeywordFilters moves the `is:` filters in keyword into their options.
+// Unknown filters are kept in keyword.
+func (opts *GetBookmarksOptions) ParseKeywordFilters() {
+	opts.Keyword = rxKeywordFilter.ReplaceAllStringFunc(opts.Keyword, func(match string) string {
+		filter := strings.ToLower(strings.TrimSpace(match))
+		switch filter {
+		case "is:broken":
+			opts.Broken = true
+		default:
+			return match
+		}
+		return ""
+	})
+
+	opts.Keyword = strings.Join(strings.Fields(opts.Keyword), " ")
+}
+
+// GetHighlightsOptions is options for fetching highlights from database.
+type GetHighlightsOptions struct {
+	IDs         []int
+	BookmarkIDs []int
+}
+
+// GetLinkChecksOptions is options for fetching link check results from database.
+type GetLinkChecksOptions struct {
+	BookmarkIDs []int
+	LatestOnly  bool
+	BrokenOnly  bool
+	Limit       int
 }
 
 // GetAccountsOptions is options for fetching accounts from database.
@@ -60,6 +251,28 @@ type DB interface {
 	// GetBookmark fetchs bookmark based on its ID or URL.
 	GetBookmark(id int, url string) (model.Bookmark, bool)
 
+	// UpdateArchiveSizes saves the size of offline archive of the bookmarks, keyed by bookmark ID.
+	UpdateArchiveSizes(sizes map[int]int64) error
+
+	// UpdateResourceSizes saves the size of resources shared between archives, keyed by their
+	// hash. Resource with zero size is removed. If replace is true, the resources that not in
+	// sizes are removed as well.
+	UpdateResourceSizes(sizes map[string]int64, replace bool) error
+
+	// GetStorageUsage returns the disk space used by offline archives and shared resources,
+	// as recorded by UpdateArchiveSizes and UpdateResourceSizes.
+	GetStorageUsage() (model.StorageUsage, error)
+
+	// SaveLinkChecks saves the results of checking bookmarks' URL.
+	SaveLinkChecks(checks ...model.LinkCheck) error
+
+	// GetLinkChecks fetch list of link check results, newest first.
+	GetLinkChecks(opts GetLinkChecksOptions) ([]model.LinkCheck, error)
+
+	// PruneLinkChecks removes the old link check results, so only the latest
+	// keep results of each bookmark are left.
+	PruneLinkChecks(keep int) error
+
 	// SaveAccount saves new account in database
 	SaveAccount(model.Account) error
 
@@ -75,15 +288,195 @@ type DB interface {
 	// GetTags fetch list of tags and its frequency from database.
 	GetTags() ([]model.Tag, error)
 
-	// RenameTag change the name of a tag.
-	RenameTag(id int, newName string) error
+	// GetTag fetch tag with matching ID or name.
+	GetTag(id int, name string) (model.Tag, bool)
+
+	// UpdateTag saves the color and description of a tag.
+	UpdateTag(tag model.Tag) error
+
+	// MergeTags moves bookmarks of the tags into the tag with specified name, which is created if needed.
+	MergeTags(name string, ids ...int) (model.Tag, error)
+
+	// DeleteTags removes the tags and detach them from all bookmarks.
+	DeleteTags(ids ...int) error
+
+	// DeleteOrphanTags removes tags that not used by any bookmark.
+	DeleteOrphanTags() (int, error)
+
+	// SaveRule saves the tagging rule, which is created if it doesn't have ID yet.
+	SaveRule(rule model.Rule) (model.Rule, error)
+
+	// GetRules fetch list of tagging rules, ordered by ID.
+	GetRules() ([]model.Rule, error)
+
+	// DeleteRules removes all tagging rules with matching ids.
+	DeleteRules(ids ...int) error
+
+	// SaveHighlight saves the highlight, which is created if it doesn't have ID yet.
+	SaveHighlight(highlight model.Highlight) (model.Highlight, error)
+
+	// GetHighlights fetch list of highlights, ordered by bookmark and their position.
+	GetHighlights(opts GetHighlightsOptions) ([]model.Highlight, error)
+
+	// DeleteHighlights removes all highlights with matching ids.
+	DeleteHighlights(ids ...int) error
+
+	// SaveEmbeddings saves the embeddings of bookmarks, replacing their old one.
+	SaveEmbeddings(embeddings ...model.Embedding) error
+
+	// GetEmbeddings fetch the embeddings created by the provider. If ids is empty,
+	// embeddings of all bookmarks are fetched.
+	GetEmbeddings(provider string, ids ...int) ([]model.Embedding, error)
+
+	// GetKeywordScores fetch the full text search relevance of bookmarks whose text
+	// matches the keyword, higher is more relevant. Bookmarks that only match by
+	// their URL are not included.
+	GetKeywordScores(keyword string) (map[int]float64, error)
+
+	// SaveBookmarkTerms saves the terms of bookmarks, replacing their old one.
+	SaveBookmarkTerms(terms ...model.BookmarkTerms) error
+
+	// GetBookmarkTerms fetch the terms of bookmarks. If ids is empty,
+	// terms of all bookmarks are fetched.
+	GetBookmarkTerms(ids ...int) ([]model.BookmarkTerms, error)
 
 	// CreateNewID creates new id for specified table.
 	CreateNewID(table string) (int, error)
 }
 
+// ruleRow is tagging rule as it's saved in database, where lists are joined by newline.
+type ruleRow struct {
+	ID              int          `db:"id"`
+	Name            string       `db:"name"`
+	Domain          string       `db:"domain"`
+	PathPattern     string       `db:"path_pattern"`
+	TitleKeywords   string       `db:"title_keywords"`
+	ContentKeywords string       `db:"content_keywords"`
+	Tags            string       `db:"tags"`
+	Public          sql.NullBool `db:"public"`
+	Enabled         bool         `db:"enabled"`
+}
+
+func newRuleRow(rule model.Rule) ruleRow {
+	row := ruleRow{
+		ID:              rule.ID,
+		Name:            rule.Name,
+		Domain:          rule.Domain,
+		PathPattern:     rule.PathPattern,
+		TitleKeywords:   strings.Join(rule.TitleKeywords, "\n"),
+		ContentKeywords: strings.Join(rule.ContentKeywords, "\n"),
+		Tags:            strings.Join(rule.Tags, "\n"),
+		Enabled:         rule.Enabled,
+	}
+
+	if rule.Public != nil {
+		row.Public = sql.NullBool{Bool: *rule.Public, Valid: true}
+	}
+
+	return row
+}
+
+func (row ruleRow) toRule() model.Rule {
+	splitLines := func(str string) []string {
+		if str == "" {
+			return []string{}
+		}
+		return strings.Split(str, "\n")
+	}
+
+	rule := model.Rule{
+		ID:              row.ID,
+		Name:            row.Name,
+		Domain:          row.Domain,
+		PathPattern:     row.PathPattern,
+		TitleKeywords:   splitLines(row.TitleKeywords),
+		ContentKeywords: splitLines(row.ContentKeywords),
+		Tags:            splitLines(row.Tags),
+		Enabled:         row.Enabled,
+	}
+
+	if row.Public.Valid {
+		public := row.Public.Bool
+		rule.Public = &public
+	}
+
+	return rule
+}
+
 func checkError(err error) {
 	if err != nil && err != sql.ErrNoRows {
 		panic(err)
 	}
 }
+
+// embeddingRow is embedding as it's saved in database, where the vector is
+// encoded as little-endian float32 values.
+type embeddingRow struct {
+	BookmarkID int    `db:"bookmark_id"`
+	Provider   string `db:"provider"`
+	Modified   string `db:"modified"`
+	Vector     []byte `db:"vector"`
+}
+
+func newEmbeddingRow(embedding model.Embedding) embeddingRow {
+	vector := make([]byte, 4*len(embedding.Vector))
+	for i, value := range embedding.Vector {
+		binary.LittleEndian.PutUint32(vector[4*i:], math.Float32bits(value))
+	}
+
+	return embeddingRow{
+		BookmarkID: embedding.BookmarkID,
+		Provider:   embedding.Provider,
+		Modified:   embedding.Modified,
+		Vector:     vector,
+	}
+}
+
+func (row embeddingRow) toEmbedding() model.Embedding {
+	vector := make([]float32, len(row.Vector)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(row.Vector[4*i:]))
+	}
+
+	return model.Embedding{
+		BookmarkID: row.BookmarkID,
+		Provider:   row.Provider,
+		Modified:   row.Modified,
+		Vector:     vector,
+	}
+}
+
+// termsRow is bookmark terms as it's saved in database, where the terms are encoded as JSON.
+type termsRow struct {
+	BookmarkID int    `db:"bookmark_id"`
+	Modified   string `db:"modified"`
+	Terms      string `db:"terms"`
+}
+
+func newTermsRow(terms model.BookmarkTerms) termsRow {
+	encoded, _ := json.Marshal(terms.Terms)
+
+	return termsRow{
+		BookmarkID: terms.BookmarkID,
+		Modified:   terms.Modified,
+		Terms:      string(encoded),
+	}
+}
+
+func (row termsRow) toBookmarkTerms() model.BookmarkTerms {
+	terms := map[string]float64{}
+	json.Unmarshal([]byte(row.Terms), &terms)
+
+	return model.BookmarkTerms{
+		BookmarkID: row.BookmarkID,
+		Modified:   row.Modified,
+		Terms:      terms,
+	}
+}
+
+// storageUsageQuery is the query for GetStorageUsage, which is the same in all databases.
+const storageUsageQuery = `SELECT
+	(SELECT COUNT(*) FROM bookmark WHERE archive_size > 0) archives,
+	(SELECT COALESCE(SUM(archive_size), 0) FROM bookmark) archive_size,
+	(SELECT COUNT(*) FROM shared_resource) shared_resources,
+	(SELECT COALESCE(SUM(size), 0) FROM shared_resource) shared_size`
@@ -0,0 +1,601 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+func TestGetBookmarksOptions_ParseKeywordFilters(t *testing.T) {
+	tests := []struct {
+		name        string
+		keyword     string
+		wantKeyword string
+		wantBroken  bool
+	}{
+		{"no filter", "golang  tips", "golang tips", false},
+		{"only filter", "is:broken", "", true},
+		{"filter with keyword", "golang IS:Broken tips", "golang tips", true},
+		{"unknown filter", "is:unknown golang", "is:unknown golang", false},
+		{"filter inside word", "this:broken", "this:broken", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := GetBookmarksOptions{Keyword: tt.keyword}
+			opts.ParseKeywordFilters()
+
+			if opts.Keyword != tt.wantKeyword || opts.Broken != tt.wantBroken {
+				t.Errorf("ParseKeywordFilters() = %q, %v, want %q, %v",
+					opts.Keyword, opts.Broken, tt.wantKeyword, tt.wantBroken)
+			}
+		})
+	}
+}
+
+func TestGetBookmarksCursor(t *testing.T) {
+	db, err := OpenSQLiteDatabase(filepath.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bookmark 2 and 4 share the modified time, so they're ordered by ID
+	modified := map[int]string{
+		1: "2021-01-03 00:00:00",
+		2: "2021-01-02 00:00:00",
+		3: "2021-01-05 00:00:00",
+		4: "2021-01-02 00:00:00",
+		5: "2021-01-01 00:00:00",
+	}
+
+	for id := 1; id <= 5; id++ {
+		book := model.Bookmark{ID: id, URL: fmt.Sprintf("https://example.com/%d", id), Title: "Example"}
+		if _, err = db.SaveBookmarks(book); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = db.Exec(`UPDATE bookmark SET modified = ? WHERE id = ?`, modified[id], id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cursor := func(id int) *BookmarkCursor {
+		return &BookmarkCursor{ID: id, Modified: modified[id]}
+	}
+
+	tests := []struct {
+		name string
+		opts GetBookmarksOptions
+		want string
+	}{
+		{"oldest", GetBookmarksOptions{OrderMethod: DefaultOrder, Limit: 2}, "1,2"},
+		{"oldest after", GetBookmarksOptions{OrderMethod: DefaultOrder, After: cursor(2), Limit: 2}, "3,4"},
+		{"oldest before", GetBookmarksOptions{OrderMethod: DefaultOrder, Before: cursor(4), Limit: 2}, "2,3"},
+		{"added", GetBookmarksOptions{OrderMethod: ByLastAdded, Limit: 2}, "5,4"},
+		{"added after", GetBookmarksOptions{OrderMethod: ByLastAdded, After: cursor(4), Limit: 2}, "3,2"},
+		{"added before", GetBookmarksOptions{OrderMethod: ByLastAdded, Before: cursor(2), Limit: 2}, "4,3"},
+		{"modified", GetBookmarksOptions{OrderMethod: ByLastModified}, "3,1,4,2,5"},
+		{"modified after", GetBookmarksOptions{OrderMethod: ByLastModified, After: cursor(4), Limit: 2}, "2,5"},
+		{"modified before", GetBookmarksOptions{OrderMethod: ByLastModified, Before: cursor(2), Limit: 2}, "1,4"},
+		{"modified between", GetBookmarksOptions{OrderMethod: ByLastModified, After: cursor(3), Before: cursor(5)}, "1,4,2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bookmarks, err := db.GetBookmarks(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := []string{}
+			for _, book := range bookmarks {
+				ids = append(ids, strconv.Itoa(book.ID))
+			}
+
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("GetBookmarks() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOrderMethod(t *testing.T) {
+	tests := map[string]OrderMethod{"oldest": DefaultOrder, "Added": ByLastAdded, "modified": ByLastModified}
+	for name, want := range tests {
+		if got, err := ParseOrderMethod(name); err != nil || got != want {
+			t.Errorf("ParseOrderMethod(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+
+	if _, err := ParseOrderMethod("random"); err == nil {
+		t.Error("ParseOrderMethod() accepts unknown method")
+	}
+}
+
+func TestTags(t *testing.T) {
+	db, err := OpenSQLiteDatabase(filepath.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	bookTags := map[int][]string{
+		1: {"Dev"},
+		2: {"dev / go", "web"},
+		3: {"dev/rust", "web"},
+		4: {"devops"},
+		5: {"dev_x"},
+	}
+
+	for id := 1; id <= 5; id++ {
+		book := model.Bookmark{ID: id, URL: fmt.Sprintf("https://example.com/%d", id), Title: "Example"}
+		for _, name := range bookTags[id] {
+			book.Tags = append(book.Tags, model.Tag{Name: name})
+		}
+
+		if _, err = db.SaveBookmarks(book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(tags, excludedTags []string) string {
+		opts := GetBookmarksOptions{Tags: tags, ExcludedTags: excludedTags}
+		bookmarks, err := db.GetBookmarks(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count, err := db.GetBookmarksCount(opts)
+		if err != nil || count != len(bookmarks) {
+			t.Errorf("GetBookmarksCount(%v, %v) = %d, %v", tags, excludedTags, count, err)
+		}
+
+		ids := []string{}
+		for _, book := range bookmarks {
+			ids = append(ids, strconv.Itoa(book.ID))
+		}
+		return strings.Join(ids, ",")
+	}
+
+	tests := []struct {
+		tags         []string
+		excludedTags []string
+		want         string
+	}{
+		{[]string{"dev"}, nil, "1,2,3"},
+		{[]string{"DEV/GO"}, nil, "2"},
+		{[]string{"dev", "web"}, nil, "2,3"},
+		{[]string{"dev_"}, nil, ""},
+		{nil, []string{"dev"}, "4,5"},
+		{[]string{"web"}, []string{"dev/go"}, "3"},
+	}
+
+	for _, tt := range tests {
+		if got := search(tt.tags, tt.excludedTags); got != tt.want {
+			t.Errorf("GetBookmarks(%v, %v) = %q, want %q", tt.tags, tt.excludedTags, got, tt.want)
+		}
+	}
+
+	// Merge into existing tag, keeping its color
+	web, _ := db.GetTag(0, "web")
+	rust, _ := db.GetTag(0, "dev/rust")
+	rust.Color, rust.Description = "#ff8800", "Rust language"
+	if err = db.UpdateTag(rust); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := db.MergeTags("Web", rust.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if merged.ID != web.ID || merged.NBookmarks != 2 || merged.Color != "#ff8800" {
+		t.Errorf("MergeTags() = %+v", merged)
+	}
+
+	if _, exist := db.GetTag(rust.ID, ""); exist {
+		t.Error("merged tag still exists")
+	}
+
+	// Merge into new name renames the first tag
+	devops, _ := db.GetTag(0, "devops")
+	renamed, err := db.MergeTags("ops", devops.ID)
+	if err != nil || renamed.ID != devops.ID || renamed.Name != "ops" {
+		t.Errorf("MergeTags() = %+v, %v", renamed, err)
+	}
+
+	// Deleted tag is detached, while untagged one is orphan until cleaned
+	if err = db.DeleteTags(renamed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := search(nil, []string{"*"}); got != "4" {
+		t.Errorf("bookmarks without tags = %q, want 4", got)
+	}
+
+	book, _ := db.GetBookmarks(GetBookmarksOptions{IDs: []int{5}})
+	book[0].Tags[0].Deleted = true
+	if _, err = db.SaveBookmarks(book[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	nRemoved, err := db.DeleteOrphanTags()
+	if err != nil || nRemoved != 1 {
+		t.Errorf("DeleteOrphanTags() = %d, %v, want 1", nRemoved, err)
+	}
+}
+
+func TestNormalizeTagName(t *testing.T) {
+	tests := map[string]string{
+		"Go":           "go",
+		"  web   dev ": "web dev",
+		"Dev / Go//":   "dev/go",
+		"/":            "",
+		"a/ b c /D":    "a/b c/d",
+	}
+
+	for name, want := range tests {
+		if got := NormalizeTagName(name); got != want {
+			t.Errorf("NormalizeTagName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	db, err := OpenSQLiteDatabase(filepath.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	private := false
+	first, err := db.SaveRule(model.Rule{Name: "code", Domain: "github.com", Tags: []string{"code", "git"}, Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := db.SaveRule(model.Rule{Name: "secret", TitleKeywords: []string{"secret, really"}, Public: &private})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.PathPattern = "^/golang/"
+	if _, err = db.SaveRule(first); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := db.GetRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := fmt.Sprintf("%+v", rules)
+	if len(rules) != 2 || rules[1].Public == nil || *rules[1].Public || rules[0].Public != nil {
+		t.Fatalf("GetRules() = %s", got)
+	}
+
+	if rules[0].PathPattern != "^/golang/" || strings.Join(rules[0].Tags, ",") != "code,git" ||
+		strings.Join(rules[1].TitleKeywords, "|") != "secret, really" || len(rules[1].Tags) != 0 || rules[1].Enabled {
+		t.Errorf("GetRules() = %s", got)
+	}
+
+	if err = db.DeleteRules(first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if rules, _ = db.GetRules(); len(rules) != 1 || rules[0].ID != second.ID {
+		t.Errorf("GetRules() after delete = %+v", rules)
+	}
+}
+
+func TestHybridSearch(t *testing.T) {
+	db, err := OpenSQLiteDatabase(filepath.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	titles := []string{"Golang tips", "Concurrency patterns", "Goroutines explained", "Sourdough bread", "Gardening", "Golang and other tips"}
+	for i, title := range titles {
+		book := model.Bookmark{ID: i + 1, URL: fmt.Sprintf("https://example.com/%d", i+1), Title: title}
+		if _, err = db.SaveBookmarks(book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Bookmark 1 only matches the keyword, and it's more relevant than 6 which has no embedding.
+	// Bookmark 4 is not similar, and 5 has no embedding.
+	err = db.SaveEmbeddings(
+		model.Embedding{BookmarkID: 1, Provider: "test", Vector: []float32{0, 1}},
+		model.Embedding{BookmarkID: 2, Provider: "test", Vector: []float32{0, 1}},
+		model.Embedding{BookmarkID: 3, Provider: "test", Vector: []float32{0.6, 0.8}},
+		model.Embedding{BookmarkID: 4, Provider: "test", Vector: []float32{-1, 0}},
+		model.Embedding{BookmarkID: 2, Provider: "test", Vector: []float32{1, 0}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	embeddings, err := db.GetEmbeddings("test", 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(embeddings) != 1 || fmt.Sprint(embeddings[0].Vector) != "[1 0]" {
+		t.Errorf("GetEmbeddings() = %+v, want replaced embedding of bookmark 2", embeddings)
+	}
+
+	tests := []struct {
+		name string
+		opts GetBookmarksOptions
+		want string
+	}{
+		{"hybrid", GetBookmarksOptions{Keyword: "golang"}, "2,3,1,6"},
+		{"paged", GetBookmarksOptions{Keyword: "golang", Limit: 1, Offset: 1}, "3"},
+		{"filtered", GetBookmarksOptions{Keyword: "golang", IDs: []int{1, 3, 4}}, "3,1"},
+		{"other provider", GetBookmarksOptions{Keyword: "golang", SearchProvider: "other"}, "1,6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.SearchVector = []float32{1, 0}
+			if tt.opts.SearchProvider == "" {
+				tt.opts.SearchProvider = "test"
+			}
+
+			bookmarks, err := db.GetBookmarks(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := []string{}
+			for _, book := range bookmarks {
+				ids = append(ids, strconv.Itoa(book.ID))
+			}
+
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("GetBookmarks() = %s, want %s", got, tt.want)
+			}
+
+			tt.opts.Limit, tt.opts.Offset = 0, 0
+			count, err := db.GetBookmarksCount(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.name != "paged" && count != len(bookmarks) {
+				t.Errorf("GetBookmarksCount() = %d, want %d", count, len(bookmarks))
+			}
+		})
+	}
+
+	if err = db.DeleteBookmarks(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if embeddings, _ = db.GetEmbeddings("test"); len(embeddings) != 3 {
+		t.Errorf("GetEmbeddings() after delete = %d embeddings, want 3", len(embeddings))
+	}
+}
+
+func TestHighlights(t *testing.T) {
+	db, err := OpenSQLiteDatabase(filepath.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for id := 1; id <= 2; id++ {
+		book := model.Bookmark{ID: id, URL: fmt.Sprintf("https://example.com/%d", id), Title: "Example"}
+		if _, err = db.SaveBookmarks(book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	second, err := db.SaveHighlight(model.Highlight{BookmarkID: 1, Quote: "channels are typed", Start: 40, End: 58})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := db.SaveHighlight(model.Highlight{BookmarkID: 1, Quote: "goroutines are cheap", Start: 10, End: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := db.SaveHighlight(model.Highlight{BookmarkID: 2, Quote: "nothing special"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second.Note = "remember the pipelines"
+	if _, err = db.SaveHighlight(second); err != nil {
+		t.Fatal(err)
+	}
+
+	highlights, err := db.GetHighlights(GetHighlightsOptions{BookmarkIDs: []int{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(highlights) != 2 || highlights[0].ID != first.ID || highlights[1].Note != second.Note ||
+		highlights[1].Created == "" {
+		t.Errorf("GetHighlights() = %+v", highlights)
+	}
+
+	search := func(keyword string) string {
+		bookmarks, err := db.GetBookmarks(GetBookmarksOptions{Keyword: keyword})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []string{}
+		for _, book := range bookmarks {
+			ids = append(ids, strconv.Itoa(book.ID))
+		}
+		return strings.Join(ids, ",")
+	}
+
+	// Bookmarks are found by the quote and note of their highlights
+	if got := search("goroutines"); got != "1" {
+		t.Errorf("search by quote = %s, want 1", got)
+	}
+
+	if got := search("pipelines"); got != "1" {
+		t.Errorf("search by note = %s, want 1", got)
+	}
+
+	if err = db.DeleteHighlights(second.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := search("pipelines"); got != "" {
+		t.Errorf("search by deleted note = %s, want nothing", got)
+	}
+
+	if err = db.DeleteBookmarks(1); err != nil {
+		t.Fatal(err)
+	}
+
+	highlights, _ = db.GetHighlights(GetHighlightsOptions{})
+	if len(highlights) != 1 || highlights[0].ID != other.ID {
+		t.Errorf("GetHighlights() after deleting bookmark = %+v", highlights)
+	}
+}
+
+func TestPruneLinkChecks(t *testing.T) {
+	db, err := OpenSQLiteDatabase(filepath.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	nChecks := map[int]int{1: 5, 2: 2}
+	for id, n := range nChecks {
+		book := model.Bookmark{ID: id, URL: fmt.Sprintf("https://example.com/%d", id), Title: "Example"}
+		if _, err = db.SaveBookmarks(book); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < n; i++ {
+			if err = db.SaveLinkChecks(model.LinkCheck{BookmarkID: id, StatusCode: 200 + i}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err = db.PruneLinkChecks(3); err != nil {
+		t.Fatalf("PruneLinkChecks() error = %v", err)
+	}
+
+	// Only the latest results are kept
+	wantCodes := map[int]string{1: "204,203,202", 2: "201,200"}
+	for id, want := range wantCodes {
+		checks, err := db.GetLinkChecks(GetLinkChecksOptions{BookmarkIDs: []int{id}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		codes := make([]string, len(checks))
+		for i, check := range checks {
+			codes[i] = strconv.Itoa(check.StatusCode)
+		}
+
+		if got := strings.Join(codes, ","); got != want {
+			t.Errorf("bookmark %d checks = %s, want %s", id, got, want)
+		}
+	}
+}
+
+func TestStorageUsage(t *testing.T) {
+	db, err := OpenSQLiteDatabase(filepath.Join(t.TempDir(), "shiori.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for id := 1; id <= 3; id++ {
+		book := model.Bookmark{ID: id, URL: fmt.Sprintf("https://example.com/%d", id), Title: "Example"}
+		if _, err = db.SaveBookmarks(book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		name      string
+		archives  map[int]int64
+		resources map[string]int64
+		replace   bool
+		want      model.StorageUsage
+	}{{
+		name:      "initial sizes",
+		archives:  map[int]int64{1: 100, 2: 50},
+		resources: map[string]int64{"aa": 10, "bb": 20},
+		want:      model.StorageUsage{Archives: 2, ArchiveSize: 150, SharedResources: 2, SharedSize: 30},
+	}, {
+		name:      "update and remove",
+		archives:  map[int]int64{2: 0, 3: 70},
+		resources: map[string]int64{"aa": 15, "bb": 0, "cc": 5},
+		want:      model.StorageUsage{Archives: 2, ArchiveSize: 170, SharedResources: 2, SharedSize: 20},
+	}, {
+		name:      "replace resources",
+		resources: map[string]int64{"dd": 40},
+		replace:   true,
+		want:      model.StorageUsage{Archives: 2, ArchiveSize: 170, SharedResources: 1, SharedSize: 40},
+	}}
+
+	for _, step := range steps {
+		if err = db.UpdateArchiveSizes(step.archives); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		if err = db.UpdateResourceSizes(step.resources, step.replace); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		usage, err := db.GetStorageUsage()
+		if err != nil || usage != step.want {
+			t.Errorf("%s: GetStorageUsage() = %+v (%v), want %+v", step.name, usage, err, step.want)
+		}
+	}
+
+	// Deleted bookmark no longer uses the storage
+	if err = db.DeleteBookmarks(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if usage, _ := db.GetStorageUsage(); usage.Archives != 1 || usage.ArchiveSize != 70 {
+		t.Errorf("GetStorageUsage() after delete = %+v", usage)
+	}
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"math"
+	"sort"
+
+	"github.com/go-shiori/shiori/internal/model"
+)
+
+const (
+	// HybridKeywordWeight is the weight of keyword match in hybrid search,
+	// while the rest is the weight of embedding similarity.
+	HybridKeywordWeight = 0.3
+
+	// MinHybridSimilarity is the minimum similarity of bookmark that doesn't
+	// match the keyword to be included in hybrid search.
+	MinHybridSimilarity = 0.2
+)
+
+// hybridRanking returns IDs of the bookmarks matching the filters of hybrid search,
+// best first. The full text search relevance, relative to the most relevant match,
+// is combined with the similarity of bookmark's embedding to SearchVector. Bookmarks
+// that don't match the keyword are only included if they're similar enough.
+func hybridRanking(db DB, opts GetBookmarksOptions) ([]int, error) {
+	filter := GetBookmarksOptions{
+		IDs:          opts.IDs,
+		Tags:         opts.Tags,
+		ExcludedTags: opts.ExcludedTags,
+		Broken:       opts.Broken,
+	}
+
+	candidates, err := db.GetBookmarks(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := map[int]struct{}{}
+	relevance := map[int]float64{}
+	if opts.Keyword != "" {
+		filter.Keyword = opts.Keyword
+		matches, err := db.GetBookmarks(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		scores, err := db.GetKeywordScores(opts.Keyword)
+		if err != nil {
+			return nil, err
+		}
+
+		maxScore := 0.0
+		for _, book := range matches {
+			matched[book.ID] = struct{}{}
+			maxScore = math.Max(maxScore, scores[book.ID])
+		}
+
+		if maxScore > 0 {
+			for _, book := range matches {
+				relevance[book.ID] = math.Max(scores[book.ID], 0) / maxScore
+			}
+		}
+	}
+
+	embeddings, err := db.GetEmbeddings(opts.SearchProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	similarities := map[int]float64{}
+	for _, embedding := range embeddings {
+		similarities[embedding.BookmarkID] = vectorSimilarity(opts.SearchVector, embedding.Vector)
+	}
+
+	type scoredBookmark struct {
+		id    int
+		score float64
+	}
+
+	scored := []scoredBookmark{}
+	for _, book := range candidates {
+		similarity := similarities[book.ID]
+		score := (1-HybridKeywordWeight)*similarity + HybridKeywordWeight*relevance[book.ID]
+		if _, isMatch := matched[book.ID]; !isMatch && similarity < MinHybridSimilarity {
+			continue
+		}
+
+		scored = append(scored, scoredBookmark{book.ID, score})
+	}
+
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score > scored[j].score
+		}
+		return scored[i].id > scored[j].id
+	})
+
+	ids := make([]int, len(scored))
+	for i, item := range scored {
+		ids[i] = item.id
+	}
+
+	return ids, nil
+}
+
+// getHybridBookmarks fetches a page of bookmarks in hybrid search, best first.
+func getHybridBookmarks(db DB, opts GetBookmarksOptions) ([]model.Bookmark, error) {
+	ids, err := hybridRanking(db, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Limit > 0 && opts.Offset >= 0 {
+		start, end := opts.Offset, opts.Offset+opts.Limit
+		if start > len(ids) {
+			start = len(ids)
+		}
+		if end > len(ids) {
+			end = len(ids)
+		}
+		ids = ids[start:end]
+	}
+
+	if len(ids) == 0 {
+		return []model.Bookmark{}, nil
+	}
+
+	bookmarks, err := db.GetBookmarks(GetBookmarksOptions{
+		IDs:         ids,
+		WithContent: opts.WithContent,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	position := map[int]int{}
+	for i, id := range ids {
+		position[id] = i
+	}
+
+	sort.Slice(bookmarks, func(i, j int) bool {
+		return position[bookmarks[i].ID] < position[bookmarks[j].ID]
+	})
+
+	return bookmarks, nil
+}
+
+// vectorSimilarity returns cosine similarity of two vectors, or zero
+// if they have different dimensions.
+func vectorSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS link_check(
+		id          INT(11)    NOT NULL AUTO_INCREMENT,
+		bookmark_id INT(11)    NOT NULL,
+		checked     TIMESTAMP  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		method      VARCHAR(8) NOT NULL DEFAULT '',
+		status_code INT(11)    NOT NULL DEFAULT 0,
+		final_url   TEXT       NOT NULL DEFAULT (''),
+		broken      BOOLEAN    NOT NULL DEFAULT 0,
+		soft_404    BOOLEAN    NOT NULL DEFAULT 0,
+		message     TEXT       NOT NULL DEFAULT (''),
+		PRIMARY KEY (id),
+		KEY link_check_bookmark_id_IDX (bookmark_id, id),
+		CONSTRAINT link_check_bookmark_id_FK FOREIGN KEY (bookmark_id) REFERENCES bookmark (id))
+		CHARACTER SET utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE bookmark ADD COLUMN archive_size BIGINT NOT NULL DEFAULT 0;
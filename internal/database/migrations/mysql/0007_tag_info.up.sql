@@ -0,0 +1,3 @@
+ALTER TABLE tag
+    ADD COLUMN color VARCHAR(7) NOT NULL DEFAULT '',
+    ADD COLUMN description VARCHAR(500) NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS bookmark_rule(
+		id               INT(11)      NOT NULL AUTO_INCREMENT,
+		name             VARCHAR(250) NOT NULL DEFAULT '',
+		domain           VARCHAR(250) NOT NULL DEFAULT '',
+		path_pattern     TEXT         NOT NULL DEFAULT (''),
+		title_keywords   TEXT         NOT NULL DEFAULT (''),
+		content_keywords TEXT         NOT NULL DEFAULT (''),
+		tags             TEXT         NOT NULL DEFAULT (''),
+		public           BOOLEAN      NULL,
+		enabled          BOOLEAN      NOT NULL DEFAULT 1,
+		PRIMARY KEY (id))
+		CHARACTER SET utf8mb4;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS bookmark_embedding(
+		bookmark_id INT(11)      NOT NULL,
+		provider    VARCHAR(250) NOT NULL DEFAULT '',
+		modified    VARCHAR(32)  NOT NULL DEFAULT '',
+		vector      MEDIUMBLOB   NOT NULL,
+		PRIMARY KEY (bookmark_id),
+		CONSTRAINT bookmark_embedding_bookmark_id_FK FOREIGN KEY (bookmark_id) REFERENCES bookmark (id))
+		CHARACTER SET utf8mb4;
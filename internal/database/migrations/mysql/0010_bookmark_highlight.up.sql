@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS bookmark_highlight(
+		id           INT(11)   NOT NULL AUTO_INCREMENT,
+		bookmark_id  INT(11)   NOT NULL,
+		quote        TEXT      NOT NULL DEFAULT (''),
+		prefix       TEXT      NOT NULL DEFAULT (''),
+		suffix       TEXT      NOT NULL DEFAULT (''),
+		start_offset INT(11)   NOT NULL DEFAULT 0,
+		end_offset   INT(11)   NOT NULL DEFAULT 0,
+		note         TEXT      NOT NULL DEFAULT (''),
+		created      TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		modified     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (id),
+		KEY bookmark_highlight_bookmark_id_IDX (bookmark_id, start_offset),
+		FULLTEXT KEY bookmark_highlight_content_IDX (quote, note),
+		CONSTRAINT bookmark_highlight_bookmark_id_FK FOREIGN KEY (bookmark_id) REFERENCES bookmark (id))
+		CHARACTER SET utf8mb4;
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS shared_resource(
+		hash VARCHAR(64) NOT NULL,
+		size BIGINT      NOT NULL DEFAULT 0,
+		PRIMARY KEY (hash))
+		CHARACTER SET utf8mb4;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS bookmark_terms(
+		bookmark_id INT(11)     NOT NULL,
+		modified    VARCHAR(32) NOT NULL DEFAULT '',
+		terms       MEDIUMTEXT  NOT NULL,
+		PRIMARY KEY (bookmark_id),
+		CONSTRAINT bookmark_terms_bookmark_id_FK FOREIGN KEY (bookmark_id) REFERENCES bookmark (id))
+		CHARACTER SET utf8mb4;
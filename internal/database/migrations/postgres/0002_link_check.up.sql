@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS link_check(
+		id          SERIAL,
+		bookmark_id INT          NOT NULL,
+		checked     TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		method      VARCHAR(8)   NOT NULL DEFAULT '',
+		status_code INT          NOT NULL DEFAULT 0,
+		final_url   TEXT         NOT NULL DEFAULT '',
+		broken      BOOLEAN      NOT NULL DEFAULT FALSE,
+		soft_404    BOOLEAN      NOT NULL DEFAULT FALSE,
+		message     TEXT         NOT NULL DEFAULT '',
+		PRIMARY KEY(id),
+		CONSTRAINT link_check_bookmark_id_FK FOREIGN KEY (bookmark_id) REFERENCES bookmark (id));
+
+CREATE INDEX IF NOT EXISTS link_check_bookmark_id_IDX ON link_check (bookmark_id, id);
@@ -0,0 +1 @@
+ALTER TABLE bookmark ADD COLUMN IF NOT EXISTS archive_size BIGINT NOT NULL DEFAULT 0;
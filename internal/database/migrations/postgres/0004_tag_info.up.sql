@@ -0,0 +1,3 @@
+ALTER TABLE tag
+    ADD COLUMN IF NOT EXISTS color VARCHAR(7) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS bookmark_highlight(
+		id           SERIAL,
+		bookmark_id  INT          NOT NULL,
+		quote        TEXT         NOT NULL DEFAULT '',
+		prefix       TEXT         NOT NULL DEFAULT '',
+		suffix       TEXT         NOT NULL DEFAULT '',
+		start_offset INT          NOT NULL DEFAULT 0,
+		end_offset   INT          NOT NULL DEFAULT 0,
+		note         TEXT         NOT NULL DEFAULT '',
+		created      TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		modified     TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(id),
+		CONSTRAINT bookmark_highlight_bookmark_id_FK FOREIGN KEY (bookmark_id) REFERENCES bookmark (id));
+
+CREATE INDEX IF NOT EXISTS bookmark_highlight_bookmark_id_IDX ON bookmark_highlight (bookmark_id, start_offset);
+
+CREATE INDEX IF NOT EXISTS bookmark_highlight_content_IDX ON bookmark_highlight
+	USING GIN (to_tsvector('simple', quote || ' ' || note));
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS shared_resource(
+		hash VARCHAR(64) NOT NULL,
+		size BIGINT      NOT NULL DEFAULT 0,
+		PRIMARY KEY(hash));
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS bookmark_terms(
+		bookmark_id INT         NOT NULL,
+		modified    VARCHAR(32) NOT NULL DEFAULT '',
+		terms       TEXT        NOT NULL DEFAULT '',
+		PRIMARY KEY(bookmark_id),
+		CONSTRAINT bookmark_terms_bookmark_id_FK FOREIGN KEY (bookmark_id) REFERENCES bookmark (id));
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS link_check(
+    id INTEGER NOT NULL,
+    bookmark_id INTEGER NOT NULL,
+    checked TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    method TEXT NOT NULL DEFAULT "",
+    status_code INTEGER NOT NULL DEFAULT 0,
+    final_url TEXT NOT NULL DEFAULT "",
+    broken INTEGER NOT NULL DEFAULT 0,
+    soft_404 INTEGER NOT NULL DEFAULT 0,
+    message TEXT NOT NULL DEFAULT "",
+    CONSTRAINT link_check_PK PRIMARY KEY(id),
+    CONSTRAINT link_check_bookmark_id_FK FOREIGN KEY(bookmark_id) REFERENCES bookmark(id)
+);
+
+CREATE INDEX IF NOT EXISTS link_check_bookmark_id_IDX ON link_check(bookmark_id, id);
@@ -0,0 +1 @@
+ALTER TABLE bookmark ADD COLUMN archive_size INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE tag ADD COLUMN color TEXT NOT NULL DEFAULT "";
+ALTER TABLE tag ADD COLUMN description TEXT NOT NULL DEFAULT "";
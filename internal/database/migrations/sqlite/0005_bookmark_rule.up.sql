@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS bookmark_rule(
+    id INTEGER NOT NULL,
+    name TEXT NOT NULL DEFAULT "",
+    domain TEXT NOT NULL DEFAULT "",
+    path_pattern TEXT NOT NULL DEFAULT "",
+    title_keywords TEXT NOT NULL DEFAULT "",
+    content_keywords TEXT NOT NULL DEFAULT "",
+    tags TEXT NOT NULL DEFAULT "",
+    public INTEGER,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT bookmark_rule_PK PRIMARY KEY(id)
+);
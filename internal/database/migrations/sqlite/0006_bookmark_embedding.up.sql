@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS bookmark_embedding(
+    bookmark_id INTEGER NOT NULL,
+    provider TEXT NOT NULL DEFAULT "",
+    modified TEXT NOT NULL DEFAULT "",
+    vector BLOB NOT NULL,
+    CONSTRAINT bookmark_embedding_PK PRIMARY KEY(bookmark_id),
+    CONSTRAINT bookmark_embedding_bookmark_id_FK FOREIGN KEY(bookmark_id) REFERENCES bookmark(id)
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS bookmark_highlight(
+    id INTEGER NOT NULL,
+    bookmark_id INTEGER NOT NULL,
+    quote TEXT NOT NULL DEFAULT "",
+    prefix TEXT NOT NULL DEFAULT "",
+    suffix TEXT NOT NULL DEFAULT "",
+    start_offset INTEGER NOT NULL DEFAULT 0,
+    end_offset INTEGER NOT NULL DEFAULT 0,
+    note TEXT NOT NULL DEFAULT "",
+    created TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modified TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT bookmark_highlight_PK PRIMARY KEY(id),
+    CONSTRAINT bookmark_highlight_bookmark_id_FK FOREIGN KEY(bookmark_id) REFERENCES bookmark(id)
+);
+
+CREATE INDEX IF NOT EXISTS bookmark_highlight_bookmark_id_IDX ON bookmark_highlight(bookmark_id, start_offset);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS bookmark_highlight_content
+    USING fts5(quote, note);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS shared_resource(
+    hash TEXT NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT shared_resource_PK PRIMARY KEY(hash)
+);
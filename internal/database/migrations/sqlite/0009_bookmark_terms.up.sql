@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS bookmark_terms(
+    bookmark_id INTEGER NOT NULL,
+    modified TEXT NOT NULL DEFAULT "",
+    terms TEXT NOT NULL DEFAULT "",
+    CONSTRAINT bookmark_terms_PK PRIMARY KEY(bookmark_id),
+    CONSTRAINT bookmark_terms_bookmark_id_FK FOREIGN KEY(bookmark_id) REFERENCES bookmark(id)
+);
@@ -140,8 +140,7 @@ func (db *PGDatabase) SaveBookmarks(bookmarks ...model.Bookmark) (result []model
 			}
 
 			// Normalize tag name
-			tagName := strings.ToLower(tag.Name)
-			tagName = strings.Join(strings.Fields(tagName), " ")
+			tagName := NormalizeTagName(tag.Name)
 
 			// If tag doesn't have any ID, fetch it from database
 			if tag.ID == 0 {
@@ -178,6 +177,10 @@ func (db *PGDatabase) SaveBookmarks(bookmarks ...model.Bookmark) (result []model
 
 // GetBookmarks fetch list of bookmarks based on submitted options.
 func (db *PGDatabase) GetBookmarks(opts GetBookmarksOptions) ([]model.Bookmark, error) {
+	if len(opts.SearchVector) > 0 {
+		return getHybridBookmarks(db, opts)
+	}
+
 	// Create initial query
 	columns := []string{
 		`id`,
@@ -187,6 +190,7 @@ func (db *PGDatabase) GetBookmarks(opts GetBookmarksOptions) ([]model.Bookmark,
 		`author`,
 		`public`,
 		`modified`,
+		`archive_size`,
 		`content <> '' has_content`}
 
 	if opts.WithContent {
@@ -209,13 +213,26 @@ func (db *PGDatabase) GetBookmarks(opts GetBookmarksOptions) ([]model.Bookmark,
 	if opts.Keyword != "" {
 		query += ` AND (
 			url LIKE :lkw OR
-			MATCH(title, excerpt, content) AGAINST (:kw IN BOOLEAN MODE)
+			MATCH(title, excerpt, content) AGAINST (:kw IN BOOLEAN MODE) OR
+			id IN (
+				SELECT bookmark_id
+				FROM bookmark_highlight
+				WHERE to_tsvector('simple', quote || ' ' || note) @@ plainto_tsquery('simple', :kw))
 		)`
 
 		arg["lkw"] = "%" + opts.Keyword + "%"
 		arg["kw"] = opts.Keyword
 	}
 
+	// Add where clause for broken links, i.e. the latest check is failed
+	if opts.Broken {
+		query += ` AND id IN (
+			SELECT lc.bookmark_id
+			FROM link_check lc
+			WHERE lc.broken = TRUE AND lc.id IN (
+				SELECT MAX(id) FROM link_check GROUP BY bookmark_id))`
+	}
+
 	// Add where clause for tags.
 	// First we check for * in excluded and included tags,
 	// which means all tags will be excluded and included, respectively.
@@ -245,39 +262,19 @@ func (db *PGDatabase) GetBookmarks(opts GetBookmarksOptions) ([]model.Bookmark,
 		query += ` AND id IN (SELECT DISTINCT bookmark_id FROM bookmark_tag)`
 	}
 
-	// Now we only need to find the normal tags
-	if len(opts.Tags) > 0 {
-		query += ` AND id IN (
-			SELECT bt.bookmark_id
-			FROM bookmark_tag bt
-			LEFT JOIN tag t ON bt.tag_id = t.id
-			WHERE t.name IN(:tags)
-			GROUP BY bt.bookmark_id
-			HAVING COUNT(bt.bookmark_id) = :ltags)`
-
-		arg["tags"] = opts.Tags
-		arg["ltags"] = len(opts.Tags)
-	}
-
-	if len(opts.ExcludedTags) > 0 {
-		query += ` AND id NOT IN (
-			SELECT DISTINCT bt.bookmark_id
-			FROM bookmark_tag bt
-			LEFT JOIN tag t ON bt.tag_id = t.id
-			WHERE t.name IN(:extags))`
-
-		arg["extags"] = opts.ExcludedTags
+	// Now we only need to find the normal tags, including their descendants
+	tagCondition, tagArg := tagClauses("id", opts.Tags, opts.ExcludedTags)
+	for name, value := range tagArg {
+		arg[name] = value
 	}
+	query += tagCondition
 
-	// Add order clause
-	switch opts.OrderMethod {
-	case ByLastAdded:
-		query += ` ORDER BY id DESC`
-	case ByLastModified:
-		query += ` ORDER BY modified DESC`
-	default:
-		query += ` ORDER BY id`
+	// Add cursor and order clause
+	cursorCondition, cursorArg, orderClause := opts.orderClauses("id", "modified")
+	for name, value := range cursorArg {
+		arg[name] = value
 	}
+	query += cursorCondition + orderClause
 
 	if opts.Limit > 0 && opts.Offset >= 0 {
 		query += ` LIMIT :limit OFFSET :offset`
@@ -300,9 +297,10 @@ func (db *PGDatabase) GetBookmarks(opts GetBookmarksOptions) ([]model.Bookmark,
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("failed to fetch data: %v", err)
 	}
+	opts.restoreOrder(bookmarks)
 
 	// Fetch tags for each bookmarks
-	stmtGetTags, err := db.Preparex(`SELECT t.id, t.name
+	stmtGetTags, err := db.Preparex(`SELECT t.id, t.name, t.color
 		FROM bookmark_tag bt
 		LEFT JOIN tag t ON bt.tag_id = t.id
 		WHERE bt.bookmark_id = $1
@@ -327,6 +325,11 @@ func (db *PGDatabase) GetBookmarks(opts GetBookmarksOptions) ([]model.Bookmark,
 
 // GetBookmarksCount fetch count of bookmarks based on submitted options.
 func (db *PGDatabase) GetBookmarksCount(opts GetBookmarksOptions) (int, error) {
+	if len(opts.SearchVector) > 0 {
+		ids, err := hybridRanking(db, opts)
+		return len(ids), err
+	}
+
 	// Create initial query
 	query := `SELECT COUNT(id) FROM bookmark WHERE TRUE`
 
@@ -342,13 +345,26 @@ func (db *PGDatabase) GetBookmarksCount(opts GetBookmarksOptions) (int, error) {
 	if opts.Keyword != "" {
 		query += ` AND (
 			url LIKE :lurl OR
-			MATCH(title, excerpt, content) AGAINST (:kw IN BOOLEAN MODE)
+			MATCH(title, excerpt, content) AGAINST (:kw IN BOOLEAN MODE) OR
+			id IN (
+				SELECT bookmark_id
+				FROM bookmark_highlight
+				WHERE to_tsvector('simple', quote || ' ' || note) @@ plainto_tsquery('simple', :kw))
 		)`
 
 		arg["lurl"] = "%" + opts.Keyword + "%"
 		arg["kw"] = opts.Keyword
 	}
 
+	// Add where clause for broken links, i.e. the latest check is failed
+	if opts.Broken {
+		query += ` AND id IN (
+			SELECT lc.bookmark_id
+			FROM link_check lc
+			WHERE lc.broken = TRUE AND lc.id IN (
+				SELECT MAX(id) FROM link_check GROUP BY bookmark_id))`
+	}
+
 	// Add where clause for tags.
 	// First we check for * in excluded and included tags,
 	// which means all tags will be excluded and included, respectively.
@@ -378,29 +394,12 @@ func (db *PGDatabase) GetBookmarksCount(opts GetBookmarksOptions) (int, error) {
 		query += ` AND id IN (SELECT DISTINCT bookmark_id FROM bookmark_tag)`
 	}
 
-	// Now we only need to find the normal tags
-	if len(opts.Tags) > 0 {
-		query += ` AND id IN (
-			SELECT bt.bookmark_id
-			FROM bookmark_tag bt
-			LEFT JOIN tag t ON bt.tag_id = t.id
-			WHERE t.name IN(:tags)
-			GROUP BY bt.bookmark_id
-			HAVING COUNT(bt.bookmark_id) = :ltags)`
-
-		arg["tags"] = opts.Tags
-		arg["ltags"] = len(opts.Tags)
-	}
-
-	if len(opts.ExcludedTags) > 0 {
-		query += ` AND id NOT IN (
-			SELECT DISTINCT bt.bookmark_id
-			FROM bookmark_tag bt
-			LEFT JOIN tag t ON bt.tag_id = t.id
-			WHERE t.name IN(:etags))`
-
-		arg["etags"] = opts.ExcludedTags
+	// Now we only need to find the normal tags, including their descendants
+	tagCondition, tagArg := tagClauses("id", opts.Tags, opts.ExcludedTags)
+	for name, value := range tagArg {
+		arg[name] = value
 	}
+	query += tagCondition
 
 	// Expand query, because some of the args might be an array
 	var err error
@@ -443,20 +442,40 @@ func (db *PGDatabase) DeleteBookmarks(ids ...int) (err error) {
 	// Prepare queries
 	delBookmark := `DELETE FROM bookmark`
 	delBookmarkTag := `DELETE FROM bookmark_tag`
+	delLinkCheck := `DELETE FROM link_check`
+	delEmbedding := `DELETE FROM bookmark_embedding`
+	delTerms := `DELETE FROM bookmark_terms`
+	delHighlight := `DELETE FROM bookmark_highlight`
 
 	// Delete bookmark(s)
 	if len(ids) == 0 {
 		tx.MustExec(delBookmarkTag)
+		tx.MustExec(delLinkCheck)
+		tx.MustExec(delEmbedding)
+		tx.MustExec(delTerms)
+		tx.MustExec(delHighlight)
 		tx.MustExec(delBookmark)
 	} else {
 		delBookmark += ` WHERE id = $1`
 		delBookmarkTag += ` WHERE bookmark_id = $1`
+		delLinkCheck += ` WHERE bookmark_id = $1`
+		delEmbedding += ` WHERE bookmark_id = $1`
+		delTerms += ` WHERE bookmark_id = $1`
+		delHighlight += ` WHERE bookmark_id = $1`
 
 		stmtDelBookmark, _ := tx.Preparex(delBookmark)
 		stmtDelBookmarkTag, _ := tx.Preparex(delBookmarkTag)
+		stmtDelLinkCheck, _ := tx.Preparex(delLinkCheck)
+		stmtDelEmbedding, _ := tx.Preparex(delEmbedding)
+		stmtDelTerms, _ := tx.Preparex(delTerms)
+		stmtDelHighlight, _ := tx.Preparex(delHighlight)
 
 		for _, id := range ids {
 			stmtDelBookmarkTag.MustExec(id)
+			stmtDelLinkCheck.MustExec(id)
+			stmtDelEmbedding.MustExec(id)
+			stmtDelTerms.MustExec(id)
+			stmtDelHighlight.MustExec(id)
 			stmtDelBookmark.MustExec(id)
 		}
 	}
@@ -474,7 +493,7 @@ func (db *PGDatabase) GetBookmark(id int, url string) (model.Bookmark, bool) {
 	args := []interface{}{id}
 	query := `SELECT
 		id, url, title, excerpt, author, public,
-		content, html, modified, content <> '' has_content
+		content, html, modified, archive_size, content <> '' has_content
 		FROM bookmark WHERE id = $1`
 
 	if url != "" {
@@ -490,6 +509,186 @@ func (db *PGDatabase) GetBookmark(id int, url string) (model.Bookmark, bool) {
 	return book, book.ID != 0
 }
 
+// UpdateArchiveSizes saves the size of offline archive of the bookmarks.
+func (db *PGDatabase) UpdateArchiveSizes(sizes map[int]int64) (err error) {
+	// Begin transaction
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	// Make sure to rollback if panic ever happened
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr, _ := r.(error)
+			if err := tx.Rollback(); err != nil {
+				log.Printf("error during rollback: %s", err)
+			}
+			err = panicErr
+		}
+	}()
+
+	stmtUpdate, _ := tx.Preparex(`UPDATE bookmark SET archive_size = $1 WHERE id = $2`)
+	for id, size := range sizes {
+		stmtUpdate.MustExec(size, id)
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	checkError(err)
+
+	return err
+}
+
+// UpdateResourceSizes saves the size of resources shared between archives.
+func (db *PGDatabase) UpdateResourceSizes(sizes map[string]int64, replace bool) (err error) {
+	// Begin transaction
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	// Make sure to rollback if panic ever happened
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr, _ := r.(error)
+			if err := tx.Rollback(); err != nil {
+				log.Printf("error during rollback: %s", err)
+			}
+			err = panicErr
+		}
+	}()
+
+	if replace {
+		tx.MustExec(`DELETE FROM shared_resource`)
+	}
+
+	stmtSave, _ := tx.Preparex(`INSERT INTO shared_resource (hash, size) VALUES ($1, $2)
+		ON CONFLICT (hash) DO UPDATE SET size = $2`)
+	stmtDelete, _ := tx.Preparex(`DELETE FROM shared_resource WHERE hash = $1`)
+	for hash, size := range sizes {
+		if size > 0 {
+			stmtSave.MustExec(hash, size)
+		} else {
+			stmtDelete.MustExec(hash)
+		}
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	checkError(err)
+
+	return err
+}
+
+// GetStorageUsage returns the disk space used by offline archives and shared resources.
+func (db *PGDatabase) GetStorageUsage() (model.StorageUsage, error) {
+	usage := model.StorageUsage{}
+	err := db.Get(&usage, storageUsageQuery)
+	return usage, err
+}
+
+// SaveLinkChecks saves the results of checking bookmarks' URL.
+func (db *PGDatabase) SaveLinkChecks(checks ...model.LinkCheck) (err error) {
+	// Begin transaction
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	// Make sure to rollback if panic ever happened
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr, _ := r.(error)
+			if err := tx.Rollback(); err != nil {
+				log.Printf("error during rollback: %s", err)
+			}
+			err = panicErr
+		}
+	}()
+
+	// Insert each result, which kept as history of the bookmark
+	stmtInsert, _ := tx.Preparex(`INSERT INTO link_check
+		(bookmark_id, checked, method, status_code, final_url, broken, soft_404, message)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+
+	checkedTime := time.Now().UTC().Format("2006-01-02 15:04:05")
+	for _, check := range checks {
+		if check.Checked == "" {
+			check.Checked = checkedTime
+		}
+
+		stmtInsert.MustExec(check.BookmarkID, check.Checked, check.Method, check.StatusCode,
+			check.FinalURL, check.Broken, check.SoftNotFound, check.Message)
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	checkError(err)
+
+	return err
+}
+
+// GetLinkChecks fetch list of link check results based on submitted options, newest first.
+func (db *PGDatabase) GetLinkChecks(opts GetLinkChecksOptions) ([]model.LinkCheck, error) {
+	// Create query
+	args := []interface{}{}
+	query := `SELECT id, bookmark_id, checked, method, status_code,
+		final_url, broken, soft_404, message
+		FROM link_check WHERE TRUE`
+
+	if len(opts.BookmarkIDs) > 0 {
+		query += ` AND bookmark_id IN (?)`
+		args = append(args, opts.BookmarkIDs)
+	}
+
+	if opts.LatestOnly {
+		query += ` AND id IN (SELECT MAX(id) FROM link_check GROUP BY bookmark_id)`
+	}
+
+	if opts.BrokenOnly {
+		query += ` AND broken = TRUE`
+	}
+
+	query += ` ORDER BY id DESC`
+
+	if opts.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, opts.Limit)
+	}
+
+	// Expand query, because some of the args might be an array
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand query: %v", err)
+	}
+	query = db.Rebind(query)
+
+	// Fetch results
+	checks := []model.LinkCheck{}
+	err = db.Select(&checks, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to fetch link checks: %v", err)
+	}
+
+	return checks, nil
+}
+
+// PruneLinkChecks removes the old link check results, so only the latest
+// keep results of each bookmark are left.
+func (db *PGDatabase) PruneLinkChecks(keep int) error {
+	// Remove the result that has at least keep newer results
+	_, err := db.Exec(`DELETE FROM link_check WHERE (
+		SELECT COUNT(*) FROM link_check AS newer
+		WHERE newer.bookmark_id = link_check.bookmark_id
+		AND newer.id > link_check.id) >= $1`, keep)
+	if err != nil {
+		return fmt.Errorf("failed to prune link checks: %v", err)
+	}
+
+	return nil
+}
+
 // SaveAccount saves new account to database. Returns error if any happened.
 func (db *PGDatabase) SaveAccount(account model.Account) (err error) {
 	// Hash password with bcrypt
@@ -585,10 +784,10 @@ func (db *PGDatabase) DeleteAccounts(usernames ...string) (err error) {
 // GetTags fetch list of tags and their frequency.
 func (db *PGDatabase) GetTags() ([]model.Tag, error) {
 	tags := []model.Tag{}
-	query := `SELECT bt.tag_id id, t.name, COUNT(bt.tag_id) n_bookmarks
+	query := `SELECT bt.tag_id id, t.name, t.color, t.description, COUNT(bt.tag_id) n_bookmarks
 		FROM bookmark_tag bt
 		LEFT JOIN tag t ON bt.tag_id = t.id
-		GROUP BY bt.tag_id, t.name ORDER BY t.name`
+		GROUP BY bt.tag_id, t.name, t.color, t.description ORDER BY t.name`
 
 	err := db.Select(&tags, query)
 	if err != nil && err != sql.ErrNoRows {
@@ -598,12 +797,463 @@ func (db *PGDatabase) GetTags() ([]model.Tag, error) {
 	return tags, nil
 }
 
-// RenameTag change the name of a tag.
-func (db *PGDatabase) RenameTag(id int, newName string) error {
-	_, err := db.Exec(`UPDATE tag SET name = $1 WHERE id = $2`, newName, id)
+// GetTag fetch tag based on its ID or name, along with its frequency.
+// Returns the tag and boolean whether it's exist or not.
+func (db *PGDatabase) GetTag(id int, name string) (model.Tag, bool) {
+	args := []interface{}{id}
+	query := `SELECT t.id, t.name, t.color, t.description,
+		(SELECT COUNT(*) FROM bookmark_tag bt WHERE bt.tag_id = t.id) n_bookmarks
+		FROM tag t
+		WHERE t.id = $1`
+
+	if name != "" {
+		query += ` OR t.name = $2`
+		args = append(args, NormalizeTagName(name))
+	}
+
+	tag := model.Tag{}
+	if err := db.Get(&tag, query, args...); err != nil && err != sql.ErrNoRows {
+		log.Printf("error during db.get: %s", err)
+	}
+
+	return tag, tag.ID != 0
+}
+
+// UpdateTag saves the color and description of a tag.
+func (db *PGDatabase) UpdateTag(tag model.Tag) error {
+	_, err := db.Exec(`UPDATE tag SET color = $1, description = $2 WHERE id = $3`,
+		tag.Color, tag.Description, tag.ID)
 	return err
 }
 
+// MergeTags moves bookmarks of the tags into the tag with specified name, then
+// removes the old tags. If there is no tag with that name yet, the first tag
+// is renamed instead. Color and description of the target are kept, unless
+// it doesn't have any.
+func (db *PGDatabase) MergeTags(name string, ids ...int) (tag model.Tag, err error) {
+	name = NormalizeTagName(name)
+	if name == "" {
+		return model.Tag{}, fmt.Errorf("tag name is empty")
+	}
+
+	if len(ids) == 0 {
+		return model.Tag{}, fmt.Errorf("no tag to merge")
+	}
+
+	// Begin transaction
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.Tag{}, err
+	}
+
+	// Make sure to rollback if panic ever happened
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr, _ := r.(error)
+			if err := tx.Rollback(); err != nil {
+				log.Printf("error during rollback: %s", err)
+			}
+			tag = model.Tag{}
+			err = panicErr
+		}
+	}()
+
+	// Find the target, or rename the first tag into it
+	var targetID int
+	err = tx.Get(&targetID, `SELECT id FROM tag WHERE name = $1`, name)
+	checkError(err)
+
+	if targetID == 0 {
+		targetID = ids[0]
+		tx.MustExec(`UPDATE tag SET name = $1 WHERE id = $2`, name, targetID)
+	}
+
+	// Prepare queries
+	stmtGetTag, _ := tx.Preparex(`SELECT id, name, color, description FROM tag WHERE id = $1`)
+	stmtMoveBookTag, _ := tx.Preparex(`INSERT INTO bookmark_tag (tag_id, bookmark_id)
+		SELECT $1, bookmark_id FROM bookmark_tag WHERE tag_id = $2
+		ON CONFLICT DO NOTHING`)
+	stmtDeleteBookTag, _ := tx.Preparex(`DELETE FROM bookmark_tag WHERE tag_id = $1`)
+	stmtDeleteTag, _ := tx.Preparex(`DELETE FROM tag WHERE id = $1`)
+	stmtFillColor, _ := tx.Preparex(`UPDATE tag SET color = $1 WHERE id = $2 AND color = ''`)
+	stmtFillDescription, _ := tx.Preparex(`UPDATE tag SET description = $1 WHERE id = $2 AND description = ''`)
+
+	// Merge the tags
+	for _, id := range ids {
+		if id == targetID {
+			continue
+		}
+
+		source := model.Tag{}
+		err = stmtGetTag.Get(&source, id)
+		checkError(err)
+		if source.ID == 0 {
+			continue
+		}
+
+		stmtMoveBookTag.MustExec(targetID, id)
+		stmtDeleteBookTag.MustExec(id)
+		stmtDeleteTag.MustExec(id)
+		stmtFillColor.MustExec(source.Color, targetID)
+		stmtFillDescription.MustExec(source.Description, targetID)
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	checkError(err)
+
+	tag, exist := db.GetTag(targetID, "")
+	if !exist {
+		return model.Tag{}, fmt.Errorf("tag is not found")
+	}
+
+	return tag, nil
+}
+
+// DeleteTags removes the tags and detach them from all bookmarks.
+func (db *PGDatabase) DeleteTags(ids ...int) (err error) {
+	// Begin transaction
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	// Make sure to rollback if panic ever happened
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr, _ := r.(error)
+			if err := tx.Rollback(); err != nil {
+				log.Printf("error during rollback: %s", err)
+			}
+			err = panicErr
+		}
+	}()
+
+	stmtDeleteBookTag, _ := tx.Preparex(`DELETE FROM bookmark_tag WHERE tag_id = $1`)
+	stmtDeleteTag, _ := tx.Preparex(`DELETE FROM tag WHERE id = $1`)
+	for _, id := range ids {
+		stmtDeleteBookTag.MustExec(id)
+		stmtDeleteTag.MustExec(id)
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	checkError(err)
+
+	return err
+}
+
+// DeleteOrphanTags removes tags that not used by any bookmark.
+// Returns the number of removed tags.
+func (db *PGDatabase) DeleteOrphanTags() (int, error) {
+	res, err := db.Exec(`DELETE FROM tag WHERE id NOT IN (SELECT DISTINCT tag_id FROM bookmark_tag)`)
+	if err != nil {
+		return 0, err
+	}
+
+	nDeleted, err := res.RowsAffected()
+	return int(nDeleted), err
+}
+
+// SaveRule saves the tagging rule, which is created if it doesn't have ID yet.
+func (db *PGDatabase) SaveRule(rule model.Rule) (model.Rule, error) {
+	row := newRuleRow(rule)
+	if row.ID == 0 {
+		err := db.Get(&row.ID, `INSERT INTO bookmark_rule
+			(name, domain, path_pattern, title_keywords, content_keywords, tags, public, enabled)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+			row.Name, row.Domain, row.PathPattern, row.TitleKeywords,
+			row.ContentKeywords, row.Tags, row.Public, row.Enabled)
+		if err != nil {
+			return model.Rule{}, fmt.Errorf("failed to insert rule: %v", err)
+		}
+	} else {
+		_, err := db.Exec(`UPDATE bookmark_rule SET
+			name = $1, domain = $2, path_pattern = $3, title_keywords = $4,
+			content_keywords = $5, tags = $6, public = $7, enabled = $8
+			WHERE id = $9`,
+			row.Name, row.Domain, row.PathPattern, row.TitleKeywords,
+			row.ContentKeywords, row.Tags, row.Public, row.Enabled, row.ID)
+		if err != nil {
+			return model.Rule{}, fmt.Errorf("failed to update rule: %v", err)
+		}
+	}
+
+	return row.toRule(), nil
+}
+
+// GetRules fetch list of tagging rules, ordered by ID.
+func (db *PGDatabase) GetRules() ([]model.Rule, error) {
+	rows := []ruleRow{}
+	err := db.Select(&rows, `SELECT id, name, domain, path_pattern, title_keywords,
+		content_keywords, tags, public, enabled
+		FROM bookmark_rule ORDER BY id`)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to fetch rules: %v", err)
+	}
+
+	rules := make([]model.Rule, len(rows))
+	for i, row := range rows {
+		rules[i] = row.toRule()
+	}
+
+	return rules, nil
+}
+
+// DeleteRules removes all tagging rules with matching ids.
+func (db *PGDatabase) DeleteRules(ids ...int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In(`DELETE FROM bookmark_rule WHERE id IN (?)`, ids)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(db.Rebind(query), args...)
+	return err
+}
+
+// SaveHighlight saves the highlight, which is created if it doesn't have ID yet.
+func (db *PGDatabase) SaveHighlight(highlight model.Highlight) (model.Highlight, error) {
+	highlight.Modified = time.Now().UTC().Format("2006-01-02 15:04:05")
+	if highlight.ID == 0 {
+		highlight.Created = highlight.Modified
+		err := db.Get(&highlight.ID, `INSERT INTO bookmark_highlight
+			(bookmark_id, quote, prefix, suffix, start_offset, end_offset, note, created, modified)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+			highlight.BookmarkID, highlight.Quote, highlight.Prefix, highlight.Suffix,
+			highlight.Start, highlight.End, highlight.Note, highlight.Created, highlight.Modified)
+		if err != nil {
+			return model.Highlight{}, fmt.Errorf("failed to insert highlight: %v", err)
+		}
+	} else {
+		_, err := db.Exec(`UPDATE bookmark_highlight SET
+			quote = $1, prefix = $2, suffix = $3, start_offset = $4, end_offset = $5,
+			note = $6, modified = $7
+			WHERE id = $8`,
+			highlight.Quote, highlight.Prefix, highlight.Suffix, highlight.Start,
+			highlight.End, highlight.Note, highlight.Modified, highlight.ID)
+		if err != nil {
+			return model.Highlight{}, fmt.Errorf("failed to update highlight: %v", err)
+		}
+	}
+
+	return highlight, nil
+}
+
+// GetHighlights fetch list of highlights, ordered by bookmark and their position.
+func (db *PGDatabase) GetHighlights(opts GetHighlightsOptions) ([]model.Highlight, error) {
+	args := []interface{}{}
+	query := `SELECT id, bookmark_id, quote, prefix, suffix, start_offset,
+		end_offset, note, created, modified
+		FROM bookmark_highlight WHERE TRUE`
+
+	if len(opts.IDs) > 0 {
+		query += ` AND id IN (?)`
+		args = append(args, opts.IDs)
+	}
+
+	if len(opts.BookmarkIDs) > 0 {
+		query += ` AND bookmark_id IN (?)`
+		args = append(args, opts.BookmarkIDs)
+	}
+
+	query += ` ORDER BY bookmark_id, start_offset, id`
+
+	// Expand query, because some of the args might be an array
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand query: %v", err)
+	}
+	query = db.Rebind(query)
+
+	highlights := []model.Highlight{}
+	err = db.Select(&highlights, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to fetch highlights: %v", err)
+	}
+
+	return highlights, nil
+}
+
+// DeleteHighlights removes all highlights with matching ids.
+func (db *PGDatabase) DeleteHighlights(ids ...int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In(`DELETE FROM bookmark_highlight WHERE id IN (?)`, ids)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(db.Rebind(query), args...)
+	return err
+}
+
+// SaveEmbeddings saves the embeddings of bookmarks, replacing their old one.
+func (db *PGDatabase) SaveEmbeddings(embeddings ...model.Embedding) (err error) {
+	// Begin transaction
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	// Make sure to rollback if panic ever happened
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr, _ := r.(error)
+			if err := tx.Rollback(); err != nil {
+				log.Printf("error during rollback: %s", err)
+			}
+			err = panicErr
+		}
+	}()
+
+	stmtSave, _ := tx.Preparex(`INSERT INTO bookmark_embedding
+		(bookmark_id, provider, modified, vector) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (bookmark_id) DO UPDATE SET provider = $2,
+		modified = $3, vector = $4`)
+
+	for _, embedding := range embeddings {
+		row := newEmbeddingRow(embedding)
+		stmtSave.MustExec(row.BookmarkID, row.Provider, row.Modified, row.Vector)
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	checkError(err)
+
+	return err
+}
+
+// GetEmbeddings fetch the embeddings created by the provider. If ids is empty,
+// embeddings of all bookmarks are fetched.
+func (db *PGDatabase) GetEmbeddings(provider string, ids ...int) ([]model.Embedding, error) {
+	query := `SELECT bookmark_id, provider, modified, vector
+		FROM bookmark_embedding WHERE provider = ?`
+	args := []interface{}{provider}
+
+	if len(ids) > 0 {
+		query += ` AND bookmark_id IN (?)`
+		args = append(args, ids)
+	}
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand query: %v", err)
+	}
+	query = db.Rebind(query)
+
+	rows := []embeddingRow{}
+	err = db.Select(&rows, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to fetch embeddings: %v", err)
+	}
+
+	embeddings := make([]model.Embedding, len(rows))
+	for i, row := range rows {
+		embeddings[i] = row.toEmbedding()
+	}
+
+	return embeddings, nil
+}
+
+// GetKeywordScores fetch the full text search relevance of bookmarks whose text
+// matches the keyword, higher is more relevant. Bookmarks that only match by
+// their URL are not included.
+func (db *PGDatabase) GetKeywordScores(keyword string) (map[int]float64, error) {
+	query := `SELECT id, ts_rank(to_tsvector('simple', title || ' ' || excerpt || ' ' || content),
+		plainto_tsquery('simple', $1)) score
+		FROM bookmark
+		WHERE to_tsvector('simple', title || ' ' || excerpt || ' ' || content) @@ plainto_tsquery('simple', $1)`
+
+	rows := []struct {
+		ID    int     `db:"id"`
+		Score float64 `db:"score"`
+	}{}
+
+	err := db.Select(&rows, query, keyword)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to fetch keyword scores: %v", err)
+	}
+
+	scores := make(map[int]float64, len(rows))
+	for _, row := range rows {
+		scores[row.ID] = row.Score
+	}
+
+	return scores, nil
+}
+
+// SaveBookmarkTerms saves the terms of bookmarks, replacing their old one.
+func (db *PGDatabase) SaveBookmarkTerms(terms ...model.BookmarkTerms) (err error) {
+	// Begin transaction
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	// Make sure to rollback if panic ever happened
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr, _ := r.(error)
+			if err := tx.Rollback(); err != nil {
+				log.Printf("error during rollback: %s", err)
+			}
+			err = panicErr
+		}
+	}()
+
+	stmtSave, _ := tx.Preparex(`INSERT INTO bookmark_terms
+		(bookmark_id, modified, terms) VALUES ($1, $2, $3)
+		ON CONFLICT (bookmark_id) DO UPDATE SET modified = $2, terms = $3`)
+
+	for _, item := range terms {
+		row := newTermsRow(item)
+		stmtSave.MustExec(row.BookmarkID, row.Modified, row.Terms)
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	checkError(err)
+
+	return err
+}
+
+// GetBookmarkTerms fetch the terms of bookmarks. If ids is empty,
+// terms of all bookmarks are fetched.
+func (db *PGDatabase) GetBookmarkTerms(ids ...int) ([]model.BookmarkTerms, error) {
+	query := `SELECT bookmark_id, modified, terms FROM bookmark_terms`
+	args := []interface{}{}
+
+	if len(ids) > 0 {
+		query += ` WHERE bookmark_id IN (?)`
+		args = append(args, ids)
+	}
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand query: %v", err)
+	}
+	query = db.Rebind(query)
+
+	rows := []termsRow{}
+	err = db.Select(&rows, query, args...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to fetch bookmark terms: %v", err)
+	}
+
+	terms := make([]model.BookmarkTerms, len(rows))
+	for i, row := range rows {
+		terms[i] = row.toBookmarkTerms()
+	}
+
+	return terms, nil
+}
+
 // CreateNewID creates new ID for specified table
 func (db *PGDatabase) CreateNewID(table string) (int, error) {
 	var tableID int
@@ -2,10 +2,12 @@ package model
 
 // Tag is the tag for a bookmark.
 type Tag struct {
-	ID         int    `db:"id"          json:"id"`
-	Name       string `db:"name"        json:"name"`
-	NBookmarks int    `db:"n_bookmarks" json:"nBookmarks,omitempty"`
-	Deleted    bool   `json:"-"`
+	ID          int    `db:"id"          json:"id"`
+	Name        string `db:"name"        json:"name"`
+	Color       string `db:"color"       json:"color,omitempty"`
+	Description string `db:"description" json:"description,omitempty"`
+	NBookmarks  int    `db:"n_bookmarks" json:"nBookmarks,omitempty"`
+	Deleted     bool   `json:"-"`
 }
 
 // Bookmark is the record for an URL.
@@ -17,13 +19,95 @@ type Bookmark struct {
 	Author        string `db:"author"        json:"author"`
 	Public        int    `db:"public"        json:"public"`
 	Modified      string `db:"modified"      json:"modified"`
+	ArchiveSize   int64  `db:"archive_size"  json:"archiveSize"`
 	Content       string `db:"content"       json:"-"`
 	HTML          string `db:"html"          json:"html,omitempty"`
 	ImageURL      string `db:"image_url"     json:"imageURL"`
+	FaviconURL    string `json:"faviconURL"`
 	HasContent    bool   `db:"has_content"   json:"hasContent"`
 	HasArchive    bool   `json:"hasArchive"`
+	Broken        bool   `json:"broken"`
+	Lost          bool   `json:"lost"`
+	LinkURL       string `json:"linkURL"`
 	Tags          []Tag  `json:"tags"`
 	CreateArchive bool   `json:"createArchive"`
+	RenderJS      bool   `json:"renderJS,omitempty"`
+}
+
+// LinkCheck is the result of checking whether bookmark's URL is still reachable.
+type LinkCheck struct {
+	ID           int    `db:"id"          json:"id"`
+	BookmarkID   int    `db:"bookmark_id" json:"bookmarkID"`
+	Checked      string `db:"checked"     json:"checked"`
+	Method       string `db:"method"      json:"method"`
+	StatusCode   int    `db:"status_code" json:"statusCode"`
+	FinalURL     string `db:"final_url"   json:"finalURL"`
+	Broken       bool   `db:"broken"      json:"broken"`
+	SoftNotFound bool   `db:"soft_404"    json:"soft404"`
+	Message      string `db:"message"     json:"message"`
+}
+
+// Rule is automatic tagging rule. It's applied to the bookmarks that match all
+// of its conditions, i.e. domain, path pattern, and any of title and content keywords.
+type Rule struct {
+	ID              int      `json:"id"`
+	Name            string   `json:"name"`
+	Domain          string   `json:"domain"`
+	PathPattern     string   `json:"pathPattern"`
+	TitleKeywords   []string `json:"titleKeywords"`
+	ContentKeywords []string `json:"contentKeywords"`
+	Tags            []string `json:"tags"`
+	Public          *bool    `json:"public"`
+	Enabled         bool     `json:"enabled"`
+}
+
+// Embedding is the vector that represents meaning of bookmark's text. Vectors
+// are only comparable if they're created by the same provider. Modified is the
+// modified time of the bookmark when the vector is created.
+type Embedding struct {
+	BookmarkID int       `json:"bookmarkId"`
+	Provider   string    `json:"provider"`
+	Modified   string    `json:"modified"`
+	Vector     []float32 `json:"vector"`
+}
+
+// BookmarkTerms is the most frequent terms in bookmark's text with their frequency,
+// which are used to find related bookmarks. Modified is the modified time of the
+// bookmark when the terms are counted.
+type BookmarkTerms struct {
+	BookmarkID int                `json:"bookmarkId"`
+	Modified   string             `json:"modified"`
+	Terms      map[string]float64 `json:"terms"`
+}
+
+// Highlight is a passage of bookmark's readable content selected by user, with
+// optional note. It's anchored by its character offsets in the text of bookmark's
+// HTML content, while the quote and the text around it are used to find it again
+// when the content has changed.
+type Highlight struct {
+	ID         int    `db:"id"           json:"id"`
+	BookmarkID int    `db:"bookmark_id"  json:"bookmarkId"`
+	Quote      string `db:"quote"        json:"quote"`
+	Prefix     string `db:"prefix"       json:"prefix"`
+	Suffix     string `db:"suffix"       json:"suffix"`
+	Start      int    `db:"start_offset" json:"start"`
+	End        int    `db:"end_offset"   json:"end"`
+	Note       string `db:"note"         json:"note"`
+	Created    string `db:"created"      json:"created"`
+	Modified   string `db:"modified"     json:"modified"`
+}
+
+// StorageUsage is the disk space used by offline archives.
+type StorageUsage struct {
+	Archives        int   `db:"archives"         json:"archives"`
+	ArchiveSize     int64 `db:"archive_size"     json:"archiveSize"`
+	SharedResources int   `db:"shared_resources" json:"sharedResources"`
+	SharedSize      int64 `db:"shared_size"      json:"sharedSize"`
+}
+
+// Total returns the total disk space used by archives and shared resources.
+func (u StorageUsage) Total() int64 {
+	return u.ArchiveSize + u.SharedSize
 }
 
 // Account is person that allowed to access web interface.
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"io"
+	"os"
+	fp "path/filepath"
+	"strings"
+)
+
+// Local is storage in a directory on local disk.
+type Local struct {
+	dir string
+}
+
+// NewLocal returns storage that keeps the files in the directory.
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+// Path returns path of the file on local disk.
+func (l *Local) Path(name string) string {
+	return fp.Join(l.dir, fp.FromSlash(name))
+}
+
+// Open opens the file for reading.
+func (l *Local) Open(name string) (io.ReadCloser, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	return os.Open(l.Path(name))
+}
+
+// Stat returns information of the file.
+func (l *Local) Stat(name string) (FileInfo, error) {
+	if err := validateName(name); err != nil {
+		return FileInfo{}, err
+	}
+
+	info, err := os.Stat(l.Path(name))
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	if info.IsDir() {
+		return FileInfo{}, notExistError(name)
+	}
+
+	return FileInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Write saves the content into the file. It's written into temporary file first,
+// then renamed to replace the existing file.
+func (l *Local) Write(name string, content io.Reader) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	dstPath := l.Path(name)
+	if err := os.MkdirAll(fp.Dir(dstPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(fp.Dir(dstPath), fp.Base(dstPath)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = io.Copy(tmpFile, content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), dstPath)
+}
+
+// Remove removes the file.
+func (l *Local) Remove(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	err := os.Remove(l.Path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Walk calls fn for each file which name starts with the prefix. Temporary files
+// that still being written are skipped.
+func (l *Local) Walk(prefix string, fn func(FileInfo) error) error {
+	// Only walk the deepest directory that contains the prefix
+	rootDir := l.dir
+	if idx := strings.LastIndex(prefix, "/"); idx >= 0 {
+		rootDir = l.Path(prefix[:idx])
+	}
+
+	err := fp.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if !info.Mode().IsRegular() || fp.Ext(path) == ".tmp" {
+			return nil
+		}
+
+		relPath, err := fp.Rel(l.dir, path)
+		if err != nil {
+			return err
+		}
+
+		name := fp.ToSlash(relPath)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+
+		return fn(FileInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()})
+	})
+
+	return err
+}
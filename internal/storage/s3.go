@@ -0,0 +1,425 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	nurl "net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config is the configuration for S3-compatible storage, e.g. Amazon S3 or MinIO.
+type S3Config struct {
+	// Endpoint is the base URL of S3 API, e.g. https://s3.amazonaws.com or http://127.0.0.1:9000.
+	Endpoint string
+	Region   string
+	Bucket   string
+
+	// Prefix is prepended to name of every file, so the bucket can be shared with other apps.
+	Prefix string
+
+	AccessKey string
+	SecretKey string
+
+	// PathStyle puts the bucket in URL path instead of host name, which is required by
+	// most self-hosted S3-compatible servers.
+	PathStyle bool
+
+	// HTTPClient is the client for accessing the API. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// S3 is storage in bucket of S3-compatible server. Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	cfg      S3Config
+	endpoint *nurl.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3 returns storage that keeps the files in S3 bucket.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("bucket is not specified")
+	}
+
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3.amazonaws.com"
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	if cfg.Prefix != "" && !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+
+	endpoint, err := nurl.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("endpoint %q is not valid", cfg.Endpoint)
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &S3{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   client,
+		now:      time.Now,
+	}, nil
+}
+
+// Open opens the file for reading.
+func (s *S3) Open(name string) (io.ReadCloser, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(http.MethodGet, s.objectURL(s.cfg.Prefix+name), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, notExistError(name)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, readS3Error(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// Stat returns information of the file.
+func (s *S3) Stat(name string) (FileInfo, error) {
+	if err := validateName(name); err != nil {
+		return FileInfo{}, err
+	}
+
+	resp, err := s.do(http.MethodHead, s.objectURL(s.cfg.Prefix+name), nil)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return FileInfo{}, notExistError(name)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return FileInfo{}, fmt.Errorf("s3: unexpected status %s", resp.Status)
+	}
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return FileInfo{Name: name, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+// Write saves the content into the file. Its hash is needed to sign the request, so the
+// content is read twice if it's seekable, e.g. a file, and streamed to the server. Otherwise
+// it's read into memory first.
+func (s *S3) Write(name string, content io.Reader) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	body, ok := content.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	// Hash the content, then rewind to where it started
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, body)
+	if err != nil {
+		return err
+	}
+
+	if _, err = body.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, "", io.NopCloser(body))
+	if err != nil {
+		return err
+	}
+	req.URL = s.objectURL(s.cfg.Prefix + name)
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	s.sign(req, hex.EncodeToString(hash.Sum(nil)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return readS3Error(resp)
+	}
+
+	return nil
+}
+
+// Remove removes the file.
+func (s *S3) Remove(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodDelete, s.objectURL(s.cfg.Prefix+name), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return readS3Error(resp)
+	}
+}
+
+// Walk calls fn for each file which name starts with the prefix.
+func (s *S3) Walk(prefix string, fn func(FileInfo) error) error {
+	query := nurl.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", s.cfg.Prefix+prefix)
+
+	for {
+		listURL := s.objectURL("")
+		listURL.RawQuery = canonicalS3Query(query)
+
+		resp, err := s.do(http.MethodGet, listURL, nil)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return readS3Error(resp)
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("s3: failed to parse object list: %v", err)
+		}
+
+		for _, object := range result.Contents {
+			if strings.HasSuffix(object.Key, "/") {
+				continue
+			}
+
+			err = fn(FileInfo{
+				Name:    strings.TrimPrefix(object.Key, s.cfg.Prefix),
+				Size:    object.Size,
+				ModTime: object.LastModified,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// s3ListResult is the response of ListObjectsV2.
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// objectURL returns URL of the object with specified key in the bucket.
+func (s *S3) objectURL(key string) *nurl.URL {
+	objectURL := *s.endpoint
+	objectURL.RawQuery = ""
+
+	if s.cfg.PathStyle {
+		objectURL.Path = "/" + s.cfg.Bucket
+		objectURL.RawPath = "/" + escapeS3(s.cfg.Bucket, false)
+		if key != "" {
+			objectURL.Path += "/" + key
+			objectURL.RawPath += "/" + escapeS3(key, false)
+		}
+	} else {
+		objectURL.Host = s.cfg.Bucket + "." + objectURL.Host
+		objectURL.Path = "/" + key
+		objectURL.RawPath = "/" + escapeS3(key, false)
+	}
+
+	return &objectURL
+}
+
+// do sends the signed request to S3 server.
+func (s *S3) do(method string, url *nurl.URL, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL = url
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	payloadHash := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(payloadHash[:]))
+
+	return s.client.Do(req)
+}
+
+// sign adds AWS Signature Version 4 to the request. Host and every header
+// that already set in the request are signed.
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Create canonical headers
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+
+	canonicalHeaders := ""
+	for _, name := range headerNames {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	// Create the string to sign
+	canonicalPath := req.URL.EscapedPath()
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath,
+		canonicalS3Query(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	// Calculate the signature
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalS3Query encodes the query sorted by its keys, as required by the signature.
+func canonicalS3Query(query nurl.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, escapeS3(key, true)+"="+escapeS3(value, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// escapeS3 percent-encodes every character except the unreserved ones. Slash is
+// kept as it is, unless the string is used in query.
+func escapeS3(s string, inQuery bool) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/' && !inQuery:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+
+	return sb.String()
+}
+
+// readS3Error reads the error returned by S3 server and closes the response body.
+func readS3Error(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	var s3Err struct {
+		Code    string
+		Message string
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := xml.Unmarshal(body, &s3Err); err != nil || s3Err.Code == "" {
+		return fmt.Errorf("s3: unexpected status %s", resp.Status)
+	}
+
+	return fmt.Errorf("s3: %s: %s", s3Err.Code, s3Err.Message)
+}
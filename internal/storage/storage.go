@@ -0,0 +1,95 @@
+// Package storage provides the backends where files of the bookmarks are kept,
+// i.e. offline archives, thumbnails, favicons and the shared archive resources.
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Storage is where files of the bookmarks are kept. Files are identified by
+// slash-separated name relative to the storage root, e.g. "archive/12" or "thumb/12-list".
+type Storage interface {
+	// Open opens the file for reading. If the file doesn't exist,
+	// the returned error matches os.ErrNotExist.
+	Open(name string) (io.ReadCloser, error)
+
+	// Stat returns information of the file. If the file doesn't exist,
+	// the returned error matches os.ErrNotExist.
+	Stat(name string) (FileInfo, error)
+
+	// Write saves the content into the file, replacing the existing one.
+	// Reader never sees the partially written file.
+	Write(name string, content io.Reader) error
+
+	// Remove removes the file. It's not an error if the file doesn't exist.
+	Remove(name string) error
+
+	// Walk calls fn for each file which name starts with the prefix. If fn
+	// returns error, the walk is stopped and the error is returned.
+	Walk(prefix string, fn func(FileInfo) error) error
+}
+
+// FileInfo is the information of file in storage.
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// ReadFile reads the whole content of the file.
+func ReadFile(s Storage, name string) ([]byte, error) {
+	f, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// Exists returns whether the file exists in the storage.
+func Exists(s Storage, name string) bool {
+	_, err := s.Stat(name)
+	return err == nil
+}
+
+// Copy copies the file from src to dst storage, which may be the same.
+func Copy(dst Storage, dstName string, src Storage, srcName string) error {
+	f, err := src.Open(srcName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return dst.Write(dstName, f)
+}
+
+// Move moves the file within the storage.
+func Move(s Storage, srcName, dstName string) error {
+	if err := Copy(s, dstName, s, srcName); err != nil {
+		return err
+	}
+
+	return s.Remove(srcName)
+}
+
+// notExistError is returned when the file doesn't exist in storage.
+func notExistError(name string) error {
+	return &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
+
+// validateName makes sure the name is clean relative path, so it can't be used to
+// access files outside of the storage.
+func validateName(name string) error {
+	if name == "" || path.IsAbs(name) || path.Clean(name) != name ||
+		name == ".." || strings.HasPrefix(name, "../") || strings.Contains(name, "\\") {
+		return fmt.Errorf("invalid file name %q", name)
+	}
+
+	return nil
+}
@@ -9,6 +9,8 @@ var template = `
 		<p class="title">{{title}}
 			<i v-if="hasContent" class="fas fa-file-alt"></i>
 			<i v-if="hasArchive" class="fas fa-archive"></i>
+			<i v-if="lost" class="fas fa-exclamation-triangle lost" title="Original is broken and there is no archive"></i>
+			<i v-else-if="broken" class="fas fa-unlink" title="Original is broken, archive is used instead"></i>
 			<i v-if="public" class="fas fa-eye"></i>
 		</p>
 		<p class="excerpt" v-if="excerptVisible">{{excerpt}}</p>
@@ -19,7 +21,8 @@ var template = `
 	</div>
 	<div class="spacer"></div>
 	<div class="bookmark-menu">
-		<a class="url" :href="url" target="_blank" rel="noopener">
+		<a class="url" :class="{broken: broken}" :href="linkURL || url" target="_blank" rel="noopener">
+			<img class="favicon" v-if="faviconURL" :src="faviconURL" alt="">
 			{{hostnameURL}}
 		</a>
 		<template v-if="!editMode && menuVisible">
@@ -45,8 +48,12 @@ export default {
 		excerpt: String,
 		public: Number,
 		imageURL: String,
+		faviconURL: String,
 		hasContent: Boolean,
 		hasArchive: Boolean,
+		broken: Boolean,
+		lost: Boolean,
+		linkURL: String,
 		index: Number,
 		showId: Boolean,
 		editMode: Boolean,
@@ -86,8 +93,9 @@ export default {
 				!this.hideExcerpt;
 		},
 		thumbnailStyleURL() {
+			var size = this.listMode ? "list" : "grid";
 			return {
-				backgroundImage: `url("${this.imageURL}")`
+				backgroundImage: `url("${this.imageURL}?size=${size}")`
 			}
 		},
 		eventItem() {
@@ -0,0 +1,264 @@
+// Number of characters kept before and after the quote, which are used
+// to find the highlight again when the content has changed.
+const contextLength = 32;
+
+export default {
+	data() {
+		return {
+			bookmarkId: 0,
+			highlights: [],
+			canHighlight: false,
+			selection: null,
+		}
+	},
+	methods: {
+		contentElement() {
+			return document.getElementById("content");
+		},
+		highlightsURL(id) {
+			var url = `api/v1/bookmarks/${this.bookmarkId}/highlights`;
+			if (id) url += `/${id}`;
+			return new URL(url, document.baseURI);
+		},
+		async getAPIErrorMessage(err) {
+			if (err instanceof Response) {
+				try {
+					var json = await err.clone().json();
+					if (json.error && json.error.message) {
+						return `${json.error.message} (${err.status})`;
+					}
+				} catch (e) { }
+			}
+
+			return this.getErrorMessage(err);
+		},
+		showAPIError(err) {
+			this.dialog.loading = false;
+			this.getAPIErrorMessage(err).then(msg => {
+				this.showErrorDialog(msg);
+			});
+		},
+		loadHighlights() {
+			fetch(this.highlightsURL()).then(response => {
+				if (!response.ok) throw response;
+				return response.json();
+			}).then(json => {
+				this.canHighlight = true;
+				this.highlights = json;
+				this.highlights.forEach(highlight => this.markHighlight(highlight));
+			}).catch(() => {
+				// Visitors of public bookmark can't see the highlights
+				this.canHighlight = false;
+			});
+		},
+		// textOffset returns offset of the position in the text of content.
+		textOffset(container, offset) {
+			var range = document.createRange();
+			range.setStart(this.contentElement(), 0);
+			range.setEnd(container, offset);
+			return range.toString().length;
+		},
+		// anchorHighlight finds the position of highlight in the text of content. If the
+		// content has changed, the quote with matching context closest to its old
+		// position is used. Returns null if the quote is no longer there.
+		anchorHighlight(highlight, text) {
+			if (text.slice(highlight.start, highlight.end) === highlight.quote) {
+				return { start: highlight.start, end: highlight.end };
+			}
+
+			var best = -1,
+				bestScore = -Infinity,
+				index = text.indexOf(highlight.quote);
+
+			while (index >= 0) {
+				var end = index + highlight.quote.length,
+					score = -Math.abs(index - highlight.start) / Math.max(text.length, 1);
+
+				if (highlight.prefix && text.slice(0, index).endsWith(highlight.prefix)) score += 1;
+				if (highlight.suffix && text.slice(end).startsWith(highlight.suffix)) score += 1;
+				if (score > bestScore) {
+					best = index;
+					bestScore = score;
+				}
+
+				index = text.indexOf(highlight.quote, index + 1);
+			}
+
+			if (best < 0) return null;
+			return { start: best, end: best + highlight.quote.length };
+		},
+		// markHighlight wraps the text of highlight in mark elements, one for each text node.
+		markHighlight(highlight) {
+			var content = this.contentElement(),
+				position = this.anchorHighlight(highlight, content.textContent);
+			if (position == null) return;
+
+			var nodes = [],
+				offset = 0,
+				walker = document.createTreeWalker(content, NodeFilter.SHOW_TEXT);
+
+			while (walker.nextNode()) {
+				var node = walker.currentNode,
+					nodeStart = offset,
+					nodeEnd = offset + node.length;
+
+				offset = nodeEnd;
+				if (nodeEnd <= position.start || nodeStart >= position.end) continue;
+				nodes.push({
+					node: node,
+					start: Math.max(position.start - nodeStart, 0),
+					end: Math.min(position.end - nodeStart, node.length),
+				});
+			}
+
+			nodes.forEach(item => {
+				var node = item.node;
+				if (item.end < node.length) node.splitText(item.end);
+				if (item.start > 0) node = node.splitText(item.start);
+				if (node.textContent.trim() === "") return;
+
+				var mark = document.createElement("mark");
+				mark.className = "highlight";
+				mark.dataset.id = highlight.id;
+				node.parentNode.replaceChild(mark, node);
+				mark.appendChild(node);
+			});
+
+			this.updateMarks(highlight);
+		},
+		updateMarks(highlight) {
+			this.contentElement().querySelectorAll(`mark[data-id="${highlight.id}"]`).forEach(mark => {
+				mark.title = highlight.note;
+				mark.classList.toggle("has-note", highlight.note !== "");
+			});
+		},
+		unmarkHighlight(highlight) {
+			this.contentElement().querySelectorAll(`mark[data-id="${highlight.id}"]`).forEach(mark => {
+				var parent = mark.parentNode;
+				while (mark.firstChild) parent.insertBefore(mark.firstChild, mark);
+				parent.removeChild(mark);
+				parent.normalize();
+			});
+		},
+		updateSelection() {
+			this.selection = null;
+
+			var sel = window.getSelection();
+			if (!this.canHighlight || sel.rangeCount === 0 || sel.isCollapsed) return;
+
+			var range = sel.getRangeAt(0),
+				content = this.contentElement();
+			if (!content.contains(range.commonAncestorContainer)) return;
+
+			var text = content.textContent,
+				start = this.textOffset(range.startContainer, range.startOffset),
+				end = this.textOffset(range.endContainer, range.endOffset),
+				quote = text.slice(start, end);
+			if (quote.trim() === "") return;
+
+			var rect = range.getBoundingClientRect();
+			this.selection = {
+				quote: quote,
+				prefix: text.slice(Math.max(start - contextLength, 0), start),
+				suffix: text.slice(end, end + contextLength),
+				start: start,
+				end: end,
+				top: rect.bottom + window.scrollY + 8,
+				left: rect.left + window.scrollX,
+			};
+		},
+		showDialogCreateHighlight() {
+			var selection = this.selection;
+			this.selection = null;
+			window.getSelection().removeAllRanges();
+
+			this.showDialog({
+				title: "New Highlight",
+				content: selection.quote,
+				fields: [{
+					name: "note",
+					label: "Note (optional)",
+					type: "area",
+				}],
+				mainText: "Save",
+				secondText: "Cancel",
+				mainClick: (data) => {
+					var request = {
+						quote: selection.quote,
+						prefix: selection.prefix,
+						suffix: selection.suffix,
+						start: selection.start,
+						end: selection.end,
+						note: data.note.trim(),
+					};
+
+					this.dialog.loading = true;
+					fetch(this.highlightsURL(), {
+						method: "post",
+						body: JSON.stringify(request),
+						headers: { "Content-Type": "application/json" }
+					}).then(response => {
+						if (!response.ok) throw response;
+						return response.json();
+					}).then(json => {
+						this.dialog.loading = false;
+						this.dialog.visible = false;
+						this.highlights.push(json);
+						this.markHighlight(json);
+					}).catch(err => this.showAPIError(err));
+				}
+			});
+		},
+		showDialogEditHighlight(id) {
+			var highlight = this.highlights.find(item => item.id === id);
+			if (highlight == null) return;
+
+			this.showDialog({
+				title: "Highlight",
+				content: highlight.quote,
+				fields: [{
+					name: "note",
+					label: "Note (optional)",
+					type: "area",
+					value: highlight.note,
+				}],
+				mainText: "Save",
+				secondText: "Delete",
+				mainClick: (data) => {
+					this.dialog.loading = true;
+					fetch(this.highlightsURL(id), {
+						method: "put",
+						body: JSON.stringify({ note: data.note.trim() }),
+						headers: { "Content-Type": "application/json" }
+					}).then(response => {
+						if (!response.ok) throw response;
+						return response.json();
+					}).then(json => {
+						this.dialog.loading = false;
+						this.dialog.visible = false;
+						Object.assign(highlight, json);
+						this.updateMarks(highlight);
+					}).catch(err => this.showAPIError(err));
+				},
+				secondClick: () => {
+					this.dialog.loading = true;
+					fetch(this.highlightsURL(id), {
+						method: "delete"
+					}).then(response => {
+						if (!response.ok) throw response;
+
+						this.dialog.loading = false;
+						this.dialog.visible = false;
+						this.highlights = this.highlights.filter(item => item.id !== id);
+						this.unmarkHighlight(highlight);
+					}).catch(err => this.showAPIError(err));
+				}
+			});
+		},
+		handleContentClick(event) {
+			var mark = event.target.closest("mark.highlight");
+			if (mark == null || !window.getSelection().isCollapsed) return;
+			this.showDialogEditHighlight(parseInt(mark.dataset.id, 10));
+		},
+	},
+}
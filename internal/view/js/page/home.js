@@ -45,8 +45,12 @@ var template = `
             :excerpt="book.excerpt"
             :public="book.public"
             :imageURL="book.imageURL"
+            :faviconURL="book.faviconURL"
             :hasContent="book.hasContent"
             :hasArchive="book.hasArchive"
+            :broken="book.broken"
+            :lost="book.lost"
+            :linkURL="book.linkURL"
             :tags="book.tags"
             :index="index"
             :key="book.id" 
@@ -370,6 +374,10 @@ export default {
 					label: "Create archive",
 					type: "check",
 					value: this.appOptions.useArchive,
+				}, {
+					name: "renderJS",
+					label: "Render JavaScript in headless browser",
+					type: "check",
 				}, {
 					name: "makePublic",
 					label: "Make archive publicly available",
@@ -405,6 +413,7 @@ export default {
 						public: data.makePublic ? 1 : 0,
 						tags: tags,
 						createArchive: data.createArchive,
+						renderJS: data.renderJS,
 					};
 
 					this.dialog.loading = true;
@@ -611,6 +620,10 @@ export default {
 					label: "Update archive as well",
 					type: "check",
 					value: this.appOptions.useArchive,
+				}, {
+					name: "renderJS",
+					label: "Render JavaScript in headless browser",
+					type: "check",
 				}],
 				mainText: "Yes",
 				secondText: "No",
@@ -618,6 +631,7 @@ export default {
 					var data = {
 						ids: ids,
 						createArchive: data.createArchive,
+						renderJS: data.renderJS,
 						keepMetadata: data.keepMetadata,
 					};
 
@@ -0,0 +1,210 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	nurl "net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/shiori/internal/warc/internal/archiver"
+)
+
+// Capture is a resource that captured in WARC file.
+type Capture struct {
+	URL         string
+	ContentType string
+	Content     []byte
+}
+
+// ReadCaptures reads the captured resources from WARC file. Records that don't have
+// payload, e.g. request or failed response, are skipped. If the same URL captured
+// several times, only the first one is used. Records that larger than maxRecordSize
+// are skipped, see NewReader.
+func ReadCaptures(r io.Reader, maxRecordSize int64) ([]Capture, error) {
+	reader, err := NewReader(r, maxRecordSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WARC file: %v", err)
+	}
+
+	var captures []Capture
+	mapCaptured := map[string]struct{}{}
+
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		targetURI := rec.TargetURI()
+		if targetURI == "" {
+			continue
+		}
+
+		key := captureKey(targetURI)
+		if _, captured := mapCaptured[key]; captured {
+			continue
+		}
+
+		contentType, content, err := rec.Payload()
+		if err != nil {
+			continue
+		}
+
+		mapCaptured[key] = struct{}{}
+		captures = append(captures, Capture{
+			URL:         targetURI,
+			ContentType: contentType,
+			Content:     content,
+		})
+	}
+
+	return captures, nil
+}
+
+// NewArchiveFromCaptures creates new archive for the root page, which sub resources
+// are taken from the captures instead of downloaded from internet.
+func NewArchiveFromCaptures(root Capture, captures []Capture, dstPath string, logEnabled bool) error {
+	req := ArchivalRequest{
+		URL:         root.URL,
+		Reader:      bytes.NewReader(root.Content),
+		ContentType: root.ContentType,
+		LogEnabled:  logEnabled,
+		Client:      newCaptureClient(captures, nil),
+	}
+
+	return NewArchive(req, dstPath)
+}
+
+// WriteWARC writes all resources in the archive as response records. The original URL
+// of sub resources is not kept in archive, so their URL is resolved from their name
+// against the page URL. This way, they still match the links in the archived page.
+func (arc *Archive) WriteWARC(w *Writer, pageURL string, date time.Time) error {
+	baseURL, err := nurl.Parse(pageURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse url: %v", err)
+	}
+
+	names, err := arc.Resources()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	// Root page is written first, so reader knows which page the file is about
+	names = append([]string{"archive-root"}, names...)
+	for _, name := range names {
+		content, contentType, err := arc.readContent(name)
+		if err != nil {
+			return err
+		}
+
+		targetURI := pageURL
+		if name != "archive-root" {
+			targetURI = resourceURL(baseURL, name)
+		}
+
+		if err = w.WriteResponse(targetURI, date, contentType, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// captureClient is HTTP client that serves the captured resources. Resources
+// that not captured are downloaded using the fallback client, if any.
+type captureClient struct {
+	captures map[string]Capture
+	fallback archiver.HTTPClient
+}
+
+func newCaptureClient(captures []Capture, fallback archiver.HTTPClient) *captureClient {
+	client := &captureClient{
+		captures: make(map[string]Capture, len(captures)),
+		fallback: fallback,
+	}
+
+	for _, capture := range captures {
+		key := captureKey(capture.URL)
+		if _, exist := client.captures[key]; !exist {
+			client.captures[key] = capture
+		}
+	}
+
+	return client
+}
+
+func (client *captureClient) Do(req *http.Request) (*http.Response, error) {
+	capture, exist := client.captures[captureKey(req.URL.String())]
+	if !exist {
+		if client.fallback != nil {
+			return client.fallback.Do(req)
+		}
+		return nil, fmt.Errorf("%s is not captured", req.URL)
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {capture.ContentType}},
+		Body:          io.NopCloser(bytes.NewReader(capture.Content)),
+		ContentLength: int64(len(capture.Content)),
+		Request:       req,
+	}, nil
+}
+
+// captureKey normalizes the URL the same way as the archival process,
+// so the captured URL matches the URL requested by archiver.
+func captureKey(url string) string {
+	parsedURL, err := nurl.Parse(strings.TrimSpace(url))
+	if err != nil {
+		return url
+	}
+
+	queries := parsedURL.Query()
+	for key := range queries {
+		if strings.HasPrefix(key, "utm_") {
+			queries.Del(key)
+		}
+	}
+
+	parsedURL.Fragment = ""
+	parsedURL.RawQuery = queries.Encode()
+	return strings.TrimRight(parsedURL.String(), "/")
+}
+
+// resourceURL returns URL of the archived resource, resolved from its name against the page URL.
+func resourceURL(pageURL *nurl.URL, name string) string {
+	return pageURL.ResolveReference(&nurl.URL{Path: name}).String()
+}
+
+// readContent reads the resource and decompresses its content.
+func (arc *Archive) readContent(name string) ([]byte, string, error) {
+	gzipped, contentType, err := arc.Read(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(gzipped))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	defer gzipReader.Close()
+
+	content, err := io.ReadAll(gzipReader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %v", name, err)
+	}
+
+	return content, contentType, nil
+}
@@ -0,0 +1,129 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"go.etcd.io/bbolt"
+)
+
+// DedupeResult is the result of moving resources of an archive into the shared store.
+type DedupeResult struct {
+	Shared     int
+	SharedSize int64
+}
+
+// Dedupe moves the resources of the archive which compressed size is at least minSize into
+// the store, leaving only their hash in the archive. Identical resources in other archives
+// are stored once. The archive is rewritten into a new file, since bbolt never shrinks.
+// The root page is never shared.
+func Dedupe(path string, store ResourceStore, minSize int) (DedupeResult, error) {
+	var result DedupeResult
+
+	src, err := bbolt.Open(path, os.ModePerm, &bbolt.Options{ReadOnly: true})
+	if err != nil {
+		return result, err
+	}
+	defer src.Close()
+
+	// Check if there is anything to share first, so archive is not rewritten needlessly
+	err = src.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bbolt.Bucket) error {
+			if isShareable(name, bucket, minSize) {
+				result.Shared++
+			}
+			return nil
+		})
+	})
+	if err != nil || result.Shared == 0 {
+		return DedupeResult{}, err
+	}
+
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+
+	dst, err := bbolt.Open(tmpPath, os.ModePerm, nil)
+	if err != nil {
+		return DedupeResult{}, err
+	}
+
+	result = DedupeResult{}
+	err = src.View(func(srcTx *bbolt.Tx) error {
+		return dst.Update(func(dstTx *bbolt.Tx) error {
+			return srcTx.ForEach(func(name []byte, srcBucket *bbolt.Bucket) error {
+				dstBucket, err := dstTx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+
+				if !isShareable(name, srcBucket, minSize) {
+					return srcBucket.ForEach(dstBucket.Put)
+				}
+
+				content := srcBucket.Get([]byte("content"))
+				hash, err := contentHash(content)
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %v", name, err)
+				}
+
+				if err = store.Put(hash, content); err != nil {
+					return fmt.Errorf("failed to share %s: %v", name, err)
+				}
+
+				result.Shared++
+				result.SharedSize += int64(len(content))
+
+				if err = dstBucket.Put([]byte("type"), srcBucket.Get([]byte("type"))); err != nil {
+					return err
+				}
+				return dstBucket.Put([]byte("ref"), []byte(hash))
+			})
+		})
+	})
+
+	dst.Close()
+	src.Close()
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return DedupeResult{}, err
+	}
+
+	if err = os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return DedupeResult{}, err
+	}
+
+	return result, nil
+}
+
+func isShareable(name []byte, bucket *bbolt.Bucket, minSize int) bool {
+	if string(name) == "archive-root" {
+		return false
+	}
+
+	content := bucket.Get([]byte("content"))
+	return content != nil && len(content) >= minSize
+}
+
+// contentHash returns SHA-256 of the uncompressed content. The compressed content
+// is not hashed directly, since its bytes may differ between gzip versions.
+func contentHash(gzipped []byte) (string, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(gzipped))
+	if err != nil {
+		return "", err
+	}
+	defer gzipReader.Close()
+
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, gzipReader); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,78 @@
+package warc
+
+import (
+	"bytes"
+	"fmt"
+	fp "path/filepath"
+	"strings"
+	"testing"
+)
+
+type memoryStore map[string][]byte
+
+func (s memoryStore) Get(hash string) ([]byte, error) {
+	content, exist := s[hash]
+	if !exist {
+		return nil, fmt.Errorf("%s doesn't exist", hash)
+	}
+	return content, nil
+}
+
+func (s memoryStore) Put(hash string, content []byte) error {
+	s[hash] = content
+	return nil
+}
+
+func TestDedupe(t *testing.T) {
+	image := []byte(strings.Repeat("shared image ", 100))
+	store := memoryStore{}
+	dir := t.TempDir()
+
+	for i := 1; i <= 2; i++ {
+		captures := []Capture{
+			{URL: fmt.Sprintf("https://example.com/page-%d", i), ContentType: "text/html",
+				Content: []byte(`<html><body><img src="/pic.png"></body></html>`)},
+			{URL: "https://example.com/pic.png", ContentType: "image/png", Content: image},
+		}
+
+		archivePath := fp.Join(dir, fmt.Sprint(i))
+		if err := NewArchiveFromCaptures(captures[0], captures, archivePath, false); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := Dedupe(archivePath, store, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Shared != 1 {
+			t.Errorf("archive %d: shared %d resources, want 1", i, result.Shared)
+		}
+
+		archive, err := OpenWithStore(archivePath, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		shared, err := archive.SharedResources()
+		if err != nil || len(shared) != 1 {
+			t.Errorf("archive %d: got shared resources %v (%v), want 1", i, shared, err)
+		}
+
+		names, _ := archive.Resources()
+		for _, name := range names {
+			content, _, err := archive.readContent(name)
+			if err != nil {
+				t.Errorf("archive %d: failed to read %s: %v", i, name, err)
+			} else if strings.HasSuffix(name, "pic.png") && !bytes.Equal(content, image) {
+				t.Errorf("archive %d: shared resource %s has wrong content", i, name)
+			}
+		}
+
+		archive.Close()
+	}
+
+	if len(store) != 1 {
+		t.Errorf("store has %d resources, want 1", len(store))
+	}
+}
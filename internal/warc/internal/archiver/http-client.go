@@ -0,0 +1,23 @@
+package archiver
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"time"
+)
+
+var httpClient *http.Client
+
+func init() {
+	jar, _ := cookiejar.New(nil)
+	httpClient = &http.Client{
+		Timeout:   time.Minute,
+		Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		Jar:       jar,
+	}
+}
+
+// DefaultClient returns the client that used when Client of the archiver is not specified.
+func DefaultClient() HTTPClient {
+	return httpClient
+}
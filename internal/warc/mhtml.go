@@ -0,0 +1,119 @@
+package warc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	nurl "net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WriteMHTML writes the archived page and its resources as MHTML file (RFC 2557),
+// which can be opened by most browsers. Like WriteWARC, URL of the resources are
+// resolved from their name against the page URL.
+func (arc *Archive) WriteMHTML(w io.Writer, pageURL, title string, date time.Time) error {
+	baseURL, err := nurl.Parse(pageURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse url: %v", err)
+	}
+
+	names, err := arc.Resources()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	// Write the message header
+	mw := multipart.NewWriter(w)
+	header := "From: <Saved by Shiori>\r\n" +
+		"Snapshot-Content-Location: " + pageURL + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", title) + "\r\n" +
+		"Date: " + date.UTC().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/related; type=\"text/html\"; boundary=\"" + mw.Boundary() + "\"\r\n\r\n"
+
+	if _, err = io.WriteString(w, header); err != nil {
+		return err
+	}
+
+	// Root page must be the first part
+	names = append([]string{"archive-root"}, names...)
+	for _, name := range names {
+		content, contentType, err := arc.readContent(name)
+		if err != nil {
+			return err
+		}
+
+		location := pageURL
+		if name != "archive-root" {
+			location = resourceURL(baseURL, name)
+		}
+
+		if err = writeMHTMLPart(mw, location, contentType, content); err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+// writeMHTMLPart writes the resource as a part of MHTML file. Text is encoded as
+// quoted-printable so it's still readable, while the others as base64.
+func writeMHTMLPart(mw *multipart.Writer, location, contentType string, content []byte) error {
+	encoding := "base64"
+	if isTextType(contentType) {
+		encoding = "quoted-printable"
+	}
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {encoding},
+		"Content-Location":          {location},
+	})
+	if err != nil {
+		return err
+	}
+
+	if encoding == "quoted-printable" {
+		qpWriter := quotedprintable.NewWriter(part)
+		if _, err = qpWriter.Write(content); err != nil {
+			return err
+		}
+		return qpWriter.Close()
+	}
+
+	// Base64 content is wrapped at 76 characters per line
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		if _, err = io.WriteString(part, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+
+	_, err = io.WriteString(part, encoded+"\r\n")
+	return err
+}
+
+func isTextType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/javascript",
+		mediaType == "application/json",
+		mediaType == "image/svg+xml":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,192 @@
+package warc
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-shiori/shiori/internal/warc/internal/processor"
+	"go.etcd.io/bbolt"
+)
+
+// Archive is the storage for archiving the web page.
+type Archive struct {
+	db    *bbolt.DB
+	store ResourceStore
+
+	// removeOnClose is set for temporary archive, which file is removed once it's closed.
+	removeOnClose bool
+}
+
+// ResourceStore is the storage for resources that shared between archives,
+// addressed by the hash of their content. The content is stored gzipped,
+// as it is in the archive.
+type ResourceStore interface {
+	Get(hash string) ([]byte, error)
+	Put(hash string, content []byte) error
+}
+
+// Open opens the archive from specified path.
+func Open(path string) (*Archive, error) {
+	return OpenWithStore(path, nil)
+}
+
+// OpenWithStore opens the archive from specified path, which shared
+// resources are read from the store.
+func OpenWithStore(path string, store ResourceStore) (*Archive, error) {
+	// Make sure archive exists
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || info.IsDir() {
+		return nil, fmt.Errorf("archive doesn't exist")
+	}
+
+	// Open database
+	options := &bbolt.Options{
+		ReadOnly: true,
+	}
+
+	db, err := bbolt.Open(path, os.ModePerm, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archive{db: db, store: store}, nil
+}
+
+// OpenTemp opens the temporary archive from specified path, e.g. the one downloaded from
+// remote storage. The file is removed once the archive is closed.
+func OpenTemp(path string, store ResourceStore) (*Archive, error) {
+	arc, err := OpenWithStore(path, store)
+	if err != nil {
+		return nil, err
+	}
+
+	arc.removeOnClose = true
+	return arc, nil
+}
+
+// Close closes the storage.
+func (arc *Archive) Close() {
+	arc.db.Close()
+	if arc.removeOnClose {
+		os.Remove(arc.db.Path())
+	}
+}
+
+// Read fetch the resource with specified name from archive.
+func (arc *Archive) Read(name string) ([]byte, string, error) {
+	// Make sure name exists
+	if name == "" {
+		name = "archive-root"
+	}
+
+	var content []byte
+	var strContentType string
+	var ref string
+
+	err := arc.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil {
+			return fmt.Errorf("%s doesn't exist", name)
+		}
+
+		contentType := bucket.Get([]byte("type"))
+		if contentType == nil {
+			return fmt.Errorf("%s doesn't exist", name)
+		}
+		strContentType = string(contentType)
+
+		// Shared resource only has reference to the store
+		content = bucket.Get([]byte("content"))
+		if content == nil {
+			ref = string(bucket.Get([]byte("ref")))
+		}
+
+		if content == nil && ref == "" {
+			return fmt.Errorf("%s doesn't exist", name)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if ref != "" {
+		if arc.store == nil {
+			return nil, "", fmt.Errorf("%s is shared, but store is not available", name)
+		}
+
+		content, err = arc.store.Get(ref)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read shared %s: %v", name, err)
+		}
+	}
+
+	return content, strContentType, nil
+}
+
+// HasResource checks if the resource exists in archive.
+func (arc *Archive) HasResource(name string) bool {
+	// Make sure name exists
+	if name == "" {
+		name = "archive-root"
+	}
+
+	var exists bool
+	arc.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		exists = bucket != nil
+		return nil
+	})
+
+	return exists
+}
+
+// Resources returns names of all resources in the archive, except the root.
+func (arc *Archive) Resources() ([]string, error) {
+	var names []string
+	err := arc.db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+			if string(name) != "archive-root" {
+				names = append(names, string(name))
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// ResourceName returns the name of the resource with specified URL in archive,
+// or empty string if the URL can't be archived.
+func ResourceName(url string) string {
+	name, err := processor.ResourceName(url)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// SharedResources returns the hash of resources that stored in the shared store.
+func (arc *Archive) SharedResources() ([]string, error) {
+	var refs []string
+	err := arc.db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(_ []byte, bucket *bbolt.Bucket) error {
+			if ref := bucket.Get([]byte("ref")); ref != nil {
+				refs = append(refs, string(ref))
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
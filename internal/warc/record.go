@@ -0,0 +1,322 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Version is the version of WARC file that written by Writer.
+const Version = "WARC/1.1"
+
+// Record is a record in WARC file, as defined by ISO 28500.
+type Record struct {
+	Header http.Header
+	Block  []byte
+
+	// maxSize is the maximum size of decompressed payload. If it's not
+	// positive, DefaultMaxRecordSize is used.
+	maxSize int64
+}
+
+// Type returns the type of the record, e.g. "response" or "resource".
+func (rec Record) Type() string {
+	return rec.Header.Get("WARC-Type")
+}
+
+// TargetURI returns the URI of the captured resource.
+func (rec Record) TargetURI() string {
+	return strings.Trim(rec.Header.Get("WARC-Target-URI"), "<>")
+}
+
+// Payload returns the content type and content of the captured resource.
+// Only successful response record and resource record have payload.
+func (rec Record) Payload() (string, []byte, error) {
+	switch rec.Type() {
+	case "resource":
+		return rec.Header.Get("Content-Type"), rec.Block, nil
+	case "response":
+	default:
+		return "", nil, fmt.Errorf("%s record doesn't have payload", rec.Type())
+	}
+
+	if !strings.HasPrefix(rec.Header.Get("Content-Type"), "application/http") {
+		return "", nil, fmt.Errorf("response is not HTTP")
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rec.Block)), nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", nil, fmt.Errorf("response status is %s", resp.Status)
+	}
+
+	// Body is truncated if the record is truncated, so keep what we have
+	content, err := io.ReadAll(resp.Body)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip", "x-gzip":
+		if gzipReader, err := gzip.NewReader(bytes.NewReader(content)); err == nil {
+			if content, err = rec.decompress(gzipReader); err != nil {
+				return "", nil, err
+			}
+		}
+	case "deflate":
+		if content, err = rec.decompress(flate.NewReader(bytes.NewReader(content))); err != nil {
+			return "", nil, err
+		}
+	case "", "identity":
+	default:
+		return "", nil, fmt.Errorf("content encoding %s is not supported", resp.Header.Get("Content-Encoding"))
+	}
+
+	return resp.Header.Get("Content-Type"), content, nil
+}
+
+// decompress reads the decompressed payload, which is limited to the maximum
+// size since a small compressed record may expand to a huge one.
+func (rec Record) decompress(r io.Reader) ([]byte, error) {
+	maxSize := rec.maxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxRecordSize
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress response: %v", err)
+	}
+
+	if int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("decompressed response exceeds the maximum of %d", maxSize)
+	}
+
+	return content, nil
+}
+
+// Writer writes records into WARC file.
+type Writer struct {
+	w        io.Writer
+	compress bool
+}
+
+// NewWriter creates writer for WARC file. If compress is true, each record is
+// compressed as separate gzip member, as recommended for `.warc.gz` file.
+func NewWriter(w io.Writer, compress bool) *Writer {
+	return &Writer{w: w, compress: compress}
+}
+
+// WriteInfo writes warcinfo record which describes the file.
+func (w *Writer) WriteInfo(filename string, date time.Time, fields map[string]string) error {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var block bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&block, "%s: %s\r\n", key, fields[key])
+	}
+
+	header := http.Header{}
+	header.Set("WARC-Type", "warcinfo")
+	header.Set("WARC-Date", date.UTC().Format(time.RFC3339))
+	header.Set("WARC-Filename", filename)
+	header.Set("Content-Type", "application/warc-fields")
+
+	return w.WriteRecord(Record{Header: header, Block: block.Bytes()})
+}
+
+// WriteResponse writes response record for the resource, with minimal HTTP response as its block.
+func (w *Writer) WriteResponse(targetURI string, date time.Time, contentType string, content []byte) error {
+	var block bytes.Buffer
+	fmt.Fprintf(&block, "HTTP/1.1 200 OK\r\n")
+	fmt.Fprintf(&block, "Content-Type: %s\r\n", contentType)
+	fmt.Fprintf(&block, "Content-Length: %d\r\n\r\n", len(content))
+	block.Write(content)
+
+	header := http.Header{}
+	header.Set("WARC-Type", "response")
+	header.Set("WARC-Target-URI", targetURI)
+	header.Set("WARC-Date", date.UTC().Format(time.RFC3339))
+	header.Set("WARC-Payload-Digest", digest(content))
+	header.Set("Content-Type", "application/http;msgtype=response")
+
+	return w.WriteRecord(Record{Header: header, Block: block.Bytes()})
+}
+
+// WriteRecord writes the record. Record ID, block digest and content length
+// are added to its header if they are not specified.
+func (w *Writer) WriteRecord(rec Record) error {
+	if rec.Header.Get("WARC-Record-ID") == "" {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return err
+		}
+		rec.Header.Set("WARC-Record-ID", "<urn:uuid:"+id.String()+">")
+	}
+
+	if rec.Header.Get("WARC-Block-Digest") == "" {
+		rec.Header.Set("WARC-Block-Digest", digest(rec.Block))
+	}
+	rec.Header.Set("Content-Length", strconv.Itoa(len(rec.Block)))
+
+	// WARC-Type is written first, as it's conventionally the first field
+	keys := make([]string, 0, len(rec.Header))
+	for key := range rec.Header {
+		if key != "Warc-Type" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var buffer bytes.Buffer
+	buffer.WriteString(Version + "\r\n")
+	buffer.WriteString("WARC-Type: " + rec.Type() + "\r\n")
+	for _, key := range keys {
+		for _, value := range rec.Header[key] {
+			buffer.WriteString(headerName(key) + ": " + value + "\r\n")
+		}
+	}
+	buffer.WriteString("\r\n")
+	buffer.Write(rec.Block)
+	buffer.WriteString("\r\n\r\n")
+
+	if !w.compress {
+		_, err := w.w.Write(buffer.Bytes())
+		return err
+	}
+
+	gzipWriter := gzip.NewWriter(w.w)
+	if _, err := gzipWriter.Write(buffer.Bytes()); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// DefaultMaxRecordSize is the maximum size of record's block that read by Reader,
+// which is the same as the maximum size of page downloaded by the fetcher.
+const DefaultMaxRecordSize = 32 << 20
+
+// Reader reads records from WARC file, which may be compressed with gzip.
+type Reader struct {
+	r             *bufio.Reader
+	maxRecordSize int64
+}
+
+// NewReader creates reader for WARC file. Records that larger than maxRecordSize
+// are skipped, since their content must be kept in memory. The same limit is
+// used for decompressed payload. If maxRecordSize is not positive,
+// DefaultMaxRecordSize is used.
+func NewReader(r io.Reader, maxRecordSize int64) (*Reader, error) {
+	if maxRecordSize <= 0 {
+		maxRecordSize = DefaultMaxRecordSize
+	}
+
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(gzipReader)
+	}
+
+	return &Reader{r: br, maxRecordSize: maxRecordSize}, nil
+}
+
+// Next reads the next record, skipping the records that larger than the maximum
+// size. Returns io.EOF when there are no more records.
+func (r *Reader) Next() (Record, error) {
+	for {
+		rec, skipped, err := r.next()
+		if err != nil || !skipped {
+			return rec, err
+		}
+	}
+}
+
+// next reads the next record. If the record is larger than the maximum size,
+// its block is discarded and skipped is true.
+func (r *Reader) next() (rec Record, skipped bool, err error) {
+	// Skip the empty lines between records
+	var versionLine string
+	for versionLine == "" {
+		line, err := r.r.ReadString('\n')
+		if err == io.EOF && strings.TrimSpace(line) == "" {
+			return Record{}, false, io.EOF
+		} else if err != nil && err != io.EOF {
+			return Record{}, false, err
+		}
+		versionLine = strings.TrimSpace(line)
+	}
+
+	if !strings.HasPrefix(versionLine, "WARC/") {
+		return Record{}, false, fmt.Errorf("invalid WARC record: %q", versionLine)
+	}
+
+	mimeHeader, err := textproto.NewReader(r.r).ReadMIMEHeader()
+	if err != nil {
+		return Record{}, false, fmt.Errorf("invalid WARC header: %v", err)
+	}
+
+	header := http.Header(mimeHeader)
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return Record{}, false, fmt.Errorf("invalid WARC content length: %q", header.Get("Content-Length"))
+	}
+
+	if length > r.maxRecordSize {
+		if _, err := io.CopyN(io.Discard, r.r, length); err != nil {
+			return Record{}, false, fmt.Errorf("failed to skip WARC record: %v", err)
+		}
+		return Record{}, true, nil
+	}
+
+	// Content length is not trusted, so the block is only allocated as it's read
+	var block bytes.Buffer
+	if _, err := io.CopyN(&block, r.r, length); err != nil {
+		return Record{}, false, fmt.Errorf("failed to read WARC record: %v", err)
+	}
+
+	return Record{Header: header, Block: block.Bytes(), maxSize: r.maxRecordSize}, false, nil
+}
+
+// digest returns SHA-1 digest of the data in format used by WARC.
+func digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerName returns the field name as written in WARC specification,
+// since http.Header canonicalizes it as "Warc-Target-Uri".
+func headerName(key string) string {
+	parts := strings.Split(key, "-")
+	for i, part := range parts {
+		switch strings.ToUpper(part) {
+		case "WARC", "URI", "ID", "IP":
+			parts[i] = strings.ToUpper(part)
+		}
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,142 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		compress bool
+	}{
+		{"plain", false},
+		{"gzip", true},
+	}
+
+	date := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := bytes.NewBuffer(nil)
+			w := NewWriter(buffer, tt.compress)
+			if err := w.WriteInfo("test.warc", date, map[string]string{"software": "shiori"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteResponse("https://example.com/page", date, "text/html", []byte("<p>hello</p>")); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteResponse("https://example.com/style.css", date, "text/css", []byte("p{}")); err != nil {
+				t.Fatal(err)
+			}
+
+			captures, err := ReadCaptures(buffer, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(captures) != 2 {
+				t.Fatalf("got %d captures, want 2", len(captures))
+			}
+
+			if c := captures[0]; c.URL != "https://example.com/page" || c.ContentType != "text/html" || string(c.Content) != "<p>hello</p>" {
+				t.Errorf("got first capture %+v", c)
+			}
+
+			if c := captures[1]; c.URL != "https://example.com/style.css" || string(c.Content) != "p{}" {
+				t.Errorf("got second capture %+v", c)
+			}
+		})
+	}
+}
+
+func TestRecordPayload(t *testing.T) {
+	gzipped := bytes.NewBuffer(nil)
+	gzipWriter := gzip.NewWriter(gzipped)
+	gzipWriter.Write([]byte("compressed"))
+	gzipWriter.Close()
+
+	bomb := bytes.NewBuffer(nil)
+	gzipWriter = gzip.NewWriter(bomb)
+	gzipWriter.Write(make([]byte, 2000))
+	gzipWriter.Close()
+
+	tests := []struct {
+		name    string
+		typ     string
+		block   string
+		want    string
+		wantErr bool
+	}{
+		{"resource", "resource", "raw", "raw", false},
+		{"request", "request", "GET / HTTP/1.1\r\n\r\n", "", true},
+		{"not found", "response", "HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n", "", true},
+		{"chunked", "response", "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n", "hello", false},
+		{"gzip", "response", "HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\n\r\n" + gzipped.String(), "compressed", false},
+		{"gzip too large", "response", "HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\n\r\n" + bomb.String(), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("WARC-Type", tt.typ)
+			if tt.typ != "resource" {
+				header.Set("Content-Type", "application/http;msgtype="+tt.typ)
+			}
+
+			_, content, err := Record{Header: header, Block: []byte(tt.block), maxSize: 1000}.Payload()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+
+			if string(content) != tt.want {
+				t.Errorf("got %q, want %q", content, tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderRecordSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		length    string
+		block     string
+		wantBlock string
+		wantErr   string
+	}{
+		{"valid", "5", "hello", "hello", ""},
+		{"larger than limit", "11", "hello world", "next", ""},
+		{"too large", "9223372036854775807", "hello", "", "failed to skip"},
+		{"truncated", "10", "hello", "", "failed to read"},
+		{"negative", "-1", "hello", "", "invalid WARC content length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := "WARC/1.0\r\nWARC-Type: resource\r\nContent-Length: " + tt.length + "\r\n\r\n" + tt.block
+			if tt.wantErr == "" {
+				file += "\r\n\r\nWARC/1.0\r\nWARC-Type: resource\r\nContent-Length: 4\r\n\r\nnext\r\n\r\n"
+			}
+
+			reader, err := NewReader(bytes.NewBufferString(file), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec, err := reader.Next()
+			if tt.wantErr == "" {
+				if err != nil || string(rec.Block) != tt.wantBlock {
+					t.Errorf("Next() = %q, %v, want %q", rec.Block, err, tt.wantBlock)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Next() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
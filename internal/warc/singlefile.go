@@ -0,0 +1,143 @@
+package warc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// WriteSingleFile writes the archived page as a single HTML file, which resources
+// are embedded as data URI, so it can be opened without Shiori.
+func (arc *Archive) WriteSingleFile(w io.Writer) error {
+	names, err := arc.Resources()
+	if err != nil {
+		return err
+	}
+
+	inliner := resourceInliner{
+		archive:  arc,
+		dataURIs: map[string]string{},
+		visiting: map[string]bool{},
+	}
+
+	// Resources are referenced in archive by their name, so look for the names.
+	// Longer names are tried first, in case a name is the prefix of another one.
+	if len(names) > 0 {
+		sort.Slice(names, func(i, j int) bool {
+			return len(names[i]) > len(names[j])
+		})
+
+		for i, name := range names {
+			names[i] = regexp.QuoteMeta(name)
+		}
+		inliner.rxNames = regexp.MustCompile(strings.Join(names, "|"))
+	}
+
+	content, _, err := arc.readContent("archive-root")
+	if err != nil {
+		return err
+	}
+
+	content, err = inliner.inlineHTML(content)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(content)
+	return err
+}
+
+// resourceInliner replaces the references to archived resources with data URI.
+type resourceInliner struct {
+	archive  *Archive
+	rxNames  *regexp.Regexp
+	dataURIs map[string]string
+	visiting map[string]bool
+}
+
+// inlineHTML replaces the resources referenced in attributes, style and script of the document.
+func (inl *resourceInliner) inlineHTML(content []byte) ([]byte, error) {
+	if inl.rxNames == nil {
+		return content, nil
+	}
+
+	doc, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.ElementNode:
+			for i := range node.Attr {
+				node.Attr[i].Val = inl.inlineText(node.Attr[i].Val)
+			}
+		case html.TextNode:
+			if node.Parent != nil && (node.Parent.Data == "style" || node.Parent.Data == "script") {
+				node.Data = inl.inlineText(node.Data)
+			}
+		}
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	buffer := bytes.NewBuffer(nil)
+	if err = html.Render(buffer, doc); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// inlineText replaces the resource names in the text with their data URI.
+func (inl *resourceInliner) inlineText(text string) string {
+	if inl.rxNames == nil {
+		return text
+	}
+
+	return inl.rxNames.ReplaceAllStringFunc(text, inl.dataURI)
+}
+
+// dataURI returns data URI of the resource. Stylesheet and embedded document are
+// inlined first, since they may reference other resources as well. If the resource
+// can't be read or references itself, the name is returned as it is.
+func (inl *resourceInliner) dataURI(name string) string {
+	if dataURI, exist := inl.dataURIs[name]; exist {
+		return dataURI
+	}
+
+	if inl.visiting[name] {
+		return name
+	}
+
+	inl.visiting[name] = true
+	defer delete(inl.visiting, name)
+
+	content, contentType, err := inl.archive.readContent(name)
+	if err != nil {
+		return name
+	}
+
+	switch {
+	case strings.Contains(contentType, "text/css"):
+		content = []byte(inl.inlineText(string(content)))
+	case strings.Contains(contentType, "text/html"):
+		if inlined, err := inl.inlineHTML(content); err == nil {
+			content = inlined
+		}
+	}
+
+	mediaType := strings.ReplaceAll(contentType, " ", "")
+	dataURI := "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(content)
+	inl.dataURIs[name] = dataURI
+	return dataURI
+}
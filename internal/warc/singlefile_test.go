@@ -0,0 +1,52 @@
+package warc
+
+import (
+	"bytes"
+	"encoding/base64"
+	fp "path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteSingleFile(t *testing.T) {
+	captures := []Capture{
+		{URL: "https://example.com/page", ContentType: "text/html",
+			Content: []byte(`<html><head><link rel="stylesheet" href="style.css"></head><body><img src="a.png"></body></html>`)},
+		{URL: "https://example.com/style.css", ContentType: "text/css",
+			Content: []byte(`body{background:url(bg.png)}`)},
+		{URL: "https://example.com/a.png", ContentType: "image/png", Content: []byte("a")},
+		{URL: "https://example.com/bg.png", ContentType: "image/png", Content: []byte("bg")},
+	}
+
+	archivePath := fp.Join(t.TempDir(), "archive")
+	if err := NewArchiveFromCaptures(captures[0], captures, archivePath, false); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	buffer := bytes.NewBuffer(nil)
+	if err := archive.WriteSingleFile(buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	output := buffer.String()
+	if strings.Contains(output, "https-example.com") {
+		t.Errorf("resource is not inlined: %s", output)
+	}
+
+	imgURI := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("a"))
+	if !strings.Contains(output, imgURI) {
+		t.Errorf("image is not inlined: %s", output)
+	}
+
+	cssURI := "data:text/css;base64," + base64.StdEncoding.EncodeToString([]byte(
+		`body{background:url("data:image/png;base64,`+base64.StdEncoding.EncodeToString([]byte("bg"))+`")}`))
+	if !strings.Contains(output, cssURI) {
+		t.Errorf("stylesheet is not inlined with its resources: %s", output)
+	}
+}
@@ -0,0 +1,79 @@
+package warc
+
+import (
+	"fmt"
+	"io"
+	nurl "net/url"
+	"os"
+	fp "path/filepath"
+
+	"github.com/go-shiori/shiori/internal/warc/internal/archiver"
+	"go.etcd.io/bbolt"
+)
+
+// ArchivalRequest is request for archiving a web page,
+// either from URL or from an io.Reader.
+type ArchivalRequest struct {
+	URL         string
+	Reader      io.Reader
+	ContentType string
+	UserAgent   string
+	LogEnabled  bool
+
+	// Client is used to download the sub resources.
+	// If nil, the default client is used.
+	Client archiver.HTTPClient
+
+	// Captures are the sub resources that already captured, e.g. while the page
+	// rendered by browser. They are used instead of downloaded with Client.
+	Captures []Capture
+}
+
+// NewArchive creates new archive based on submitted request,
+// then save it to specified path.
+func NewArchive(req ArchivalRequest, dstPath string) error {
+	// Make sure URL is valid
+	parsedURL, err := nurl.ParseRequestURI(req.URL)
+	if err != nil || parsedURL.Scheme == "" || parsedURL.Hostname() == "" {
+		return fmt.Errorf("url \"%s\" is not valid", req.URL)
+	}
+
+	// Create database for archive
+	os.MkdirAll(fp.Dir(dstPath), os.ModePerm)
+
+	db, err := bbolt.Open(dstPath, os.ModePerm, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %v", err)
+	}
+	defer db.Close()
+
+	// Serve the captured resources first
+	if len(req.Captures) > 0 {
+		client := req.Client
+		if client == nil {
+			client = archiver.DefaultClient()
+		}
+		req.Client = newCaptureClient(req.Captures, client)
+	}
+
+	// Start archival
+	arc := archiver.Archiver{
+		DB:         db,
+		Client:     req.Client,
+		UserAgent:  req.UserAgent,
+		LogEnabled: req.LogEnabled,
+	}
+
+	arcRequest := archiver.Request{
+		URL:         req.URL,
+		Reader:      req.Reader,
+		ContentType: req.ContentType,
+	}
+
+	err = arc.Start(arcRequest)
+	if err != nil {
+		return fmt.Errorf("archival failed: %v", err)
+	}
+
+	return nil
+}
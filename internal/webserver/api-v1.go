@@ -0,0 +1,311 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// apiV1Error is an error of REST API v1. It's sent to client as JSON
+// envelope with matching HTTP status code.
+type apiV1Error struct {
+	Status  int    `json:"status" doc:"HTTP status code"`
+	Code    string `json:"code" doc:"Machine readable error code, e.g. not_found"`
+	Message string `json:"message" doc:"Human readable error message"`
+}
+
+func (err *apiV1Error) Error() string {
+	return err.Message
+}
+
+// apiV1ErrorResponse is the envelope of error returned by REST API v1.
+type apiV1ErrorResponse struct {
+	Error apiV1Error `json:"error"`
+}
+
+// newAPIV1Error creates error with the specified HTTP status. The error code
+// is derived from the status text, e.g. 404 becomes "not_found".
+func newAPIV1Error(status int, format string, args ...interface{}) *apiV1Error {
+	code := strings.ToLower(http.StatusText(status))
+	code = strings.ReplaceAll(code, " ", "_")
+	code = strings.ReplaceAll(code, "-", "_")
+
+	return &apiV1Error{
+		Status:  status,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// apiV1Route is a single endpoint of REST API v1. Query, Body and Response
+// are zero values of the types used by the endpoint, which are described
+// in the OpenAPI document.
+type apiV1Route struct {
+	Method   string
+	Path     string // e.g. /bookmarks/{id}
+	Tag      string
+	Summary  string
+	Public   bool // doesn't need session
+	Query    interface{}
+	Body     interface{}
+	Response interface{}
+	Status   int // status of successful response, 200 by default
+	Handle   func(req *apiV1Request) (interface{}, error)
+}
+
+// apiV1Request is the request received by REST API v1 endpoint.
+type apiV1Request struct {
+	*http.Request
+	Writer    http.ResponseWriter
+	SessionID string
+	params    map[string]string
+}
+
+// Param returns value of the path parameter.
+func (req *apiV1Request) Param(name string) string {
+	return req.params[name]
+}
+
+// IntParam returns value of the path parameter as integer.
+func (req *apiV1Request) IntParam(name string) (int, error) {
+	value, err := strconv.Atoi(req.params[name])
+	if err != nil {
+		return 0, newAPIV1Error(http.StatusBadRequest, "%s must be an integer", name)
+	}
+
+	return value, nil
+}
+
+// DecodeBody decodes JSON body into dst, which must be pointer to struct.
+// String fields tagged with `required:"true"` must not be empty.
+func (req *apiV1Request) DecodeBody(dst interface{}) error {
+	err := json.NewDecoder(req.Body).Decode(dst)
+	if err == io.EOF {
+		return newAPIV1Error(http.StatusBadRequest, "request body is empty")
+	} else if err != nil {
+		return newAPIV1Error(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+
+	value := reflect.ValueOf(dst).Elem()
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Tag.Get("required") == "true" && value.Field(i).IsZero() {
+			return newAPIV1Error(http.StatusBadRequest, "%s is required", jsonFieldName(field))
+		}
+	}
+
+	return nil
+}
+
+// DecodeQuery decodes URL query into dst, which must be pointer to struct
+// whose fields are tagged with `query`. Slice fields are filled from the
+// repeated parameters, e.g. ?tag=a&tag=b.
+func (req *apiV1Request) DecodeQuery(dst interface{}) error {
+	query := req.URL.Query()
+	value := reflect.ValueOf(dst).Elem()
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := field.Tag.Get("query")
+		values, exist := query[name]
+		if name == "" || !exist || len(values) == 0 {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		switch field.Type.Kind() {
+		case reflect.String:
+			fieldValue.SetString(values[0])
+		case reflect.Int:
+			number, err := strconv.Atoi(values[0])
+			if err != nil {
+				return newAPIV1Error(http.StatusBadRequest, "%s must be an integer", name)
+			}
+			fieldValue.SetInt(int64(number))
+		case reflect.Bool:
+			flag, err := strconv.ParseBool(values[0])
+			if err != nil {
+				return newAPIV1Error(http.StatusBadRequest, "%s must be a boolean", name)
+			}
+			fieldValue.SetBool(flag)
+		case reflect.Slice:
+			items := []string{}
+			for _, v := range values {
+				if v = strings.TrimSpace(v); v != "" {
+					items = append(items, v)
+				}
+			}
+			fieldValue.Set(reflect.ValueOf(items))
+		}
+	}
+
+	return nil
+}
+
+// apiV1Router dispatches requests of REST API v1 to its routes. Unlike
+// httprouter, static path segments take precedence over the parameters, so
+// /bookmarks/{id} may live next to e.g. /bookmarks/bulk.
+type apiV1Router struct {
+	handler *handler
+	routes  []apiV1Route
+}
+
+// match returns the routes matching the path, the one with most static
+// segments first, and the path parameters of each route.
+func (rt *apiV1Router) match(urlPath string) ([]apiV1Route, []map[string]string) {
+	type candidate struct {
+		route  apiV1Route
+		params map[string]string
+		score  int
+	}
+
+	segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	candidates := []candidate{}
+
+	for _, route := range rt.routes {
+		patterns := strings.Split(strings.Trim(route.Path, "/"), "/")
+		if len(patterns) != len(segments) {
+			continue
+		}
+
+		matched := true
+		score := 0
+		params := map[string]string{}
+		for i, pattern := range patterns {
+			if strings.HasPrefix(pattern, "{") && strings.HasSuffix(pattern, "}") {
+				params[strings.Trim(pattern, "{}")] = segments[i]
+			} else if pattern == segments[i] {
+				score++
+			} else {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			candidates = append(candidates, candidate{route, params, score})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	routes := make([]apiV1Route, len(candidates))
+	params := make([]map[string]string, len(candidates))
+	for i, c := range candidates {
+		routes[i] = c.route
+		params[i] = c.params
+	}
+
+	return routes, params
+}
+
+// serve handles the request for the path relative to /api/v1.
+func (rt *apiV1Router) serve(w http.ResponseWriter, r *http.Request, urlPath string) {
+	// Convert panic from the shared helpers into internal error
+	defer func() {
+		if arg := recover(); arg != nil {
+			logrus.Errorf("api v1: %s %s: %v", r.Method, r.URL.Path, arg)
+			writeAPIV1Error(w, newAPIV1Error(http.StatusInternalServerError, "%v", arg))
+		}
+	}()
+
+	// Find the route with matching path and method
+	routes, params := rt.match(urlPath)
+	if len(routes) == 0 {
+		writeAPIV1Error(w, newAPIV1Error(http.StatusNotFound, "endpoint %s is not found", urlPath))
+		return
+	}
+
+	var route *apiV1Route
+	var routeParams map[string]string
+	var allowed []string
+	for i := range routes {
+		if routes[i].Method == r.Method && route == nil {
+			route, routeParams = &routes[i], params[i]
+		}
+		allowed = append(allowed, routes[i].Method)
+	}
+
+	if route == nil {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeAPIV1Error(w, newAPIV1Error(http.StatusMethodNotAllowed, "method %s is not allowed", r.Method))
+		return
+	}
+
+	// Make sure session still valid
+	if !route.Public {
+		if err := rt.handler.validateSession(r); err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, errAccountLevel) {
+				status = http.StatusForbidden
+			}
+			writeAPIV1Error(w, newAPIV1Error(status, "%v", err))
+			return
+		}
+	}
+
+	// Run the handler
+	req := &apiV1Request{
+		Request:   r,
+		Writer:    w,
+		SessionID: rt.handler.getSessionID(r),
+		params:    routeParams,
+	}
+
+	resp, err := route.Handle(req)
+	if err != nil {
+		writeAPIV1Error(w, err)
+		return
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if resp == nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeAPIV1Error writes the error as JSON envelope. Error which is not
+// apiV1Error is considered as internal server error.
+func writeAPIV1Error(w http.ResponseWriter, err error) {
+	var apiErr *apiV1Error
+	if !errors.As(err, &apiErr) {
+		apiErr = newAPIV1Error(http.StatusInternalServerError, "%v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(apiV1ErrorResponse{Error: *apiErr})
+}
+
+// jsonFieldName returns name of the struct field in JSON.
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	return name
+}
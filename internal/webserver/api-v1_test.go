@@ -0,0 +1,186 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-shiori/shiori/internal/model"
+	cch "github.com/patrickmn/go-cache"
+)
+
+func TestAPIV1Router(t *testing.T) {
+	hdl := &handler{SessionCache: cch.New(time.Hour, time.Hour)}
+	hdl.SessionCache.Set("owner", model.Account{Username: "owner", Owner: true}, time.Hour)
+	hdl.SessionCache.Set("visitor", model.Account{Username: "visitor"}, time.Hour)
+
+	router := &apiV1Router{handler: hdl, routes: []apiV1Route{{
+		Method: "GET", Path: "/items/{id}",
+		Handle: func(req *apiV1Request) (interface{}, error) {
+			id, err := req.IntParam("id")
+			if err != nil {
+				return nil, err
+			}
+			return map[string]int{"id": id}, nil
+		},
+	}, {
+		Method: "GET", Path: "/items/search",
+		Handle: func(req *apiV1Request) (interface{}, error) {
+			var query apiV1BookmarksQuery
+			if err := req.DecodeQuery(&query); err != nil {
+				return nil, err
+			}
+			return query.Tags, nil
+		},
+	}, {
+		Method: "POST", Path: "/items", Status: http.StatusCreated,
+		Handle: func(req *apiV1Request) (interface{}, error) {
+			var request apiV1CreateBookmarkRequest
+			if err := req.DecodeBody(&request); err != nil {
+				return nil, err
+			}
+			return request.URL, nil
+		},
+	}, {
+		Method: "DELETE", Path: "/items/{id}",
+		Handle: func(req *apiV1Request) (interface{}, error) {
+			panic("boom")
+		},
+	}}}
+
+	tests := []struct {
+		method  string
+		path    string
+		session string
+		body    string
+		status  int
+		result  string
+	}{
+		{"GET", "/items/12", "visitor", "", 200, `{"id":12}`},
+		{"GET", "/items/search?tag=a&tag=b+c&tag=", "visitor", "", 200, `["a","b c"]`},
+		{"GET", "/items/abc", "visitor", "", 400, `"bad_request"`},
+		{"GET", "/items/12", "", "", 401, `"unauthorized"`},
+		{"GET", "/items/12", "expired", "", 401, `"session has been expired"`},
+		{"POST", "/items", "visitor", `{"url":"x"}`, 403, `"forbidden"`},
+		{"POST", "/items", "owner", `{"url":"x"}`, 201, `"x"`},
+		{"POST", "/items", "owner", `{"title":"x"}`, 400, `"url is required"`},
+		{"POST", "/items", "owner", `{"url":`, 400, `"bad_request"`},
+		{"PUT", "/items", "owner", "", 405, `"method_not_allowed"`},
+		{"GET", "/nothing", "owner", "", 404, `"not_found"`},
+		{"DELETE", "/items/1", "owner", "", 500, `"boom"`},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/api/v1"+test.path, strings.NewReader(test.body))
+		if test.session != "" {
+			r.Header.Set("X-Session-Id", test.session)
+		}
+
+		w := httptest.NewRecorder()
+		router.serve(w, r, strings.Split(test.path, "?")[0])
+
+		body := w.Body.String()
+		if w.Code != test.status || !strings.Contains(body, test.result) {
+			t.Errorf("%s %s = %d %s, want %d containing %s", test.method, test.path, w.Code, body, test.status, test.result)
+		}
+
+		if w.Code >= 400 {
+			var resp apiV1ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error.Status != w.Code {
+				t.Errorf("%s %s error envelope = %s", test.method, test.path, body)
+			}
+		}
+	}
+}
+
+func TestAPIV1OpenAPIDocument(t *testing.T) {
+	hdl := &handler{}
+	router := &apiV1Router{handler: hdl, routes: hdl.apiV1Routes()}
+
+	raw, err := json.Marshal(router.openAPIDocument("/api/v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+		Comps   struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+				Required   []string                          `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+
+	if err = json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, route := range router.routes {
+		if doc.Paths[route.Path][strings.ToLower(route.Method)] == nil {
+			t.Errorf("document doesn't describe %s %s", route.Method, route.Path)
+		}
+	}
+
+	bookmark := doc.Comps.Schemas["Bookmark"]
+	if bookmark.Properties["tags"]["type"] != "array" || bookmark.Properties["public"]["type"] != "boolean" {
+		t.Errorf("Bookmark schema = %v", bookmark.Properties)
+	}
+
+	if request := doc.Comps.Schemas["CreateBookmarkRequest"]; strings.Join(request.Required, ",") != "url" {
+		t.Errorf("CreateBookmarkRequest required = %v", request.Required)
+	}
+
+	if login := doc.Comps.Schemas["LoginResponse"]; login.Properties["expires"]["format"] != "date-time" {
+		t.Errorf("LoginResponse schema = %v", login.Properties)
+	}
+
+	if _, exist := doc.Comps.Schemas["ErrorResponse"]; !exist {
+		t.Error("document doesn't describe error envelope")
+	}
+}
+
+func TestBookmarkETag(t *testing.T) {
+	book := model.Bookmark{ID: 1, URL: "https://example.com", Title: "Example", Modified: "2021-01-01 00:00:00",
+		Tags: []model.Tag{{Name: "b"}, {Name: "a"}}}
+	etag := bookmarkETag(book)
+
+	reordered := book
+	reordered.Tags = []model.Tag{{Name: "a"}, {Name: "b"}}
+	if bookmarkETag(reordered) != etag {
+		t.Error("ETag depends on the order of tags")
+	}
+
+	edited := book
+	edited.Title = "Edited"
+	if bookmarkETag(edited) == etag {
+		t.Error("ETag doesn't change after title edited")
+	}
+
+	tests := map[string]int{
+		"":                 0,
+		"*":                0,
+		etag:               0,
+		`"old", ` + etag:   0,
+		`"old"`:            http.StatusPreconditionFailed,
+		"W/" + etag + `x"`: http.StatusPreconditionFailed,
+	}
+
+	for ifMatch, status := range tests {
+		r := httptest.NewRequest("PATCH", "/api/v1/bookmarks/1", nil)
+		r.Header.Set("If-Match", ifMatch)
+
+		got := 0
+		if err := matchETag(&apiV1Request{Request: r}, etag); err != nil {
+			got = err.(*apiV1Error).Status
+		}
+
+		if got != status {
+			t.Errorf("matchETag(%q) status = %d, want %d", ifMatch, got, status)
+		}
+	}
+}
@@ -7,9 +7,6 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
-	fp "path/filepath"
-	"strconv"
 
 	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/model"
@@ -28,9 +25,14 @@ func (h *handler) apiInsertViaExtension(w http.ResponseWriter, r *http.Request,
 	checkError(err)
 
 	// Clean up bookmark URL
-	request.URL, err = core.RemoveUTMParams(request.URL)
+	request.URL, err = h.Canonicalizer.Canonicalize(request.URL)
 	if err != nil {
-		panic(fmt.Errorf("failed to clean URL: %v", err))
+		panic(newHTTPError(http.StatusBadRequest, "failed to clean URL: %v", err))
+	}
+
+	// Make sure the URL is allowed to be fetched
+	if err = h.Fetcher.CheckURL(request.URL); err != nil {
+		panic(newHTTPError(http.StatusBadRequest, "URL is not allowed: %v", err))
 	}
 
 	// Check if bookmark already exists.
@@ -63,10 +65,14 @@ func (h *handler) apiInsertViaExtension(w http.ResponseWriter, r *http.Request,
 	// so we download it here.
 	var contentType string
 	var contentBuffer io.Reader
+	var browser *core.Browser
 
 	if book.HTML == "" {
-		contentBuffer, contentType, _ = core.DownloadBookmark(book.URL)
+		contentBuffer, contentType, _ = h.Fetcher.DownloadBookmark(book.URL)
+		browser = h.Browser
 	} else {
+		// The page is already rendered by user's browser
+		book.RenderJS = false
 		contentType = "text/html; charset=UTF-8"
 		contentBuffer = bytes.NewBufferString(book.HTML)
 	}
@@ -76,10 +82,16 @@ func (h *handler) apiInsertViaExtension(w http.ResponseWriter, r *http.Request,
 	if contentBuffer != nil {
 		book.CreateArchive = true
 		request := core.ProcessRequest{
-			DataDir:     h.DataDir,
-			Bookmark:    book,
-			Content:     contentBuffer,
-			ContentType: contentType,
+			DB:            h.DB,
+			Storage:       h.Storage,
+			Bookmark:      book,
+			Content:       contentBuffer,
+			ContentType:   contentType,
+			Canonicalizer: h.Canonicalizer,
+			Fetcher:       h.Fetcher,
+			Extractors:    h.Extractors,
+			Browser:       browser,
+			Quota:         h.StorageQuota,
 		}
 
 		var isFatalErr bool
@@ -93,6 +105,9 @@ func (h *handler) apiInsertViaExtension(w http.ResponseWriter, r *http.Request,
 			panic(fmt.Errorf("failed to process bookmark: %v", err))
 		}
 	}
+
+	// Apply the tagging rules, now the content is available
+	h.applyRules(&book)
 	if _, err := h.DB.SaveBookmarks(book); err != nil {
 		log.Printf("error saving bookmark after downloading content: %s", err)
 	}
@@ -104,6 +119,13 @@ func (h *handler) apiInsertViaExtension(w http.ResponseWriter, r *http.Request,
 	}
 	book = results[0]
 
+	if err := h.saveArchiveSizes(book); err != nil {
+		log.Printf("error saving archive size: %s", err)
+	}
+
+	h.indexRelated(book)
+	h.embedBookmarks(book)
+
 	// Return the new bookmark
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(&book)
@@ -128,13 +150,8 @@ func (h *handler) apiDeleteViaExtension(w http.ResponseWriter, r *http.Request,
 		err = h.DB.DeleteBookmarks(book.ID)
 		checkError(err)
 
-		// Delete thumbnail image and archives from local disk
-		strID := strconv.Itoa(book.ID)
-		imgPath := fp.Join(h.DataDir, "thumb", strID)
-		archivePath := fp.Join(h.DataDir, "archive", strID)
-
-		os.Remove(imgPath)
-		os.Remove(archivePath)
+		// Delete thumbnail image and archives from storage
+		core.RemoveBookmarkFiles(h.Storage, book.ID)
 	}
 
 	fmt.Fprint(w, 1)
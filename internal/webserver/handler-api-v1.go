@@ -0,0 +1,1429 @@
+package webserver
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/shiori/internal/core"
+	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxBulkRefetch is the maximum number of bookmarks refetched in a single bulk request.
+const maxBulkRefetch = 100
+
+type apiV1LoginRequest struct {
+	Username string `json:"username" required:"true"`
+	Password string `json:"password" required:"true"`
+	Remember bool   `json:"remember" doc:"Keep the session for 30 days instead of an hour"`
+	Owner    bool   `json:"owner" doc:"Only allow owner account to log in"`
+}
+
+type apiV1LoginResponse struct {
+	Session string       `json:"session" doc:"Session ID, sent back in X-Session-Id header"`
+	Expires time.Time    `json:"expires" doc:"Expiration time of the session"`
+	Account apiV1Account `json:"account"`
+}
+
+type apiV1Account struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Owner    bool   `json:"owner"`
+}
+
+type apiV1CreateAccountRequest struct {
+	Username string `json:"username" required:"true"`
+	Password string `json:"password" required:"true"`
+	Owner    bool   `json:"owner"`
+}
+
+type apiV1UpdateAccountRequest struct {
+	OldPassword string `json:"oldPassword" required:"true"`
+	NewPassword string `json:"newPassword" required:"true"`
+	Owner       bool   `json:"owner"`
+}
+
+type apiV1Bookmark struct {
+	ID          int      `json:"id"`
+	URL         string   `json:"url"`
+	Title       string   `json:"title"`
+	Excerpt     string   `json:"excerpt"`
+	Author      string   `json:"author"`
+	Public      bool     `json:"public"`
+	Modified    string   `json:"modified"`
+	ImageURL    string   `json:"imageURL"`
+	FaviconURL  string   `json:"faviconURL"`
+	HasContent  bool     `json:"hasContent"`
+	HasArchive  bool     `json:"hasArchive"`
+	ArchiveSize int64    `json:"archiveSize" doc:"Size of the offline archive in bytes"`
+	Broken      bool     `json:"broken" doc:"Original URL is no longer reachable"`
+	Lost        bool     `json:"lost" doc:"Original URL is broken and there is no archive"`
+	LinkURL     string   `json:"linkURL" doc:"URL to open, which is the archive when original is broken"`
+	Tags        []string `json:"tags"`
+	ETag        string   `json:"etag" doc:"Version of the bookmark, same as ETag header"`
+}
+
+type apiV1UpdateBookmarkRequest struct {
+	URL     *string   `json:"url"`
+	Title   *string   `json:"title"`
+	Excerpt *string   `json:"excerpt"`
+	Public  *bool     `json:"public"`
+	Tags    *[]string `json:"tags" doc:"New tags of the bookmark, replacing the old ones"`
+}
+
+type apiV1BookmarksQuery struct {
+	Keyword      string   `query:"keyword" doc:"Search keyword, which may contain is: filters"`
+	Tags         []string `query:"tag" doc:"Only bookmarks with all of these tags, repeat for more tags"`
+	ExcludedTags []string `query:"excludeTag" doc:"Skip bookmarks with any of these tags, repeat for more tags"`
+	Order        string   `query:"order" doc:"Order of bookmarks: added (default), modified or oldest"`
+	Limit        int      `query:"limit" doc:"Number of bookmarks in a page, 30 by default and 100 at most"`
+	After        string   `query:"after" doc:"Cursor from nextCursor, to fetch the next page"`
+	Before       string   `query:"before" doc:"Cursor from prevCursor, to fetch the previous page"`
+	Count        bool     `query:"count" doc:"Count all matching bookmarks as well"`
+	Mode         string   `query:"mode" doc:"Search mode: keyword (default), or hybrid that ranks by both keyword and meaning, without cursors"`
+}
+
+type apiV1BookmarkList struct {
+	Bookmarks  []apiV1Bookmark `json:"bookmarks"`
+	NextCursor string          `json:"nextCursor,omitempty" doc:"Cursor of the next page, empty on the last page"`
+	PrevCursor string          `json:"prevCursor,omitempty" doc:"Cursor of the previous page, empty on the first page"`
+	Total      *int            `json:"total,omitempty" doc:"Number of matching bookmarks, only when count is requested"`
+}
+
+type apiV1CreateBookmarkRequest struct {
+	URL           string   `json:"url" required:"true"`
+	Title         string   `json:"title" doc:"Title of the bookmark, fetched from the page when empty"`
+	Excerpt       string   `json:"excerpt"`
+	Tags          []string `json:"tags"`
+	Public        bool     `json:"public"`
+	CreateArchive bool     `json:"createArchive" doc:"Create offline archive of the page"`
+	RenderJS      bool     `json:"renderJS" doc:"Render the page in headless browser"`
+	Async         *bool    `json:"async" doc:"Download the page in background, true by default"`
+}
+
+type apiV1BulkRequest struct {
+	IDs     []int             `json:"ids" doc:"IDs of the bookmarks, or empty to select them by filter"`
+	Filter  *apiV1BulkFilter  `json:"filter" doc:"Search filter, used when there are no IDs. Empty filter selects all bookmarks"`
+	Actions []core.BulkAction `json:"actions" required:"true" doc:"Actions applied in order: tag, untag, public, private, refetch or delete"`
+}
+
+type apiV1BulkFilter struct {
+	Keyword      string   `json:"keyword"`
+	Tags         []string `json:"tags"`
+	ExcludedTags []string `json:"excludeTags"`
+}
+
+type apiV1BulkResponse struct {
+	Results []core.BulkResult `json:"results"`
+	Updated int               `json:"updated"`
+	Deleted int               `json:"deleted"`
+	Failed  int               `json:"failed"`
+}
+
+type apiV1RelatedBookmarksQuery struct {
+	Limit int `query:"limit" doc:"Number of related bookmarks, 10 by default"`
+}
+
+type apiV1RelatedBookmark struct {
+	Bookmark apiV1Bookmark `json:"bookmark"`
+	Score    float64       `json:"score" doc:"Similarity of both bookmarks, between 0 and 1"`
+}
+
+type apiV1Highlight struct {
+	ID         int    `json:"id"`
+	BookmarkID int    `json:"bookmarkId"`
+	Quote      string `json:"quote" doc:"Highlighted text"`
+	Prefix     string `json:"prefix" doc:"Text right before the quote"`
+	Suffix     string `json:"suffix" doc:"Text right after the quote"`
+	Start      int    `json:"start" doc:"Offset of the quote in text of bookmark's HTML content"`
+	End        int    `json:"end" doc:"Offset of the end of quote"`
+	Note       string `json:"note"`
+	Created    string `json:"created"`
+	Modified   string `json:"modified"`
+}
+
+type apiV1CreateHighlightRequest struct {
+	Quote  string `json:"quote" required:"true" doc:"Highlighted text, exactly as it's in the content"`
+	Prefix string `json:"prefix" doc:"Text right before the quote, used to find it again when the content has changed"`
+	Suffix string `json:"suffix" doc:"Text right after the quote"`
+	Start  int    `json:"start" doc:"Offset of the quote in text of bookmark's HTML content"`
+	End    int    `json:"end" doc:"Offset of the end of quote"`
+	Note   string `json:"note"`
+}
+
+type apiV1UpdateHighlightRequest struct {
+	Note string `json:"note" doc:"New note, empty to remove it"`
+}
+
+type apiV1SuggestTagsRequest struct {
+	ID      int      `json:"id" doc:"ID of saved bookmark, or empty to use the submitted text"`
+	Title   string   `json:"title"`
+	Excerpt string   `json:"excerpt"`
+	Content string   `json:"content" doc:"Readable text of the page"`
+	Tags    []string `json:"tags" doc:"Tags the bookmark already has, which are not suggested"`
+	Limit   int      `json:"limit" doc:"Number of suggestions, 5 by default"`
+}
+
+type apiV1Tag struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name" doc:"Hierarchical tag uses / between levels, e.g. dev/go"`
+	Parent        string `json:"parent,omitempty" doc:"Name of the parent tag, if any"`
+	Color         string `json:"color,omitempty" doc:"Hex color, e.g. #ff8800"`
+	Description   string `json:"description,omitempty"`
+	BookmarkCount int    `json:"bookmarkCount"`
+}
+
+type apiV1RenameTagRequest struct {
+	Name string `json:"name" required:"true" doc:"New name, the tags are merged if it's already used"`
+}
+
+type apiV1UpdateTagRequest struct {
+	Name        *string `json:"name" doc:"New name, the tags are merged if it's already used"`
+	Color       *string `json:"color" doc:"Hex color, empty to remove it"`
+	Description *string `json:"description"`
+}
+
+type apiV1MergeTagsRequest struct {
+	IDs  []int  `json:"ids" required:"true" doc:"IDs of the merged tags"`
+	Name string `json:"name" required:"true" doc:"Name of the resulting tag, which is created if needed"`
+}
+
+type apiV1DeleteOrphanTagsResponse struct {
+	Removed int `json:"removed" doc:"Number of removed tags"`
+}
+
+type apiV1Rule struct {
+	ID              int      `json:"id"`
+	Name            string   `json:"name"`
+	Domain          string   `json:"domain" doc:"Matches the domain and its subdomains"`
+	PathPattern     string   `json:"pathPattern" doc:"Regular expression for URL path"`
+	TitleKeywords   []string `json:"titleKeywords" doc:"Title must contain any of these keywords"`
+	ContentKeywords []string `json:"contentKeywords" doc:"Content must contain any of these keywords"`
+	Tags            []string `json:"tags" doc:"Tags added to the matching bookmarks"`
+	Public          *bool    `json:"public" doc:"Visibility set to the matching bookmarks, null to keep it"`
+	Enabled         bool     `json:"enabled"`
+}
+
+type apiV1SaveRuleRequest struct {
+	Name            string   `json:"name" doc:"Defaults to the tags"`
+	Domain          string   `json:"domain" doc:"Matches the domain and its subdomains"`
+	PathPattern     string   `json:"pathPattern" doc:"Regular expression for URL path"`
+	TitleKeywords   []string `json:"titleKeywords" doc:"Title must contain any of these keywords"`
+	ContentKeywords []string `json:"contentKeywords" doc:"Content must contain any of these keywords"`
+	Tags            []string `json:"tags" doc:"Tags added to the matching bookmarks"`
+	Public          *bool    `json:"public" doc:"Visibility set to the matching bookmarks, null to keep it"`
+	Enabled         *bool    `json:"enabled" doc:"Defaults to true"`
+}
+
+// apiV1Routes returns the routes of REST API v1.
+func (h *handler) apiV1Routes() []apiV1Route {
+	return []apiV1Route{{
+		Method: "POST", Path: "/auth/login", Tag: "auth", Summary: "Log in",
+		Public: true, Body: apiV1LoginRequest{}, Response: apiV1LoginResponse{},
+		Handle: h.apiV1Login,
+	}, {
+		Method: "POST", Path: "/auth/logout", Tag: "auth", Summary: "Log out",
+		Public: true, Status: http.StatusNoContent,
+		Handle: h.apiV1Logout,
+	}, {
+		Method: "GET", Path: "/bookmarks", Tag: "bookmarks", Summary: "List bookmarks",
+		Query: apiV1BookmarksQuery{}, Response: apiV1BookmarkList{},
+		Handle: h.apiV1GetBookmarks,
+	}, {
+		Method: "POST", Path: "/bookmarks", Tag: "bookmarks", Summary: "Add bookmark",
+		Body: apiV1CreateBookmarkRequest{}, Response: apiV1Bookmark{}, Status: http.StatusCreated,
+		Handle: h.apiV1InsertBookmark,
+	}, {
+		Method: "POST", Path: "/bookmarks/bulk", Tag: "bookmarks", Summary: "Apply actions to many bookmarks",
+		Body: apiV1BulkRequest{}, Response: apiV1BulkResponse{},
+		Handle: h.apiV1BulkBookmarks,
+	}, {
+		Method: "POST", Path: "/bookmarks/suggest-tags", Tag: "bookmarks", Summary: "Suggest tags from similar bookmarks and keywords",
+		Body: apiV1SuggestTagsRequest{}, Response: []core.TagSuggestion{},
+		Handle: h.apiV1SuggestTags,
+	}, {
+		Method: "GET", Path: "/bookmarks/{id}", Tag: "bookmarks", Summary: "Get bookmark",
+		Response: apiV1Bookmark{},
+		Handle:   h.apiV1GetBookmark,
+	}, {
+		Method: "PATCH", Path: "/bookmarks/{id}", Tag: "bookmarks", Summary: "Update some fields of bookmark",
+		Body: apiV1UpdateBookmarkRequest{}, Response: apiV1Bookmark{},
+		Handle: h.apiV1UpdateBookmark,
+	}, {
+		Method: "DELETE", Path: "/bookmarks/{id}", Tag: "bookmarks", Summary: "Delete bookmark",
+		Status: http.StatusNoContent,
+		Handle: h.apiV1DeleteBookmark,
+	}, {
+		Method: "GET", Path: "/bookmarks/{id}/related", Tag: "bookmarks", Summary: "List bookmarks most similar to bookmark",
+		Query: apiV1RelatedBookmarksQuery{}, Response: []apiV1RelatedBookmark{},
+		Handle: h.apiV1GetRelatedBookmarks,
+	}, {
+		Method: "GET", Path: "/bookmarks/{id}/highlights", Tag: "highlights", Summary: "List highlights of bookmark",
+		Response: []apiV1Highlight{},
+		Handle:   h.apiV1GetHighlights,
+	}, {
+		Method: "POST", Path: "/bookmarks/{id}/highlights", Tag: "highlights", Summary: "Create highlight",
+		Body: apiV1CreateHighlightRequest{}, Response: apiV1Highlight{}, Status: http.StatusCreated,
+		Handle: h.apiV1InsertHighlight,
+	}, {
+		Method: "PUT", Path: "/bookmarks/{id}/highlights/{highlightId}", Tag: "highlights", Summary: "Change note of highlight",
+		Body: apiV1UpdateHighlightRequest{}, Response: apiV1Highlight{},
+		Handle: h.apiV1UpdateHighlight,
+	}, {
+		Method: "DELETE", Path: "/bookmarks/{id}/highlights/{highlightId}", Tag: "highlights", Summary: "Delete highlight",
+		Status: http.StatusNoContent,
+		Handle: h.apiV1DeleteHighlight,
+	}, {
+		Method: "GET", Path: "/tags", Tag: "tags", Summary: "List tags",
+		Response: []apiV1Tag{},
+		Handle:   h.apiV1GetTags,
+	}, {
+		Method: "POST", Path: "/tags/merge", Tag: "tags", Summary: "Merge tags",
+		Body: apiV1MergeTagsRequest{}, Response: apiV1Tag{},
+		Handle: h.apiV1MergeTags,
+	}, {
+		Method: "DELETE", Path: "/tags/orphans", Tag: "tags", Summary: "Remove tags that not used by any bookmark",
+		Response: apiV1DeleteOrphanTagsResponse{},
+		Handle:   h.apiV1DeleteOrphanTags,
+	}, {
+		Method: "GET", Path: "/tags/{id}", Tag: "tags", Summary: "Get tag",
+		Response: apiV1Tag{},
+		Handle:   h.apiV1GetTag,
+	}, {
+		Method: "PUT", Path: "/tags/{id}", Tag: "tags", Summary: "Rename tag",
+		Body: apiV1RenameTagRequest{}, Response: apiV1Tag{},
+		Handle: h.apiV1RenameTag,
+	}, {
+		Method: "PATCH", Path: "/tags/{id}", Tag: "tags", Summary: "Update name, color and description of tag",
+		Body: apiV1UpdateTagRequest{}, Response: apiV1Tag{},
+		Handle: h.apiV1UpdateTag,
+	}, {
+		Method: "DELETE", Path: "/tags/{id}", Tag: "tags", Summary: "Delete tag and detach it from all bookmarks",
+		Status: http.StatusNoContent,
+		Handle: h.apiV1DeleteTag,
+	}, {
+		Method: "GET", Path: "/rules", Tag: "rules", Summary: "List tagging rules",
+		Response: []apiV1Rule{},
+		Handle:   h.apiV1GetRules,
+	}, {
+		Method: "POST", Path: "/rules", Tag: "rules", Summary: "Create tagging rule",
+		Body: apiV1SaveRuleRequest{}, Response: apiV1Rule{}, Status: http.StatusCreated,
+		Handle: h.apiV1InsertRule,
+	}, {
+		Method: "PUT", Path: "/rules/{id}", Tag: "rules", Summary: "Replace tagging rule",
+		Body: apiV1SaveRuleRequest{}, Response: apiV1Rule{},
+		Handle: h.apiV1UpdateRule,
+	}, {
+		Method: "DELETE", Path: "/rules/{id}", Tag: "rules", Summary: "Delete tagging rule",
+		Status: http.StatusNoContent,
+		Handle: h.apiV1DeleteRule,
+	}, {
+		Method: "GET", Path: "/accounts", Tag: "accounts", Summary: "List accounts",
+		Response: []apiV1Account{},
+		Handle:   h.apiV1GetAccounts,
+	}, {
+		Method: "POST", Path: "/accounts", Tag: "accounts", Summary: "Create account",
+		Body: apiV1CreateAccountRequest{}, Response: apiV1Account{}, Status: http.StatusCreated,
+		Handle: h.apiV1InsertAccount,
+	}, {
+		Method: "PUT", Path: "/accounts/{username}", Tag: "accounts", Summary: "Change password and level of account",
+		Body: apiV1UpdateAccountRequest{}, Response: apiV1Account{},
+		Handle: h.apiV1UpdateAccount,
+	}, {
+		Method: "DELETE", Path: "/accounts/{username}", Tag: "accounts", Summary: "Delete account",
+		Status: http.StatusNoContent,
+		Handle: h.apiV1DeleteAccount,
+	}, {
+		Method: "GET", Path: "/openapi.json", Tag: "meta", Summary: "OpenAPI document of this API",
+		Public: true,
+		Handle: h.apiV1OpenAPI,
+	}}
+}
+
+// newAPIV1Bookmark converts the bookmark into its API v1 representation.
+func newAPIV1Bookmark(book model.Bookmark) apiV1Bookmark {
+	tags := make([]string, len(book.Tags))
+	for i, tag := range book.Tags {
+		tags[i] = tag.Name
+	}
+
+	return apiV1Bookmark{
+		ID:          book.ID,
+		URL:         book.URL,
+		Title:       book.Title,
+		Excerpt:     book.Excerpt,
+		Author:      book.Author,
+		Public:      book.Public == 1,
+		Modified:    book.Modified,
+		ImageURL:    book.ImageURL,
+		FaviconURL:  book.FaviconURL,
+		HasContent:  book.HasContent,
+		HasArchive:  book.HasArchive,
+		ArchiveSize: book.ArchiveSize,
+		Broken:      book.Broken,
+		Lost:        book.Lost,
+		LinkURL:     book.LinkURL,
+		Tags:        tags,
+		ETag:        bookmarkETag(book),
+	}
+}
+
+// bookmarkETag returns the entity tag of the bookmark, which changes
+// whenever any of its editable fields is changed.
+func bookmarkETag(book model.Bookmark) string {
+	tags := make([]string, len(book.Tags))
+	for i, tag := range book.Tags {
+		tags[i] = tag.Name
+	}
+	sort.Strings(tags)
+
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%d\n%s\n%s\n%s\n%d\n%s\n%s",
+		book.ID, book.URL, book.Title, book.Excerpt, book.Public, book.Modified,
+		strings.Join(tags, "\n"))
+
+	return fmt.Sprintf(`"%x"`, hash.Sum(nil)[:8])
+}
+
+// matchETag checks the If-Match header of the request against the current
+// entity tag. Request without the header always matches.
+func matchETag(req *apiV1Request, etag string) error {
+	ifMatch := req.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return nil
+	}
+
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(candidate) == etag {
+			return nil
+		}
+	}
+
+	return newAPIV1Error(http.StatusPreconditionFailed, "bookmark has been modified, fetch it again")
+}
+
+// getBookmarkByID returns the bookmark with its tags and content.
+func (h *handler) getBookmarkByID(id int) (model.Bookmark, error) {
+	bookmarks, err := h.DB.GetBookmarks(database.GetBookmarksOptions{
+		IDs:         []int{id},
+		WithContent: true,
+	})
+	if err != nil {
+		return model.Bookmark{}, err
+	}
+
+	if len(bookmarks) == 0 {
+		return model.Bookmark{}, newAPIV1Error(http.StatusNotFound, "bookmark %d is not found", id)
+	}
+
+	if err = h.prepareBookmarks(bookmarks); err != nil {
+		return model.Bookmark{}, err
+	}
+
+	return bookmarks[0], nil
+}
+
+func newAPIV1Tag(tag model.Tag) apiV1Tag {
+	parent := ""
+	if idx := strings.LastIndex(tag.Name, database.TagSeparator); idx > 0 {
+		parent = tag.Name[:idx]
+	}
+
+	return apiV1Tag{
+		ID:            tag.ID,
+		Name:          tag.Name,
+		Parent:        parent,
+		Color:         tag.Color,
+		Description:   tag.Description,
+		BookmarkCount: tag.NBookmarks,
+	}
+}
+
+// newAPIV1Tags converts the tag names into tags, skipping the empty and duplicate ones.
+func newAPIV1Tags(names []string) []model.Tag {
+	tags := []model.Tag{}
+	seen := map[string]struct{}{}
+	for _, name := range names {
+		name = database.NormalizeTagName(name)
+		if _, exist := seen[name]; exist || name == "" {
+			continue
+		}
+
+		seen[name] = struct{}{}
+		tags = append(tags, model.Tag{Name: name})
+	}
+
+	return tags
+}
+
+func newAPIV1Account(account model.Account) apiV1Account {
+	return apiV1Account{
+		ID:       account.ID,
+		Username: account.Username,
+		Owner:    account.Owner,
+	}
+}
+
+// apiV1Login is handler for POST /api/v1/auth/login
+func (h *handler) apiV1Login(req *apiV1Request) (interface{}, error) {
+	var request apiV1LoginRequest
+	if err := req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	account, err := h.authenticate(request.Username, request.Password)
+	if errors.Is(err, errUnknownUsername) || errors.Is(err, errWrongPassword) {
+		return nil, newAPIV1Error(http.StatusUnauthorized, "username and password don't match")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if request.Owner && !account.Owner {
+		return nil, newAPIV1Error(http.StatusForbidden, "account level is not sufficient as owner")
+	}
+
+	// Default account only lives for an hour
+	expTime := time.Hour
+	if request.Remember && account.ID != 0 {
+		expTime = time.Hour * 24 * 30
+	}
+
+	sessionID, err := h.createSession(account, expTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiV1LoginResponse{
+		Session: sessionID,
+		Expires: time.Now().Add(expTime).UTC().Truncate(time.Second),
+		Account: newAPIV1Account(account),
+	}, nil
+}
+
+// apiV1Logout is handler for POST /api/v1/auth/logout
+func (h *handler) apiV1Logout(req *apiV1Request) (interface{}, error) {
+	if req.SessionID != "" {
+		h.SessionCache.Delete(req.SessionID)
+	}
+
+	return nil, nil
+}
+
+// apiV1GetBookmarks is handler for GET /api/v1/bookmarks
+func (h *handler) apiV1GetBookmarks(req *apiV1Request) (interface{}, error) {
+	var query apiV1BookmarksQuery
+	if err := req.DecodeQuery(&query); err != nil {
+		return nil, err
+	}
+
+	searchOptions := database.GetBookmarksOptions{
+		Tags:         query.Tags,
+		ExcludedTags: query.ExcludedTags,
+		Keyword:      query.Keyword,
+		OrderMethod:  database.ByLastAdded,
+	}
+	searchOptions.ParseKeywordFilters()
+
+	var err error
+	if query.Order != "" {
+		searchOptions.OrderMethod, err = database.ParseOrderMethod(query.Order)
+		if err != nil {
+			return nil, newAPIV1Error(http.StatusBadRequest, "%v", err)
+		}
+	}
+
+	if query.After != "" && query.Before != "" {
+		return nil, newAPIV1Error(http.StatusBadRequest, "after and before can't be used together")
+	}
+
+	if searchOptions.After, err = decodeBookmarkCursor(query.After); err != nil {
+		return nil, err
+	}
+
+	if searchOptions.Before, err = decodeBookmarkCursor(query.Before); err != nil {
+		return nil, err
+	}
+
+	// Hybrid search returns only the most relevant page, so there are no cursors
+	hybridSearch := false
+	switch query.Mode {
+	case "", "keyword":
+	case "hybrid":
+		if searchOptions.After != nil || searchOptions.Before != nil {
+			return nil, newAPIV1Error(http.StatusBadRequest, "cursors are not supported in hybrid mode")
+		}
+
+		if err = h.prepareHybridSearch(&searchOptions); err != nil {
+			return nil, err
+		}
+		hybridSearch = len(searchOptions.SearchVector) > 0
+	default:
+		return nil, newAPIV1Error(http.StatusBadRequest, "unknown search mode %q", query.Mode)
+	}
+
+	result := apiV1BookmarkList{}
+	if query.Count {
+		nBookmarks, err := h.DB.GetBookmarksCount(searchOptions)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &nBookmarks
+	}
+
+	// Fetch one more bookmark to know whether there are more pages
+	limit := pageSize(query.Limit)
+	searchOptions.Limit = limit + 1
+	bookmarks, err := h.DB.GetBookmarks(searchOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := len(bookmarks) > limit
+	if hasMore && searchOptions.Before != nil {
+		bookmarks = bookmarks[1:]
+	} else if hasMore {
+		bookmarks = bookmarks[:limit]
+	}
+
+	if len(bookmarks) > 0 && !hybridSearch {
+		first, last := bookmarks[0], bookmarks[len(bookmarks)-1]
+		if searchOptions.Before != nil {
+			result.NextCursor = encodeBookmarkCursor(last)
+			if hasMore {
+				result.PrevCursor = encodeBookmarkCursor(first)
+			}
+		} else {
+			if hasMore {
+				result.NextCursor = encodeBookmarkCursor(last)
+			}
+			if searchOptions.After != nil {
+				result.PrevCursor = encodeBookmarkCursor(first)
+			}
+		}
+	}
+
+	if err = h.prepareBookmarks(bookmarks); err != nil {
+		return nil, err
+	}
+
+	result.Bookmarks = make([]apiV1Bookmark, len(bookmarks))
+	for i, book := range bookmarks {
+		result.Bookmarks[i] = newAPIV1Bookmark(book)
+	}
+
+	return result, nil
+}
+
+// encodeBookmarkCursor returns the opaque cursor of the bookmark position.
+func encodeBookmarkCursor(book model.Bookmark) string {
+	cursor, _ := json.Marshal(database.BookmarkCursor{ID: book.ID, Modified: book.Modified})
+	return base64.RawURLEncoding.EncodeToString(cursor)
+}
+
+// decodeBookmarkCursor parses the cursor created by encodeBookmarkCursor.
+// Empty cursor returns nil.
+func decodeBookmarkCursor(str string) (*database.BookmarkCursor, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	var cursor database.BookmarkCursor
+	raw, err := base64.RawURLEncoding.DecodeString(str)
+	if err == nil {
+		err = json.Unmarshal(raw, &cursor)
+	}
+
+	if err != nil || cursor.ID <= 0 {
+		return nil, newAPIV1Error(http.StatusBadRequest, "invalid cursor")
+	}
+
+	return &cursor, nil
+}
+
+// apiV1InsertBookmark is handler for POST /api/v1/bookmarks
+func (h *handler) apiV1InsertBookmark(req *apiV1Request) (interface{}, error) {
+	var request apiV1CreateBookmarkRequest
+	if err := req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	book := model.Bookmark{
+		Title:         request.Title,
+		Excerpt:       request.Excerpt,
+		Tags:          newAPIV1Tags(request.Tags),
+		CreateArchive: request.CreateArchive,
+		RenderJS:      request.RenderJS,
+	}
+
+	if request.Public {
+		book.Public = 1
+	}
+
+	// Clean up bookmark URL and make sure it's allowed to be fetched
+	var err error
+	book.URL, err = h.Canonicalizer.Canonicalize(request.URL)
+	if err != nil {
+		return nil, newAPIV1Error(http.StatusBadRequest, "failed to clean URL: %v", err)
+	}
+
+	if err = h.Fetcher.CheckURL(book.URL); err != nil {
+		return nil, newAPIV1Error(http.StatusBadRequest, "URL is not allowed: %v", err)
+	}
+
+	async := request.Async == nil || *request.Async
+	book, err = h.saveNewBookmark(book, async)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarks := []model.Bookmark{book}
+	if err = h.prepareBookmarks(bookmarks); err != nil {
+		return nil, err
+	}
+
+	return newAPIV1Bookmark(bookmarks[0]), nil
+}
+
+// apiV1GetBookmark is handler for GET /api/v1/bookmarks/:id
+func (h *handler) apiV1GetBookmark(req *apiV1Request) (interface{}, error) {
+	id, err := req.IntParam("id")
+	if err != nil {
+		return nil, err
+	}
+
+	book, err := h.getBookmarkByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newAPIV1Bookmark(book)
+	req.Writer.Header().Set("ETag", result.ETag)
+	return result, nil
+}
+
+// apiV1UpdateBookmark is handler for PATCH /api/v1/bookmarks/:id
+func (h *handler) apiV1UpdateBookmark(req *apiV1Request) (interface{}, error) {
+	id, err := req.IntParam("id")
+	if err != nil {
+		return nil, err
+	}
+
+	var request apiV1UpdateBookmarkRequest
+	if err = req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	// Make sure nobody else modifies the bookmark between checking
+	// its entity tag and saving it
+	h.bookmarkMx.Lock()
+	defer h.bookmarkMx.Unlock()
+
+	book, err := h.getBookmarkByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = matchETag(req, bookmarkETag(book)); err != nil {
+		return nil, err
+	}
+
+	// Set the submitted fields
+	if request.URL != nil {
+		book.URL, err = h.Canonicalizer.Canonicalize(*request.URL)
+		if err != nil {
+			return nil, newAPIV1Error(http.StatusBadRequest, "failed to clean URL: %v", err)
+		}
+
+		if err = h.Fetcher.CheckURL(book.URL); err != nil {
+			return nil, newAPIV1Error(http.StatusBadRequest, "URL is not allowed: %v", err)
+		}
+
+		if other, exist := h.DB.GetBookmark(0, book.URL); exist && other.ID != book.ID {
+			return nil, newAPIV1Error(http.StatusConflict, "URL is already saved as bookmark %d", other.ID)
+		}
+	}
+
+	if request.Title != nil {
+		if strings.TrimSpace(*request.Title) == "" {
+			return nil, newAPIV1Error(http.StatusBadRequest, "title must not be empty")
+		}
+		book.Title = *request.Title
+	}
+
+	if request.Excerpt != nil {
+		book.Excerpt = *request.Excerpt
+	}
+
+	if request.Public != nil {
+		book.Public = 0
+		if *request.Public {
+			book.Public = 1
+		}
+	}
+
+	if request.Tags != nil {
+		newTags := newAPIV1Tags(*request.Tags)
+		for i := range book.Tags {
+			book.Tags[i].Deleted = true
+		}
+
+		for _, newTag := range newTags {
+			for i, oldTag := range book.Tags {
+				if newTag.Name == oldTag.Name {
+					newTag.ID = oldTag.ID
+					book.Tags[i].Deleted = false
+					break
+				}
+			}
+
+			if newTag.ID == 0 {
+				book.Tags = append(book.Tags, newTag)
+			}
+		}
+	}
+
+	if _, err = h.DB.SaveBookmarks(book); err != nil {
+		return nil, err
+	}
+
+	// Fetch it again, so the modified time and tags match the database
+	book, err = h.getBookmarkByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newAPIV1Bookmark(book)
+	req.Writer.Header().Set("ETag", result.ETag)
+	return result, nil
+}
+
+// apiV1DeleteBookmark is handler for DELETE /api/v1/bookmarks/:id
+func (h *handler) apiV1DeleteBookmark(req *apiV1Request) (interface{}, error) {
+	id, err := req.IntParam("id")
+	if err != nil {
+		return nil, err
+	}
+
+	h.bookmarkMx.Lock()
+	defer h.bookmarkMx.Unlock()
+
+	book, err := h.getBookmarkByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = matchETag(req, bookmarkETag(book)); err != nil {
+		return nil, err
+	}
+
+	if err = h.DB.DeleteBookmarks(id); err != nil {
+		return nil, err
+	}
+
+	core.RemoveBookmarkFiles(h.Storage, id)
+	return nil, nil
+}
+
+// apiV1BulkBookmarks is handler for POST /api/v1/bookmarks/bulk
+func (h *handler) apiV1BulkBookmarks(req *apiV1Request) (interface{}, error) {
+	var request apiV1BulkRequest
+	if err := req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	return h.runBulk(request)
+}
+
+// runBulk applies the bulk actions, for both REST API v1 and the legacy API.
+// Invalid request is reported as *apiV1Error with status 400.
+func (h *handler) runBulk(request apiV1BulkRequest) (apiV1BulkResponse, error) {
+	if err := core.ValidateBulkActions(request.Actions); err != nil {
+		return apiV1BulkResponse{}, newAPIV1Error(http.StatusBadRequest, "%v", err)
+	}
+
+	if len(request.IDs) == 0 && request.Filter == nil {
+		return apiV1BulkResponse{}, newAPIV1Error(http.StatusBadRequest, "either ids or filter is required")
+	}
+
+	bulkRequest := core.BulkRequest{
+		DB:            h.DB,
+		Storage:       h.Storage,
+		IDs:           request.IDs,
+		Actions:       request.Actions,
+		Canonicalizer: h.Canonicalizer,
+		Fetcher:       h.Fetcher,
+		Extractors:    h.Extractors,
+		Browser:       h.Browser,
+		Quota:         h.StorageQuota,
+	}
+
+	if request.Filter != nil {
+		bulkRequest.Filter = database.GetBookmarksOptions{
+			Keyword:      request.Filter.Keyword,
+			Tags:         request.Filter.Tags,
+			ExcludedTags: request.Filter.ExcludedTags,
+		}
+		bulkRequest.Filter.ParseKeywordFilters()
+	}
+
+	// Refetching takes a while, so limit it to keep the request within server timeout
+	ids, err := core.SelectBulkIDs(bulkRequest)
+	if err != nil {
+		return apiV1BulkResponse{}, err
+	}
+
+	for _, action := range request.Actions {
+		if action.Type == core.BulkRefetch && len(ids) > maxBulkRefetch {
+			return apiV1BulkResponse{}, newAPIV1Error(http.StatusBadRequest,
+				"max %d bookmarks to refetch, use shiori bulk command for more", maxBulkRefetch)
+		}
+	}
+
+	// Make sure the bookmarks are not edited while the batches are saved
+	h.bookmarkMx.Lock()
+	defer h.bookmarkMx.Unlock()
+
+	bulkRequest.IDs = ids
+	results, err := core.RunBulk(bulkRequest)
+	if err != nil {
+		return apiV1BulkResponse{}, err
+	}
+
+	response := apiV1BulkResponse{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case core.BulkUpdated:
+			response.Updated++
+		case core.BulkDeleted:
+			response.Deleted++
+		default:
+			response.Failed++
+		}
+	}
+
+	return response, nil
+}
+
+// apiV1GetRelatedBookmarks is handler for GET /api/v1/bookmarks/:id/related
+func (h *handler) apiV1GetRelatedBookmarks(req *apiV1Request) (interface{}, error) {
+	id, err := req.IntParam("id")
+	if err != nil {
+		return nil, err
+	}
+
+	var query apiV1RelatedBookmarksQuery
+	if err = req.DecodeQuery(&query); err != nil {
+		return nil, err
+	}
+
+	if _, err = h.getBookmarkByID(id); err != nil {
+		return nil, err
+	}
+
+	related, err := h.getRelatedBookmarks(id, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]apiV1RelatedBookmark, len(related))
+	for i, item := range related {
+		result[i] = apiV1RelatedBookmark{
+			Bookmark: newAPIV1Bookmark(item.Bookmark),
+			Score:    item.Score,
+		}
+	}
+
+	return result, nil
+}
+
+// apiV1SuggestTags is handler for POST /api/v1/bookmarks/suggest-tags
+func (h *handler) apiV1SuggestTags(req *apiV1Request) (interface{}, error) {
+	var request apiV1SuggestTagsRequest
+	if err := req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	var book model.Bookmark
+	var err error
+	if request.ID != 0 {
+		book, err = h.getBookmarkByID(request.ID)
+	} else {
+		book, err = h.suggestionBookmark(apiSuggestTagsPayload(request))
+		if err != nil {
+			err = newAPIV1Error(http.StatusBadRequest, "%v", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return core.SuggestBookmarkTags(h.DB, book, request.Limit)
+}
+
+// apiV1GetTags is handler for GET /api/v1/tags
+func (h *handler) apiV1GetTags(req *apiV1Request) (interface{}, error) {
+	tags, err := h.DB.GetTags()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]apiV1Tag, len(tags))
+	for i, tag := range tags {
+		result[i] = newAPIV1Tag(tag)
+	}
+
+	return result, nil
+}
+
+// apiV1GetTag is handler for GET /api/v1/tags/:id
+func (h *handler) apiV1GetTag(req *apiV1Request) (interface{}, error) {
+	tag, err := h.getTagByID(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAPIV1Tag(tag), nil
+}
+
+// apiV1RenameTag is handler for PUT /api/v1/tags/:id
+func (h *handler) apiV1RenameTag(req *apiV1Request) (interface{}, error) {
+	tag, err := h.getTagByID(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var request apiV1RenameTagRequest
+	if err = req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	if database.NormalizeTagName(request.Name) == "" {
+		return nil, newAPIV1Error(http.StatusBadRequest, "name is required")
+	}
+
+	tag, err = core.RenameTag(h.DB, tag.ID, request.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAPIV1Tag(tag), nil
+}
+
+// apiV1UpdateTag is handler for PATCH /api/v1/tags/:id
+func (h *handler) apiV1UpdateTag(req *apiV1Request) (interface{}, error) {
+	tag, err := h.getTagByID(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var request apiV1UpdateTagRequest
+	if err = req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	if request.Name != nil && database.NormalizeTagName(*request.Name) == "" {
+		return nil, newAPIV1Error(http.StatusBadRequest, "name can't be empty")
+	}
+
+	color := tag.Color
+	if request.Color != nil {
+		if color, err = core.NormalizeTagColor(*request.Color); err != nil {
+			return nil, newAPIV1Error(http.StatusBadRequest, "%v", err)
+		}
+	}
+
+	if request.Name != nil {
+		if tag, err = core.RenameTag(h.DB, tag.ID, *request.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Color != nil || request.Description != nil {
+		tag.Color = color
+		if request.Description != nil {
+			tag.Description = strings.TrimSpace(*request.Description)
+		}
+
+		if err = h.DB.UpdateTag(tag); err != nil {
+			return nil, err
+		}
+	}
+
+	return newAPIV1Tag(tag), nil
+}
+
+// apiV1DeleteTag is handler for DELETE /api/v1/tags/:id
+func (h *handler) apiV1DeleteTag(req *apiV1Request) (interface{}, error) {
+	tag, err := h.getTagByID(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, h.DB.DeleteTags(tag.ID)
+}
+
+// apiV1MergeTags is handler for POST /api/v1/tags/merge
+func (h *handler) apiV1MergeTags(req *apiV1Request) (interface{}, error) {
+	var request apiV1MergeTagsRequest
+	if err := req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	if database.NormalizeTagName(request.Name) == "" {
+		return nil, newAPIV1Error(http.StatusBadRequest, "name is required")
+	}
+
+	for _, id := range request.IDs {
+		if _, exist := h.DB.GetTag(id, ""); !exist {
+			return nil, newAPIV1Error(http.StatusNotFound, "tag %d is not found", id)
+		}
+	}
+
+	tag, err := h.DB.MergeTags(request.Name, request.IDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAPIV1Tag(tag), nil
+}
+
+// apiV1DeleteOrphanTags is handler for DELETE /api/v1/tags/orphans
+func (h *handler) apiV1DeleteOrphanTags(req *apiV1Request) (interface{}, error) {
+	nRemoved, err := h.DB.DeleteOrphanTags()
+	if err != nil {
+		return nil, err
+	}
+
+	return apiV1DeleteOrphanTagsResponse{Removed: nRemoved}, nil
+}
+
+// getTagByID returns the tag whose ID is in the path, or not found error.
+func (h *handler) getTagByID(req *apiV1Request) (model.Tag, error) {
+	id, err := req.IntParam("id")
+	if err != nil {
+		return model.Tag{}, err
+	}
+
+	tag, exist := h.DB.GetTag(id, "")
+	if !exist {
+		return model.Tag{}, newAPIV1Error(http.StatusNotFound, "tag %d is not found", id)
+	}
+
+	return tag, nil
+}
+
+// apiV1GetRules is handler for GET /api/v1/rules
+func (h *handler) apiV1GetRules(req *apiV1Request) (interface{}, error) {
+	rules, err := h.DB.GetRules()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]apiV1Rule, len(rules))
+	for i, rule := range rules {
+		result[i] = apiV1Rule(rule)
+	}
+
+	return result, nil
+}
+
+// apiV1InsertRule is handler for POST /api/v1/rules
+func (h *handler) apiV1InsertRule(req *apiV1Request) (interface{}, error) {
+	return h.saveAPIV1Rule(req, 0)
+}
+
+// apiV1UpdateRule is handler for PUT /api/v1/rules/:id
+func (h *handler) apiV1UpdateRule(req *apiV1Request) (interface{}, error) {
+	rule, err := h.getRuleByID(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.saveAPIV1Rule(req, rule.ID)
+}
+
+// apiV1DeleteRule is handler for DELETE /api/v1/rules/:id
+func (h *handler) apiV1DeleteRule(req *apiV1Request) (interface{}, error) {
+	rule, err := h.getRuleByID(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, h.DB.DeleteRules(rule.ID)
+}
+
+// saveAPIV1Rule saves the rule in request body with specified ID, or as new rule if ID is 0.
+func (h *handler) saveAPIV1Rule(req *apiV1Request, id int) (interface{}, error) {
+	var request apiV1SaveRuleRequest
+	if err := req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	rule, err := core.CleanRule(model.Rule{
+		ID:              id,
+		Name:            request.Name,
+		Domain:          request.Domain,
+		PathPattern:     request.PathPattern,
+		TitleKeywords:   request.TitleKeywords,
+		ContentKeywords: request.ContentKeywords,
+		Tags:            request.Tags,
+		Public:          request.Public,
+		Enabled:         request.Enabled == nil || *request.Enabled,
+	})
+	if err != nil {
+		return nil, newAPIV1Error(http.StatusBadRequest, "%v", err)
+	}
+
+	rule, err = h.DB.SaveRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiV1Rule(rule), nil
+}
+
+// getRuleByID returns the tagging rule whose ID is in the path, or not found error.
+func (h *handler) getRuleByID(req *apiV1Request) (model.Rule, error) {
+	id, err := req.IntParam("id")
+	if err != nil {
+		return model.Rule{}, err
+	}
+
+	rules, err := h.DB.GetRules()
+	if err != nil {
+		return model.Rule{}, err
+	}
+
+	for _, rule := range rules {
+		if rule.ID == id {
+			return rule, nil
+		}
+	}
+
+	return model.Rule{}, newAPIV1Error(http.StatusNotFound, "rule %d is not found", id)
+}
+
+// apiV1GetHighlights is handler for GET /api/v1/bookmarks/:id/highlights
+func (h *handler) apiV1GetHighlights(req *apiV1Request) (interface{}, error) {
+	id, err := req.IntParam("id")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = h.getBookmarkByID(id); err != nil {
+		return nil, err
+	}
+
+	highlights, err := h.DB.GetHighlights(database.GetHighlightsOptions{BookmarkIDs: []int{id}})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]apiV1Highlight, len(highlights))
+	for i, highlight := range highlights {
+		result[i] = apiV1Highlight(highlight)
+	}
+
+	return result, nil
+}
+
+// apiV1InsertHighlight is handler for POST /api/v1/bookmarks/:id/highlights
+func (h *handler) apiV1InsertHighlight(req *apiV1Request) (interface{}, error) {
+	id, err := req.IntParam("id")
+	if err != nil {
+		return nil, err
+	}
+
+	var request apiV1CreateHighlightRequest
+	if err = req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	book, err := h.getBookmarkByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	highlight, err := core.CleanHighlight(model.Highlight{
+		BookmarkID: id,
+		Quote:      request.Quote,
+		Prefix:     request.Prefix,
+		Suffix:     request.Suffix,
+		Start:      request.Start,
+		End:        request.End,
+		Note:       request.Note,
+	})
+	if err == nil {
+		err = core.CheckHighlightAnchor(highlight, book)
+	}
+
+	if err != nil {
+		return nil, newAPIV1Error(http.StatusBadRequest, "%v", err)
+	}
+
+	highlight, err = h.DB.SaveHighlight(highlight)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiV1Highlight(highlight), nil
+}
+
+// apiV1UpdateHighlight is handler for PUT /api/v1/bookmarks/:id/highlights/:highlightId
+func (h *handler) apiV1UpdateHighlight(req *apiV1Request) (interface{}, error) {
+	highlight, err := h.getHighlightByID(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var request apiV1UpdateHighlightRequest
+	if err = req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	highlight.Note = request.Note
+	if highlight, err = core.CleanHighlight(highlight); err != nil {
+		return nil, newAPIV1Error(http.StatusBadRequest, "%v", err)
+	}
+
+	highlight, err = h.DB.SaveHighlight(highlight)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiV1Highlight(highlight), nil
+}
+
+// apiV1DeleteHighlight is handler for DELETE /api/v1/bookmarks/:id/highlights/:highlightId
+func (h *handler) apiV1DeleteHighlight(req *apiV1Request) (interface{}, error) {
+	highlight, err := h.getHighlightByID(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, h.DB.DeleteHighlights(highlight.ID)
+}
+
+// getHighlightByID returns the highlight whose bookmark and ID are in the path, or not found error.
+func (h *handler) getHighlightByID(req *apiV1Request) (model.Highlight, error) {
+	bookID, err := req.IntParam("id")
+	if err != nil {
+		return model.Highlight{}, err
+	}
+
+	id, err := req.IntParam("highlightId")
+	if err != nil {
+		return model.Highlight{}, err
+	}
+
+	highlights, err := h.DB.GetHighlights(database.GetHighlightsOptions{
+		IDs:         []int{id},
+		BookmarkIDs: []int{bookID},
+	})
+	if err != nil {
+		return model.Highlight{}, err
+	}
+
+	if len(highlights) == 0 {
+		return model.Highlight{}, newAPIV1Error(http.StatusNotFound, "highlight %d is not found", id)
+	}
+
+	return highlights[0], nil
+}
+
+// apiV1GetAccounts is handler for GET /api/v1/accounts
+func (h *handler) apiV1GetAccounts(req *apiV1Request) (interface{}, error) {
+	accounts, err := h.DB.GetAccounts(database.GetAccountsOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]apiV1Account, len(accounts))
+	for i, account := range accounts {
+		result[i] = newAPIV1Account(account)
+	}
+
+	return result, nil
+}
+
+// apiV1InsertAccount is handler for POST /api/v1/accounts
+func (h *handler) apiV1InsertAccount(req *apiV1Request) (interface{}, error) {
+	var request apiV1CreateAccountRequest
+	if err := req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	if _, exist := h.DB.GetAccount(request.Username); exist {
+		return nil, newAPIV1Error(http.StatusConflict, "username %s already exists", request.Username)
+	}
+
+	err := h.DB.SaveAccount(model.Account{
+		Username: request.Username,
+		Password: request.Password,
+		Owner:    request.Owner,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account, _ := h.DB.GetAccount(request.Username)
+	return newAPIV1Account(account), nil
+}
+
+// apiV1UpdateAccount is handler for PUT /api/v1/accounts/:username
+func (h *handler) apiV1UpdateAccount(req *apiV1Request) (interface{}, error) {
+	var request apiV1UpdateAccountRequest
+	if err := req.DecodeBody(&request); err != nil {
+		return nil, err
+	}
+
+	username := req.Param("username")
+	account, exist := h.DB.GetAccount(username)
+	if !exist {
+		return nil, newAPIV1Error(http.StatusNotFound, "account %s is not found", username)
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(request.OldPassword))
+	if err != nil {
+		return nil, newAPIV1Error(http.StatusForbidden, "old password doesn't match")
+	}
+
+	account.Password = request.NewPassword
+	account.Owner = request.Owner
+	if err = h.DB.SaveAccount(account); err != nil {
+		return nil, err
+	}
+
+	h.clearUserSessions(username)
+	return newAPIV1Account(account), nil
+}
+
+// apiV1DeleteAccount is handler for DELETE /api/v1/accounts/:username
+func (h *handler) apiV1DeleteAccount(req *apiV1Request) (interface{}, error) {
+	username := req.Param("username")
+	if _, exist := h.DB.GetAccount(username); !exist {
+		return nil, newAPIV1Error(http.StatusNotFound, "account %s is not found", username)
+	}
+
+	if err := h.DB.DeleteAccounts(username); err != nil {
+		return nil, err
+	}
+
+	h.clearUserSessions(username)
+	return nil, nil
+}
+
+// apiV1OpenAPI is handler for GET /api/v1/openapi.json
+func (h *handler) apiV1OpenAPI(req *apiV1Request) (interface{}, error) {
+	router := apiV1Router{handler: h, routes: h.apiV1Routes()}
+	return router.openAPIDocument(path.Join(h.RootPath, "/api/v1")), nil
+}
@@ -6,9 +6,7 @@ import (
 	"log"
 	"math"
 	"net/http"
-	"os"
 	"path"
-	fp "path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,22 +15,28 @@ import (
 	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/database"
 	"github.com/go-shiori/shiori/internal/model"
-	"github.com/gofrs/uuid"
+	"github.com/go-shiori/shiori/internal/storage"
 	"github.com/julienschmidt/httprouter"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func downloadBookmarkContent(book *model.Bookmark, dataDir string, request *http.Request) (*model.Bookmark, error) {
-	content, contentType, err := core.DownloadBookmark(book.URL)
+func (h *handler) downloadBookmarkContent(book *model.Bookmark) (*model.Bookmark, error) {
+	content, contentType, err := h.Fetcher.DownloadBookmark(book.URL)
 	if err != nil {
-		return nil, fmt.Errorf("error downloading bookmark: %s", err)
+		return book, fmt.Errorf("error downloading bookmark: %s", err)
 	}
 
 	processRequest := core.ProcessRequest{
-		DataDir:     dataDir,
-		Bookmark:    *book,
-		Content:     content,
-		ContentType: contentType,
+		DB:            h.DB,
+		Storage:       h.Storage,
+		Bookmark:      *book,
+		Content:       content,
+		ContentType:   contentType,
+		Canonicalizer: h.Canonicalizer,
+		Fetcher:       h.Fetcher,
+		Extractors:    h.Extractors,
+		Browser:       h.Browser,
+		Quota:         h.StorageQuota,
 	}
 
 	result, isFatalErr, err := core.ProcessBookmark(processRequest)
@@ -58,79 +62,35 @@ func (h *handler) apiLogin(w http.ResponseWriter, r *http.Request, ps httprouter
 	err := json.NewDecoder(r.Body).Decode(&request)
 	checkError(err)
 
-	// Prepare function to generate session
-	genSession := func(account model.Account, expTime time.Duration) {
-		// Create session ID
-		sessionID, err := uuid.NewV4()
-		checkError(err)
-
-		// Save session ID to cache
-		strSessionID := sessionID.String()
-		h.SessionCache.Set(strSessionID, account, expTime)
-
-		// Save user's session IDs to cache as well
-		// useful for mass logout
-		sessionIDs := []string{strSessionID}
-		if val, found := h.UserCache.Get(request.Username); found {
-			sessionIDs = val.([]string)
-			sessionIDs = append(sessionIDs, strSessionID)
-		}
-		h.UserCache.Set(request.Username, sessionIDs, -1)
-
-		// Send login result
-		account.Password = ""
-		loginResult := struct {
-			Session string        `json:"session"`
-			Account model.Account `json:"account"`
-		}{strSessionID, account}
-
-		w.Header().Set("Content-Type", "application/json")
-		err = json.NewEncoder(w).Encode(&loginResult)
-		checkError(err)
-	}
-
-	// Check if user's database is empty or there are no owner.
-	// If yes, and user uses default account, let him in.
-	searchOptions := database.GetAccountsOptions{
-		Owner: true,
-	}
-
-	accounts, err := h.DB.GetAccounts(searchOptions)
+	// Authenticate the account
+	account, err := h.authenticate(request.Username, request.Password)
 	checkError(err)
 
-	if h.DisableAuth || (len(accounts) == 0 && request.Username == "shiori" && request.Password == "gopher") {
-		genSession(model.Account{
-			Username: "shiori",
-			Owner:    true,
-		}, time.Hour)
-		return
-	}
-
-	// Get account data from database
-	account, exist := h.DB.GetAccount(request.Username)
-	if !exist {
-		panic(fmt.Errorf("username doesn't exist"))
-	}
-
-	// Compare password with database
-	err = bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(request.Password))
-	if err != nil {
-		panic(fmt.Errorf("username and password don't match"))
-	}
-
 	// If login request is as owner, make sure this account is owner
 	if request.Owner && !account.Owner {
 		panic(fmt.Errorf("account level is not sufficient as owner"))
 	}
 
-	// Calculate expiration time
+	// Calculate expiration time. Default account only lives for an hour.
 	expTime := time.Hour
-	if request.Remember {
+	if request.Remember && account.ID != 0 {
 		expTime = time.Hour * 24 * 30
 	}
 
 	// Create session
-	genSession(account, expTime)
+	sessionID, err := h.createSession(account, expTime)
+	checkError(err)
+
+	// Send login result
+	account.Password = ""
+	loginResult := struct {
+		Session string        `json:"session"`
+		Account model.Account `json:"account"`
+	}{sessionID, account}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(&loginResult)
+	checkError(err)
 }
 
 // apiLogout is handler for POST /api/logout
@@ -144,6 +104,15 @@ func (h *handler) apiLogout(w http.ResponseWriter, r *http.Request, ps httproute
 	fmt.Fprint(w, 1)
 }
 
+const (
+	defaultPageSize = 30
+	maxPageSize     = 100
+
+	// maxEPUBBookmarks is the maximum number of bookmarks exported as EPUB book,
+	// since their content and images are kept in memory while it's written.
+	maxEPUBBookmarks = 100
+)
+
 // apiGetBookmarks is handler for GET /api/bookmarks
 func (h *handler) apiGetBookmarks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	// Make sure session still valid
@@ -171,39 +140,47 @@ func (h *handler) apiGetBookmarks(w http.ResponseWriter, r *http.Request, ps htt
 		page = 1
 	}
 
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	limit = pageSize(limit)
+
+	orderMethod := database.ByLastAdded
+	if strOrder := r.URL.Query().Get("order"); strOrder != "" {
+		orderMethod, err = database.ParseOrderMethod(strOrder)
+		checkError(err)
+	}
+
 	// Prepare filter for database
 	searchOptions := database.GetBookmarksOptions{
 		Tags:         tags,
 		ExcludedTags: excludedTags,
 		Keyword:      keyword,
-		Limit:        30,
-		Offset:       (page - 1) * 30,
-		OrderMethod:  database.ByLastAdded,
+		Limit:        limit,
+		Offset:       (page - 1) * limit,
+		OrderMethod:  orderMethod,
+	}
+	searchOptions.ParseKeywordFilters()
+
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "keyword":
+	case "hybrid":
+		err = h.prepareHybridSearch(&searchOptions)
+		checkError(err)
+	default:
+		panic(fmt.Errorf("unknown search mode %q", mode))
 	}
 
 	// Calculate max page
 	nBookmarks, err := h.DB.GetBookmarksCount(searchOptions)
 	checkError(err)
-	maxPage := int(math.Ceil(float64(nBookmarks) / 30))
+	maxPage := int(math.Ceil(float64(nBookmarks) / float64(limit)))
 
 	// Fetch all matching bookmarks
 	bookmarks, err := h.DB.GetBookmarks(searchOptions)
 	checkError(err)
 
 	// Get image URL for each bookmark, and check if it has archive
-	for i := range bookmarks {
-		strID := strconv.Itoa(bookmarks[i].ID)
-		imgPath := fp.Join(h.DataDir, "thumb", strID)
-		archivePath := fp.Join(h.DataDir, "archive", strID)
-
-		if fileExists(imgPath) {
-			bookmarks[i].ImageURL = path.Join(h.RootPath, "bookmark", strID, "thumb")
-		}
-
-		if fileExists(archivePath) {
-			bookmarks[i].HasArchive = true
-		}
-	}
+	err = h.prepareBookmarks(bookmarks)
+	checkError(err)
 
 	// Return JSON response
 	resp := map[string]interface{}{
@@ -217,6 +194,19 @@ func (h *handler) apiGetBookmarks(w http.ResponseWriter, r *http.Request, ps htt
 	checkError(err)
 }
 
+// pageSize returns the number of bookmarks in a single page. Zero means
+// the default size, while the larger one is limited to the max size.
+func pageSize(limit int) int {
+	switch {
+	case limit <= 0:
+		return defaultPageSize
+	case limit > maxPageSize:
+		return maxPageSize
+	default:
+		return limit
+	}
+}
+
 // apiGetTags is handler for GET /api/tags
 func (h *handler) apiGetTags(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	// Make sure session still valid
@@ -243,8 +233,8 @@ func (h *handler) apiRenameTag(w http.ResponseWriter, r *http.Request, ps httpro
 	err = json.NewDecoder(r.Body).Decode(&tag)
 	checkError(err)
 
-	// Update name
-	err = h.DB.RenameTag(tag.ID, tag.Name)
+	// Update name, merging it with the existing tag if needed
+	_, err = core.RenameTag(h.DB, tag.ID, tag.Name)
 	checkError(err)
 
 	fmt.Fprint(w, 1)
@@ -257,6 +247,7 @@ type apiInsertBookmarkPayload struct {
 	Excerpt       string      `json:"excerpt"`
 	Tags          []model.Tag `json:"tags"`
 	CreateArchive bool        `json:"createArchive"`
+	RenderJS      bool        `json:"renderJS"`
 	MakePublic    int         `json:"public"`
 	Async         bool        `json:"async"`
 }
@@ -288,18 +279,38 @@ func (h *handler) apiInsertBookmark(w http.ResponseWriter, r *http.Request, ps h
 		Tags:          payload.Tags,
 		Public:        payload.MakePublic,
 		CreateArchive: payload.CreateArchive,
+		RenderJS:      payload.RenderJS,
 	}
 
-	// Create bookmark ID
-	book.ID, err = h.DB.CreateNewID("bookmark")
+	// Clean up bookmark URL
+	book.URL, err = h.Canonicalizer.Canonicalize(book.URL)
 	if err != nil {
-		panic(fmt.Errorf("failed to create ID: %v", err))
+		panic(newHTTPError(http.StatusBadRequest, "failed to clean URL: %v", err))
 	}
 
-	// Clean up bookmark URL
-	book.URL, err = core.RemoveUTMParams(book.URL)
+	// Make sure the URL is allowed to be fetched
+	if err = h.Fetcher.CheckURL(book.URL); err != nil {
+		panic(newHTTPError(http.StatusBadRequest, "URL is not allowed: %v", err))
+	}
+
+	// Save the bookmark and download its content
+	result, err := h.saveNewBookmark(*book, payload.Async)
+	checkError(err)
+
+	// Return the new bookmark
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(result)
+	checkError(err)
+}
+
+// saveNewBookmark saves the new bookmark with clean URL, then downloads its content.
+// If async is true, the content is downloaded in background after the bookmark saved.
+func (h *handler) saveNewBookmark(book model.Bookmark, async bool) (model.Bookmark, error) {
+	// Create bookmark ID
+	var err error
+	book.ID, err = h.DB.CreateNewID("bookmark")
 	if err != nil {
-		panic(fmt.Errorf("failed to clean URL: %v", err))
+		return book, fmt.Errorf("failed to create ID: %v", err)
 	}
 
 	// Make sure bookmark's title not empty
@@ -307,35 +318,51 @@ func (h *handler) apiInsertBookmark(w http.ResponseWriter, r *http.Request, ps h
 		book.Title = book.URL
 	}
 
-	if !payload.Async {
-		book, err = downloadBookmarkContent(book, h.DataDir, r)
+	if !async {
+		var result *model.Bookmark
+		result, err = h.downloadBookmarkContent(&book)
 		if err != nil {
 			log.Printf("error downloading boorkmark: %s", err)
 		}
+		book = *result
 	}
 
 	// Save bookmark to database
-	results, err := h.DB.SaveBookmarks(*book)
+	h.applyRules(&book)
+	results, err := h.DB.SaveBookmarks(book)
 	if err != nil || len(results) == 0 {
-		panic(fmt.Errorf("failed to save bookmark: %v", err))
+		return book, fmt.Errorf("failed to save bookmark: %v", err)
+	}
+
+	if err := h.saveArchiveSizes(results[0]); err != nil {
+		log.Printf("error saving archive size: %s", err)
+	}
+
+	if !async {
+		h.indexRelated(results[0])
+		h.embedBookmarks(results[0])
 	}
 
-	if payload.Async {
+	if async {
 		go func() {
-			bookmark, err := downloadBookmarkContent(book, h.DataDir, r)
+			bookmark, err := h.downloadBookmarkContent(&book)
 			if err != nil {
 				log.Printf("error downloading boorkmark: %s", err)
 			}
-			if _, err := h.DB.SaveBookmarks(*bookmark); err != nil {
+			h.applyRules(bookmark)
+			saved, err := h.DB.SaveBookmarks(*bookmark)
+			if err != nil {
 				log.Printf("failed to save bookmark: %s", err)
 			}
+			if err := h.saveArchiveSizes(*bookmark); err != nil {
+				log.Printf("error saving archive size: %s", err)
+			}
+			h.indexRelated(saved...)
+			h.embedBookmarks(saved...)
 		}()
 	}
 
-	// Return the new bookmark
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(results[0])
-	checkError(err)
+	return results[0], nil
 }
 
 // apiDeleteBookmarks is handler for DELETE /api/bookmark
@@ -353,14 +380,9 @@ func (h *handler) apiDeleteBookmark(w http.ResponseWriter, r *http.Request, ps h
 	err = h.DB.DeleteBookmarks(ids...)
 	checkError(err)
 
-	// Delete thumbnail image and archives from local disk
+	// Delete thumbnail image and archives from storage
 	for _, id := range ids {
-		strID := strconv.Itoa(id)
-		imgPath := fp.Join(h.DataDir, "thumb", strID)
-		archivePath := fp.Join(h.DataDir, "archive", strID)
-
-		os.Remove(imgPath)
-		os.Remove(archivePath)
+		core.RemoveBookmarkFiles(h.Storage, id)
 	}
 
 	fmt.Fprint(w, 1)
@@ -396,15 +418,22 @@ func (h *handler) apiUpdateBookmark(w http.ResponseWriter, r *http.Request, ps h
 
 	// Set new bookmark data
 	book := bookmarks[0]
-	book.URL = request.URL
 	book.Title = request.Title
 	book.Excerpt = request.Excerpt
 	book.Public = request.Public
 
-	// Clean up bookmark URL
-	book.URL, err = core.RemoveUTMParams(book.URL)
-	if err != nil {
-		panic(fmt.Errorf("failed to clean URL: %v", err))
+	// Clean up and check the URL only if it's changed, so bookmark whose URL
+	// can't be fetched anymore can still be edited. If it's fetched later,
+	// the address is checked again when it's dialed.
+	if request.URL != book.URL {
+		book.URL, err = h.Canonicalizer.Canonicalize(request.URL)
+		if err != nil {
+			panic(newHTTPError(http.StatusBadRequest, "failed to clean URL: %v", err))
+		}
+
+		if err = h.Fetcher.CheckURL(book.URL); err != nil {
+			panic(newHTTPError(http.StatusBadRequest, "URL is not allowed: %v", err))
+		}
 	}
 
 	// Set new tags
@@ -452,6 +481,7 @@ func (h *handler) apiUpdateCache(w http.ResponseWriter, r *http.Request, ps http
 		IDs           []int `json:"ids"`
 		KeepMetadata  bool  `json:"keepMetadata"`
 		CreateArchive bool  `json:"createArchive"`
+		RenderJS      bool  `json:"renderJS"`
 	}{}
 
 	err = json.NewDecoder(r.Body).Decode(&request)
@@ -489,6 +519,7 @@ func (h *handler) apiUpdateCache(w http.ResponseWriter, r *http.Request, ps http
 
 		// Mark whether book will be archived
 		book.CreateArchive = request.CreateArchive
+		book.RenderJS = request.RenderJS
 
 		go func(i int, book model.Bookmark, keepMetadata bool) {
 			// Make sure to finish the WG
@@ -501,19 +532,25 @@ func (h *handler) apiUpdateCache(w http.ResponseWriter, r *http.Request, ps http
 			}()
 
 			// Download data from internet
-			content, contentType, err := core.DownloadBookmark(book.URL)
+			content, contentType, err := h.Fetcher.DownloadBookmark(book.URL)
 			if err != nil {
 				chProblem <- book.ID
 				return
 			}
 
 			request := core.ProcessRequest{
-				DataDir:     h.DataDir,
-				Bookmark:    book,
-				Content:     content,
-				ContentType: contentType,
-				KeepTitle:   keepMetadata,
-				KeepExcerpt: keepMetadata,
+				DB:            h.DB,
+				Storage:       h.Storage,
+				Bookmark:      book,
+				Content:       content,
+				ContentType:   contentType,
+				KeepTitle:     keepMetadata,
+				KeepExcerpt:   keepMetadata,
+				Canonicalizer: h.Canonicalizer,
+				Fetcher:       h.Fetcher,
+				Extractors:    h.Extractors,
+				Browser:       h.Browser,
+				Quota:         h.StorageQuota,
 			}
 
 			book, _, err = core.ProcessBookmark(request)
@@ -549,9 +586,15 @@ func (h *handler) apiUpdateCache(w http.ResponseWriter, r *http.Request, ps http
 	close(chDone)
 
 	// Update database
-	_, err = h.DB.SaveBookmarks(bookmarks...)
+	saved, err := h.DB.SaveBookmarks(bookmarks...)
+	checkError(err)
+
+	err = h.saveArchiveSizes(bookmarks...)
 	checkError(err)
 
+	h.indexRelated(saved...)
+	h.embedBookmarks(saved...)
+
 	// Return new saved result
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(&bookmarks)
@@ -615,12 +658,13 @@ func (h *handler) apiUpdateBookmarkTags(w http.ResponseWriter, r *http.Request,
 	// Get image URL for each bookmark
 	for i := range bookmarks {
 		strID := strconv.Itoa(bookmarks[i].ID)
-		imgPath := fp.Join(h.DataDir, "thumb", strID)
 		imgURL := path.Join(h.RootPath, "bookmark", strID, "thumb")
 
-		if fileExists(imgPath) {
+		if storage.Exists(h.Storage, core.ThumbnailName(bookmarks[i].ID, core.ThumbnailGrid)) {
 			bookmarks[i].ImageURL = imgURL
 		}
+
+		bookmarks[i].FaviconURL = core.FaviconURL(h.Storage, h.RootPath, bookmarks[i].URL)
 	}
 
 	// Return new saved result
@@ -628,6 +672,483 @@ func (h *handler) apiUpdateBookmarkTags(w http.ResponseWriter, r *http.Request,
 	checkError(err)
 }
 
+// apiBulkBookmarks is handler for POST /api/bookmarks/bulk. It works the same
+// way as POST /api/v1/bookmarks/bulk.
+func (h *handler) apiBulkBookmarks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure session still valid
+	err := h.validateSession(r)
+	checkError(err)
+
+	// Decode request
+	request := apiV1BulkRequest{}
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		panic(newHTTPError(http.StatusBadRequest, "failed to decode request: %v", err))
+	}
+
+	response, err := h.runBulk(request)
+	if apiErr, ok := err.(*apiV1Error); ok {
+		panic(newHTTPError(apiErr.Status, "%s", apiErr.Message))
+	}
+	checkError(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(&response)
+	checkError(err)
+}
+
+// apiSuggestTagsPayload is the request for suggesting tags. Either ID of
+// saved bookmark, or the text of the new one must be specified.
+type apiSuggestTagsPayload struct {
+	ID      int      `json:"id"`
+	Title   string   `json:"title"`
+	Excerpt string   `json:"excerpt"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+	Limit   int      `json:"limit"`
+}
+
+// apiSuggestTags is handler for POST /api/bookmarks/suggest-tags
+func (h *handler) apiSuggestTags(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure session still valid
+	err := h.validateSession(r)
+	checkError(err)
+
+	// Decode request
+	payload := apiSuggestTagsPayload{}
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	checkError(err)
+
+	book, err := h.suggestionBookmark(payload)
+	checkError(err)
+
+	suggestions, err := core.SuggestBookmarkTags(h.DB, book, payload.Limit)
+	checkError(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(&suggestions)
+	checkError(err)
+}
+
+// suggestionBookmark returns the bookmark whose tags will be suggested. Saved bookmark
+// is loaded with its content, while the new one is built from the submitted text.
+func (h *handler) suggestionBookmark(payload apiSuggestTagsPayload) (model.Bookmark, error) {
+	if payload.ID != 0 {
+		bookmarks, err := h.DB.GetBookmarks(database.GetBookmarksOptions{
+			IDs:         []int{payload.ID},
+			WithContent: true,
+		})
+		if err != nil {
+			return model.Bookmark{}, err
+		}
+
+		if len(bookmarks) == 0 {
+			return model.Bookmark{}, fmt.Errorf("bookmark %d is not found", payload.ID)
+		}
+
+		return bookmarks[0], nil
+	}
+
+	if strings.TrimSpace(payload.Title+payload.Excerpt+payload.Content) == "" {
+		return model.Bookmark{}, fmt.Errorf("either bookmark ID or its text is required")
+	}
+
+	book := model.Bookmark{
+		Title:   payload.Title,
+		Excerpt: payload.Excerpt,
+		Content: payload.Content,
+	}
+
+	for _, tag := range payload.Tags {
+		book.Tags = append(book.Tags, model.Tag{Name: tag})
+	}
+
+	return book, nil
+}
+
+// relatedBookmark is an item in the related bookmarks.
+type relatedBookmark struct {
+	Bookmark model.Bookmark `json:"bookmark"`
+	Score    float64        `json:"score"`
+}
+
+// apiGetRelatedBookmarks is handler for GET /api/bookmarks/:id/related
+func (h *handler) apiGetRelatedBookmarks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure session still valid
+	err := h.validateSession(r)
+	checkError(err)
+
+	// Get bookmark ID and number of related bookmarks
+	id, err := strconv.Atoi(ps.ByName("id"))
+	checkError(err)
+
+	if _, exist := h.DB.GetBookmark(id, ""); !exist {
+		panic(fmt.Errorf("bookmark not found"))
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	related, err := h.getRelatedBookmarks(id, limit)
+	checkError(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(&related)
+	checkError(err)
+}
+
+// apiGetHighlights is handler for GET /api/bookmarks/:id/highlights
+func (h *handler) apiGetHighlights(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure session still valid
+	err := h.validateSession(r)
+	checkError(err)
+
+	id, err := strconv.Atoi(ps.ByName("id"))
+	checkError(err)
+
+	if _, exist := h.DB.GetBookmark(id, ""); !exist {
+		panic(fmt.Errorf("bookmark not found"))
+	}
+
+	highlights, err := h.DB.GetHighlights(database.GetHighlightsOptions{BookmarkIDs: []int{id}})
+	checkError(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(&highlights)
+	checkError(err)
+}
+
+// getRelatedBookmarks returns the bookmarks most similar to the bookmark with specified
+// ID, most similar first. The index is synced in background, see cmd.runIndexSync.
+func (h *handler) getRelatedBookmarks(id int, limit int) ([]relatedBookmark, error) {
+	if h.RelatedIndex == nil {
+		return nil, fmt.Errorf("related bookmarks index is not available")
+	}
+
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	results := h.RelatedIndex.Related(id, limit)
+	if len(results) == 0 {
+		return []relatedBookmark{}, nil
+	}
+
+	ids := make([]int, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+
+	bookmarks, err := h.DB.GetBookmarks(database.GetBookmarksOptions{IDs: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.prepareBookmarks(bookmarks); err != nil {
+		return nil, err
+	}
+
+	mapBookmarks := map[int]model.Bookmark{}
+	for _, book := range bookmarks {
+		mapBookmarks[book.ID] = book
+	}
+
+	related := []relatedBookmark{}
+	for _, result := range results {
+		if book, exist := mapBookmarks[result.ID]; exist {
+			related = append(related, relatedBookmark{Bookmark: book, Score: result.Score})
+		}
+	}
+
+	return related, nil
+}
+
+// apiGetDuplicates is handler for GET /api/duplicates
+func (h *handler) apiGetDuplicates(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure session still valid
+	err := h.validateSession(r)
+	checkError(err)
+
+	// Fetch all bookmarks and group the duplicates
+	bookmarks, err := h.DB.GetBookmarks(database.GetBookmarksOptions{})
+	checkError(err)
+
+	groups := h.Canonicalizer.FindDuplicates(bookmarks)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(&groups)
+	checkError(err)
+}
+
+// apiMergeDuplicates is handler for POST /api/duplicates
+func (h *handler) apiMergeDuplicates(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure session still valid
+	err := h.validateSession(r)
+	checkError(err)
+
+	// Decode request. If no keys submitted, all duplicates will be merged.
+	request := struct {
+		Keys []string `json:"keys"`
+	}{}
+
+	err = json.NewDecoder(r.Body).Decode(&request)
+	checkError(err)
+
+	mapKeys := map[string]struct{}{}
+	for _, key := range request.Keys {
+		mapKeys[key] = struct{}{}
+	}
+
+	// Fetch all bookmarks and group the duplicates
+	bookmarks, err := h.DB.GetBookmarks(database.GetBookmarksOptions{WithContent: true})
+	checkError(err)
+
+	// Merge each group
+	mergedBookmarks := []model.Bookmark{}
+	for _, group := range h.Canonicalizer.FindDuplicates(bookmarks) {
+		if _, requested := mapKeys[group.Key]; len(mapKeys) > 0 && !requested {
+			continue
+		}
+
+		book, removedIDs := core.MergeDuplicates(group)
+		results, err := h.DB.SaveBookmarks(book)
+		checkError(err)
+
+		err = h.DB.DeleteBookmarks(removedIDs...)
+		checkError(err)
+
+		core.MergeDuplicateFiles(h.Storage, book.ID, removedIDs)
+		mergedBookmarks = append(mergedBookmarks, results[0])
+	}
+
+	// Return the merged bookmarks
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(&mergedBookmarks)
+	checkError(err)
+}
+
+// brokenLink is an item in the broken links report.
+type brokenLink struct {
+	Bookmark model.Bookmark  `json:"bookmark"`
+	Check    model.LinkCheck `json:"check"`
+}
+
+// apiGetBrokenLinks is handler for GET /api/links/broken
+func (h *handler) apiGetBrokenLinks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure session still valid
+	err := h.validateSession(r)
+	checkError(err)
+
+	// Fetch the latest check of each bookmark which is failed
+	checks, err := h.DB.GetLinkChecks(database.GetLinkChecksOptions{
+		LatestOnly: true,
+		BrokenOnly: true,
+	})
+	checkError(err)
+
+	ids := make([]int, len(checks))
+	for i, check := range checks {
+		ids[i] = check.BookmarkID
+	}
+
+	report := []brokenLink{}
+	if len(ids) > 0 {
+		bookmarks, err := h.DB.GetBookmarks(database.GetBookmarksOptions{IDs: ids})
+		checkError(err)
+
+		// Mark which bookmarks are lost, i.e. don't have archive to fall back to
+		for i := range bookmarks {
+			bookmarks[i].HasArchive = storage.Exists(h.Storage, core.ArchiveName(bookmarks[i].ID))
+		}
+
+		err = h.applyLinkStatus(bookmarks)
+		checkError(err)
+
+		mapBookmarks := map[int]model.Bookmark{}
+		for _, book := range bookmarks {
+			mapBookmarks[book.ID] = book
+		}
+
+		for _, check := range checks {
+			if book, exist := mapBookmarks[check.BookmarkID]; exist {
+				report = append(report, brokenLink{Bookmark: book, Check: check})
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(&report)
+	checkError(err)
+}
+
+// apiGetLinkHistory is handler for GET /api/links/history
+func (h *handler) apiGetLinkHistory(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure session still valid
+	err := h.validateSession(r)
+	checkError(err)
+
+	// Get bookmark ID
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id <= 0 {
+		panic(fmt.Errorf("bookmark ID is not valid"))
+	}
+
+	// Fetch the check history, newest first
+	checks, err := h.DB.GetLinkChecks(database.GetLinkChecksOptions{
+		BookmarkIDs: []int{id},
+		Limit:       100,
+	})
+	checkError(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(&checks)
+	checkError(err)
+}
+
+// apiCheckLinks is handler for POST /api/links/check
+func (h *handler) apiCheckLinks(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure session still valid
+	err := h.validateSession(r)
+	checkError(err)
+
+	// Decode request
+	ids := []int{}
+	err = json.NewDecoder(r.Body).Decode(&ids)
+	checkError(err)
+
+	if len(ids) == 0 {
+		panic(fmt.Errorf("no bookmarks to check"))
+	}
+
+	// Check the bookmarks and save the results
+	bookmarks, err := h.DB.GetBookmarks(database.GetBookmarksOptions{IDs: ids})
+	checkError(err)
+
+	results := []model.LinkCheck{}
+	core.CheckLinks(h.Fetcher, bookmarks, 10, func(book model.Bookmark, result model.LinkCheck) {
+		results = append(results, result)
+	})
+
+	err = h.DB.SaveLinkChecks(results...)
+	checkError(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(&results)
+	checkError(err)
+}
+
+// apiExportArchive is handler for GET /api/archive/:id
+func (h *handler) apiExportArchive(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Get bookmark from database
+	id, err := strconv.Atoi(ps.ByName("id"))
+	checkError(err)
+
+	book, exist := h.DB.GetBookmark(id, "")
+	if !exist {
+		panic(fmt.Errorf("bookmark not found"))
+	}
+
+	// If it's not public, make sure session still valid
+	if book.Public != 1 {
+		err = h.validateSession(r)
+		checkError(err)
+	}
+
+	format, valid := core.GetArchiveFormat(r.URL.Query().Get("format"))
+	if !valid {
+		panic(fmt.Errorf("unknown archive format: %s", r.URL.Query().Get("format")))
+	}
+
+	h.writeArchiveExport(w, book, format)
+}
+
+// apiExportEPUB is handler for GET /api/export/epub
+func (h *handler) apiExportEPUB(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure session still valid
+	err := h.validateSession(r)
+	checkError(err)
+
+	// Get URL queries
+	keyword := r.URL.Query().Get("keyword")
+	title := r.URL.Query().Get("title")
+
+	ids := []int{}
+	for _, strID := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if strID = strings.TrimSpace(strID); strID == "" {
+			continue
+		}
+
+		id, err := strconv.Atoi(strID)
+		checkError(err)
+		ids = append(ids, id)
+	}
+
+	tags := strings.Split(r.URL.Query().Get("tags"), ",")
+	if len(tags) == 1 && tags[0] == "" {
+		tags = []string{}
+	}
+
+	excludedTags := strings.Split(r.URL.Query().Get("exclude"), ",")
+	if len(excludedTags) == 1 && excludedTags[0] == "" {
+		excludedTags = []string{}
+	}
+
+	if len(ids) == 0 && len(tags) == 0 && keyword == "" {
+		panic(newHTTPError(http.StatusBadRequest, "ids, tags or keyword is required"))
+	}
+
+	// Count the bookmarks first, so they are only loaded with their content if
+	// there are not too many of them
+	searchOptions := database.GetBookmarksOptions{
+		IDs:          ids,
+		Tags:         tags,
+		ExcludedTags: excludedTags,
+		Keyword:      keyword,
+	}
+	searchOptions.ParseKeywordFilters()
+
+	nBookmarks, err := h.DB.GetBookmarksCount(searchOptions)
+	checkError(err)
+
+	if nBookmarks == 0 {
+		panic(newHTTPError(http.StatusNotFound, "no matching bookmarks found"))
+	}
+
+	if nBookmarks > maxEPUBBookmarks {
+		panic(newHTTPError(http.StatusBadRequest, "found %d bookmarks, at most %d can be exported", nBookmarks, maxEPUBBookmarks))
+	}
+
+	searchOptions.WithContent = true
+	bookmarks, err := h.DB.GetBookmarks(searchOptions)
+	checkError(err)
+
+	if len(bookmarks) == 0 {
+		panic(newHTTPError(http.StatusNotFound, "no matching bookmarks found"))
+	}
+
+	if title == "" {
+		title = core.EPUBTitle(keyword, tags)
+	}
+
+	filename := "shiori-bookmarks.epub"
+	if len(bookmarks) == 1 {
+		filename = fmt.Sprintf("shiori-%d.epub", bookmarks[0].ID)
+	}
+
+	// The book is written directly to response, so once it's started the error
+	// can't be reported anymore. In that case abort the response instead.
+	w.Header().Set("Content-Type", "application/epub+zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	err = core.WriteEPUB(w, core.EPUBRequest{
+		Storage:   h.Storage,
+		Title:     title,
+		Bookmarks: bookmarks,
+	})
+	if err != nil {
+		log.Printf("error exporting EPUB: %s", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
 // apiGetAccounts is handler for GET /api/accounts
 func (h *handler) apiGetAccounts(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	// Make sure session still valid
@@ -697,14 +1218,7 @@ func (h *handler) apiUpdateAccount(w http.ResponseWriter, r *http.Request, ps ht
 	checkError(err)
 
 	// Delete user's sessions
-	if val, found := h.UserCache.Get(request.Username); found {
-		userSessions := val.([]string)
-		for _, session := range userSessions {
-			h.SessionCache.Delete(session)
-		}
-
-		h.UserCache.Delete(request.Username)
-	}
+	h.clearUserSessions(request.Username)
 
 	fmt.Fprint(w, 1)
 }
@@ -725,16 +1239,8 @@ func (h *handler) apiDeleteAccount(w http.ResponseWriter, r *http.Request, ps ht
 	checkError(err)
 
 	// Delete user's sessions
-	var userSessions []string
 	for _, username := range usernames {
-		if val, found := h.UserCache.Get(username); found {
-			userSessions = val.([]string)
-			for _, session := range userSessions {
-				h.SessionCache.Delete(session)
-			}
-
-			h.UserCache.Delete(username)
-		}
+		h.clearUserSessions(username)
 	}
 
 	fmt.Fprint(w, 1)
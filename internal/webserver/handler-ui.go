@@ -4,18 +4,18 @@ import (
 	"bytes"
 	"compress/gzip"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
 	"path"
 	fp "path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/model"
-	"github.com/go-shiori/warc"
+	"github.com/go-shiori/shiori/internal/storage"
+	"github.com/go-shiori/shiori/internal/warc"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -119,8 +119,7 @@ func (h *handler) serveBookmarkContent(w http.ResponseWriter, r *http.Request, p
 	}
 
 	// Check if it has archive.
-	archivePath := fp.Join(h.DataDir, "archive", strID)
-	if fileExists(archivePath) {
+	if storage.Exists(h.Storage, core.ArchiveName(id)) {
 		bookmark.HasArchive = true
 
 		// Open archive, look in cache first
@@ -130,8 +129,7 @@ func (h *handler) serveBookmarkContent(w http.ResponseWriter, r *http.Request, p
 		if found {
 			archive = cacheData.(*warc.Archive)
 		} else {
-			archivePath := fp.Join(h.DataDir, "archive", strID)
-			archive, err = warc.Open(archivePath)
+			archive, err = core.OpenArchive(h.Storage, id)
 			checkError(err)
 
 			h.ArchiveCache.Set(strID, archive, 0)
@@ -186,6 +184,12 @@ func (h *handler) serveBookmarkContent(w http.ResponseWriter, r *http.Request, p
 		checkError(err)
 	}
 
+	// Check if the original is broken, so the archive can be suggested
+	bookmarks := []model.Bookmark{bookmark}
+	err = h.applyLinkStatus(bookmarks)
+	checkError(err)
+	bookmark = bookmarks[0]
+
 	// Execute template
 	if developmentMode {
 		if err := h.prepareTemplates(); err != nil {
@@ -202,39 +206,38 @@ func (h *handler) serveBookmarkContent(w http.ResponseWriter, r *http.Request, p
 	checkError(err)
 }
 
-// serveThumbnailImage is handler for GET /bookmark/:id/thumb
+// serveThumbnailImage is handler for GET /bookmark/:id/thumb?size=grid|list|favicon
 func (h *handler) serveThumbnailImage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	// Get bookmark ID from URL
-	id := ps.ByName("id")
-
-	// Open image
-	imgPath := fp.Join(h.DataDir, "thumb", id)
-	img, err := os.Open(imgPath)
+	// Get bookmark ID and thumbnail size from URL
+	id, err := strconv.Atoi(ps.ByName("id"))
 	checkError(err)
-	defer img.Close()
 
-	// Get image type from its 512 first bytes
-	buffer := make([]byte, 512)
-	_, err = img.Read(buffer)
-	checkError(err)
-
-	mimeType := http.DetectContentType(buffer)
-	w.Header().Set("Content-Type", mimeType)
+	size, valid := core.GetThumbnailSize(r.URL.Query().Get("size"))
+	if !valid {
+		panic(fmt.Errorf("thumbnail size %q is not valid", r.URL.Query().Get("size")))
+	}
 
-	// Set cache value
-	info, err := img.Stat()
-	checkError(err)
+	// Thumbnail created by older version only has the default size,
+	// so create the requested size from it.
+	imgName := core.ThumbnailName(id, size)
+	if !storage.Exists(h.Storage, imgName) && size != core.ThumbnailGrid {
+		if err := core.ResizeThumbnail(h.Storage, id, size); err != nil {
+			imgName = core.ThumbnailName(id, core.ThumbnailGrid)
+		}
+	}
 
-	etag := fmt.Sprintf(`W/"%x-%x"`, info.ModTime().Unix(), info.Size())
-	w.Header().Set("ETag", etag)
-	w.Header().Set("Cache-Control", "max-age=86400")
+	h.serveStoredImage(w, imgName, "")
+}
 
-	// Serve image
-	if _, err := img.Seek(0, 0); err != nil {
-		log.Printf("error during image seek: %s", err)
+// serveFavicon is handler for GET /favicon/:domain
+func (h *handler) serveFavicon(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Make sure domain is valid, so it can't be used to read other files
+	domain := ps.ByName("domain")
+	if strings.HasPrefix(domain, ".") || domain != core.FaviconDomain("http://"+domain) {
+		panic(fmt.Errorf("domain %q is not valid", domain))
 	}
-	_, err = io.Copy(w, img)
-	checkError(err)
+
+	h.serveStoredImage(w, core.FaviconName(domain), "image/png")
 }
 
 // serveBookmarkArchive is handler for GET /bookmark/:id/archive/*filepath
@@ -271,8 +274,7 @@ func (h *handler) serveBookmarkArchive(w http.ResponseWriter, r *http.Request, p
 	if found {
 		archive = cacheData.(*warc.Archive)
 	} else {
-		archivePath := fp.Join(h.DataDir, "archive", strID)
-		archive, err = warc.Open(archivePath)
+		archive, err = core.OpenArchive(h.Storage, id)
 		checkError(err)
 
 		h.ArchiveCache.Set(strID, archive, 0)
@@ -328,3 +330,29 @@ func (h *handler) serveBookmarkArchive(w http.ResponseWriter, r *http.Request, p
 		log.Printf("error writting response: %s", err)
 	}
 }
+
+// serveBookmarkArchiveFile is handler for GET /bookmark/:id/archive.html
+func (h *handler) serveBookmarkArchiveFile(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	// Get bookmark from database
+	id, err := strconv.Atoi(ps.ByName("id"))
+	checkError(err)
+
+	bookmark, exist := h.DB.GetBookmark(id, "")
+	if !exist {
+		panic(fmt.Errorf("Bookmark not found"))
+	}
+
+	// If it's not public, make sure session still valid
+	if bookmark.Public != 1 {
+		err = h.validateSession(r)
+		if err != nil {
+			newPath := path.Join(h.RootPath, "/login")
+			redirectURL := createRedirectURL(newPath, r.URL.String())
+			redirectPage(w, r, redirectURL)
+			return
+		}
+	}
+
+	format, _ := core.GetArchiveFormat("singlefile")
+	h.writeArchiveExport(w, bookmark, format)
+}
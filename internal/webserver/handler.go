@@ -1,30 +1,60 @@
 package webserver
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"html/template"
+	"log"
 	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/database"
 	"github.com/go-shiori/shiori/internal/model"
-	"github.com/go-shiori/warc"
+	"github.com/go-shiori/shiori/internal/storage"
+	"github.com/go-shiori/shiori/internal/warc"
+	"github.com/gofrs/uuid"
 	cch "github.com/patrickmn/go-cache"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var developmentMode = false
 
+var (
+	errSessionNotExist = errors.New("session is not exist")
+	errSessionExpired  = errors.New("session has been expired")
+	errAccountLevel    = errors.New("account level is not sufficient")
+	errUnknownUsername = errors.New("username doesn't exist")
+	errWrongPassword   = errors.New("username and password don't match")
+)
+
 // Handler is handler for serving the web interface.
 type handler struct {
-	DB           database.DB
-	DataDir      string
-	RootPath     string
-	UserCache    *cch.Cache
-	SessionCache *cch.Cache
-	ArchiveCache *cch.Cache
-	Log          bool
+	DB            database.DB
+	Storage       storage.Storage
+	RootPath      string
+	UserCache     *cch.Cache
+	SessionCache  *cch.Cache
+	ArchiveCache  *cch.Cache
+	Log           bool
+	Canonicalizer *core.Canonicalizer
+	Fetcher       *core.Fetcher
+	Extractors    *core.ExtractorRegistry
+	StorageQuota  core.StorageQuota
+	Browser       *core.Browser
+	RelatedIndex  *core.RelatedIndex
+	Embedder      core.Embedder
 
 	templates   map[string]*template.Template
 	DisableAuth bool
+
+	// bookmarkMx serializes edits of single bookmark in API v1, so the
+	// entity tag can't change between checking and saving it.
+	bookmarkMx sync.Mutex
 }
 
 func (h *handler) prepareSessionCache() {
@@ -119,21 +149,235 @@ func (h *handler) validateSession(r *http.Request) error {
 	}
 	sessionID := h.getSessionID(r)
 	if sessionID == "" {
-		return fmt.Errorf("session is not exist")
+		return errSessionNotExist
 	}
 
 	// Make sure session is not expired yet
 	val, found := h.SessionCache.Get(sessionID)
 	if !found {
-		return fmt.Errorf("session has been expired")
+		return errSessionExpired
 	}
 
 	// If this is not get request, make sure it's owner
 	if r.Method != "" && r.Method != "GET" {
 		if account := val.(model.Account); !account.Owner {
-			return fmt.Errorf("account level is not sufficient")
+			return errAccountLevel
+		}
+	}
+
+	return nil
+}
+
+// authenticate returns the account with matching username and password.
+// If there are no owner yet, the default account is allowed as well.
+func (h *handler) authenticate(username, password string) (model.Account, error) {
+	// Check if user's database is empty or there are no owner.
+	// If yes, and user uses default account, let him in.
+	searchOptions := database.GetAccountsOptions{
+		Owner: true,
+	}
+
+	accounts, err := h.DB.GetAccounts(searchOptions)
+	if err != nil {
+		return model.Account{}, err
+	}
+
+	if h.DisableAuth || (len(accounts) == 0 && username == "shiori" && password == "gopher") {
+		return model.Account{Username: "shiori", Owner: true}, nil
+	}
+
+	// Get account data from database
+	account, exist := h.DB.GetAccount(username)
+	if !exist {
+		return model.Account{}, errUnknownUsername
+	}
+
+	// Compare password with database
+	err = bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(password))
+	if err != nil {
+		return model.Account{}, errWrongPassword
+	}
+
+	return account, nil
+}
+
+// createSession creates new session ID for the account.
+func (h *handler) createSession(account model.Account, expTime time.Duration) (string, error) {
+	// Create session ID
+	sessionID, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	// Save session ID to cache
+	strSessionID := sessionID.String()
+	h.SessionCache.Set(strSessionID, account, expTime)
+
+	// Save user's session IDs to cache as well
+	// useful for mass logout
+	sessionIDs := []string{strSessionID}
+	if val, found := h.UserCache.Get(account.Username); found {
+		sessionIDs = val.([]string)
+		sessionIDs = append(sessionIDs, strSessionID)
+	}
+	h.UserCache.Set(account.Username, sessionIDs, -1)
+
+	return strSessionID, nil
+}
+
+// clearUserSessions removes all sessions of the user.
+func (h *handler) clearUserSessions(username string) {
+	if val, found := h.UserCache.Get(username); found {
+		userSessions := val.([]string)
+		for _, session := range userSessions {
+			h.SessionCache.Delete(session)
+		}
+
+		h.UserCache.Delete(username)
+	}
+}
+
+// prepareBookmarks sets the image URL, favicon and archive status of the bookmarks,
+// then links them to their archive when the original is broken.
+func (h *handler) prepareBookmarks(bookmarks []model.Bookmark) error {
+	for i := range bookmarks {
+		strID := strconv.Itoa(bookmarks[i].ID)
+		if storage.Exists(h.Storage, core.ThumbnailName(bookmarks[i].ID, core.ThumbnailGrid)) {
+			bookmarks[i].ImageURL = path.Join(h.RootPath, "bookmark", strID, "thumb")
+		}
+
+		bookmarks[i].FaviconURL = core.FaviconURL(h.Storage, h.RootPath, bookmarks[i].URL)
+
+		if storage.Exists(h.Storage, core.ArchiveName(bookmarks[i].ID)) {
+			bookmarks[i].HasArchive = true
 		}
 	}
 
+	return h.applyLinkStatus(bookmarks)
+}
+
+// applyLinkStatus sets the link status of the bookmarks from their latest link check.
+// HasArchive must be already set, since broken bookmark links to its archive.
+func (h *handler) applyLinkStatus(bookmarks []model.Bookmark) error {
+	if len(bookmarks) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(bookmarks))
+	for i, book := range bookmarks {
+		ids[i] = book.ID
+	}
+
+	checks, err := h.DB.GetLinkChecks(database.GetLinkChecksOptions{
+		BookmarkIDs: ids,
+		LatestOnly:  true,
+	})
+	if err != nil {
+		return err
+	}
+
+	mapChecks := map[int]*model.LinkCheck{}
+	for i := range checks {
+		mapChecks[checks[i].BookmarkID] = &checks[i]
+	}
+
+	for i := range bookmarks {
+		archiveURL := path.Join(h.RootPath, "bookmark", strconv.Itoa(bookmarks[i].ID), "archive") + "/"
+		core.ApplyLinkStatus(&bookmarks[i], mapChecks[bookmarks[i].ID], archiveURL)
+	}
+
 	return nil
 }
+
+// serveStoredImage serves the image from storage with cache headers. If content
+// type is not specified, it's detected from the image.
+func (h *handler) serveStoredImage(w http.ResponseWriter, name string, contentType string) {
+	info, err := h.Storage.Stat(name)
+	checkError(err)
+
+	img, err := storage.ReadFile(h.Storage, name)
+	checkError(err)
+
+	if contentType == "" {
+		contentType = http.DetectContentType(img)
+	}
+
+	etag := fmt.Sprintf(`W/"%x-%x"`, info.ModTime.Unix(), info.Size)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "max-age=86400")
+
+	_, err = w.Write(img)
+	checkError(err)
+}
+
+// applyRules applies the tagging rules to the bookmark. Failing to load the rules
+// shouldn't prevent the bookmark from being saved, so it's only logged.
+func (h *handler) applyRules(book *model.Bookmark) {
+	rules, err := core.LoadRuleSet(h.DB)
+	if err != nil {
+		log.Printf("error loading tagging rules: %s", err)
+		return
+	}
+
+	rules.Apply(book)
+}
+
+// indexRelated adds the bookmarks, which must have their content, into the related
+// bookmarks index. Failing to index them only makes them missing from the related
+// bookmarks until the index is synced, so it's only logged.
+func (h *handler) indexRelated(bookmarks ...model.Bookmark) {
+	if h.RelatedIndex == nil {
+		return
+	}
+
+	if err := h.RelatedIndex.Index(bookmarks...); err != nil {
+		log.Printf("error updating related bookmarks index: %s", err)
+	}
+}
+
+// embedBookmarks creates embeddings of the bookmarks, which must have their content,
+// for hybrid search. Failures are only logged, since they're created again on sync.
+func (h *handler) embedBookmarks(bookmarks ...model.Bookmark) {
+	if h.Embedder == nil {
+		return
+	}
+
+	if err := core.EmbedBookmarks(h.DB, h.Embedder, bookmarks...); err != nil {
+		log.Printf("error creating embeddings: %s", err)
+	}
+}
+
+// prepareHybridSearch sets the search options to rank bookmarks by both keyword and meaning.
+func (h *handler) prepareHybridSearch(opts *database.GetBookmarksOptions) error {
+	if h.Embedder == nil {
+		return fmt.Errorf("hybrid search is not available")
+	}
+
+	return core.PrepareHybridSearch(h.DB, h.Embedder, opts)
+}
+
+// saveArchiveSizes records the current size of offline archive of the bookmarks in database.
+func (h *handler) saveArchiveSizes(bookmarks ...model.Bookmark) error {
+	sizes := make(map[int]int64, len(bookmarks))
+	for _, book := range bookmarks {
+		sizes[book.ID] = core.ArchiveSize(h.Storage, book.ID)
+	}
+
+	return h.DB.UpdateArchiveSizes(sizes)
+}
+
+// writeArchiveExport exports offline archive of the bookmark as attachment. The archive is
+// exported to buffer first, so error can still be reported before the response is written.
+func (h *handler) writeArchiveExport(w http.ResponseWriter, book model.Bookmark, format core.ArchiveFormat) {
+	buffer := bytes.NewBuffer(nil)
+	err := core.ExportArchive(h.Storage, book, format, buffer)
+	checkError(err)
+
+	filename := core.ArchiveFilename(book, format)
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Content-Length", strconv.Itoa(buffer.Len()))
+	_, err = buffer.WriteTo(w)
+	checkError(err)
+}
@@ -0,0 +1,216 @@
+package webserver
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPISchemas collects the schemas of types used by REST API v1, keyed by
+// their name in the OpenAPI document.
+type openAPISchemas map[string]interface{}
+
+// schemaOf returns the schema of the type. Named structs are stored as component
+// and referenced, so the `apiV1` prefix of their name is removed.
+func (schemas openAPISchemas) schemaOf(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemas.schemaOf(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemas.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemas.schemaOf(t.Elem())}
+	case reflect.Struct:
+		break
+	default:
+		return map[string]interface{}{}
+	}
+
+	name := strings.TrimPrefix(t.Name(), "apiV1")
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	if name != "" {
+		if _, exist := schemas[name]; exist {
+			return ref
+		}
+
+		// Reserve the name first, in case the struct refers to itself
+		schemas[name] = map[string]interface{}{}
+	}
+
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+			continue
+		}
+
+		fieldName := jsonFieldName(field)
+		property := schemas.schemaOf(field.Type)
+		if doc := field.Tag.Get("doc"); doc != "" && property["$ref"] == nil {
+			property["description"] = doc
+		}
+
+		properties[fieldName] = property
+		if field.Tag.Get("required") == "true" {
+			required = append(required, fieldName)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	if name == "" {
+		return schema
+	}
+
+	schemas[name] = schema
+	return ref
+}
+
+// openAPIDocument generates the OpenAPI 3 document that describes the routes.
+func (rt *apiV1Router) openAPIDocument(serverURL string) map[string]interface{} {
+	schemas := openAPISchemas{}
+	jsonContent := func(v interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": schemas.schemaOf(reflect.TypeOf(v)),
+			},
+		}
+	}
+
+	paths := map[string]map[string]interface{}{}
+	for _, route := range rt.routes {
+		// Describe parameters in path and query
+		parameters := []interface{}{}
+		for _, segment := range strings.Split(route.Path, "/") {
+			if !strings.HasPrefix(segment, "{") {
+				continue
+			}
+
+			name := strings.Trim(segment, "{}")
+			schemaType := "string"
+			if name == "id" {
+				schemaType = "integer"
+			}
+
+			parameters = append(parameters, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": schemaType},
+			})
+		}
+
+		if route.Query != nil {
+			queryType := reflect.TypeOf(route.Query)
+			for i := 0; i < queryType.NumField(); i++ {
+				field := queryType.Field(i)
+				name := field.Tag.Get("query")
+				if name == "" {
+					continue
+				}
+
+				parameter := map[string]interface{}{
+					"name":   name,
+					"in":     "query",
+					"schema": schemas.schemaOf(field.Type),
+				}
+
+				if doc := field.Tag.Get("doc"); doc != "" {
+					parameter["description"] = doc
+				}
+
+				parameters = append(parameters, parameter)
+			}
+		}
+
+		// Describe the operation itself
+		operation := map[string]interface{}{
+			"summary":    route.Summary,
+			"tags":       []string{route.Tag},
+			"parameters": parameters,
+		}
+
+		if route.Body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(route.Body),
+			}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		success := map[string]interface{}{"description": http.StatusText(status)}
+		if route.Response != nil {
+			success["content"] = jsonContent(route.Response)
+		}
+
+		operation["responses"] = map[string]interface{}{
+			strconv.Itoa(status): success,
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content":     jsonContent(apiV1ErrorResponse{}),
+			},
+		}
+
+		if !route.Public {
+			operation["security"] = []interface{}{
+				map[string][]string{"sessionId": {}},
+			}
+		}
+
+		if paths[route.Path] == nil {
+			paths[route.Path] = map[string]interface{}{}
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Shiori API",
+			"version": "1.0.0",
+		},
+		"servers": []interface{}{
+			map[string]string{"url": serverURL},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"sessionId": map[string]string{
+					"type": "apiKey",
+					"in":   "header",
+					"name": "X-Session-Id",
+				},
+			},
+		},
+	}
+}
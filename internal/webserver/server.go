@@ -6,7 +6,9 @@ import (
 	"path"
 	"time"
 
+	"github.com/go-shiori/shiori/internal/core"
 	"github.com/go-shiori/shiori/internal/database"
+	"github.com/go-shiori/shiori/internal/storage"
 	"github.com/julienschmidt/httprouter"
 	cch "github.com/patrickmn/go-cache"
 	"github.com/sirupsen/logrus"
@@ -15,12 +17,19 @@ import (
 // Config is parameter that used for starting web server
 type Config struct {
 	DB            database.DB
-	DataDir       string
+	Storage       storage.Storage
 	ServerAddress string
 	ServerPort    int
 	RootPath      string
 	Log           bool
 	DisableAuth   bool
+	Canonicalizer *core.Canonicalizer
+	Fetcher       *core.Fetcher
+	Extractors    *core.ExtractorRegistry
+	StorageQuota  core.StorageQuota
+	Browser       *core.Browser
+	RelatedIndex  *core.RelatedIndex
+	Embedder      core.Embedder
 }
 
 // ErrorResponse defines a single HTTP error response.
@@ -109,14 +118,21 @@ func Logger(r *http.Request, statusCode int, size int) {
 func ServeApp(cfg Config) error {
 	// Create handler
 	hdl := handler{
-		DB:           cfg.DB,
-		DataDir:      cfg.DataDir,
-		UserCache:    cch.New(time.Hour, 10*time.Minute),
-		SessionCache: cch.New(time.Hour, 10*time.Minute),
-		ArchiveCache: cch.New(time.Minute, 5*time.Minute),
-		RootPath:     cfg.RootPath,
-		Log:          cfg.Log,
-		DisableAuth:  cfg.DisableAuth,
+		DB:            cfg.DB,
+		Storage:       cfg.Storage,
+		UserCache:     cch.New(time.Hour, 10*time.Minute),
+		SessionCache:  cch.New(time.Hour, 10*time.Minute),
+		ArchiveCache:  cch.New(time.Minute, 5*time.Minute),
+		RootPath:      cfg.RootPath,
+		Log:           cfg.Log,
+		DisableAuth:   cfg.DisableAuth,
+		Canonicalizer: cfg.Canonicalizer,
+		Fetcher:       cfg.Fetcher,
+		Extractors:    cfg.Extractors,
+		StorageQuota:  cfg.StorageQuota,
+		Browser:       cfg.Browser,
+		RelatedIndex:  cfg.RelatedIndex,
+		Embedder:      cfg.Embedder,
 	}
 
 	hdl.prepareSessionCache()
@@ -186,11 +202,15 @@ func ServeApp(cfg Config) error {
 		router.GET(jp("/login"), withLogging(hdl.serveLoginPage))
 	}
 	router.GET(jp("/bookmark/:id/thumb"), withLogging(hdl.serveThumbnailImage))
+	router.GET(jp("/favicon/:domain"), withLogging(hdl.serveFavicon))
 	router.GET(jp("/bookmark/:id/content"), withLogging(hdl.serveBookmarkContent))
 	router.GET(jp("/bookmark/:id/archive/*filepath"), withLogging(hdl.serveBookmarkArchive))
+	router.GET(jp("/bookmark/:id/archive.html"), withLogging(hdl.serveBookmarkArchiveFile))
 	router.POST(jp("/api/login"), withLogging(hdl.apiLogin))
 	router.POST(jp("/api/logout"), withLogging(hdl.apiLogout))
 	router.GET(jp("/api/bookmarks"), withLogging(hdl.apiGetBookmarks))
+	router.GET(jp("/api/bookmarks/:id/related"), withLogging(hdl.apiGetRelatedBookmarks))
+	router.GET(jp("/api/bookmarks/:id/highlights"), withLogging(hdl.apiGetHighlights))
 	router.GET(jp("/api/tags"), withLogging(hdl.apiGetTags))
 	router.PUT(jp("/api/tag"), withLogging(hdl.apiRenameTag))
 	router.POST(jp("/api/bookmarks"), withLogging(hdl.apiInsertBookmark))
@@ -198,16 +218,40 @@ func ServeApp(cfg Config) error {
 	router.PUT(jp("/api/bookmarks"), withLogging(hdl.apiUpdateBookmark))
 	router.PUT(jp("/api/cache"), withLogging(hdl.apiUpdateCache))
 	router.PUT(jp("/api/bookmarks/tags"), withLogging(hdl.apiUpdateBookmarkTags))
+	router.POST(jp("/api/bookmarks/suggest-tags"), withLogging(hdl.apiSuggestTags))
+	router.POST(jp("/api/bookmarks/bulk"), withLogging(hdl.apiBulkBookmarks))
 	router.POST(jp("/api/bookmarks/ext"), withLogging(hdl.apiInsertViaExtension))
 	router.DELETE(jp("/api/bookmarks/ext"), withLogging(hdl.apiDeleteViaExtension))
+	router.GET(jp("/api/duplicates"), withLogging(hdl.apiGetDuplicates))
+	router.POST(jp("/api/duplicates"), withLogging(hdl.apiMergeDuplicates))
+	router.GET(jp("/api/links/broken"), withLogging(hdl.apiGetBrokenLinks))
+	router.GET(jp("/api/links/history"), withLogging(hdl.apiGetLinkHistory))
+	router.POST(jp("/api/links/check"), withLogging(hdl.apiCheckLinks))
+	router.GET(jp("/api/archive/:id"), withLogging(hdl.apiExportArchive))
+	router.GET(jp("/api/export/epub"), withLogging(hdl.apiExportEPUB))
 
 	router.GET(jp("/api/accounts"), withLogging(hdl.apiGetAccounts))
 	router.PUT(jp("/api/accounts"), withLogging(hdl.apiUpdateAccount))
 	router.POST(jp("/api/accounts"), withLogging(hdl.apiInsertAccount))
 	router.DELETE(jp("/api/accounts"), withLogging(hdl.apiDeleteAccount))
 
+	// REST API v1 has its own router, which returns errors as JSON
+	apiV1 := &apiV1Router{handler: &hdl, routes: hdl.apiV1Routes()}
+	serveAPIV1 := withLogging(func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		apiV1.serve(w, r, ps.ByName("path"))
+	})
+
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		router.Handle(method, jp("/api/v1/*path"), serveAPIV1)
+	}
+
 	// Route for panic, keep logging anyhow
 	router.PanicHandler = func(w http.ResponseWriter, r *http.Request, arg interface{}) {
+		// Response that already started is aborted by HTTP server
+		if arg == http.ErrAbortHandler {
+			panic(arg)
+		}
+
 		d := &responseData{
 			status: 0,
 			size:   0,
@@ -216,7 +260,13 @@ func ServeApp(cfg Config) error {
 			ResponseWriter: w,
 			responseData:   d,
 		}
-		http.Error(&lrw, fmt.Sprint(arg), 500)
+
+		status := http.StatusInternalServerError
+		if err, ok := arg.(*httpError); ok {
+			status = err.status
+		}
+
+		http.Error(&lrw, fmt.Sprint(arg), status)
 		if hdl.Log {
 			Logger(r, d.status, d.size)
 		}
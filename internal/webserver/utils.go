@@ -96,11 +96,6 @@ func assetExists(filePath string) bool {
 	return err == nil || !os.IsNotExist(err)
 }
 
-func fileExists(filePath string) bool {
-	info, err := os.Stat(filePath)
-	return !os.IsNotExist(err) && !info.IsDir()
-}
-
 func createTemplate(filename string, funcMap template.FuncMap) (*template.Template, error) {
 	// Open file
 	src, err := assets.Open(filename)
@@ -150,6 +145,21 @@ func getArchivalName(src string) string {
 	return archivalURL
 }
 
+// httpError is error with HTTP status code. When handler panics with it,
+// the status is used for the response instead of 500.
+type httpError struct {
+	status int
+	err    error
+}
+
+func newHTTPError(status int, format string, args ...interface{}) *httpError {
+	return &httpError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
 func checkError(err error) {
 	if err == nil {
 		return